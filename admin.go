@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/token"
+)
+
+// runAdmin runs the admin subcommand, whose subcommands are for looking after the schedds rather than running jobs.  args are
+// the arguments after "admin"
+func runAdmin(cfg *config.Config, args []string) error {
+	adminCommands := map[string]func(*config.Config, []string) error{
+		"explain": runAdminExplain,
+		"import":  runAdminImport,
+	}
+	names := slices.Sorted(maps.Keys(adminCommands))
+
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "fakeJobsub admin must be run with one of the subcommands %v\n", names)
+		return errUsage
+	}
+	runCommand, ok := adminCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("invalid admin subcommand %s.  Choose from %v", args[0], names)
+	}
+	return runCommand(cfg, args[1:])
+}
+
+// runAdminExplain runs admin explain, which prints SQLite's query plan for each schedd for a list invocation instead of listing
+// jobs.  args are the list flags, optionally preceded by "list"
+func runAdminExplain(cfg *config.Config, args []string) error {
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	}
+	explainCmd := flag.NewFlagSet("admin explain", flag.ContinueOnError)
+	var opts listOptions
+	opts.addFlags(explainCmd)
+	if err := explainCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	opts.explain = true
+	return runList(cfg, opts)
+}
+
+// runAdminImport runs admin import, which loads the jobs in a condor_q -json or condor_history -json dump into a schedd, so that
+// what happened on a real schedd can be reproduced.  args are the flags, followed by the dump file, or - for stdin
+func runAdminImport(cfg *config.Config, args []string) error {
+	importCmd := flag.NewFlagSet("admin import", flag.ContinueOnError)
+	importSchedd := importCmd.String("schedd", "", "schedd to import the jobs into.  It must not have any of their clusters already")
+	importCmd.Usage = func() {
+		fmt.Fprintln(importCmd.Output(), "Usage: fakeJobsub admin import --schedd schedd dump.json")
+		importCmd.PrintDefaults()
+	}
+
+	if err := importCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	if *importSchedd == "" {
+		return errors.New("--schedd must be specified")
+	}
+	if names := cfg.ScheddNames(); !slices.Contains(names, *importSchedd) {
+		return fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v", *importSchedd, names)
+	}
+	if importCmd.NArg() != 1 {
+		return errors.New("admin import takes exactly one dump file, or - to read the dump from stdin")
+	}
+
+	var r io.Reader = os.Stdin
+	if fn := importCmd.Arg(0); fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Attributes that aren't in the dump get the defaults of submit
+	defaults := func(name string) (db.Job, error) {
+		group, err := cfg.Group(name)
+		if err != nil {
+			return db.Job{}, err
+		}
+		return newJob(cfg, group, group.DefaultRole(), jobSpec{runtime: time.Minute}, "")
+	}
+	imp, err := condor.ParseJobAds(r, defaults, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not import %s: %w", importCmd.Arg(0), err)
+	}
+
+	// Importing jobs creates them, so the bearer token must authorize that for each group (and role) imported, like submit
+	authorized := make(map[string]bool)
+	for _, job := range imp.Jobs {
+		if authorized[job.Group+"/"+job.Role] {
+			continue
+		}
+		if _, err := verifyToken(cfg, job.Group, job.Role == config.RoleProduction, token.ScopeCreate); err != nil {
+			return fmt.Errorf("not authorized to import jobs of group %s: %w", job.Group, err)
+		}
+		authorized[job.Group+"/"+job.Role] = true
+	}
+	user, err := whoami(cfg)
+	if err != nil {
+		return err
+	}
+	schedd, err := condor.GetSchedd(*importSchedd)
+	if err != nil {
+		return fmt.Errorf("could not get schedd: %w", err)
+	}
+	if err := schedd.Import(imp, user); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d jobs in %d clusters into schedd %s\n", len(imp.Procs), len(imp.Jobs), schedd.Name)
+	if len(imp.Unmapped) > 0 {
+		fmt.Println("These attributes could not be mapped, and were left out:")
+		for _, name := range slices.Sorted(maps.Keys(imp.Unmapped)) {
+			fmt.Printf("  %s:  %d of %d jobs\n", name, imp.Unmapped[name], len(imp.Procs))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/token"
+)
+
+// runAnalyze runs the analyze subcommand, which explains why a job is not running, like jobsub_q --better-analyze.
+// args are the arguments after "analyze"
+func runAnalyze(cfg *config.Config, args []string) error {
+	c := newJobCommand("analyze", "analyze")
+	_, id, _, err := c.setup(cfg, args, token.ScopeRead)
+	if err != nil {
+		return err
+	}
+	if id.ProcID == db.AllProcs {
+		return errors.New("analyze applies to single jobs.  Give --jobid as cluster.proc@schedd")
+	}
+
+	n, _, err := newNegotiator(cfg)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(n.Schedds, func(s *condor.Schedd) bool { return s.Name == id.Schedd })
+	a, err := n.Analyze(n.Schedds[idx], id, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("-- Schedd: %s : %d.%d\n", id.Schedd, id.ClusterID, id.ProcID)
+	switch a.Proc.Status {
+	case db.Idle:
+	case db.Running:
+		fmt.Printf("Job is running in slot %s.\n", a.Proc.Slot)
+		return nil
+	default:
+		fmt.Printf("Job is %s, so it will not be matched.\n", a.Proc.Status)
+		return nil
+	}
+
+	fmt.Printf("The Requirements expression for job %d.%03d reduces to these conditions:\n\n", id.ClusterID, id.ProcID)
+	fmt.Printf("%-5s  %8s\n", "", "Slots")
+	fmt.Printf("%-5s  %8s  %s\n", "Step", "Matched", "Condition")
+	fmt.Printf("%-5s  %8s  %s\n", "-----", "--------", "---------")
+	for idx, c := range a.Clauses {
+		fmt.Printf("%-5s  %8d  %s\n", fmt.Sprintf("[%d]", idx), c.Slots, c.Clause)
+	}
+	fmt.Printf("\nMost restrictive condition: %s\n\n", a.MostRestrictive)
+
+	fmt.Printf("%d.%03d:  Run analysis summary.  Of %d slots,\n", id.ClusterID, id.ProcID, a.Slots)
+	fmt.Printf("  %6d are rejected by your job's requirements\n", a.Slots-a.Matching)
+	fmt.Printf("  %6d match and are busy running other jobs\n", a.Matching-a.Available)
+	fmt.Printf("  %6d are available to run your job\n\n", a.Available)
+
+	quota := "no quota"
+	if a.Quota > 0 {
+		quota = fmt.Sprintf("quota of %d", a.Quota)
+	}
+	fmt.Printf("Group %s has %d running job(s) (%s)\n", a.Proc.Group, a.GroupRunning, quota)
+	if a.Priority != nil {
+		fmt.Printf("Group %s has effective priority %.2f\n", a.Proc.Group, a.Priority.EffectivePriority)
+	}
+	fmt.Printf("%d idle job(s) are ahead of this one, %d of them from groups with better priority\n", a.Ahead, a.AheadBetterPriority)
+	if a.Proc.Reason != "" {
+		fmt.Printf("Last negotiation cycle:  %s\n", a.Proc.Reason)
+	} else {
+		fmt.Println("Last negotiation cycle:  job has not yet been considered by the matchmaker")
+	}
+	fmt.Printf("\nBlocked by %s\n", a.Blocker())
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+// auditActions are the operations recorded in the audit log
+var auditActions = []string{"submit", "remove", "hold", "release", "edit", "import"}
+
+// auditHeader is the header of the table audit prints
+var auditHeader = []string{"time", "schedd", "action", "user", "group", "clusterid", "procs", "detail", "result", "command"}
+
+// runAudit runs the audit subcommand, which prints the audit log of the operations that changed jobs on each schedd, oldest
+// first.  args are the arguments after "audit"
+func runAudit(cfg *config.Config, args []string) error {
+	auditCmd := flag.NewFlagSet("audit", flag.ContinueOnError)
+	auditSince := auditCmd.Duration("since", 0, "Only print operations from this long ago, like 1h, until now.  If 0, print every operation")
+	auditUser := auditCmd.String("user", "", "Only print operations by this user")
+	auditMe := auditCmd.Bool("me", false, "Only print operations by me (the token subject, or the current OS user if there is no token)")
+	auditGroup := auditCmd.String("group", "", "Only print operations on jobs of this group")
+	auditAction := auditCmd.String("action", "", fmt.Sprintf("Only print operations of this kind.  One of %v", auditActions))
+	auditSchedd := auditCmd.String("schedd", "", "schedd whose audit log to print.  If blank, the logs of all configured schedds are merged")
+
+	if err := auditCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	if *auditSince < 0 {
+		return errors.New("--since must not be negative")
+	}
+	if *auditAction != "" && !slices.Contains(auditActions, *auditAction) {
+		return fmt.Errorf("invalid --action %s.  Choose from %v", *auditAction, auditActions)
+	}
+
+	filter := db.AuditFilter{User: *auditUser, Group: *auditGroup, Action: *auditAction}
+	if *auditSince > 0 {
+		filter.Since = time.Now().Add(-*auditSince)
+	}
+	if *auditMe {
+		if *auditUser != "" {
+			return errors.New("only one of --user and --me may be specified")
+		}
+		var err error
+		if filter.User, err = whoami(cfg); err != nil {
+			return err
+		}
+	}
+
+	names := cfg.ScheddNames()
+	if *auditSchedd != "" {
+		if !slices.Contains(names, *auditSchedd) {
+			return fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v or do not set the --schedd flag", *auditSchedd, names)
+		}
+		names = []string{*auditSchedd}
+	}
+	schedds, err := getSchedds(names)
+	if err != nil {
+		return err
+	}
+	lines, err := auditLines(schedds, filter)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// auditLines concurrently reads the audit records that match filter from schedds, and returns them as the lines of a table,
+// merged in the order they happened
+func auditLines(schedds []*condor.Schedd, filter db.AuditFilter) ([]string, error) {
+	type scheddRecord struct {
+		schedd string
+		db.AuditRecord
+	}
+	results := make([][]db.AuditRecord, len(schedds))
+	errs := make([]error, len(schedds))
+	var wg sync.WaitGroup
+	for i, s := range schedds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if results[i], errs[i] = s.Audit(filter); errs[i] != nil {
+				errs[i] = fmt.Errorf("schedd %s: %w", s.Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	merged := make([]scheddRecord, 0)
+	for i, records := range results {
+		for _, r := range records {
+			merged = append(merged, scheddRecord{schedds[i].Name, r})
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b scheddRecord) int { return a.Time.Compare(b.Time) })
+
+	lines := []string{strings.Join(auditHeader, "\t")}
+	for _, r := range merged {
+		lines = append(lines, strings.Join([]string{
+			r.Time.Format(time.DateTime), r.schedd, r.Action, r.User, r.Group, strconv.Itoa(r.ClusterID), db.FormatProcRanges(r.Procs),
+			r.Detail, r.Result, r.Command,
+		}, "\t"))
+	}
+	return lines, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/db"
+)
+
+func TestAuditLines(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	schedds, err := getSchedds([]string{"schedd1", "schedd2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range schedds {
+		s.Latency = condor.Latency{}
+	}
+
+	// Operations on both schedds are merged in the order they happened.  They are recorded to the microsecond, so make sure
+	// they don't happen at the same time
+	submit := func(s *condor.Schedd, owner string) {
+		if _, err := s.SubmitJob(db.Job{Group: "nova", Num: 2, Owner: owner}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	submit(schedds[1], "alice")
+	submit(schedds[0], "bob")
+	if _, err := schedds[1].Remove(condor.Requester{User: "alice"}, condor.JobID{ClusterID: 1, ProcID: db.AllProcs, Schedd: "schedd2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := auditLines(schedds, db.AuditFilter{})
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	expected := []string{
+		"time\tschedd\taction\tuser\tgroup\tclusterid\tprocs\tdetail\tresult\tcommand",
+		"schedd2\tsubmit\talice\tnova\t1\t0-1\t\tok\t",
+		"schedd1\tsubmit\tbob\tnova\t1\t0-1\t\tok\t",
+		"schedd2\tremove\talice\tnova\t1\t0-1\t\tok\t",
+	}
+	if len(lines) != len(expected) || lines[0] != expected[0] {
+		t.Fatalf("Expected %d lines with header %q.  Got %q instead", len(expected), expected[0], lines)
+	}
+	for i, line := range lines[1:] {
+		if _, rest, _ := strings.Cut(line, "\t"); !strings.HasPrefix(rest, expected[i+1]) {
+			t.Errorf("Expected line %d to start with %q after the time.  Got %q instead", i+1, expected[i+1], line)
+		}
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		lines, err := auditLines(schedds, db.AuditFilter{User: "bob"})
+		if err != nil || len(lines) != 2 || !strings.Contains(lines[1], "schedd1\tsubmit\tbob") {
+			t.Errorf("Expected only bob's submission.  Got %q, %v instead", lines, err)
+		}
+	})
+}
+
+func TestRunAudit(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"invalid action", []string{"--action", "delete"}, "invalid --action delete"},
+		{"negative since", []string{"--since", "-1h"}, "--since must not be negative"},
+		{"invalid schedd", []string{"--schedd", "schedd9"}, "invalid schedd: schedd9"},
+		{"user and me", []string{"--user", "alice", "--me"}, "only one of --user and --me"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := run(append([]string{"fakeJobsub", "audit"}, test.args...))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+}
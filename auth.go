@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"strings"
+
+	"fakeJobsub/config"
+	"fakeJobsub/token"
+)
+
+// runToken runs the token subcommand.  args are the arguments after "token"
+func runToken(cfg *config.Config, args []string) error {
+	issueCmd := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	issueGroup := issueCmd.String("group", "", "Group/Experiment the token is for")
+	issueScope := issueCmd.String("scope", strings.Join(token.DefaultScopes, ","), "Comma- or space-separated scopes to grant")
+	issueRole := issueCmd.String("role", "", "Role to authorize.  Production tokens also authorize the Analysis role")
+	issueSubject := issueCmd.String("subject", "", "Subject (user) of the token.  If blank, the current OS user is used")
+	issueLifetime := issueCmd.Duration("lifetime", token.DefaultLifetime, "How long the token is valid for")
+	issueOut := issueCmd.String("out", "", "File to write the token to.  If blank, $BEARER_TOKEN_FILE or the default WLCG token location is used")
+
+	if len(args) < 1 || args[0] != "issue" {
+		fmt.Fprintf(os.Stderr, "fakeJobsub token must be run with the \"issue\" subcommand\n\n")
+		issueCmd.Usage()
+		return errUsage
+	}
+
+	if err := issueCmd.Parse(args[1:]); err != nil {
+		return errParseFlags
+	}
+
+	if err := checkSubmitForGroup(*issueGroup); err != nil {
+		return errors.New("--group must be specified")
+	}
+	group, err := cfg.Group(*issueGroup)
+	if err != nil {
+		return err
+	}
+
+	production := false
+	if *issueRole != "" {
+		role, err := group.CanonicalRole(*issueRole)
+		if err != nil {
+			return err
+		}
+		production = role == config.RoleProduction
+	}
+
+	subject := *issueSubject
+	if subject == "" {
+		if subject, err = currentUsername(); err != nil {
+			return err
+		}
+	}
+
+	scopes := strings.FieldsFunc(*issueScope, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(scopes) == 0 {
+		return errors.New("--scope must not be empty")
+	}
+
+	key, err := token.LoadOrCreateKey(keyFile(cfg))
+	if err != nil {
+		return err
+	}
+	claims := token.NewClaims(issuer(cfg), subject, group.Name, production, scopes, *issueLifetime)
+	raw, err := token.Issue(key, claims)
+	if err != nil {
+		return fmt.Errorf("could not issue token: %w", err)
+	}
+
+	out := *issueOut
+	if out == "" {
+		if out = os.Getenv("BEARER_TOKEN_FILE"); out == "" {
+			out = token.DefaultPath()
+		}
+	}
+	if err := token.Save(out, raw); err != nil {
+		return fmt.Errorf("could not save token: %w", err)
+	}
+	fmt.Printf("Token for %s in group %s with scopes %v written to %s.  Expires at %s\n", subject, group.Name, scopes, out, claims.ExpiresAt())
+	return nil
+}
+
+// verifyToken finds the bearer token and checks that it authorizes scope for group (and the Production role, if production is true)
+func verifyToken(cfg *config.Config, group string, production bool, scope string) (*token.Claims, error) {
+	raw, err := token.Find()
+	if err != nil {
+		return nil, fmt.Errorf("%w.  Run \"fakeJobsub token issue\" to get one", err)
+	}
+
+	key, err := token.LoadKey(keyFile(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	req := token.Requirements{
+		Issuer:     issuer(cfg),
+		Group:      group,
+		Production: production,
+		Scope:      scope,
+	}
+	return token.Verify(raw, key.Public().(ed25519.PublicKey), req)
+}
+
+// whoami returns the subject of the bearer token, or the current OS user if there is no token.  A token that is there but
+// invalid (or expired) is an error, rather than a reason to act as the OS user
+func whoami(cfg *config.Config) (string, error) {
+	if _, err := token.Find(); errors.Is(err, fs.ErrNotExist) {
+		return currentUsername()
+	}
+	claims, err := verifyToken(cfg, "", false, "")
+	if err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return currentUsername()
+	}
+	return claims.Subject, nil
+}
+
+func issuer(cfg *config.Config) string {
+	if cfg.Auth.Issuer != "" {
+		return cfg.Auth.Issuer
+	}
+	return token.DefaultIssuer
+}
+
+func keyFile(cfg *config.Config) string {
+	if cfg.Auth.KeyFile != "" {
+		return cfg.Auth.KeyFile
+	}
+	return token.DefaultKeyFile()
+}
+
+func currentUsername() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("could not determine current user: %w", err)
+	}
+	return u.Username, nil
+}
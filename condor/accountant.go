@@ -0,0 +1,152 @@
+package condor
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+// minRealPriority is the lowest (best) real priority a group can have, as in HTCondor
+const minRealPriority = 0.5
+
+// Accountant records how many core-hours each group has used, decaying that usage over a half-life, and turns usage into
+// priorities for fair-share scheduling, like HTCondor's accountant.  Lower priorities are better
+type Accountant struct {
+	db       db.AccountingDB
+	HalfLife time.Duration
+	Factor   func(group string) float64 // The configured priority factor of each group
+}
+
+// Priority is a group's standing with the accountant
+type Priority struct {
+	Group             string
+	Usage             float64 // Decayed usage, in core-hours
+	Factor            float64
+	RealPriority      float64
+	EffectivePriority float64 // RealPriority * Factor
+}
+
+// GetAccountant opens the accountant's database in the system temporary directory, configured according to cfg
+func GetAccountant(cfg *config.Config) (*Accountant, error) {
+	d, err := db.CreateOrOpenAccountingDB(filepath.Join(os.TempDir(), "fakeJobsubAccountant.db"))
+	if err != nil {
+		return nil, err
+	}
+	return &Accountant{
+		db:       d,
+		HalfLife: time.Duration(cfg.Accounting.Or(config.DefaultAccounting).HalfLife),
+		Factor:   cfg.PriorityFactor,
+	}, nil
+}
+
+// LastUpdate returns when usage was last charged.  If it never has been, the zero time is returned
+func (a *Accountant) LastUpdate() (time.Time, error) {
+	return a.db.LastUpdate()
+}
+
+// decay returns how much usage recorded at last has decayed by now
+func (a *Accountant) decay(last, now time.Time) float64 {
+	if last.IsZero() || !now.After(last) || a.HalfLife <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(now.Sub(last))/float64(a.HalfLife))
+}
+
+// Charge decays all groups' usage to now, and charges each running job's group for the core-hours the job has used
+// since the last charge
+func (a *Accountant) Charge(now time.Time, running []db.Proc) error {
+	last, err := a.db.LastUpdate()
+	if err != nil {
+		return fmt.Errorf("could not get last accounting update: %w", err)
+	}
+	stored, err := a.db.Usage()
+	if err != nil {
+		return fmt.Errorf("could not get usage: %w", err)
+	}
+
+	decay := a.decay(last, now)
+	usage := make(map[string]float64, len(stored))
+	for _, u := range stored {
+		usage[u.Group] = u.Usage * decay
+	}
+	for _, p := range running {
+		from := p.Start
+		if from.Before(last) {
+			from = last
+		}
+		to := p.End
+		if to.After(now) {
+			to = now
+		}
+		if to.After(from) {
+			usage[p.Group] += float64(p.CPUs) * to.Sub(from).Hours()
+		}
+	}
+
+	if err := a.db.UpdateUsage(usage, now); err != nil {
+		return fmt.Errorf("could not update usage: %w", err)
+	}
+	return nil
+}
+
+// Priorities returns the priorities, as of now, of groups and of any other group the accountant knows about, best first
+func (a *Accountant) Priorities(now time.Time, groups []string) ([]Priority, error) {
+	last, err := a.db.LastUpdate()
+	if err != nil {
+		return nil, fmt.Errorf("could not get last accounting update: %w", err)
+	}
+	stored, err := a.db.Usage()
+	if err != nil {
+		return nil, fmt.Errorf("could not get usage: %w", err)
+	}
+
+	decay := a.decay(last, now)
+	priorities := make([]Priority, 0, len(groups)+len(stored))
+	for _, u := range stored {
+		factor := u.Factor
+		if factor == 0 {
+			factor = a.Factor(u.Group)
+		}
+		priorities = append(priorities, newPriority(u.Group, u.Usage*decay, factor))
+	}
+	for _, g := range groups {
+		if !slices.ContainsFunc(priorities, func(p Priority) bool { return p.Group == g }) {
+			priorities = append(priorities, newPriority(g, 0, a.Factor(g)))
+		}
+	}
+
+	slices.SortFunc(priorities, func(x, y Priority) int {
+		return cmp.Or(cmp.Compare(x.EffectivePriority, y.EffectivePriority), cmp.Compare(x.Group, y.Group))
+	})
+	return priorities, nil
+}
+
+func newPriority(group string, usage, factor float64) Priority {
+	realPriority := max(usage, minRealPriority)
+	return Priority{Group: group, Usage: usage, Factor: factor, RealPriority: realPriority, EffectivePriority: realPriority * factor}
+}
+
+// SetFactor sets the priority factor of group, which must be positive, overriding the configured factor until ResetFactor
+func (a *Accountant) SetFactor(group string, factor float64) error {
+	if factor <= 0 {
+		return fmt.Errorf("invalid priority factor %g.  It must be positive", factor)
+	}
+	return a.db.SetFactor(group, factor)
+}
+
+// ResetFactor restores the configured priority factor of group
+func (a *Accountant) ResetFactor(group string) error {
+	return a.db.SetFactor(group, 0)
+}
+
+// ResetUsage forgets all of group's usage
+func (a *Accountant) ResetUsage(group string) error {
+	return a.db.ResetUsage(group)
+}
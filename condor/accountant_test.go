@@ -0,0 +1,94 @@
+package condor
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+func TestAccountant(t *testing.T) {
+	d, err := db.CreateOrOpenAccountingDB(filepath.Join(t.TempDir(), "accountant.db"))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	factors := map[string]float64{"nova": 1000, "dune": 2000}
+	a := &Accountant{db: d, HalfLife: time.Hour, Factor: func(g string) float64 { return factors[g] }}
+	start := time.Unix(1700000000, 0)
+
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	priority := func(now time.Time, group string) Priority {
+		prios, err := a.Priorities(now, []string{"nova", "dune"})
+		if err != nil {
+			t.Fatalf("Could not get priorities: %s", err)
+		}
+		for _, p := range prios {
+			if p.Group == group {
+				return p
+			}
+		}
+		t.Fatalf("No priority for %s in %+v", group, prios)
+		return Priority{}
+	}
+
+	t.Run("Test 1: Groups without usage have the minimum real priority", func(t *testing.T) {
+		if p := priority(start, "dune"); p.RealPriority != minRealPriority || p.EffectivePriority != 1000 {
+			t.Errorf("Got wrong priority for dune: %+v", p)
+		}
+		prios, _ := a.Priorities(start, []string{"nova", "dune"})
+		if prios[0].Group != "nova" {
+			t.Errorf("nova should have the best priority.  Got %+v", prios)
+		}
+	})
+
+	t.Run("Test 2: Running jobs are charged core-hours", func(t *testing.T) {
+		running := []db.Proc{
+			{Job: db.Job{Group: "nova", CPUs: 4}, Start: start, End: start.Add(2 * time.Hour)},
+			{Job: db.Job{Group: "dune", CPUs: 1}, Start: start, End: start.Add(30 * time.Minute)},
+		}
+		if err := a.Charge(start, nil); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if err := a.Charge(start.Add(time.Hour), running); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if p := priority(start.Add(time.Hour), "nova"); !near(p.Usage, 4) || !near(p.EffectivePriority, 4000) {
+			t.Errorf("Got wrong priority for nova: %+v", p)
+		}
+		if p := priority(start.Add(time.Hour), "dune"); !near(p.Usage, 0.5) {
+			t.Errorf("Got wrong priority for dune: %+v", p)
+		}
+	})
+
+	t.Run("Test 3: Usage decays over the half-life", func(t *testing.T) {
+		if p := priority(start.Add(2*time.Hour), "nova"); !near(p.Usage, 2) {
+			t.Errorf("nova's usage should have halved.  Got %+v", p)
+		}
+	})
+
+	t.Run("Test 4: Priority factors can be overridden and usage reset", func(t *testing.T) {
+		if err := a.SetFactor("nova", 10); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if p := priority(start.Add(time.Hour), "nova"); !near(p.EffectivePriority, 40) {
+			t.Errorf("Got wrong priority for nova: %+v", p)
+		}
+		if err := a.SetFactor("nova", 0); err == nil {
+			t.Error("Should have gotten an error setting a factor of zero")
+		}
+		if err := a.ResetFactor("nova"); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if p := priority(start.Add(time.Hour), "nova"); near(p.EffectivePriority, 40) {
+			t.Errorf("nova's configured priority factor should have been restored.  Got %+v", p)
+		}
+		if err := a.ResetUsage("nova"); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if p := priority(start.Add(time.Hour), "nova"); p.Usage != 0 || p.RealPriority != minRealPriority {
+			t.Errorf("Got wrong priority for nova: %+v", p)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package condor
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+// ClauseMatch is how many slots in the pool satisfy one clause of a job's requirements
+type ClauseMatch struct {
+	Clause string
+	Slots  int
+}
+
+// Analysis explains why a job is or isn't matching slots in the pool, like condor_q -better-analyze
+type Analysis struct {
+	Proc            db.Proc
+	Slots           int // Slots in the pool
+	Clauses         []ClauseMatch
+	MostRestrictive string // The clause satisfied by the fewest slots
+	Matching        int    // Slots that satisfy all of the job's requirements
+	Available       int    // Matching slots that aren't busy
+
+	Backoff time.Duration // How long the job, which is being retried, waits before it may be matched
+
+	GroupRunning int       // Jobs the job's group has running
+	Quota        int       // The most jobs the group may run.  Zero means no limit
+	Priority     *Priority // The group's priority, or nil if the negotiator has no accountant
+
+	Ahead               int // Idle jobs that will be considered before this one in the next cycle
+	AheadBetterPriority int // How many of those belong to groups with better priority
+}
+
+// Analyze analyzes job id on schedd s as of now
+func (n *Negotiator) Analyze(s *Schedd, id JobID, now time.Time) (*Analysis, error) {
+	p, err := s.db.GetProc(id.ClusterID, id.ProcID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("could not find job %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find job %s: %w", id, err)
+	}
+
+	busy, running, err := n.usage()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Analysis{Proc: p, Slots: len(n.Pool.Slots), GroupRunning: running[p.Group], Quota: n.Quotas[p.Group]}
+	if p.Status == db.Idle && p.RetryAfter.After(now) {
+		a.Backoff = p.RetryAfter.Sub(now)
+	}
+	fewest := -1
+	for _, r := range requirements {
+		c := ClauseMatch{Clause: r.name}
+		for _, slot := range n.Pool.Slots {
+			if r.matches(slot, p.Job) {
+				c.Slots++
+			}
+		}
+		if fewest == -1 || c.Slots < fewest {
+			fewest = c.Slots
+			a.MostRestrictive = c.Clause
+		}
+		a.Clauses = append(a.Clauses, c)
+	}
+	for _, slot := range n.Pool.Slots {
+		if slot.Matches(p.Job) {
+			a.Matching++
+			if !busy[slot.Name] {
+				a.Available++
+			}
+		}
+	}
+
+	idle := make([]idleJob, 0)
+	if p.Status == db.Idle {
+		if idle, err = n.idleJobs(now); err != nil {
+			return nil, err
+		}
+	}
+
+	priorities := make(map[string]float64)
+	if n.Accountant != nil {
+		groups := []string{p.Group}
+		for _, j := range idle {
+			if !slices.Contains(groups, j.proc.Group) {
+				groups = append(groups, j.proc.Group)
+			}
+		}
+		prios, err := n.Accountant.Priorities(now, groups)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range prios {
+			priorities[prios[idx].Group] = prios[idx].EffectivePriority
+			if prios[idx].Group == p.Group {
+				a.Priority = &prios[idx]
+			}
+		}
+	}
+
+	for _, j := range idle {
+		if j.schedd.Name == s.Name && j.proc.ClusterID == p.ClusterID && j.proc.ProcID == p.ProcID {
+			break
+		}
+		a.Ahead++
+		if a.Priority != nil && priorities[j.proc.Group] < a.Priority.EffectivePriority {
+			a.AheadBetterPriority++
+		}
+	}
+	return a, nil
+}
+
+// Blocker summarizes what is keeping the idle job from running
+func (a *Analysis) Blocker() string {
+	switch {
+	case a.Backoff > 0:
+		return "backoff:  " + backoffReason(a.Proc)
+	case a.Matching == 0:
+		return fmt.Sprintf("requirements:  no slot in the pool satisfies all of them, and the most restrictive is %s", a.MostRestrictive)
+	case a.Quota > 0 && a.GroupRunning >= a.Quota:
+		return "quota:  " + quotaReason(a.Proc.Group, a.Quota)
+	case a.Available == 0:
+		return fmt.Sprintf("busy slots:  all %d matching slots are busy", a.Matching)
+	case a.Ahead >= a.Available && a.AheadBetterPriority > 0:
+		return fmt.Sprintf("priority:  %d job(s) from groups with better priority are ahead of it for %d available slot(s)", a.AheadBetterPriority, a.Available)
+	case a.Ahead >= a.Available:
+		return fmt.Sprintf("queue position:  %d older job(s) are ahead of it for %d available slot(s)", a.Ahead, a.Available)
+	default:
+		return "nothing:  it should start in the next negotiation cycle"
+	}
+}
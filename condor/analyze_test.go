@@ -0,0 +1,98 @@
+package condor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+func TestAnalyze(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	ad, err := db.CreateOrOpenAccountingDB(filepath.Join(t.TempDir(), "accountant.db"))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	a := &Accountant{db: ad, HalfLife: 24 * time.Hour, Factor: func(string) float64 { return 1000 }}
+
+	// Three small slots at SiteA, one big slot at SiteB
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{
+		{Name: "SiteA", Slots: 3, Resources: config.Resources{MemoryMB: 2000, CPUs: 1}},
+		{Name: "SiteB", Slots: 1, Resources: config.Resources{MemoryMB: 8000, CPUs: 4}},
+	}})
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}, Accountant: a, Quotas: map[string]int{"minerva": 1}}
+
+	start := time.Unix(1700000000, 0)
+	jobs := []db.Job{
+		{ClusterID: 1, Group: "nova", Num: 1, MemoryMB: 4000, CPUs: 2, Runtime: time.Hour, QDate: start},
+		{ClusterID: 2, Group: "nova", Num: 1, MemoryMB: 4000, CPUs: 8, Runtime: time.Hour, QDate: start},
+		{ClusterID: 3, Group: "minerva", Num: 2, MemoryMB: 1000, CPUs: 1, Runtime: time.Hour, QDate: start},
+		{ClusterID: 4, Group: "dune", Num: 1, MemoryMB: 4000, CPUs: 2, Runtime: time.Hour, QDate: start.Add(time.Second)},
+	}
+	for _, j := range jobs {
+		if err := s.db.InsertJobIntoDB(j); err != nil {
+			t.Fatalf("Could not create row in test db: %s", err.Error())
+		}
+	}
+	if _, err := n.Cycle(start); err != nil {
+		t.Fatalf("Could not run negotiation cycle: %s", err)
+	}
+
+	analyze := func(clusterID, procID int) *Analysis {
+		a, err := n.Analyze(s, JobID{ClusterID: clusterID, ProcID: procID, Schedd: s.Name}, start)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		return a
+	}
+
+	t.Run("Test 1: Running job", func(t *testing.T) {
+		if a := analyze(1, 0); a.Proc.Status != db.Running || a.Proc.Slot != "slot1@SiteB" {
+			t.Errorf("Job 1.0 should be running in slot1@SiteB.  Got %+v", a.Proc)
+		}
+	})
+
+	t.Run("Test 2: Requirements no slot satisfies", func(t *testing.T) {
+		a := analyze(2, 0)
+		if a.Matching != 0 || a.MostRestrictive != "TARGET.Cpus >= RequestCpus" || a.Clauses[0].Slots != 0 || a.Clauses[1].Slots != 1 {
+			t.Errorf("Got wrong analysis: %+v", a)
+		}
+		if b := a.Blocker(); !strings.HasPrefix(b, "requirements") {
+			t.Errorf("Should have been blocked by requirements.  Got %s", b)
+		}
+	})
+
+	t.Run("Test 3: Group at quota", func(t *testing.T) {
+		a := analyze(3, 1)
+		if a.GroupRunning != 1 || a.Quota != 1 || a.Available != 2 {
+			t.Errorf("Got wrong analysis: %+v", a)
+		}
+		if b := a.Blocker(); !strings.HasPrefix(b, "quota") {
+			t.Errorf("Should have been blocked by quota.  Got %s", b)
+		}
+	})
+
+	t.Run("Test 4: Matching slots busy", func(t *testing.T) {
+		a := analyze(4, 0)
+		if a.Matching != 1 || a.Available != 0 || a.Priority == nil || a.Priority.Group != "dune" {
+			t.Errorf("Got wrong analysis: %+v", a)
+		}
+		if b := a.Blocker(); !strings.HasPrefix(b, "busy slots") {
+			t.Errorf("Should have been blocked by busy slots.  Got %s", b)
+		}
+	})
+
+	t.Run("Test 5: No such job", func(t *testing.T) {
+		if _, err := n.Analyze(s, JobID{ClusterID: 42, ProcID: 0, Schedd: s.Name}, start); err == nil {
+			t.Error("Should have gotten an error for a job that doesn't exist")
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package condor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+// commandLine is the command line of this process, which is recorded in the audit log as the command that asked for each
+// operation.  Arguments with spaces or quotes in them are quoted, so that it can be pasted into a shell
+var commandLine = quoteArgs(os.Args)
+
+func quoteArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$") {
+			arg = strconv.Quote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// audit appends r, with the result err, to the audit log.  As with user logs, the operation has already happened by the time
+// it is audited, so errors are only reported
+func (s *Schedd) audit(r db.AuditRecord, err error) {
+	r.Command = commandLine
+	r.Result = db.AuditOK
+	if err != nil {
+		r.Result = err.Error()
+	}
+	if err := s.db.InsertAuditRecord(r); err != nil {
+		s.log().Error("could not write audit record", "action", r.Action, "cluster", r.ClusterID, "user", r.User, "error", err)
+	}
+}
+
+// Audit returns the records in the audit log that match filter, oldest first
+func (s *Schedd) Audit(filter db.AuditFilter) ([]db.AuditRecord, error) {
+	records, err := s.db.AuditRecords(filter)
+	if err != nil {
+		return nil, fmt.Errorf("could not read audit log: %w", err)
+	}
+
+	// Mock some processing time
+	time.Sleep(s.Latency.Query)
+
+	return records, nil
+}
+
+// procRange returns the proc IDs of a cluster of n jobs
+func procRange(n int) []int {
+	procs := make([]int, 0, n)
+	for procID := range n {
+		procs = append(procs, procID)
+	}
+	return procs
+}
@@ -0,0 +1,88 @@
+package condor
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+func TestAudit(t *testing.T) {
+	name := "audited"
+	s := &Schedd{Name: name}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+
+	start := time.Now()
+	job, err := s.SubmitJob(db.Job{Group: "nova", Num: 3, Owner: "alice"})
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	alice, bob := Requester{User: "alice"}, Requester{User: "bob"}
+	cluster := JobID{ClusterID: job.ClusterID, ProcID: db.AllProcs, Schedd: name}
+	if _, err := s.Hold(alice, JobID{ClusterID: job.ClusterID, ProcID: 1, Schedd: name}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Remove(bob, cluster); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("Should have gotten ErrPermissionDenied.  Got %v instead", err)
+	}
+	if err := s.Edit(alice, cluster, "memory", 4000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Release(alice, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := s.Audit(db.AuditFilter{Since: start.Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	type entry struct {
+		action, user, procs, detail string
+		ok                          bool
+	}
+	expected := []entry{
+		{"submit", "alice", "0-2", "", true},
+		{"hold", "alice", "1", "", true},
+		{"remove", "bob", "", "", false},
+		{"edit", "alice", "", "memory=4000", true},
+		{"release", "alice", "1", "", true},
+	}
+	got := make([]entry, 0, len(records))
+	for _, r := range records {
+		got = append(got, entry{r.Action, r.User, db.FormatProcRanges(r.Procs), r.Detail, r.Result == db.AuditOK})
+		if r.Group != "nova" || r.ClusterID != job.ClusterID || r.Command != commandLine {
+			t.Errorf("Got wrong audit record %+v", r)
+		}
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected audit records %v.  Got %v instead", expected, got)
+	}
+	if len(records) == len(expected) && !strings.Contains(records[2].Result, "permission denied") {
+		t.Errorf("Expected the failed remove to record why it failed.  Got %q instead", records[2].Result)
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		records, err := s.Audit(db.AuditFilter{User: "bob"})
+		if err != nil || len(records) != 1 || records[0].Action != "remove" {
+			t.Errorf("Expected bob's remove.  Got %v, %v instead", records, err)
+		}
+		if records, err := s.Audit(db.AuditFilter{Since: time.Now().Add(time.Hour)}); err != nil || len(records) != 0 {
+			t.Errorf("Expected no records in the future.  Got %v, %v instead", records, err)
+		}
+	})
+}
+
+func TestQuoteArgs(t *testing.T) {
+	got := quoteArgs([]string{"fakeJobsub", "edit", "--value", "two words", ""})
+	expected := `fakeJobsub edit --value "two words" ""`
+	if got != expected {
+		t.Errorf("Expected %s.  Got %s instead", expected, got)
+	}
+}
@@ -2,89 +2,290 @@ package condor
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"fakeJobsub/db"
+	"fakeJobsub/notify"
+	"fakeJobsub/userlog"
 )
 
 // DefaultSchedd is the default schedd whose backend is in the default db location
 var DefaultSchedd *Schedd
 
+// Latency is how long a schedd pretends to be busy for each operation, to mimic a real schedd under load
+type Latency struct {
+	Submit time.Duration
+	Query  time.Duration // Listing jobs, procs, history, DAG nodes, or totals
+}
+
+// DefaultLatency is the latency of the schedds returned by GetSchedd
+var DefaultLatency = Latency{Submit: 3 * time.Second, Query: 2 * time.Second}
+
 // Schedd is a condor Schedd
 type Schedd struct {
-	Name string
-	db   scheddDB
+	Name    string
+	Latency Latency // Zero for no simulated latency
+	db      scheddDB
+	spool   string       // Directory holding job output.  If blank, no output is kept
+	logger  *slog.Logger // Nil means slog's default logger
+
+	// Notifier is sent notifications about jobs that were submitted with Notify set, as they change state.  If it is nil,
+	// no notifications are sent
+	Notifier *notify.Notifier
+
+	submitMu sync.Mutex // Held while a cluster ID is chosen and the cluster inserted, so concurrent submissions get different IDs
 }
 
 func init() {
-	DefaultSchedd = &Schedd{Name: "DefaultSchedd"}
+	DefaultSchedd = &Schedd{Name: "DefaultSchedd", Latency: DefaultLatency}
 	d, err := db.CreateOrOpenDB(DefaultSchedd.getFilename(os.TempDir()))
 	if err != nil {
 		panic(err)
 	}
 	DefaultSchedd.db = d
+	DefaultSchedd.spool = DefaultSchedd.getSpoolDir(os.TempDir())
 }
 
 // GetSchedd opens the underlying db.FakeJobsubDB for further operations
 func GetSchedd(name string) (*Schedd, error) {
+	return OpenSchedd(name, slog.Default())
+}
+
+// OpenSchedd is like GetSchedd, but the schedd and its database log to logger, with the schedd's name
+func OpenSchedd(name string, logger *slog.Logger) (*Schedd, error) {
 	if name == "" {
 		return DefaultSchedd, nil
 	}
 
-	s := &Schedd{Name: name}
-	s.Name = name
+	s := &Schedd{Name: name, Latency: DefaultLatency, logger: logger.With("schedd", name)}
+	s.spool = s.getSpoolDir(os.TempDir())
 
 	d, err := db.CreateOrOpenDB(s.getFilename(os.TempDir()))
 	if err != nil {
 		return nil, err
 	}
 
-	s.db = d
+	s.db = d.WithLogger(s.logger)
 	return s, nil
 }
 
-// Submit submits a certain number of jobs based on the config
-func (s *Schedd) Submit(group string, numJobs int) error {
+// log returns the logger s logs to, which has the schedd's name
+func (s *Schedd) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.Default().With("schedd", s.Name)
+	}
+	return s.logger
+}
+
+// SubmitJob submits a cluster of job.Num jobs based on the config, and returns the job as it was queued.  job.ClusterID and
+// job.QDate are ignored; the next free clusterID and the current time are used.  If job.Log is set, a submit event for each
+// job is written to it.  As in HTCondor, $(Cluster) in job.Log is replaced with the clusterID
+func (s *Schedd) SubmitJob(job db.Job) (db.Job, error) {
+	defer s.observeSince(submitDuration, time.Now())
+	job, err := s.submit(job, time.Now())
+	if err != nil {
+		return job, fmt.Errorf("could not submit job: %w", err)
+	}
+
+	// Fake some CPU-intensive activity
+	s.log().Debug("submitting", "cluster", job.ClusterID, "latency", s.Latency.Submit)
+	time.Sleep(s.Latency.Submit)
+	return job, nil
+}
+
+// submit does the work of SubmitJob at time now, and returns the job as it was queued
+func (s *Schedd) submit(job db.Job, now time.Time) (_ db.Job, err error) {
+	defer func() { s.countError("submit", err) }()
+	defer func() {
+		var procs []int
+		if err == nil {
+			procs = procRange(job.Num)
+		}
+		s.audit(db.AuditRecord{Time: now, Action: "submit", User: job.Owner, Group: job.Group, ClusterID: job.ClusterID, Procs: procs}, err)
+	}()
+	s.submitMu.Lock()
+	defer s.submitMu.Unlock()
 	cid, err := s.db.GetNextClusterID()
 	if err != nil {
-		return fmt.Errorf("could not submit job: %w", err)
+		return job, err
 	}
+	job.ClusterID = cid
+	job.QDate = now
+	job.Log = strings.NewReplacer("$(Cluster)", strconv.Itoa(cid), "$(ClusterId)", strconv.Itoa(cid)).Replace(job.Log)
 
-	if err = s.db.InsertJobIntoDB(cid, group, numJobs); err != nil {
-		return fmt.Errorf("could not submit job: %w", err)
+	// Make sure we can write the user log before we accept the job
+	if job.Log != "" {
+		if err := userlog.Append(job.Log); err != nil {
+			return job, err
+		}
 	}
 
-	// Fake some CPU-intensive activity
-	fmt.Printf("Submitting....\n\n")
-	time.Sleep(3 * time.Second)
+	if err = s.db.InsertJobIntoDB(job); err != nil {
+		return job, err
+	}
+	submittedClusters.Inc(s.Name, job.Group)
+	submittedJobs.Add(float64(job.Num), s.Name, job.Group)
+
+	events := make([]userlog.Event, 0, job.Num)
+	for procID := range job.Num {
+		events = append(events, userlog.SubmitEvent{EventHeader: header(job, procID, job.QDate), Host: sinful(s.Name)})
+	}
+	s.logEvents(job, events...)
+	return job, nil
+}
 
-	fmt.Printf("Submitted %d jobs to cluster %d for group %s on schedd %s\n", numJobs, cid, group, s.Name)
+// List returns a list of the jobs in the queue, in the order filter gives.  The jobs are read as the listing's rows are iterated.  If keys are given, it will only return the values for those keys.  It only allows filtering based on clusterID and owner for simplicity in this demo
+func (s *Schedd) List(filter db.Filter, keys ...string) (*db.Listing, error) {
+	start := time.Now()
+	listing, err := s.db.RetrieveJobsFromDB(filter, keys...)
+	if err != nil {
+		s.countError("list", err)
+		s.observeSince(listDuration, start)
+		return nil, fmt.Errorf("could not list jobs: %w", err)
+	}
 
-	return nil
+	// Mock some processing time
+	time.Sleep(s.Latency.Query)
+
+	return s.finishRows(listing, "could not list jobs", start), nil
 }
 
-// List prints a list of the jobs in the queue.  If keys are given, it will only return the values for those keys.  It only allows filtering based on clusterID for simplicity in this demo
-func (s *Schedd) List(clusterID int, keys ...string) ([]string, error) {
-	rows, err := s.db.RetrieveJobsFromDB(clusterID, keys...)
+// ListProcs is like List, but returns the individual jobs (procs) in each cluster
+func (s *Schedd) ListProcs(filter db.Filter, keys ...string) (*db.Listing, error) {
+	start := time.Now()
+	listing, err := s.db.RetrieveProcsFromDB(filter, keys...)
 	if err != nil {
+		s.countError("list", err)
+		s.observeSince(listDuration, start)
 		return nil, fmt.Errorf("could not list jobs: %w", err)
 	}
 
 	// Mock some processing time
-	time.Sleep(2 * time.Second)
+	time.Sleep(s.Latency.Query)
+
+	return s.finishRows(listing, "could not list jobs", start), nil
+}
+
+// Totals counts the clusters and jobs in the queue that match filter, grouped by the column groupBy (one of
+// db.TotalsGroupByColumns, or blank for a single group), and overall
+func (s *Schedd) Totals(filter db.Filter, groupBy string) ([]db.Totals, db.Totals, error) {
+	defer s.observeSince(listDuration, time.Now())
+	totals, total, err := s.db.RetrieveTotalsFromDB(filter, groupBy)
+	if err != nil {
+		s.countError("list", err)
+		return nil, total, fmt.Errorf("could not get totals: %w", err)
+	}
+
+	// Mock some processing time
+	time.Sleep(s.Latency.Query)
+
+	return totals, total, nil
+}
+
+// History is like ListProcs, but returns only the jobs that have left the queue, like condor_history
+func (s *Schedd) History(filter db.Filter, keys ...string) (*db.Listing, error) {
+	start := time.Now()
+	listing, err := s.db.RetrieveHistoryFromDB(filter, keys...)
+	if err != nil {
+		s.countError("list", err)
+		s.observeSince(listDuration, start)
+		return nil, fmt.Errorf("could not get job history: %w", err)
+	}
+
+	// Mock some processing time
+	time.Sleep(s.Latency.Query)
+
+	return s.finishRows(listing, "could not get job history", start), nil
+}
+
+// ListDAGNodes is like List, but returns the nodes of each DAG.  filter.ClusterID selects a DAG by its cluster ID
+func (s *Schedd) ListDAGNodes(filter db.Filter, keys ...string) (*db.Listing, error) {
+	start := time.Now()
+	listing, err := s.db.RetrieveDAGNodesFromDB(filter, keys...)
+	if err != nil {
+		s.countError("list", err)
+		s.observeSince(listDuration, start)
+		return nil, fmt.Errorf("could not list DAG nodes: %w", err)
+	}
+
+	// Mock some processing time
+	time.Sleep(s.Latency.Query)
+
+	return s.finishRows(listing, "could not list DAG nodes", start), nil
+}
 
-	return rows, nil
+// QueryPlan returns the database's plan for reading the rows of listing, which must have come from this schedd
+func (s *Schedd) QueryPlan(listing *db.Listing) ([]string, error) {
+	plan, err := s.db.QueryPlan(listing)
+	if err != nil {
+		return nil, fmt.Errorf("could not get query plan: %w", err)
+	}
+	return plan, nil
+}
+
+// finishRows wraps any error from iterating listing's rows in the message msg, like the errors returned when the listing
+// could not be made at all, and counts it.  Since the rows are read as they are iterated, the listing's latency is observed when
+// each iteration of the rows ends:  the time from start to making the listing, plus the time the iteration took
+func (s *Schedd) finishRows(listing *db.Listing, msg string, start time.Time) *db.Listing {
+	rows := listing.Rows
+	setup := time.Since(start)
+	listing.Rows = func(yield func(db.Row, error) bool) {
+		defer s.observeSince(listDuration, time.Now().Add(-setup))
+		for row, err := range rows {
+			if err != nil {
+				s.countError("list", err)
+				yield(row, fmt.Errorf("%s: %w", msg, err))
+				return
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+	return listing
 }
 
 func (s *Schedd) getFilename(tempdir string) string {
 	return filepath.Join(tempdir, fmt.Sprintf("fakeJobsubSchedd_%s.db", s.Name))
 }
 
+func (s *Schedd) getSpoolDir(tempdir string) string {
+	return filepath.Join(tempdir, fmt.Sprintf("fakeJobsubSpool_%s", s.Name))
+}
+
 // scheddDB contains the methods needed to interact with a jobs database for job submission and jobs listing purposes
 type scheddDB interface {
-	InsertJobIntoDB(int, string, int) error
-	RetrieveJobsFromDB(int, ...string) ([]string, error)
+	InsertJobIntoDB(db.Job) error
+	ImportJobs([]db.Job, []db.Proc) error
+	RetrieveJobsFromDB(db.Filter, ...string) (*db.Listing, error)
 	GetNextClusterID() (int, error)
+	GetJob(int) (db.Job, error)
+	GetProc(int, int) (db.Proc, error)
+	ClusterProcs(int) ([]db.Proc, error)
+	ProcExists(int, int) (bool, error)
+	SetProcStatus(int, int, []db.JobStatus, db.JobStatus) ([]int, error)
+	UpdateJob(int, string, any) error
+	RetrieveProcsFromDB(db.Filter, ...string) (*db.Listing, error)
+	Procs(db.JobStatus) ([]db.Proc, error)
+	StartProc(int, int, string, time.Time, time.Time) (bool, error)
+	CompleteProcs(time.Time) ([]db.Proc, error)
+	SetProcReason(int, int, string) error
+	RequeueProc(int, int, time.Time, string) (bool, error)
+	RetrieveHistoryFromDB(db.Filter, ...string) (*db.Listing, error)
+	RetrieveTotalsFromDB(db.Filter, string) ([]db.Totals, db.Totals, error)
+	InsertDAG(db.DAG, []db.DAGNode) error
+	DAGs(string) ([]db.DAG, error)
+	DAGNodes(int) ([]db.DAGNode, error)
+	InsertAuditRecord(db.AuditRecord) error
+	AuditRecords(db.AuditFilter) ([]db.AuditRecord, error)
+	UpdateDAGNode(db.DAGNode) error
+	SetDAGStatus(int, string, string) error
+	RetrieveDAGNodesFromDB(db.Filter, ...string) (*db.Listing, error)
+	QueryPlan(*db.Listing) ([]string, error)
 }
@@ -1,8 +1,11 @@
 package condor
 
 import (
+	"bytes"
+	"encoding/json"
 	"fakeJobsub/db"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
@@ -61,6 +64,39 @@ func TestGetSchedd(t *testing.T) {
 	})
 }
 
+func TestOpenScheddLogs(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	var b bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s, err := OpenSchedd("logged", logger)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	s.Latency = Latency{}
+	if _, err := s.SubmitJob(db.Job{Group: "nova", Num: 2}); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	// Both the schedd and its database log, with the schedd's name
+	messages := make(map[string]int)
+	for _, line := range bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("Could not parse log record %s: %s", line, err)
+		}
+		if record["schedd"] != "logged" {
+			t.Errorf("Expected every record to have schedd=logged.  Got %s instead", line)
+		}
+		if record["msg"] == "ran query" && record["elapsed"] == nil {
+			t.Errorf("Expected query record to have how long the query took.  Got %s instead", line)
+		}
+		messages[record["msg"].(string)]++
+	}
+	if messages["submitting"] != 1 || messages["ran query"] == 0 {
+		t.Errorf("Expected records of the submission and its queries.  Got %v instead", messages)
+	}
+}
+
 func TestSubmit(t *testing.T) {
 	name := "name"
 	group := "testgroup"
@@ -75,16 +111,20 @@ func TestSubmit(t *testing.T) {
 	}
 	s.db = d
 
-	if err := s.Submit(group, numJobs); err != nil {
+	if _, err := s.SubmitJob(db.Job{Group: group, Num: numJobs, Role: "Production", Owner: "testuser"}); err != nil {
 		t.Errorf("Failed to submit test jobs: %s", err.Error())
 	}
 
-	expectedHeader := "clusterid\tgroup\tnum"
-	expectedRow := fmt.Sprintf("1\t%s\t%d", group, numJobs)
+	expectedHeader := "clusterid\tgroup\tnum\trole\towner\tstatus"
+	expectedRow := fmt.Sprintf("1\t%s\t%d\tProduction\ttestuser\tIdle", group, numJobs)
 	expectedResult := []string{expectedHeader, expectedRow}
-	rows, err := s.db.RetrieveJobsFromDB(1)
+	listing, err := s.db.RetrieveJobsFromDB(db.Filter{ClusterID: 1})
 	if err != nil {
-		t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	rows, err := listing.Collect()
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
 	}
 
 	if !slices.Equal(rows, expectedResult) {
@@ -104,10 +144,10 @@ func TestList(t *testing.T) {
 	}
 	s.db = d
 
-	if err := s.db.InsertJobIntoDB(42, "testgroup", 17); err != nil {
+	if err := s.db.InsertJobIntoDB(db.Job{ClusterID: 42, Group: "testgroup", Num: 17, Role: "Analysis"}); err != nil {
 		t.Errorf("Could not create row in test db: %s", err.Error())
 	}
-	if err := s.db.InsertJobIntoDB(43, "testgroup", 17); err != nil {
+	if err := s.db.InsertJobIntoDB(db.Job{ClusterID: 43, Group: "testgroup", Num: 17, Role: "Analysis"}); err != nil {
 		t.Errorf("Could not create row in test db: %s", err.Error())
 	}
 
@@ -116,9 +156,13 @@ func TestList(t *testing.T) {
 		expectedHeader := ("clusterid\tgroup")
 		expectedRow := ("42\ttestgroup")
 		expectedResult := []string{expectedHeader, expectedRow}
-		result, err := s.List(42, "clusterid", "group")
+		listing, err := s.List(db.Filter{ClusterID: 42}, "clusterid", "group")
 		if err != nil {
-			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		result, err := listing.Collect()
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
 		}
 		if !slices.Equal(expectedResult, result) {
 			t.Errorf("Got wrong result.  Expected %v, got %v", expectedResult, result)
@@ -127,7 +171,11 @@ func TestList(t *testing.T) {
 
 	// Try to get an invalid row
 	t.Run("Invalid result", func(t *testing.T) {
-		_, err = s.List(22)
+		listing, err := s.List(db.Filter{ClusterID: 22})
+		if err != nil {
+			t.Fatalf("Should have gotten nil error until the rows are read.  Got %v instead", err)
+		}
+		_, err = listing.Collect()
 		if err == nil || !strings.Contains(err.Error(), "could not list jobs") {
 			t.Errorf("Got unexpected error. Expected error that indicated that jobs could not be listed; got %v", err)
 		}
@@ -150,7 +198,7 @@ func TestSubmitAndList(t *testing.T) {
 	// Submit a job
 	group := "testgroup"
 	numJobs := 42
-	if err := s.Submit(group, numJobs); err != nil {
+	if _, err := s.SubmitJob(db.Job{Group: group, Num: numJobs, Role: "Analysis"}); err != nil {
 		t.Errorf("Failed to submit test jobs: %s", err.Error())
 	}
 
@@ -159,9 +207,13 @@ func TestSubmitAndList(t *testing.T) {
 		expectedHeader := ("clusterid\tgroup\tnum")
 		expectedRow := ("1\ttestgroup\t42")
 		expectedResult := []string{expectedHeader, expectedRow}
-		result, err := s.List(1, "clusterid", "group", "num")
+		listing, err := s.List(db.Filter{ClusterID: 1}, "clusterid", "group", "num")
 		if err != nil {
-			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		result, err := listing.Collect()
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
 		}
 		if !slices.Equal(expectedResult, result) {
 			t.Errorf("Got wrong result.  Expected %v, got %v", expectedResult, result)
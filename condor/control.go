@@ -0,0 +1,143 @@
+package condor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/db"
+	"fakeJobsub/notify"
+	"fakeJobsub/userlog"
+)
+
+// ErrPermissionDenied is wrapped by every *PermissionDeniedError, so callers can check for it with errors.Is
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionDeniedError is returned when a Requester may not act on a job
+type PermissionDeniedError struct {
+	User   string
+	Action string
+	Job    JobID
+	Owner  string
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("%s: %s may not %s job %s owned by %s", ErrPermissionDenied, e.User, e.Action, e.Job, e.Owner)
+}
+
+func (e *PermissionDeniedError) Unwrap() error {
+	return ErrPermissionDenied
+}
+
+// Requester is the user asking for an operation on a job
+type Requester struct {
+	User        string
+	SuperuserOf []string // Groups for which User is a superuser, and so may act on anyone's jobs
+}
+
+// authorize checks that r may perform action on the cluster job
+func (r Requester) authorize(action string, id JobID, job db.Job) error {
+	if r.User != "" && (r.User == job.Owner || slices.Contains(r.SuperuserOf, job.Group)) {
+		return nil
+	}
+	return &PermissionDeniedError{User: r.User, Action: action, Job: id, Owner: job.Owner}
+}
+
+// Remove removes the job(s) identified by id, if r is allowed to.  It returns the number of jobs removed
+func (s *Schedd) Remove(r Requester, id JobID) (int, error) {
+	n, err := s.setStatus(r, "remove", id, []db.JobStatus{db.Idle, db.Running, db.Held}, db.Removed, func(h userlog.EventHeader) userlog.Event {
+		return userlog.AbortedEvent{EventHeader: h, Reason: "via condor_rm (by user " + r.User + ")"}
+	})
+	removedJobs.Add(float64(n), s.Name)
+	return n, err
+}
+
+// Hold holds the job(s) identified by id, if r is allowed to.  It returns the number of jobs held
+func (s *Schedd) Hold(r Requester, id JobID) (int, error) {
+	return s.setStatus(r, "hold", id, []db.JobStatus{db.Idle, db.Running}, db.Held, func(h userlog.EventHeader) userlog.Event {
+		return userlog.HeldEvent{EventHeader: h, Reason: "via condor_hold (by user " + r.User + ")", HoldCode: userlog.HoldCodeUserRequest}
+	})
+}
+
+// Release releases the held job(s) identified by id, if r is allowed to.  It returns the number of jobs released
+func (s *Schedd) Release(r Requester, id JobID) (int, error) {
+	return s.setStatus(r, "release", id, []db.JobStatus{db.Held}, db.Idle, func(h userlog.EventHeader) userlog.Event {
+		return userlog.ReleasedEvent{EventHeader: h, Reason: "via condor_release (by user " + r.User + ")"}
+	})
+}
+
+// Edit sets key to value for the cluster identified by id, if r is allowed to
+func (s *Schedd) Edit(r Requester, id JobID, key string, value any) (err error) {
+	defer func() { s.countError("edit", err) }()
+	job, err := s.lookupAndAuthorize(r, "edit", id)
+	defer func() {
+		s.audit(db.AuditRecord{Time: time.Now(), Action: "edit", User: r.User, Group: job.Group, ClusterID: id.ClusterID, Detail: fmt.Sprintf("%s=%v", key, value)}, err)
+	}()
+	if err != nil {
+		return err
+	}
+	if err := s.db.UpdateJob(id.ClusterID, key, value); err != nil {
+		return fmt.Errorf("could not edit job %s: %w", id, err)
+	}
+	return nil
+}
+
+// Job returns the cluster identified by id
+func (s *Schedd) Job(id JobID) (db.Job, error) {
+	job, err := s.db.GetJob(id.ClusterID)
+	if err != nil {
+		return job, fmt.Errorf("could not find job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// setStatus performs action on the job(s) identified by id, changing those with a status in from to status to, and writing
+// the user log event that event returns for each
+func (s *Schedd) setStatus(r Requester, action string, id JobID, from []db.JobStatus, to db.JobStatus, event func(userlog.EventHeader) userlog.Event) (_ int, err error) {
+	defer func() { s.countError(action, err) }()
+	job, err := s.lookupAndAuthorize(r, action, id)
+	var changed []int
+	defer func() {
+		s.audit(db.AuditRecord{Time: time.Now(), Action: action, User: r.User, Group: job.Group, ClusterID: id.ClusterID, Procs: changed}, err)
+	}()
+	if err != nil {
+		return 0, err
+	}
+	changed, err = s.db.SetProcStatus(id.ClusterID, id.ProcID, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("could not %s job %s: %w", action, id, err)
+	}
+
+	now := time.Now()
+	events := make([]userlog.Event, 0, len(changed))
+	for _, procID := range changed {
+		events = append(events, event(header(job, procID, now)))
+	}
+	s.logEvents(job, events...)
+	for _, procID := range changed {
+		s.notify(job, procID, notify.Notification{Time: now, Event: actionEvents[action], Reason: "by user " + r.User})
+	}
+	return len(changed), nil
+}
+
+// lookupAndAuthorize finds the job identified by id, and checks that r may perform action on it
+func (s *Schedd) lookupAndAuthorize(r Requester, action string, id JobID) (db.Job, error) {
+	job, err := s.Job(id)
+	if err != nil {
+		return job, err
+	}
+	if id.ProcID != db.AllProcs {
+		exists, err := s.db.ProcExists(id.ClusterID, id.ProcID)
+		if err != nil {
+			return job, fmt.Errorf("could not find job %s: %w", id, err)
+		}
+		if !exists {
+			return job, fmt.Errorf("could not find job %s: no such proc", id)
+		}
+	}
+	if err := r.authorize(action, id, job); err != nil {
+		return job, err
+	}
+	return job, nil
+}
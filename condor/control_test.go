@@ -0,0 +1,115 @@
+package condor
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"fakeJobsub/db"
+)
+
+func TestJobControl(t *testing.T) {
+	// Setup DB with one cluster of 3 jobs owned by alice in group nova
+	name := "test1"
+	s := &Schedd{Name: name}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	if err := s.db.InsertJobIntoDB(db.Job{ClusterID: 1, Group: "nova", Num: 3, Role: "Analysis", Owner: "alice"}); err != nil {
+		t.Fatalf("Could not create row in test db: %s", err.Error())
+	}
+
+	owner := Requester{User: "alice"}
+	other := Requester{User: "bob"}
+	superuser := Requester{User: "novapro", SuperuserOf: []string{"nova"}}
+	otherSuperuser := Requester{User: "dunepro", SuperuserOf: []string{"dune"}}
+	cluster := JobID{ClusterID: 1, ProcID: db.AllProcs, Schedd: name}
+	proc0 := JobID{ClusterID: 1, ProcID: 0, Schedd: name}
+
+	status := func() string {
+		listing, err := s.db.RetrieveJobsFromDB(db.Filter{ClusterID: 1}, "status")
+		if err != nil {
+			t.Fatalf("Could not get status: %s", err)
+		}
+		rows, err := listing.Collect()
+		if err != nil || len(rows) != 2 {
+			t.Fatalf("Expected one row.  Got %v, %v", rows, err)
+		}
+		return rows[1]
+	}
+
+	t.Run("Other user can't hold", func(t *testing.T) {
+		_, err := s.Hold(other, cluster)
+		var permErr *PermissionDeniedError
+		if !errors.As(err, &permErr) || !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Should have gotten PermissionDeniedError.  Got %v instead", err)
+		}
+		if permErr != nil && (permErr.User != "bob" || permErr.Owner != "alice" || permErr.Action != "hold") {
+			t.Errorf("Got wrong PermissionDeniedError: %+v", permErr)
+		}
+	})
+
+	t.Run("Superuser of another group can't remove", func(t *testing.T) {
+		if _, err := s.Remove(otherSuperuser, cluster); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Should have gotten ErrPermissionDenied.  Got %v instead", err)
+		}
+	})
+
+	t.Run("Owner can hold a single job", func(t *testing.T) {
+		n, err := s.Hold(owner, proc0)
+		if err != nil || n != 1 {
+			t.Errorf("Should have held 1 job with nil error.  Got %d, %v instead", n, err)
+		}
+		if st := status(); st != "Held" {
+			t.Errorf("Cluster should be Held.  Got %s", st)
+		}
+	})
+
+	t.Run("Superuser can release", func(t *testing.T) {
+		n, err := s.Release(superuser, cluster)
+		if err != nil || n != 1 {
+			t.Errorf("Should have released 1 job with nil error.  Got %d, %v instead", n, err)
+		}
+		if st := status(); st != "Idle" {
+			t.Errorf("Cluster should be Idle.  Got %s", st)
+		}
+	})
+
+	t.Run("Owner can edit", func(t *testing.T) {
+		if err := s.Edit(owner, cluster, "role", "Production"); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		listing, err := s.db.RetrieveJobsFromDB(db.Filter{ClusterID: 1}, "role")
+		if err != nil {
+			t.Fatalf("Could not get role: %s", err)
+		}
+		if rows, err := listing.Collect(); err != nil || !slices.Equal(rows, []string{"role", "Production"}) {
+			t.Errorf("Role should have been edited.  Got %v, %v", rows, err)
+		}
+		if err := s.Edit(other, cluster, "role", "Analysis"); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Should have gotten ErrPermissionDenied.  Got %v instead", err)
+		}
+	})
+
+	t.Run("Nonexistent proc", func(t *testing.T) {
+		if _, err := s.Remove(owner, JobID{ClusterID: 1, ProcID: 7, Schedd: name}); err == nil || errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Should have gotten error indicating no such proc.  Got %v instead", err)
+		}
+	})
+
+	t.Run("Owner can remove", func(t *testing.T) {
+		n, err := s.Remove(owner, cluster)
+		if err != nil || n != 3 {
+			t.Errorf("Should have removed 3 jobs with nil error.  Got %d, %v instead", n, err)
+		}
+		if st := status(); st != "Removed" {
+			t.Errorf("Cluster should be Removed.  Got %s", st)
+		}
+		// Removed jobs can't be released
+		if n, _ := s.Release(owner, cluster); n != 0 {
+			t.Errorf("Should not have released any removed jobs.  Released %d", n)
+		}
+	})
+}
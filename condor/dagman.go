@@ -0,0 +1,190 @@
+package condor
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/dag"
+	"fakeJobsub/db"
+)
+
+// removedExitCode is the exit code a DAG node's cluster is treated as having if any of its jobs were removed
+const removedExitCode = -1
+
+// SubmitDAG queues a DAG made up of nodes at time now, and submits the nodes that have no parents left to run.  Nodes whose
+// status is db.NodeDone are never run; all other nodes start out waiting.  dag.ClusterID, dag.QDate, and dag.Status are
+// ignored.  It returns the cluster ID the DAG was given
+func (s *Schedd) SubmitDAG(d db.DAG, nodes []db.DAGNode, now time.Time) (int, error) {
+	s.submitMu.Lock()
+	cid, err := s.db.GetNextClusterID()
+	if err != nil {
+		s.submitMu.Unlock()
+		return 0, fmt.Errorf("could not submit DAG: %w", err)
+	}
+	d.ClusterID = cid
+	d.QDate = now
+	d.Status = db.DAGRunning
+	for idx := range nodes {
+		if nodes[idx].Status != db.NodeDone {
+			nodes[idx].Status = db.NodeWaiting
+		}
+	}
+	err = s.db.InsertDAG(d, nodes)
+	s.submitMu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("could not submit DAG: %w", err)
+	}
+	if _, err := s.advanceDAG(d, now); err != nil {
+		return cid, err
+	}
+	return cid, nil
+}
+
+// AdvanceDAGs moves each running DAG on the schedd forward at time now, like DAGMan:  nodes whose clusters have left the
+// queue are marked done or failed (or retried, if they have retries left), and nodes whose parents are all done are
+// submitted, highest priority first.  When a DAG can make no more progress, it is marked completed or failed, and a
+// rescue DAG is written next to the DAG file of a failed DAG.  It returns the number of nodes submitted
+func (s *Schedd) AdvanceDAGs(now time.Time) (int, error) {
+	dags, err := s.db.DAGs(db.DAGRunning)
+	if err != nil {
+		return 0, fmt.Errorf("could not get running DAGs: %w", err)
+	}
+	submitted := 0
+	for _, d := range dags {
+		n, err := s.advanceDAG(d, now)
+		submitted += n
+		if err != nil {
+			return submitted, err
+		}
+	}
+	return submitted, nil
+}
+
+func (s *Schedd) advanceDAG(d db.DAG, now time.Time) (int, error) {
+	nodes, err := s.db.DAGNodes(d.ClusterID)
+	if err != nil {
+		return 0, fmt.Errorf("could not get nodes of DAG %d: %w", d.ClusterID, err)
+	}
+	status := make(map[string]string, len(nodes))
+	submitted := 0
+
+	update := func(n *db.DAGNode, newStatus string) error {
+		n.Status = newStatus
+		status[n.Name] = newStatus
+		if err := s.db.UpdateDAGNode(*n); err != nil {
+			return fmt.Errorf("could not update node %s of DAG %d: %w", n.Name, d.ClusterID, err)
+		}
+		return nil
+	}
+	// As in DAGMan, a node that can't be submitted fails
+	submit := func(n *db.DAGNode) error {
+		n.Attempts++
+		job, err := s.submit(n.Job, now)
+		if err != nil {
+			s.log().Warn("could not submit DAG node", "dag", d.ClusterID, "node", n.Name, "error", err)
+			return update(n, db.NodeFailed)
+		}
+		n.ClusterID = job.ClusterID
+		submitted++
+		return update(n, db.NodeSubmitted)
+	}
+
+	// Check on the nodes in the queue
+	for idx := range nodes {
+		n := &nodes[idx]
+		status[n.Name] = n.Status
+		if n.Status != db.NodeSubmitted {
+			continue
+		}
+		procs, err := s.db.ClusterProcs(n.ClusterID)
+		if err != nil {
+			return submitted, fmt.Errorf("could not get jobs of node %s of DAG %d: %w", n.Name, d.ClusterID, err)
+		}
+		exitCode, finished := clusterOutcome(procs)
+		switch {
+		case !finished:
+			continue
+		case exitCode == 0:
+			err = update(n, db.NodeDone)
+		case n.Attempts <= n.Retries && (n.UnlessExit == nil || *n.UnlessExit != exitCode):
+			err = submit(n)
+		default:
+			err = update(n, db.NodeFailed)
+		}
+		if err != nil {
+			return submitted, err
+		}
+	}
+
+	// Submit the nodes whose parents are all done
+	ready := make([]*db.DAGNode, 0)
+	for idx := range nodes {
+		n := &nodes[idx]
+		if n.Status == db.NodeWaiting && !slices.ContainsFunc(n.Parents, func(p string) bool { return status[p] != db.NodeDone }) {
+			ready = append(ready, n)
+		}
+	}
+	slices.SortStableFunc(ready, func(a, b *db.DAGNode) int { return cmp.Compare(b.Priority, a.Priority) })
+	for _, n := range ready {
+		if err := submit(n); err != nil {
+			return submitted, err
+		}
+	}
+
+	// The DAG is finished once nothing is in the queue
+	if slices.ContainsFunc(nodes, func(n db.DAGNode) bool { return n.Status == db.NodeSubmitted }) {
+		return submitted, nil
+	}
+	if !slices.ContainsFunc(nodes, func(n db.DAGNode) bool { return n.Status != db.NodeDone }) {
+		if err := s.db.SetDAGStatus(d.ClusterID, db.DAGCompleted, ""); err != nil {
+			return submitted, fmt.Errorf("could not update DAG %d: %w", d.ClusterID, err)
+		}
+		return submitted, nil
+	}
+
+	// Some node failed, so its descendants can never run
+	done := make([]string, 0)
+	for idx := range nodes {
+		n := &nodes[idx]
+		switch n.Status {
+		case db.NodeDone:
+			done = append(done, n.Name)
+		case db.NodeWaiting:
+			if err := update(n, db.NodeFutile); err != nil {
+				return submitted, err
+			}
+		}
+	}
+	rescue, err := dag.WriteRescue(d.File, done)
+	if err != nil {
+		s.log().Warn("could not write rescue DAG", "dag", d.ClusterID, "error", err)
+	}
+	if err := s.db.SetDAGStatus(d.ClusterID, db.DAGFailed, rescue); err != nil {
+		return submitted, fmt.Errorf("could not update DAG %d: %w", d.ClusterID, err)
+	}
+	return submitted, nil
+}
+
+// clusterOutcome reports whether all of the jobs in a DAG node's cluster have left the queue, and if so, the node's exit
+// code:  removedExitCode if any job was removed, otherwise the first non-zero exit code of the jobs
+func clusterOutcome(procs []db.Proc) (int, bool) {
+	exitCode := 0
+	if len(procs) == 0 {
+		return removedExitCode, true
+	}
+	for _, p := range procs {
+		switch p.Status {
+		case db.Removed:
+			exitCode = removedExitCode
+		case db.Completed:
+			if exitCode == 0 {
+				exitCode = p.ExitCode
+			}
+		default:
+			return 0, false
+		}
+	}
+	return exitCode, true
+}
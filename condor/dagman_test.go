@@ -0,0 +1,170 @@
+package condor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/dag"
+	"fakeJobsub/db"
+)
+
+func TestDAGManager(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	alice := Requester{User: "alice"}
+	dagFile := filepath.Join(t.TempDir(), "workflow.dag")
+
+	// A diamond:  A, then B and C, then D.  B may be retried once
+	job := db.Job{Group: "nova", Num: 1, Role: "Analysis", Owner: "alice", CPUs: 1, Runtime: time.Minute}
+	nodes := []db.DAGNode{
+		{Name: "A", Job: job},
+		{Name: "B", Job: job, Parents: []string{"A"}, Retries: 1},
+		{Name: "C", Job: job, Parents: []string{"A"}, Priority: 10},
+		{Name: "D", Job: job, Parents: []string{"B", "C"}},
+	}
+
+	now := time.Unix(1700000000, 0)
+	dagID, err := s.SubmitDAG(db.DAG{File: dagFile, Group: "nova", Owner: "alice"}, nodes, now)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	node := func(name string) db.DAGNode {
+		nodes, err := s.db.DAGNodes(dagID)
+		if err != nil {
+			t.Fatalf("Could not get DAG nodes: %s", err)
+		}
+		for _, n := range nodes {
+			if n.Name == name {
+				return n
+			}
+		}
+		t.Fatalf("No node %s", name)
+		return db.DAGNode{}
+	}
+	finish := func(name string) {
+		n := node(name)
+		if _, err := s.db.StartProc(n.ClusterID, 0, "slot1@SiteA", now, now); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.db.CompleteProcs(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remove := func(name string) {
+		if _, err := s.Remove(alice, JobID{ClusterID: node(name).ClusterID, ProcID: db.AllProcs, Schedd: s.Name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	advance := func(expected int) {
+		submitted, err := s.AdvanceDAGs(now)
+		if err != nil || submitted != expected {
+			t.Fatalf("Expected %d node(s) submitted and nil error.  Got %d, %v", expected, submitted, err)
+		}
+	}
+
+	t.Run("Only the root node is submitted", func(t *testing.T) {
+		a := node("A")
+		if a.Status != db.NodeSubmitted || a.Attempts != 1 || a.ClusterID == dagID {
+			t.Errorf("A should have been submitted to a cluster of its own.  Got %+v", a)
+		}
+		if b := node("B"); b.Status != db.NodeWaiting {
+			t.Errorf("B should be waiting.  Got %s", b.Status)
+		}
+		advance(0)
+	})
+
+	t.Run("Children are submitted when the parent is done, highest priority first", func(t *testing.T) {
+		finish("A")
+		advance(2)
+		a, b, c := node("A"), node("B"), node("C")
+		if a.Status != db.NodeDone || b.Status != db.NodeSubmitted || c.Status != db.NodeSubmitted {
+			t.Errorf("Expected A done and B, C submitted.  Got %s, %s, %s", a.Status, b.Status, c.Status)
+		}
+		if c.ClusterID > b.ClusterID {
+			t.Errorf("C has higher priority, so should have been submitted before B.  Got clusters %d, %d", c.ClusterID, b.ClusterID)
+		}
+	})
+
+	t.Run("A failed node with retries left is resubmitted", func(t *testing.T) {
+		first := node("B").ClusterID
+		remove("B")
+		advance(1)
+		if b := node("B"); b.Status != db.NodeSubmitted || b.Attempts != 2 || b.ClusterID == first {
+			t.Errorf("B should have been resubmitted.  Got %+v", b)
+		}
+	})
+
+	t.Run("The DAG fails once nothing else can run, and a rescue DAG is written", func(t *testing.T) {
+		remove("B")
+		advance(0)
+		if b := node("B"); b.Status != db.NodeFailed {
+			t.Errorf("B should have failed.  Got %s", b.Status)
+		}
+		if dags, err := s.db.DAGs(db.DAGRunning); err != nil || len(dags) != 1 {
+			t.Errorf("DAG should still be running while C is.  Got %+v, %v", dags, err)
+		}
+
+		finish("C")
+		advance(0)
+		if dd := node("D"); dd.Status != db.NodeFutile {
+			t.Errorf("D should be futile.  Got %s", dd.Status)
+		}
+		dags, err := s.db.DAGs(db.DAGFailed)
+		if err != nil || len(dags) != 1 || dags[0].Rescue != dag.RescueFile(dagFile, 1) {
+			t.Fatalf("DAG should have failed with a rescue DAG.  Got %+v, %v", dags, err)
+		}
+		contents, err := os.ReadFile(dags[0].Rescue)
+		if err != nil || !strings.Contains(string(contents), "DONE A\nDONE C\n") {
+			t.Errorf("Rescue DAG should mark A and C done.  Got %q, %v", contents, err)
+		}
+	})
+
+	t.Run("List DAG nodes", func(t *testing.T) {
+		listing, err := s.db.RetrieveDAGNodesFromDB(db.Filter{ClusterID: dagID}, "node", "status", "attempts")
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		rows, err := listing.Collect()
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		expected := []string{"node\tstatus\tattempts", "A\tDone\t1", "B\tFailed\t2", "C\tDone\t1", "D\tFutile\t0"}
+		if strings.Join(rows, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected %q, got %q", expected, rows)
+		}
+	})
+}
+
+func TestDAGManagerSubmitFailure(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	dir := t.TempDir()
+
+	// B's user log is in a directory that doesn't exist, so B can't be submitted
+	job := db.Job{Group: "nova", Num: 1, Owner: "alice", CPUs: 1, Runtime: time.Minute}
+	bad := job
+	bad.Log = filepath.Join(dir, "nonexistent", "b.log")
+	nodes := []db.DAGNode{{Name: "A", Job: job}, {Name: "B", Job: bad}}
+	if _, err := s.SubmitDAG(db.DAG{File: filepath.Join(dir, "workflow.dag"), Owner: "alice"}, nodes, time.Now()); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	got, err := s.db.DAGNodes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Status != db.NodeSubmitted || got[1].Status != db.NodeFailed || got[1].Attempts != 1 {
+		t.Errorf("Expected A submitted and B failed.  Got %+v", got)
+	}
+}
@@ -0,0 +1,331 @@
+package condor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+// Import is what ParseJobAds makes of a dump of job ClassAds:  the clusters and procs to import into a schedd, and the
+// attributes that were left out
+type Import struct {
+	Jobs  []db.Job
+	Procs []db.Proc
+
+	// Unmapped counts the ads that had each attribute that has no equivalent in the database, or whose value couldn't be
+	// understood
+	Unmapped map[string]int
+}
+
+// adAttribute sets a field of a cluster or proc from the value of a job ClassAd attribute.  It returns false if the value
+// couldn't be understood
+type adAttribute func(job *db.Job, proc *db.Proc, value any) bool
+
+// clusterAttributes map the job ClassAd attributes that describe a whole cluster to its fields.  They are read from the first
+// ad of each cluster.  Attribute names are lowercased, as ClassAd attribute names are case-insensitive
+var clusterAttributes = map[string]adAttribute{
+	"owner":                     func(job *db.Job, _ *db.Proc, v any) bool { return setString(&job.Owner, v) },
+	"qdate":                     func(job *db.Job, _ *db.Proc, v any) bool { return setTime(&job.QDate, v) },
+	"requestmemory":             func(job *db.Job, _ *db.Proc, v any) bool { return setInt(&job.MemoryMB, v) },
+	"requestdisk":               func(job *db.Job, _ *db.Proc, v any) bool { return setInt(&job.DiskKB, v) },
+	"requestcpus":               func(job *db.Job, _ *db.Proc, v any) bool { return setInt(&job.CPUs, v) },
+	"requestgpus":               func(job *db.Job, _ *db.Proc, v any) bool { return setInt(&job.GPUs, v) },
+	"job_expected_max_lifetime": func(job *db.Job, _ *db.Proc, v any) bool { return setSeconds(&job.Lifetime, v) },
+	"desired_sites":             func(job *db.Job, _ *db.Proc, v any) bool { return setList(&job.Sites, v) },
+	"userlog":                   func(job *db.Job, _ *db.Proc, v any) bool { return setString(&job.Log, v) },
+	"transferoutput":            func(job *db.Job, _ *db.Proc, v any) bool { return setList(&job.OutputFiles, v) },
+	"jobnotification": func(job *db.Job, _ *db.Proc, v any) bool {
+		// HTCondor's notification values, in the order of its NOTIFY_* constants
+		notify := []string{db.NotifyNever, db.NotifyAlways, db.NotifyComplete, db.NotifyError}
+		var n int
+		if !setInt(&n, v) || n < 0 || n >= len(notify) {
+			return false
+		}
+		job.Notify = notify[n]
+		return true
+	},
+	// Written by submit --emit
+	"simruntime": func(job *db.Job, _ *db.Proc, v any) bool {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return false
+		}
+		job.Runtime = d
+		return true
+	},
+	"simexitcodes": func(job *db.Job, _ *db.Proc, v any) bool {
+		var codes []string
+		if !setList(&codes, v) {
+			return false
+		}
+		exitCodes := make([]int, 0, len(codes))
+		for _, c := range codes {
+			code, err := strconv.Atoi(c)
+			if err != nil {
+				return false
+			}
+			exitCodes = append(exitCodes, code)
+		}
+		job.SimExitCodes = exitCodes
+		return true
+	},
+}
+
+// procAttributes map the job ClassAd attributes that describe each job to its fields
+var procAttributes = map[string]adAttribute{
+	"clusterid":           func(_ *db.Job, proc *db.Proc, v any) bool { return setInt(&proc.ClusterID, v) },
+	"procid":              func(_ *db.Job, proc *db.Proc, v any) bool { return setInt(&proc.ProcID, v) },
+	"jobstatus":           func(_ *db.Job, proc *db.Proc, v any) bool { return setStatus(&proc.Status, v) },
+	"numjobstarts":        func(_ *db.Job, proc *db.Proc, v any) bool { return setInt(&proc.Attempts, v) },
+	"exitcode":            func(_ *db.Job, proc *db.Proc, v any) bool { return setInt(&proc.ExitCode, v) },
+	"jobcurrentstartdate": func(_ *db.Job, proc *db.Proc, v any) bool { return setTime(&proc.Start, v) },
+	"completiondate":      func(_ *db.Job, proc *db.Proc, v any) bool { return setTime(&proc.End, v) },
+	"remotehost":          func(_ *db.Job, proc *db.Proc, v any) bool { return setString(&proc.Slot, v) },
+	"holdreason":          func(_ *db.Job, proc *db.Proc, v any) bool { return setString(&proc.Reason, v) },
+}
+
+// fallbackAttributes are attributes that are used in place of another (the value) if an ad doesn't have that one
+var fallbackAttributes = map[string]string{
+	"acctgroupuser":   "owner",
+	"accountinggroup": "acctgroup",
+	"lastremotehost":  "remotehost", // Where a job that isn't running last ran
+}
+
+// requiredAttributes are the attributes, besides the group and owner, that every job ad must have, as HTCondor names them
+var requiredAttributes = []string{"ClusterId", "ProcId", "JobStatus"}
+
+// ParseJobAds parses the job ClassAds that condor_q -json or condor_history -json printed to r, one per job, into clusters
+// and procs.  Each ad must have ClusterId, ProcId, JobStatus, AcctGroup (or AccountingGroup), and Owner (or AcctGroupUser).
+// defaults returns the cluster that a group submits when it asks for nothing in particular, which attributes that aren't in
+// the ads are taken from.  Running jobs run for the cluster's runtime from when they last started, or from now if that
+// isn't known
+func ParseJobAds(r io.Reader, defaults func(group string) (db.Job, error), now time.Time) (Import, error) {
+	imp := Import{Jobs: make([]db.Job, 0), Procs: make([]db.Proc, 0), Unmapped: make(map[string]int)}
+	var ads []map[string]any
+	if err := json.NewDecoder(r).Decode(&ads); err != nil {
+		// condor_q prints nothing at all if there are no jobs
+		if errors.Is(err, io.EOF) {
+			return imp, nil
+		}
+		return imp, fmt.Errorf("could not parse job ads: %w", err)
+	}
+
+	clusters := make(map[int]int) // Indexes of the clusters in imp.Jobs
+	seen := make(map[[2]int]bool) // Cluster and proc IDs of the jobs so far
+	for i, ad := range ads {
+		attrs := make(map[string]any, len(ad))
+		names := make(map[string]string, len(ad)) // The attribute names as they were in the ad
+		for name, v := range ad {
+			attrs[strings.ToLower(name)] = v
+			names[strings.ToLower(name)] = name
+		}
+		for fallback, attr := range fallbackAttributes {
+			if _, ok := attrs[attr]; !ok {
+				if v, ok := attrs[fallback]; ok {
+					attrs[attr], names[attr] = v, names[fallback]
+				}
+			}
+			delete(attrs, fallback)
+		}
+		unmapped := func(k string) { imp.Unmapped[names[k]]++ }
+
+		// The proc's attributes are read first, so that we know which cluster it is in
+		for _, name := range requiredAttributes {
+			if _, ok := attrs[strings.ToLower(name)]; !ok {
+				return imp, fmt.Errorf("job ad %d: missing %s", i+1, name)
+			}
+		}
+		var proc db.Proc
+		for k, v := range attrs {
+			if set, ok := procAttributes[k]; ok && !set(nil, &proc, v) {
+				unmapped(k)
+			}
+		}
+		if proc.ClusterID < 1 || proc.ProcID < 0 {
+			return imp, fmt.Errorf("job ad %d: invalid ClusterId %v or ProcId %v", i+1, attrs["clusterid"], attrs["procid"])
+		}
+		id := fmt.Sprintf("job %d.%d", proc.ClusterID, proc.ProcID)
+		if proc.Status == 0 {
+			return imp, fmt.Errorf("%s: unsupported JobStatus %v", id, attrs["jobstatus"])
+		}
+		if seen[[2]int{proc.ClusterID, proc.ProcID}] {
+			return imp, fmt.Errorf("%s appears more than once", id)
+		}
+		seen[[2]int{proc.ClusterID, proc.ProcID}] = true
+
+		idx, ok := clusters[proc.ClusterID]
+		if !ok {
+			var group string
+			if !setGroup(&group, attrs["acctgroup"]) {
+				return imp, fmt.Errorf("%s: missing AcctGroup", id)
+			}
+			job, err := defaults(group)
+			if err != nil {
+				return imp, fmt.Errorf("%s: %w", id, err)
+			}
+			job.ClusterID, job.Group, job.Owner, job.QDate = proc.ClusterID, group, "", now
+			for k, v := range attrs {
+				if set, ok := clusterAttributes[k]; ok && !set(&job, nil, v) {
+					unmapped(k)
+				}
+			}
+			if job.Owner == "" {
+				return imp, fmt.Errorf("%s: missing Owner", id)
+			}
+			idx = len(imp.Jobs)
+			clusters[proc.ClusterID] = idx
+			imp.Jobs = append(imp.Jobs, job)
+		}
+		for k := range attrs {
+			_, isCluster := clusterAttributes[k]
+			_, isProc := procAttributes[k]
+			if !isCluster && !isProc && k != "acctgroup" {
+				unmapped(k)
+			}
+		}
+		imp.Jobs[idx].Num++
+		imp.Procs = append(imp.Procs, proc)
+	}
+
+	for i, p := range imp.Procs {
+		p.Job = imp.Jobs[clusters[p.ClusterID]]
+		if p.Status == db.Running {
+			if p.Start.IsZero() {
+				p.Start = now
+			}
+			p.End = p.Start.Add(p.Runtime)
+		}
+		if p.Status != db.Held {
+			// Only held jobs have a reason
+			p.Reason = ""
+		}
+		imp.Procs[i] = p
+	}
+	return imp, nil
+}
+
+// Import imports the clusters and procs in imp into the schedd for user.  They keep their cluster IDs, so the schedd must
+// not have any of them already
+func (s *Schedd) Import(imp Import, user string) (err error) {
+	now := time.Now()
+	defer func() {
+		for _, job := range imp.Jobs {
+			var procs []int
+			if err == nil {
+				for _, p := range imp.Procs {
+					if p.ClusterID == job.ClusterID {
+						procs = append(procs, p.ProcID)
+					}
+				}
+				slices.Sort(procs)
+			}
+			s.audit(db.AuditRecord{Time: now, Action: "import", User: user, Group: job.Group, ClusterID: job.ClusterID, Procs: procs,
+				Detail: "owner=" + job.Owner}, err)
+		}
+	}()
+	s.submitMu.Lock()
+	defer s.submitMu.Unlock()
+	if err := s.db.ImportJobs(imp.Jobs, imp.Procs); err != nil {
+		return fmt.Errorf("could not import jobs: %w", err)
+	}
+	s.log().Info("imported jobs", "clusters", len(imp.Jobs), "procs", len(imp.Procs))
+
+	// Fake some CPU-intensive activity
+	time.Sleep(s.Latency.Submit)
+	return nil
+}
+
+func setString(dst *string, v any) bool {
+	s, ok := v.(string)
+	if ok {
+		*dst = s
+	}
+	return ok
+}
+
+// setInt sets dst to v, which must be a whole number
+func setInt(dst *int, v any) bool {
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) {
+		return false
+	}
+	*dst = int(f)
+	return true
+}
+
+// setTime sets dst to v, a Unix time.  0 means the time isn't known, and leaves dst unset
+func setTime(dst *time.Time, v any) bool {
+	var secs int
+	if !setInt(&secs, v) {
+		return false
+	}
+	if secs > 0 {
+		*dst = time.Unix(int64(secs), 0)
+	}
+	return true
+}
+
+func setSeconds(dst *time.Duration, v any) bool {
+	var secs int
+	if !setInt(&secs, v) {
+		return false
+	}
+	*dst = time.Duration(secs) * time.Second
+	return true
+}
+
+// setList sets dst to the comma-separated list v
+func setList(dst *[]string, v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+	return true
+}
+
+// setGroup sets dst to the group of the HTCondor accounting group v, like group_nova or group_nova.alice
+func setGroup(dst *string, v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	*dst, _, _ = strings.Cut(strings.TrimPrefix(s, "group_"), ".")
+	return *dst != ""
+}
+
+// setStatus sets dst to the JobStatus v.  Jobs that are transferring output (6) or suspended (7) are running as far as the
+// simulated pool is concerned
+func setStatus(dst *db.JobStatus, v any) bool {
+	var status int
+	if !setInt(&status, v) {
+		return false
+	}
+	switch {
+	case status >= int(db.Idle) && status <= int(db.Held):
+		*dst = db.JobStatus(status)
+	case status == 6 || status == 7:
+		*dst = db.Running
+	default:
+		return false
+	}
+	return true
+}
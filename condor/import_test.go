@@ -0,0 +1,134 @@
+package condor
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+// importDefaults are the defaults of every group but unknown
+func importDefaults(group string) (db.Job, error) {
+	if group == "unknown" {
+		return db.Job{}, errors.New("unknown group")
+	}
+	return db.Job{Role: "Analysis", MemoryMB: 2000, DiskKB: 10485760, CPUs: 1, Lifetime: 8 * time.Hour, Runtime: time.Minute}, nil
+}
+
+func TestParseJobAds(t *testing.T) {
+	now := time.Unix(1760900000, 0)
+	dump := `[
+		{"ClusterId": 45, "ProcId": 0, "JobStatus": 2, "Owner": "alice", "AcctGroup": "group_nova", "RequestMemory": 4000,
+		 "RequestCpus": 2, "QDate": 1760850000, "JobCurrentStartDate": 1760850100, "RemoteHost": "slot1@fnpc1.fnal.gov",
+		 "NumJobStarts": 1, "DESIRED_Sites": "FermiGrid, Wisconsin", "JobNotification": 3, "Cmd": "/grid/run.sh", "SimRuntime": "10m"},
+		{"ClusterId": 45, "ProcId": 2, "JobStatus": 5, "Owner": "alice", "AcctGroup": "group_nova", "HoldReason": "Over memory",
+		 "LastRemoteHost": "slot2@fnpc2.fnal.gov", "Cmd": "/grid/run.sh"},
+		{"clusterid": 46, "procid": 0, "jobstatus": 4, "AcctGroupUser": "bob", "AccountingGroup": "group_dune.bob", "ExitCode": 1,
+		 "CompletionDate": 1760860000, "RequestDisk": "ifThenElse(DiskUsage > 1, 2, 3)", "HoldReason": "Released"}
+	]`
+	imp, err := ParseJobAds(strings.NewReader(dump), importDefaults, now)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if len(imp.Jobs) != 2 || len(imp.Procs) != 3 {
+		t.Fatalf("Expected 2 clusters and 3 procs.  Got %+v", imp)
+	}
+
+	nova := imp.Jobs[0]
+	if nova.ClusterID != 45 || nova.Group != "nova" || nova.Owner != "alice" || nova.Num != 2 || nova.MemoryMB != 4000 ||
+		nova.CPUs != 2 || nova.DiskKB != 10485760 || nova.QDate.Unix() != 1760850000 || len(nova.Sites) != 2 ||
+		nova.Notify != db.NotifyError || nova.Runtime != 10*time.Minute {
+		t.Errorf("Got wrong cluster %+v", nova)
+	}
+	dune := imp.Jobs[1]
+	if dune.ClusterID != 46 || dune.Group != "dune" || dune.Owner != "bob" || dune.DiskKB != 10485760 || !dune.QDate.Equal(now) {
+		t.Errorf("Got wrong cluster %+v", dune)
+	}
+
+	running, held, completed := imp.Procs[0], imp.Procs[1], imp.Procs[2]
+	if running.Status != db.Running || running.Slot != "slot1@fnpc1.fnal.gov" || running.Attempts != 1 ||
+		running.End.Unix() != 1760850100+600 || running.MemoryMB != 4000 {
+		t.Errorf("Got wrong running proc %+v", running)
+	}
+	if held.ProcID != 2 || held.Status != db.Held || held.Reason != "Over memory" || held.Slot != "slot2@fnpc2.fnal.gov" {
+		t.Errorf("Got wrong held proc %+v", held)
+	}
+	if completed.Status != db.Completed || completed.ExitCode != 1 || completed.End.Unix() != 1760860000 || completed.Reason != "" {
+		t.Errorf("Got wrong completed proc %+v", completed)
+	}
+
+	expected := map[string]int{"Cmd": 2, "RequestDisk": 1}
+	if !maps.Equal(imp.Unmapped, expected) {
+		t.Errorf("Expected unmapped attributes %v.  Got %v instead", expected, imp.Unmapped)
+	}
+}
+
+func TestParseJobAdsErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		dump      string
+		errSubstr string
+	}{
+		{"not JSON", `ClusterId = 1`, "could not parse job ads"},
+		{"missing ProcId", `[{"ClusterId": 1, "JobStatus": 1, "Owner": "alice", "AcctGroup": "nova"}]`, "missing ProcId"},
+		{"missing group", `[{"ClusterId": 1, "ProcId": 0, "JobStatus": 1, "Owner": "alice"}]`, "missing AcctGroup"},
+		{"missing owner", `[{"ClusterId": 1, "ProcId": 0, "JobStatus": 1, "AcctGroup": "nova"}]`, "missing Owner"},
+		{"unknown group", `[{"ClusterId": 1, "ProcId": 0, "JobStatus": 1, "Owner": "alice", "AcctGroup": "unknown"}]`, "unknown group"},
+		{"bad status", `[{"ClusterId": 1, "ProcId": 0, "JobStatus": 9, "Owner": "alice", "AcctGroup": "nova"}]`, "unsupported JobStatus"},
+		{"duplicate", `[{"ClusterId": 1, "ProcId": 0, "JobStatus": 1, "Owner": "a", "AcctGroup": "nova"},
+			{"ClusterId": 1, "ProcId": 0, "JobStatus": 2, "Owner": "a", "AcctGroup": "nova"}]`, "more than once"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseJobAds(strings.NewReader(test.dump), importDefaults, time.Now()); err == nil || !strings.Contains(err.Error(), test.errSubstr) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.errSubstr, err)
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		// condor_q prints nothing when the queue is empty
+		if imp, err := ParseJobAds(strings.NewReader(""), importDefaults, time.Now()); err != nil || len(imp.Jobs) != 0 {
+			t.Errorf("Expected nothing to import and nil error.  Got %+v, %v instead", imp, err)
+		}
+	})
+}
+
+func TestImport(t *testing.T) {
+	s := &Schedd{Name: "importtest"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+
+	dump := `[{"ClusterId": 7, "ProcId": 3, "JobStatus": 1, "Owner": "alice", "AcctGroup": "group_nova"}]`
+	imp, err := ParseJobAds(strings.NewReader(dump), importDefaults, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Import(imp, "admin"); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if p, err := d.GetProc(7, 3); err != nil || p.Status != db.Idle || p.Owner != "alice" {
+		t.Errorf("Expected idle job 7.3 owned by alice.  Got %+v, %v", p, err)
+	}
+	if err := s.Import(imp, "admin"); err == nil || !strings.Contains(err.Error(), "cluster 7 already exists") {
+		t.Errorf("Should have gotten error indicating the cluster exists.  Got %v instead", err)
+	}
+
+	records, err := s.Audit(db.AuditFilter{Action: "import"})
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 import audit records.  Got %v, %v", records, err)
+	}
+	if r := records[0]; r.User != "admin" || r.Group != "nova" || db.FormatProcRanges(r.Procs) != "3" || r.Result != db.AuditOK {
+		t.Errorf("Got wrong audit record %+v", r)
+	}
+	if records[1].Result == db.AuditOK {
+		t.Errorf("Expected the failed import to record why it failed.  Got %+v", records[1])
+	}
+}
@@ -0,0 +1,56 @@
+package condor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fakeJobsub/db"
+)
+
+// JobID identifies a cluster, or a single proc in a cluster, on a schedd, like jobsub_lite's 12.0@schedd1
+type JobID struct {
+	ClusterID int
+	ProcID    int // db.AllProcs if the JobID refers to the whole cluster
+	Schedd    string
+}
+
+// ParseJobID parses a job ID of the form cluster[.proc][@schedd]
+func ParseJobID(s string) (JobID, error) {
+	id := JobID{ProcID: db.AllProcs}
+
+	idPart, schedd, hasSchedd := strings.Cut(s, "@")
+	if hasSchedd {
+		if schedd == "" {
+			return id, fmt.Errorf("invalid job ID %q: empty schedd", s)
+		}
+		id.Schedd = schedd
+	}
+
+	clusterPart, procPart, hasProc := strings.Cut(idPart, ".")
+	cid, err := strconv.Atoi(clusterPart)
+	if err != nil || cid <= 0 {
+		return id, fmt.Errorf("invalid job ID %q: cluster must be a positive integer", s)
+	}
+	id.ClusterID = cid
+
+	if hasProc {
+		pid, err := strconv.Atoi(procPart)
+		if err != nil || pid < 0 {
+			return id, fmt.Errorf("invalid job ID %q: proc must be a non-negative integer", s)
+		}
+		id.ProcID = pid
+	}
+	return id, nil
+}
+
+func (id JobID) String() string {
+	s := strconv.Itoa(id.ClusterID)
+	if id.ProcID != db.AllProcs {
+		s += "." + strconv.Itoa(id.ProcID)
+	}
+	if id.Schedd != "" {
+		s += "@" + id.Schedd
+	}
+	return s
+}
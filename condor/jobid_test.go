@@ -0,0 +1,45 @@
+package condor
+
+import (
+	"testing"
+
+	"fakeJobsub/db"
+)
+
+func TestParseJobID(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   JobID
+		shouldFail bool
+	}{
+		{"12.3@schedd1", JobID{12, 3, "schedd1"}, false},
+		{"12@schedd1", JobID{12, db.AllProcs, "schedd1"}, false},
+		{"12.0", JobID{12, 0, ""}, false},
+		{"12", JobID{12, db.AllProcs, ""}, false},
+		{"0.1@schedd1", JobID{}, true},
+		{"abc@schedd1", JobID{}, true},
+		{"12.x@schedd1", JobID{}, true},
+		{"12.0@", JobID{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			id, err := ParseJobID(test.input)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error parsing %s.  Got %v instead", test.input, id)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if id != test.expected {
+				t.Errorf("Got wrong job ID.  Expected %v, got %v", test.expected, id)
+			}
+			if id.String() != test.input {
+				t.Errorf("String() should round trip.  Expected %s, got %s", test.input, id.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package condor
+
+import (
+	"time"
+
+	"fakeJobsub/metrics"
+)
+
+// Metrics holds the metrics of the schedd operations that this process has performed.  Commands that run for a while, like
+// serve and loadgen, serve them at /metrics
+var Metrics = metrics.NewRegistry()
+
+var (
+	submittedClusters = Metrics.Counter("fakejobsub_submitted_clusters_total", "Clusters submitted to each schedd, by group.", "schedd", "group")
+	submittedJobs     = Metrics.Counter("fakejobsub_submitted_jobs_total", "Jobs submitted to each schedd, by group.", "schedd", "group")
+	removedJobs       = Metrics.Counter("fakejobsub_removed_jobs_total", "Jobs removed from each schedd.", "schedd")
+	operationErrors   = Metrics.Counter("fakejobsub_errors_total", "Schedd operations that failed, by operation:  submit, list, remove, hold, release, or edit.", "schedd", "op")
+	submitDuration    = Metrics.Histogram("fakejobsub_schedd_submit_duration_seconds", "How long Schedd.SubmitJob took, including the simulated latency.", metrics.DefaultBuckets, "schedd")
+	listDuration      = Metrics.Histogram("fakejobsub_schedd_list_duration_seconds", "How long listing jobs, job history, DAG nodes, or totals took, from the query to reading the last row, including the simulated latency.", metrics.DefaultBuckets, "schedd")
+)
+
+// countError counts err, if it isn't nil, as an error of the operation op
+func (s *Schedd) countError(op string, err error) {
+	if err != nil {
+		operationErrors.Inc(s.Name, op)
+	}
+}
+
+// observeSince adds the time since start to the histogram h
+func (s *Schedd) observeSince(h *metrics.Histogram, start time.Time) {
+	h.Observe(time.Since(start).Seconds(), s.Name)
+}
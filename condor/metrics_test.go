@@ -0,0 +1,94 @@
+package condor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"fakeJobsub/db"
+)
+
+func TestMetrics(t *testing.T) {
+	// The metrics are kept for the whole process, so this schedd's name must not be used by any other test
+	name := "metricstest"
+	s := &Schedd{Name: name}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+
+	job, err := s.SubmitJob(db.Job{Group: "nova", Num: 3, Role: "Analysis", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if _, err := s.SubmitJob(db.Job{Group: "dune", Num: 2, Role: "Analysis", Owner: "alice", Log: "/nonexistent/dir/job.log"}); err == nil {
+		t.Fatal("Should have gotten an error submitting with an unwritable log.  Got nil instead")
+	}
+	id := JobID{ClusterID: job.ClusterID, ProcID: db.AllProcs, Schedd: name}
+	if _, err := s.Hold(Requester{User: "bob"}, id); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("Should have gotten ErrPermissionDenied.  Got %v instead", err)
+	}
+	if _, err := s.Remove(Requester{User: "alice"}, id); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	// Listings are only timed once their rows are read
+	for _, list := range []func(db.Filter, ...string) (*db.Listing, error){s.List, s.ListProcs, s.History} {
+		before := listDuration.Count(name)
+		listing, err := list(db.Filter{})
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if n := listDuration.Count(name); n != before {
+			t.Errorf("Expected no list latency before the rows are read.  Got %d instead of %d", n, before)
+		}
+		if _, err := listing.Collect(); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+	}
+	if _, _, err := s.Totals(db.Filter{}, "owner"); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if _, err := s.List(db.Filter{Sort: []db.SortKey{{Column: "nosuchcolumn"}}}); err == nil {
+		t.Fatal("Should have gotten an error listing by an invalid column.  Got nil instead")
+	}
+
+	counters := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{"nova clusters", submittedClusters.Value(name, "nova"), 1},
+		{"nova jobs", submittedJobs.Value(name, "nova"), 3},
+		{"dune clusters", submittedClusters.Value(name, "dune"), 0},
+		{"removed jobs", removedJobs.Value(name), 3},
+		{"submit errors", operationErrors.Value(name, "submit"), 1},
+		{"hold errors", operationErrors.Value(name, "hold"), 1},
+		{"list errors", operationErrors.Value(name, "list"), 1},
+	}
+	for _, c := range counters {
+		if c.got != c.expected {
+			t.Errorf("Expected %s to be %v.  Got %v instead", c.name, c.expected, c.got)
+		}
+	}
+	if n := submitDuration.Count(name); n != 2 {
+		t.Errorf("Expected 2 submit latencies.  Got %d instead", n)
+	}
+	if n := listDuration.Count(name); n != 5 {
+		t.Errorf("Expected 5 list latencies.  Got %d instead", n)
+	}
+
+	var b strings.Builder
+	if err := Metrics.Write(&b); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	for _, want := range []string{
+		`fakejobsub_submitted_jobs_total{schedd="metricstest",group="nova"} 3`,
+		`fakejobsub_errors_total{schedd="metricstest",op="hold"} 1`,
+		`fakejobsub_schedd_submit_duration_seconds_count{schedd="metricstest"} 2`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected metrics to contain %q.  Got:\n%s", want, b.String())
+		}
+	}
+}
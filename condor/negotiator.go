@@ -0,0 +1,203 @@
+package condor
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/db"
+	"fakeJobsub/notify"
+	"fakeJobsub/userlog"
+)
+
+// Negotiator matches idle jobs on its schedds to free slots in its pool, and finishes running jobs whose (simulated) runtime
+// has elapsed, like HTCondor's negotiator and startds rolled into one
+type Negotiator struct {
+	Pool    *Pool
+	Schedds []*Schedd
+
+	// Accountant charges groups for the jobs they run, and decides which group's jobs are matched first.  If it is nil,
+	// jobs are matched oldest first
+	Accountant *Accountant
+
+	// Quotas are the most jobs each group may have running at once.  Groups that aren't in Quotas have no limit
+	Quotas map[string]int
+}
+
+// CycleResult summarizes a negotiation cycle
+type CycleResult struct {
+	Completed int // Jobs that finished
+	Retried   int // Finished jobs that were put back in the queue by their retry policy
+	Matched   int // Idle jobs that started running
+	Idle      int // Idle jobs that could not be matched
+	DAGNodes  int // DAG nodes that were submitted
+}
+
+// idleJob is an idle proc and the schedd it is on
+type idleJob struct {
+	schedd *Schedd
+	proc   db.Proc
+}
+
+// Cycle runs one negotiation cycle at time now:  groups are charged for their running jobs, running jobs whose runtime has
+// elapsed are completed (and put back in the queue if they failed and their retry policy says to), DAGs are advanced, and
+// then idle jobs that aren't backing off before a retry are matched to free slots.  Jobs of groups with better priority
+// are matched first, and within a group, oldest first
+func (n *Negotiator) Cycle(now time.Time) (CycleResult, error) {
+	var result CycleResult
+
+	if n.Accountant != nil {
+		running := make([]db.Proc, 0)
+		for _, s := range n.Schedds {
+			procs, err := s.db.Procs(db.Running)
+			if err != nil {
+				return result, fmt.Errorf("could not get running jobs on schedd %s: %w", s.Name, err)
+			}
+			running = append(running, procs...)
+		}
+		if err := n.Accountant.Charge(now, running); err != nil {
+			return result, err
+		}
+	}
+
+	// Finish jobs, and retry the ones that failed if their retry policy says to
+	for _, s := range n.Schedds {
+		completed, err := s.db.CompleteProcs(now)
+		if err != nil {
+			return result, fmt.Errorf("could not complete jobs on schedd %s: %w", s.Name, err)
+		}
+		result.Completed += len(completed)
+		for _, p := range completed {
+			s.logEvents(p.Job, userlog.TerminatedEvent{EventHeader: header(p.Job, p.ProcID, p.End), Normal: true, ReturnValue: p.ExitCode})
+			s.writeSandbox(p, p.End)
+			retried, err := s.retry(p)
+			if err != nil {
+				return result, fmt.Errorf("could not retry job on schedd %s: %w", s.Name, err)
+			}
+			event := notify.EventCompleted
+			if retried {
+				result.Retried++
+				event = notify.EventRetried
+			}
+			s.notify(p.Job, p.ProcID, notify.Notification{Time: p.End, Event: event, ExitCode: &p.ExitCode})
+		}
+	}
+
+	// Submit DAG nodes whose parents finished, so that they can be matched in this cycle
+	for _, s := range n.Schedds {
+		submitted, err := s.AdvanceDAGs(now)
+		result.DAGNodes += submitted
+		if err != nil {
+			return result, fmt.Errorf("could not advance DAGs on schedd %s: %w", s.Name, err)
+		}
+	}
+
+	busy, running, err := n.usage()
+	if err != nil {
+		return result, err
+	}
+
+	idle, err := n.idleJobs(now)
+	if err != nil {
+		return result, err
+	}
+
+	// Match each idle job to the first free slot that it matches
+	for _, j := range idle {
+		if j.proc.RetryAfter.After(now) {
+			result.Idle++
+			if err := j.schedd.db.SetProcReason(j.proc.ClusterID, j.proc.ProcID, backoffReason(j.proc)); err != nil {
+				return result, fmt.Errorf("could not record reason for idle job on schedd %s: %w", j.schedd.Name, err)
+			}
+			continue
+		}
+		if quota, ok := n.Quotas[j.proc.Group]; ok && running[j.proc.Group] >= quota {
+			result.Idle++
+			if err := j.schedd.db.SetProcReason(j.proc.ClusterID, j.proc.ProcID, quotaReason(j.proc.Group, quota)); err != nil {
+				return result, fmt.Errorf("could not record reason for idle job on schedd %s: %w", j.schedd.Name, err)
+			}
+			continue
+		}
+
+		idx := slices.IndexFunc(n.Pool.Slots, func(s Slot) bool { return !busy[s.Name] && s.Matches(j.proc.Job) })
+		if idx == -1 {
+			result.Idle++
+			if err := j.schedd.db.SetProcReason(j.proc.ClusterID, j.proc.ProcID, n.Pool.explain(j.proc.Job, busy)); err != nil {
+				return result, fmt.Errorf("could not record reason for idle job on schedd %s: %w", j.schedd.Name, err)
+			}
+			continue
+		}
+
+		slot := n.Pool.Slots[idx]
+		started, err := j.schedd.db.StartProc(j.proc.ClusterID, j.proc.ProcID, slot.Name, now, now.Add(j.proc.Runtime))
+		if err != nil {
+			return result, fmt.Errorf("could not start job on schedd %s: %w", j.schedd.Name, err)
+		}
+		if started {
+			busy[slot.Name] = true
+			running[j.proc.Group]++
+			result.Matched++
+			j.schedd.logEvents(j.proc.Job, userlog.ExecuteEvent{EventHeader: header(j.proc.Job, j.proc.ProcID, now), Host: sinful(slot.Site), SlotName: slot.Name})
+			j.schedd.notify(j.proc.Job, j.proc.ProcID, notify.Notification{Time: now, Event: notify.EventStarted})
+		}
+	}
+
+	return result, nil
+}
+
+// usage returns which slots are busy, and how many jobs each group has running
+func (n *Negotiator) usage() (map[string]bool, map[string]int, error) {
+	busy := make(map[string]bool)
+	running := make(map[string]int)
+	for _, s := range n.Schedds {
+		procs, err := s.db.Procs(db.Running)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not get running jobs on schedd %s: %w", s.Name, err)
+		}
+		for _, p := range procs {
+			busy[p.Slot] = true
+			running[p.Group]++
+		}
+	}
+	return busy, running, nil
+}
+
+func quotaReason(group string, quota int) string {
+	return fmt.Sprintf("group %s is at its quota of %d running jobs", group, quota)
+}
+
+// idleJobs returns the idle jobs on all of the schedds in the order they should be matched at now
+func (n *Negotiator) idleJobs(now time.Time) ([]idleJob, error) {
+	idle := make([]idleJob, 0)
+	for _, s := range n.Schedds {
+		procs, err := s.db.Procs(db.Idle)
+		if err != nil {
+			return nil, fmt.Errorf("could not get idle jobs on schedd %s: %w", s.Name, err)
+		}
+		for _, p := range procs {
+			idle = append(idle, idleJob{schedd: s, proc: p})
+		}
+	}
+	priorities := make(map[string]float64)
+	if n.Accountant != nil {
+		groups := make([]string, 0)
+		for _, j := range idle {
+			if !slices.Contains(groups, j.proc.Group) {
+				groups = append(groups, j.proc.Group)
+			}
+		}
+		prios, err := n.Accountant.Priorities(now, groups)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range prios {
+			priorities[p.Group] = p.EffectivePriority
+		}
+	}
+
+	slices.SortStableFunc(idle, func(a, b idleJob) int {
+		return cmp.Or(cmp.Compare(priorities[a.proc.Group], priorities[b.proc.Group]), a.proc.QDate.Compare(b.proc.QDate))
+	})
+	return idle, nil
+}
@@ -0,0 +1,156 @@
+package condor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+func TestNegotiatorCycle(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+
+	// Two slots at SiteA, one GPU slot at SiteB
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{
+		{Name: "SiteA", Slots: 2, Resources: config.Resources{MemoryMB: 4000, DiskKB: 1000000, CPUs: 4}},
+		{Name: "SiteB", Slots: 1, Resources: config.Resources{MemoryMB: 8000, DiskKB: 1000000, CPUs: 4, GPUs: 1}},
+	}})
+	if len(pool.Slots) != 3 || pool.Slots[2].Name != "slot1@SiteB" {
+		t.Fatalf("Got wrong slots: %+v", pool.Slots)
+	}
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}}
+
+	start := time.Unix(1700000000, 0)
+	jobs := []db.Job{
+		{ClusterID: 1, Num: 2, MemoryMB: 2000, DiskKB: 1000, CPUs: 1, Sites: []string{"SiteA"}, Runtime: time.Minute, QDate: start},
+		{ClusterID: 2, Num: 1, MemoryMB: 2000, DiskKB: 1000, CPUs: 1, Sites: []string{"SiteA"}, Runtime: time.Minute, QDate: start.Add(time.Second)},
+		{ClusterID: 3, Num: 1, MemoryMB: 2000, DiskKB: 1000, CPUs: 1, GPUs: 2, Runtime: time.Minute, QDate: start.Add(2 * time.Second)},
+	}
+	for _, j := range jobs {
+		if err := s.db.InsertJobIntoDB(j); err != nil {
+			t.Fatalf("Could not create row in test db: %s", err.Error())
+		}
+	}
+
+	reason := func(clusterID int) string {
+		listing, err := s.db.RetrieveProcsFromDB(db.Filter{ClusterID: clusterID}, "reason")
+		if err != nil {
+			t.Fatalf("Could not get reason: %s", err)
+		}
+		rows, err := listing.Collect()
+		if err != nil || len(rows) != 2 {
+			t.Fatalf("Expected one row.  Got %v, %v", rows, err)
+		}
+		return rows[1]
+	}
+
+	t.Run("Test 1: Oldest jobs fill the matching slots", func(t *testing.T) {
+		result, err := n.Cycle(start)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		expected := CycleResult{Completed: 0, Matched: 2, Idle: 2}
+		if result != expected {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+		if r := reason(2); r != "all 2 matching slots are busy" {
+			t.Errorf("Got wrong reason for cluster 2: %s", r)
+		}
+		if r := reason(3); !strings.Contains(r, "TARGET.GPUs >= RequestGPUs") {
+			t.Errorf("Got wrong reason for cluster 3: %s", r)
+		}
+	})
+
+	t.Run("Test 2: Nothing changes before the running jobs finish", func(t *testing.T) {
+		result, err := n.Cycle(start.Add(30 * time.Second))
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		expected := CycleResult{Completed: 0, Matched: 0, Idle: 2}
+		if result != expected {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+	})
+
+	t.Run("Test 3: Finished jobs free their slots", func(t *testing.T) {
+		result, err := n.Cycle(start.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		expected := CycleResult{Completed: 2, Matched: 1, Idle: 1}
+		if result != expected {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+		listing, err := s.db.RetrieveProcsFromDB(db.Filter{ClusterID: 2}, "status", "site")
+		if err != nil {
+			t.Fatalf("Could not list procs: %s", err)
+		}
+		if rows, err := listing.Collect(); err != nil || rows[1] != "Running\tSiteA" {
+			t.Errorf("Cluster 2 should be running at SiteA.  Got %v, %v", rows, err)
+		}
+	})
+}
+
+func TestNegotiatorFairShare(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	ad, err := db.CreateOrOpenAccountingDB(filepath.Join(t.TempDir(), "accountant.db"))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	a := &Accountant{db: ad, HalfLife: 24 * time.Hour, Factor: func(string) float64 { return 1000 }}
+
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{{Name: "SiteA", Slots: 1, Resources: config.Resources{CPUs: 1}}}})
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}, Accountant: a}
+
+	// nova has used the pool, so dune's newer job goes first
+	start := time.Unix(1700000000, 0)
+	if err := a.Charge(start, []db.Proc{{Job: db.Job{Group: "nova", CPUs: 1}, Start: start.Add(-10 * time.Hour), End: start}}); err != nil {
+		t.Fatalf("Could not charge nova: %s", err)
+	}
+	jobs := []db.Job{
+		{ClusterID: 1, Group: "nova", Num: 1, CPUs: 1, Runtime: time.Hour, QDate: start},
+		{ClusterID: 2, Group: "dune", Num: 1, CPUs: 1, Runtime: time.Hour, QDate: start.Add(time.Second)},
+	}
+	for _, j := range jobs {
+		if err := s.db.InsertJobIntoDB(j); err != nil {
+			t.Fatalf("Could not create row in test db: %s", err.Error())
+		}
+	}
+
+	if _, err := n.Cycle(start.Add(time.Minute)); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	listing, err := s.db.RetrieveProcsFromDB(db.Filter{}, "clusterid", "status")
+	if err != nil {
+		t.Fatalf("Could not list procs: %s", err)
+	}
+	rows, err := listing.Collect()
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if rows[1] != "1\tIdle" || rows[2] != "2\tRunning" {
+		t.Errorf("dune's job should be running and nova's idle.  Got %v", rows)
+	}
+
+	// Once dune's job finishes, dune has been charged for the core-hour it used
+	if _, err := n.Cycle(start.Add(time.Minute + time.Hour)); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	prios, err := a.Priorities(start.Add(time.Minute+time.Hour), nil)
+	if err != nil || len(prios) != 2 || prios[0].Group != "dune" || prios[0].Usage < 0.99 || prios[0].Usage > 1 {
+		t.Errorf("dune should have about 1 core-hour of usage.  Got %+v, %v", prios, err)
+	}
+}
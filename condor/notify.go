@@ -0,0 +1,37 @@
+package condor
+
+import (
+	"fakeJobsub/db"
+	"fakeJobsub/notify"
+)
+
+// actionEvents are the notification events of the actions setStatus performs
+var actionEvents = map[string]string{
+	"remove":  notify.EventRemoved,
+	"hold":    notify.EventHeld,
+	"release": notify.EventReleased,
+}
+
+// notify sends note about the proc procID of job to s.Notifier, if the job asked to be notified of it
+func (s *Schedd) notify(job db.Job, procID int, note notify.Notification) {
+	if s.Notifier == nil || !wantsNotification(job.Notify, note) {
+		return
+	}
+	note.JobID = JobID{ClusterID: job.ClusterID, ProcID: procID, Schedd: s.Name}.String()
+	note.Owner = job.Owner
+	note.Group = job.Group
+	s.Notifier.Notify(note)
+}
+
+// wantsNotification returns whether a job whose notify setting is setting wants note
+func wantsNotification(setting string, note notify.Notification) bool {
+	switch setting {
+	case db.NotifyAlways:
+		return true
+	case db.NotifyComplete:
+		return note.Event == notify.EventCompleted
+	case db.NotifyError:
+		return note.Event == notify.EventCompleted && note.ExitCode != nil && *note.ExitCode != 0
+	}
+	return false
+}
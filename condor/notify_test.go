@@ -0,0 +1,144 @@
+package condor
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/notify"
+)
+
+// recordingSink records the notifications delivered to it
+type recordingSink struct {
+	mu    sync.Mutex
+	notes []notify.Notification
+}
+
+func (r *recordingSink) Name() string { return "recording" }
+
+func (r *recordingSink) Deliver(_ context.Context, n notify.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes = append(r.notes, n)
+	return nil
+}
+
+// events returns the job ID and event of each notification, in order.  Notifications are delivered in the background, so
+// those sent at the same time may be delivered in any order
+func (r *recordingSink) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]string, 0, len(r.notes))
+	for _, n := range r.notes {
+		events = append(events, n.JobID+" "+n.Event)
+	}
+	return events
+}
+
+func TestWantsNotification(t *testing.T) {
+	ok, failed := 0, 1
+	tests := []struct {
+		setting  string
+		note     notify.Notification
+		expected bool
+	}{
+		{"", notify.Notification{Event: notify.EventCompleted, ExitCode: &ok}, false},
+		{db.NotifyNever, notify.Notification{Event: notify.EventCompleted, ExitCode: &failed}, false},
+		{db.NotifyComplete, notify.Notification{Event: notify.EventCompleted, ExitCode: &ok}, true},
+		{db.NotifyComplete, notify.Notification{Event: notify.EventRetried, ExitCode: &failed}, false},
+		{db.NotifyComplete, notify.Notification{Event: notify.EventRemoved}, false},
+		{db.NotifyError, notify.Notification{Event: notify.EventCompleted, ExitCode: &ok}, false},
+		{db.NotifyError, notify.Notification{Event: notify.EventCompleted, ExitCode: &failed}, true},
+		{db.NotifyAlways, notify.Notification{Event: notify.EventStarted}, true},
+		{db.NotifyAlways, notify.Notification{Event: notify.EventHeld}, true},
+	}
+	for _, test := range tests {
+		if got := wantsNotification(test.setting, test.note); got != test.expected {
+			t.Errorf("Expected %t for %q and %+v.  Got %t instead", test.expected, test.setting, test.note, got)
+		}
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	sink := &recordingSink{}
+	s.Notifier = &notify.Notifier{Sinks: []notify.Sink{sink}}
+
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{{Name: "SiteA", Slots: 4, Resources: config.Resources{MemoryMB: 4000, DiskKB: 1000000, CPUs: 4}}}})
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}}
+
+	// Cluster 1 is notified of everything, cluster 2 only of errors, and cluster 3 of nothing.  The jobs of cluster 2 fail, are
+	// retried, and fail again
+	start := time.Unix(1700000000, 0)
+	jobs := []db.Job{
+		{ClusterID: 1, Num: 1, Owner: "alice", Group: "nova", MemoryMB: 1000, CPUs: 1, Runtime: time.Minute, QDate: start, Notify: db.NotifyAlways, SimExitCodes: []int{2}},
+		{ClusterID: 2, Num: 2, Owner: "bob", Group: "dune", MemoryMB: 1000, CPUs: 1, Runtime: time.Minute, QDate: start, Notify: db.NotifyError, MaxRetries: 1, SimExitCodes: []int{1, 3}},
+		{ClusterID: 3, Num: 1, Owner: "carol", Group: "nova", MemoryMB: 1000, CPUs: 1, Runtime: time.Minute, QDate: start, Notify: db.NotifyNever, SimExitCodes: []int{1}},
+	}
+	for _, j := range jobs {
+		if err := s.db.InsertJobIntoDB(j); err != nil {
+			t.Fatalf("Could not create row in test db: %s", err.Error())
+		}
+	}
+	for i := range 3 {
+		if _, err := n.Cycle(start.Add(time.Duration(i) * 2 * time.Minute)); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		s.Notifier.Wait(context.Background())
+	}
+	expected := []string{"1.0@test1 started", "1.0@test1 completed", "2.0@test1 completed", "2.1@test1 completed"}
+	got := sink.events()
+	if len(got) != len(expected) || !slices.Equal(got[:2], expected[:2]) || !slices.Equal(slices.Sorted(slices.Values(got[2:])), expected[2:]) {
+		t.Errorf("Expected notifications %v.  Got %v instead", expected, got)
+	}
+	for _, note := range sink.notes {
+		if note.Event != notify.EventCompleted {
+			continue
+		}
+		if note.JobID == "1.0@test1" && (note.Owner != "alice" || note.Group != "nova" || *note.ExitCode != 2) ||
+			note.JobID != "1.0@test1" && (note.Owner != "bob" || *note.ExitCode != 3) {
+			t.Errorf("Got wrong notification %+v", note)
+		}
+	}
+
+	t.Run("job control", func(t *testing.T) {
+		sink.notes = nil
+		if err := s.db.InsertJobIntoDB(db.Job{ClusterID: 4, Num: 2, Owner: "alice", Group: "nova", Notify: db.NotifyAlways}); err != nil {
+			t.Fatal(err)
+		}
+		alice := Requester{User: "alice"}
+		if _, err := s.Hold(alice, JobID{ClusterID: 4, ProcID: 1, Schedd: s.Name}); err != nil {
+			t.Fatal(err)
+		}
+		s.Notifier.Wait(context.Background())
+		if _, err := s.Release(alice, JobID{ClusterID: 4, ProcID: 1, Schedd: s.Name}); err != nil {
+			t.Fatal(err)
+		}
+		s.Notifier.Wait(context.Background())
+		if _, err := s.Remove(alice, JobID{ClusterID: 4, ProcID: db.AllProcs, Schedd: s.Name}); err != nil {
+			t.Fatal(err)
+		}
+		s.Notifier.Wait(context.Background())
+
+		got := sink.events()
+		expected := []string{"4.1@test1 held", "4.1@test1 released"}
+		if len(got) != 4 || !slices.Equal(got[:2], expected) {
+			t.Fatalf("Expected notifications %v, then removal of both jobs.  Got %v instead", expected, got)
+		}
+		if removed := got[2:]; !slices.Contains(removed, "4.0@test1 removed") || !slices.Contains(removed, "4.1@test1 removed") {
+			t.Errorf("Expected both jobs to be removed.  Got %v instead", removed)
+		}
+		if sink.notes[0].Reason != "by user alice" {
+			t.Errorf("Expected reason \"by user alice\".  Got %q instead", sink.notes[0].Reason)
+		}
+	})
+}
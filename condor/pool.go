@@ -0,0 +1,92 @@
+package condor
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+// Slot is an execute slot in the simulated pool.  Each slot runs at most one job at a time
+type Slot struct {
+	Name        string // Like slot3@FermiGrid
+	Site        string
+	CPUs        int
+	MemoryMB    int
+	DiskKB      int
+	GPUs        int
+	MaxLifetime time.Duration // Zero means no limit
+}
+
+// Pool is the simulated pool of execute slots
+type Pool struct {
+	Slots []Slot
+}
+
+// NewPool creates the pool described by cfg
+func NewPool(cfg config.PoolConfig) *Pool {
+	p := &Pool{Slots: make([]Slot, 0)}
+	for _, site := range cfg.Sites {
+		for idx := range site.Slots {
+			p.Slots = append(p.Slots, Slot{
+				Name:        fmt.Sprintf("slot%d@%s", idx+1, site.Name),
+				Site:        site.Name,
+				CPUs:        site.CPUs,
+				MemoryMB:    site.MemoryMB,
+				DiskKB:      site.DiskKB,
+				GPUs:        site.GPUs,
+				MaxLifetime: time.Duration(site.Lifetime),
+			})
+		}
+	}
+	return p
+}
+
+// requirement is one clause of the requirements that a slot must satisfy to run a job
+type requirement struct {
+	name    string
+	matches func(Slot, db.Job) bool
+}
+
+// requirements are the clauses of every job's requirements, written like HTCondor expressions
+var requirements = []requirement{
+	{"TARGET.Cpus >= RequestCpus", func(s Slot, j db.Job) bool { return s.CPUs >= j.CPUs }},
+	{"TARGET.Memory >= RequestMemory", func(s Slot, j db.Job) bool { return s.MemoryMB >= j.MemoryMB }},
+	{"TARGET.Disk >= RequestDisk", func(s Slot, j db.Job) bool { return s.DiskKB >= j.DiskKB }},
+	{"TARGET.GPUs >= RequestGPUs", func(s Slot, j db.Job) bool { return s.GPUs >= j.GPUs }},
+	{"TARGET.MaxJobLifetime >= ExpectedLifetime", func(s Slot, j db.Job) bool { return s.MaxLifetime == 0 || s.MaxLifetime >= j.Lifetime }},
+	{"member(TARGET.Site, DesiredSites)", func(s Slot, j db.Job) bool { return len(j.Sites) == 0 || slices.Contains(j.Sites, s.Site) }},
+}
+
+// Matches reports whether the slot satisfies all of the job's requirements
+func (s Slot) Matches(j db.Job) bool {
+	for _, r := range requirements {
+		if !r.matches(s, j) {
+			return false
+		}
+	}
+	return true
+}
+
+// explain returns why the job can't be matched to any slot that isn't busy
+func (p *Pool) explain(j db.Job, busy map[string]bool) string {
+	matching := 0
+	for _, s := range p.Slots {
+		if s.Matches(j) {
+			matching++
+		}
+	}
+	if matching > 0 {
+		return fmt.Sprintf("all %d matching slots are busy", matching)
+	}
+
+	// Nothing matches.  If there's a clause that no slot satisfies, blame that
+	for _, r := range requirements {
+		if !slices.ContainsFunc(p.Slots, func(s Slot) bool { return r.matches(s, j) }) {
+			return "no slot in the pool satisfies " + r.name
+		}
+	}
+	return "no slot in the pool satisfies all of the job's requirements together"
+}
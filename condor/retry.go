@@ -0,0 +1,43 @@
+package condor
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+// maxRetryDelay caps how long a job backs off before it is retried
+const maxRetryDelay = 24 * time.Hour
+
+// shouldRetry reports whether the proc, which just completed, should be put back in the queue under its cluster's retry policy
+func shouldRetry(p db.Proc) bool {
+	return p.ExitCode != 0 && p.Attempts <= p.MaxRetries && (len(p.RetryExitCodes) == 0 || slices.Contains(p.RetryExitCodes, p.ExitCode))
+}
+
+// retryDelay returns how long the cluster's jobs wait before their nth retry:  the backoff doubles with each retry
+func retryDelay(j db.Job, n int) time.Duration {
+	delay := j.RetryBackoff
+	for range n - 1 {
+		if delay >= maxRetryDelay {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// backoffReason is why the idle proc, which is being retried, isn't matched before p.RetryAfter
+func backoffReason(p db.Proc) string {
+	return fmt.Sprintf("backing off until %s before retry %d of %d after exit code %d", p.RetryAfter.Format(time.DateTime), p.Attempts, p.MaxRetries, p.ExitCode)
+}
+
+// retry puts the proc, which just completed, back in the queue if its cluster's retry policy says to.  It returns whether it did
+func (s *Schedd) retry(p db.Proc) (bool, error) {
+	if !shouldRetry(p) {
+		return false, nil
+	}
+	p.RetryAfter = p.End.Add(retryDelay(p.Job, p.Attempts))
+	return s.db.RequeueProc(p.ClusterID, p.ProcID, p.RetryAfter, backoffReason(p))
+}
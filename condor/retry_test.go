@@ -0,0 +1,117 @@
+package condor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+func TestRetryDelay(t *testing.T) {
+	job := db.Job{RetryBackoff: time.Minute}
+	tests := []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{100, maxRetryDelay},
+	}
+	for _, test := range tests {
+		if d := retryDelay(job, test.retry); d != test.expected {
+			t.Errorf("Expected retry %d to wait %s.  Got %s", test.retry, test.expected, d)
+		}
+	}
+}
+
+func TestNegotiatorRetry(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{
+		{Name: "SiteA", Slots: 2, Resources: config.Resources{MemoryMB: 4000, DiskKB: 1000000, CPUs: 4}},
+	}})
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}}
+
+	// Cluster 1 fails twice with a retryable exit code, then succeeds.  Cluster 2 fails with an exit code it isn't retried on
+	start := time.Unix(1700000000, 0)
+	jobs := []db.Job{
+		{ClusterID: 1, Num: 1, CPUs: 1, Runtime: time.Minute, QDate: start, MaxRetries: 2, RetryExitCodes: []int{3}, RetryBackoff: time.Minute, SimExitCodes: []int{3, 3, 0}},
+		{ClusterID: 2, Num: 1, CPUs: 1, Runtime: time.Minute, QDate: start, MaxRetries: 2, RetryExitCodes: []int{3}, SimExitCodes: []int{7}},
+	}
+	for _, j := range jobs {
+		if err := s.db.InsertJobIntoDB(j); err != nil {
+			t.Fatalf("Could not create row in test db: %s", err.Error())
+		}
+	}
+
+	cycle := func(now time.Time, expected CycleResult) {
+		t.Helper()
+		result, err := n.Cycle(now)
+		if err != nil || result != expected {
+			t.Fatalf("Expected %+v and nil error.  Got %+v, %v", expected, result, err)
+		}
+	}
+	proc := func(clusterID int) db.Proc {
+		t.Helper()
+		p, err := s.db.GetProc(clusterID, 0)
+		if err != nil {
+			t.Fatalf("Could not get proc: %s", err)
+		}
+		return p
+	}
+
+	t.Run("Failed job is retried after backing off", func(t *testing.T) {
+		cycle(start, CycleResult{Matched: 2})
+		now := start.Add(time.Minute)
+		cycle(now, CycleResult{Completed: 2, Retried: 1, Idle: 1})
+		p := proc(1)
+		if p.Status != db.Idle || p.Attempts != 1 || p.ExitCode != 3 || !p.RetryAfter.Equal(now.Add(time.Minute)) {
+			t.Errorf("Cluster 1 should be backing off after its first attempt.  Got %+v", p)
+		}
+		if !strings.HasPrefix(p.Reason, "backing off until") {
+			t.Errorf("Expected backoff reason.  Got %q", p.Reason)
+		}
+		if p := proc(2); p.Status != db.Completed || p.ExitCode != 7 || p.Attempts != 1 {
+			t.Errorf("Cluster 2 should not have been retried.  Got %+v", p)
+		}
+
+		now = now.Add(time.Minute)
+		cycle(now, CycleResult{Matched: 1})
+		now = now.Add(time.Minute)
+		cycle(now, CycleResult{Completed: 1, Retried: 1, Idle: 1})
+		if p := proc(1); !p.RetryAfter.Equal(now.Add(2 * time.Minute)) {
+			t.Errorf("Second retry should back off twice as long.  Got %s", p.RetryAfter.Sub(now))
+		}
+	})
+
+	t.Run("Job that succeeds is not retried", func(t *testing.T) {
+		now := start.Add(5 * time.Minute)
+		cycle(now, CycleResult{Matched: 1})
+		cycle(now.Add(time.Minute), CycleResult{Completed: 1})
+		if p := proc(1); p.Status != db.Completed || p.ExitCode != 0 || p.Attempts != 3 {
+			t.Errorf("Cluster 1 should have completed on its third attempt.  Got %+v", p)
+		}
+	})
+
+	t.Run("History lists finished jobs with their attempts", func(t *testing.T) {
+		listing, err := s.db.RetrieveHistoryFromDB(db.Filter{}, "clusterid", "attempts", "exitcode")
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		rows, err := listing.Collect()
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		expected := []string{"clusterid\tattempts\texitcode", "1\t3\t0", "2\t1\t7"}
+		if strings.Join(rows, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected %q, got %q", expected, rows)
+		}
+	})
+}
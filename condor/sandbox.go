@@ -0,0 +1,215 @@
+package condor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fakeJobsub/db"
+)
+
+// progressSteps is how many progress lines a job writes to its simulated stdout over its runtime
+const progressSteps = 10
+
+// generatedFileLines is how many lines of 64 hex digits each generated output file has
+const generatedFileLines = 64
+
+// clusterSpool returns the directory in the spool holding the cluster's output
+func (s *Schedd) clusterSpool(clusterID int) string {
+	return filepath.Join(s.spool, strconv.Itoa(clusterID))
+}
+
+// clusterLog returns the cluster's user log in the spool
+func (s *Schedd) clusterLog(clusterID int) string {
+	return filepath.Join(s.clusterSpool(clusterID), fmt.Sprintf("%d.log", clusterID))
+}
+
+// sandboxPrefix is the prefix of the names of the proc's files in the cluster's spool directory
+func sandboxPrefix(p db.Proc) string {
+	return fmt.Sprintf("%d.%d.", p.ClusterID, p.ProcID)
+}
+
+// sandboxFile is a file in a job's output sandbox
+type sandboxFile struct {
+	name    string
+	content []byte
+}
+
+// sandbox returns the output the proc has written as of now:  its stdout and stderr, and, if it has finished, the output files
+// it generates.  The content only depends on the proc and now, so it's the same every time
+func (s *Schedd) sandbox(p db.Proc, now time.Time) []sandboxFile {
+	finished := !now.Before(p.End)
+
+	var stdout strings.Builder
+	fmt.Fprintf(&stdout, "fakeJobsub simulated job %d.%d@%s\n", p.ClusterID, p.ProcID, s.Name)
+	fmt.Fprintf(&stdout, "Group %s, owner %s, role %s\n", p.Group, p.Owner, p.Role)
+	fmt.Fprintf(&stdout, "Started at %s in %s\n", p.Start.Format(time.DateTime), p.Slot)
+	for step := 1; step <= progressSteps; step++ {
+		at := p.Start.Add(p.End.Sub(p.Start) * time.Duration(step) / progressSteps)
+		if at.After(now) {
+			break
+		}
+		fmt.Fprintf(&stdout, "Step %d of %d done at %s\n", step, progressSteps, at.Format(time.DateTime))
+	}
+
+	var stderr strings.Builder
+	if finished {
+		fmt.Fprintf(&stdout, "Finished at %s with exit code %d\n", p.End.Format(time.DateTime), p.ExitCode)
+		if p.ExitCode != 0 {
+			fmt.Fprintf(&stderr, "Error: job exited with code %d\n", p.ExitCode)
+		}
+	}
+
+	prefix := sandboxPrefix(p)
+	files := []sandboxFile{
+		{prefix + "out", []byte(stdout.String())},
+		{prefix + "err", []byte(stderr.String())},
+	}
+	if finished {
+		for _, name := range p.OutputFiles {
+			files = append(files, sandboxFile{prefix + name, generatedContent(p, name)})
+		}
+	}
+	return files
+}
+
+// generatedContent returns the content of the output file called name that the proc generates
+func generatedContent(p db.Proc, name string) []byte {
+	var b strings.Builder
+	for line := range generatedFileLines {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d.%d/%s/%d", p.ClusterID, p.ProcID, name, line)))
+		b.WriteString(hex.EncodeToString(sum[:]) + "\n")
+	}
+	return []byte(b.String())
+}
+
+// writeSandbox writes the output of the proc, as of now, to the spool.  As with user logs, errors are only reported
+func (s *Schedd) writeSandbox(p db.Proc, now time.Time) {
+	if err := s.writeSandboxFiles(p, now); err != nil {
+		s.log().Warn("could not write output to spool", "job", fmt.Sprintf("%d.%d", p.ClusterID, p.ProcID), "error", err)
+	}
+}
+
+func (s *Schedd) writeSandboxFiles(p db.Proc, now time.Time) error {
+	if s.spool == "" {
+		return nil
+	}
+	dir := s.clusterSpool(p.ClusterID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, f := range s.sandbox(p, now) {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchLog writes a gzipped tar archive of the output of the job(s) identified by id to w, if r is allowed to see it.  The
+// archive holds the cluster's user log, and each job's stdout, stderr, and generated output files.  Only completed jobs have
+// output, unless partial is true, in which case the output that running jobs have written by now is included too.  It returns
+// the names of the files in the archive
+func (s *Schedd) FetchLog(r Requester, id JobID, partial bool, now time.Time, w io.Writer) ([]string, error) {
+	if _, err := s.lookupAndAuthorize(r, "fetch logs of", id); err != nil {
+		return nil, err
+	}
+	if s.spool == "" {
+		return nil, fmt.Errorf("schedd %s does not keep job output", s.Name)
+	}
+
+	procs, err := s.db.ClusterProcs(id.ClusterID)
+	if err != nil {
+		return nil, fmt.Errorf("could not find jobs in %s: %w", id, err)
+	}
+	withOutput := make([]db.Proc, 0, len(procs))
+	running := 0
+	for _, p := range procs {
+		if id.ProcID != db.AllProcs && p.ProcID != id.ProcID {
+			continue
+		}
+		switch {
+		case p.Status == db.Completed:
+			// Jobs that completed before there was a spool get their output now
+			if _, err := os.Stat(filepath.Join(s.clusterSpool(p.ClusterID), sandboxPrefix(p)+"out")); errors.Is(err, os.ErrNotExist) {
+				s.writeSandbox(p, p.End)
+			}
+			withOutput = append(withOutput, p)
+		case p.Status == db.Running && partial:
+			s.writeSandbox(p, now)
+			withOutput = append(withOutput, p)
+		case p.Status == db.Running:
+			running++
+		}
+	}
+	if len(withOutput) == 0 {
+		if running > 0 {
+			return nil, fmt.Errorf("%s has no completed jobs.  Use --partial to fetch the output of its %d running job(s) so far", id, running)
+		}
+		return nil, fmt.Errorf("%s has no completed or running jobs, so there is no output", id)
+	}
+
+	// The cluster's log (which clusters submitted before there was a spool don't have), then each job's files
+	dir := s.clusterSpool(id.ClusterID)
+	names := make([]string, 0)
+	if _, err := os.Stat(s.clusterLog(id.ClusterID)); err == nil {
+		names = append(names, filepath.Base(s.clusterLog(id.ClusterID)))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool: %w", err)
+	}
+	for _, p := range withOutput {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), sandboxPrefix(p)) {
+				names = append(names, e.Name())
+			}
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		if err := addToTar(tw, filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("could not archive %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// addToTar adds the file at filename to tw, under its base name
+func addToTar(tw *tar.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
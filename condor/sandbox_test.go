@@ -0,0 +1,130 @@
+package condor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+// untar returns the contents of each file in the tar.gz archive b
+func untar(t *testing.T, b []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Could not read archive: %s", err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("Could not read archive: %s", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Could not read archive: %s", err)
+		}
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestFetchLog(t *testing.T) {
+	s := &Schedd{Name: "test1", spool: t.TempDir()}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+
+	start := time.Unix(1700000000, 0)
+	jobs := []db.Job{
+		{ClusterID: 1, Group: "nova", Num: 2, Owner: "alice", CPUs: 1, Runtime: 10 * time.Minute, OutputFiles: []string{"hist.root"}, QDate: start},
+		{ClusterID: 2, Group: "nova", Num: 1, Owner: "alice", CPUs: 1, Runtime: 10 * time.Minute, QDate: start.Add(time.Second)},
+	}
+	for _, j := range jobs {
+		if err := s.db.InsertJobIntoDB(j); err != nil {
+			t.Fatalf("Could not create row in test db: %s", err.Error())
+		}
+	}
+
+	// One slot, so 1.0 runs and completes, and then 1.1 runs, while cluster 2 waits
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{{Name: "SiteA", Slots: 1, Resources: config.Resources{CPUs: 1}}}})
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}}
+	for _, at := range []time.Time{start, start.Add(10 * time.Minute)} {
+		if _, err := n.Cycle(at); err != nil {
+			t.Fatalf("Could not run negotiation cycle: %s", err)
+		}
+	}
+
+	alice := Requester{User: "alice"}
+	cluster1 := JobID{ClusterID: 1, ProcID: db.AllProcs, Schedd: s.Name}
+	now := start.Add(15 * time.Minute)
+
+	t.Run("Test 1: Completed jobs only", func(t *testing.T) {
+		var b bytes.Buffer
+		names, err := s.FetchLog(alice, cluster1, false, now, &b)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		expected := []string{"1.log", "1.0.err", "1.0.hist.root", "1.0.out"}
+		if !slices.Equal(names, expected) {
+			t.Errorf("Expected files %v, got %v", expected, names)
+		}
+		files := untar(t, b.Bytes())
+		if !strings.Contains(files["1.0.out"], "Finished at") || strings.Count(files["1.0.out"], "Step ") != progressSteps {
+			t.Errorf("Got wrong stdout: %s", files["1.0.out"])
+		}
+		if files["1.0.hist.root"] != string(generatedContent(db.Proc{Job: db.Job{ClusterID: 1}, ProcID: 0}, "hist.root")) {
+			t.Error("Generated file content should be deterministic")
+		}
+		if !strings.Contains(files["1.log"], "Job terminated.") {
+			t.Errorf("Got wrong user log: %s", files["1.log"])
+		}
+	})
+
+	t.Run("Test 2: Partial output of running jobs", func(t *testing.T) {
+		var b bytes.Buffer
+		names, err := s.FetchLog(alice, JobID{ClusterID: 1, ProcID: 1, Schedd: s.Name}, true, now, &b)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if !slices.Equal(names, []string{"1.log", "1.1.err", "1.1.out"}) {
+			t.Errorf("Got wrong files: %v", names)
+		}
+		out := untar(t, b.Bytes())["1.1.out"]
+		if strings.Contains(out, "Finished at") || strings.Count(out, "Step ") != progressSteps/2 {
+			t.Errorf("Got wrong partial stdout: %s", out)
+		}
+	})
+
+	t.Run("Test 3: Running jobs need --partial", func(t *testing.T) {
+		_, err := s.FetchLog(alice, JobID{ClusterID: 1, ProcID: 1, Schedd: s.Name}, false, now, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "--partial") {
+			t.Errorf("Should have gotten error suggesting --partial.  Got %v instead", err)
+		}
+	})
+
+	t.Run("Test 4: Idle jobs have no output", func(t *testing.T) {
+		_, err := s.FetchLog(alice, JobID{ClusterID: 2, ProcID: db.AllProcs, Schedd: s.Name}, true, now, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "no output") {
+			t.Errorf("Should have gotten error indicating there is no output.  Got %v instead", err)
+		}
+	})
+
+	t.Run("Test 5: Other users can't fetch logs", func(t *testing.T) {
+		if _, err := s.FetchLog(Requester{User: "bob"}, cluster1, false, now, io.Discard); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Should have gotten ErrPermissionDenied.  Got %v instead", err)
+		}
+	})
+}
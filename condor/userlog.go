@@ -0,0 +1,44 @@
+package condor
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"fakeJobsub/db"
+	"fakeJobsub/userlog"
+)
+
+// sinful returns an HTCondor "sinful string" address for the named daemon, for user log events
+func sinful(alias string) string {
+	return fmt.Sprintf("<127.0.0.1:9618?alias=%s>", alias)
+}
+
+// logEvents appends events to the cluster's user log in the spool, and to job's own user log, if it has one.  As in HTCondor,
+// failing to write to a user log doesn't undo whatever caused the events, so errors are only reported
+func (s *Schedd) logEvents(job db.Job, events ...userlog.Event) {
+	if len(events) == 0 {
+		return
+	}
+	logs := make([]string, 0, 2)
+	if s.spool != "" {
+		if err := os.MkdirAll(s.clusterSpool(job.ClusterID), 0o755); err != nil {
+			s.log().Warn("could not create spool directory", "cluster", job.ClusterID, "error", err)
+		} else {
+			logs = append(logs, s.clusterLog(job.ClusterID))
+		}
+	}
+	if job.Log != "" {
+		logs = append(logs, job.Log)
+	}
+	for _, l := range logs {
+		if err := userlog.Append(l, events...); err != nil {
+			s.log().Warn("could not write user log", "cluster", job.ClusterID, "log", l, "error", err)
+		}
+	}
+}
+
+// header returns the user log event header for proc procID of job at t
+func header(job db.Job, procID int, t time.Time) userlog.EventHeader {
+	return userlog.EventHeader{Cluster: job.ClusterID, Proc: procID, Time: t}
+}
@@ -0,0 +1,77 @@
+package condor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/userlog"
+)
+
+func TestUserLog(t *testing.T) {
+	s := &Schedd{Name: "test1"}
+	d, err := db.CreateOrOpenDB(s.getFilename(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Could not create test db: %s", err.Error())
+	}
+	s.db = d
+
+	dir := t.TempDir()
+	if _, err := s.SubmitJob(db.Job{Group: "nova", Num: 2, Owner: "alice", CPUs: 1, Runtime: time.Minute, Log: filepath.Join(dir, "job_$(Cluster).log")}); err != nil {
+		t.Fatalf("Could not submit job: %s", err)
+	}
+	logFile := filepath.Join(dir, "job_1.log")
+
+	alice := Requester{User: "alice"}
+	if _, err := s.Hold(alice, JobID{ClusterID: 1, ProcID: 1, Schedd: s.Name}); err != nil {
+		t.Fatalf("Could not hold job: %s", err)
+	}
+	if _, err := s.Release(alice, JobID{ClusterID: 1, ProcID: 1, Schedd: s.Name}); err != nil {
+		t.Fatalf("Could not release job: %s", err)
+	}
+
+	// Only one slot, so proc 0 runs and finishes, and then proc 1 runs until it is removed
+	pool := NewPool(config.PoolConfig{Sites: []config.SiteConfig{{Name: "SiteA", Slots: 1, Resources: config.Resources{CPUs: 1}}}})
+	n := &Negotiator{Pool: pool, Schedds: []*Schedd{s}}
+	now := time.Now()
+	for _, at := range []time.Time{now, now.Add(time.Minute)} {
+		if _, err := n.Cycle(at); err != nil {
+			t.Fatalf("Could not run negotiation cycle: %s", err)
+		}
+	}
+	if _, err := s.Remove(alice, JobID{ClusterID: 1, ProcID: db.AllProcs, Schedd: s.Name}); err != nil {
+		t.Fatalf("Could not remove job: %s", err)
+	}
+
+	events, err := userlog.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Could not read user log: %s", err)
+	}
+	type event struct {
+		code userlog.Code
+		proc int
+	}
+	got := make([]event, 0)
+	for _, e := range events {
+		got = append(got, event{e.Code(), e.Header().Proc})
+	}
+	expected := []event{
+		{userlog.CodeSubmit, 0},
+		{userlog.CodeSubmit, 1},
+		{userlog.CodeHeld, 1},
+		{userlog.CodeReleased, 1},
+		{userlog.CodeExecute, 0},
+		{userlog.CodeTerminated, 0},
+		{userlog.CodeExecute, 1},
+		{userlog.CodeAborted, 1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected events %v, got %v", expected, got)
+	}
+	if e, ok := events[4].(userlog.ExecuteEvent); !ok || e.SlotName != "slot1@SiteA" {
+		t.Errorf("Got wrong execute event: %+v", events[4])
+	}
+}
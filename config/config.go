@@ -0,0 +1,382 @@
+// Package config holds the configuration of the fake batch system:  which schedds exist, and which
+// groups (experiments/VOs) are allowed to submit to them, and how
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EnvVar is the environment variable that, if set, points to a JSON configuration file to use instead of the
+// built-in configuration
+const EnvVar = "FAKEJOBSUB_CONFIG"
+
+// Roles that jobs can be submitted with, modeled on jobsub_lite's --role
+const (
+	RoleAnalysis   = "Analysis"
+	RoleProduction = "Production"
+)
+
+// Config is the configuration of the fake batch system
+type Config struct {
+	Schedds    []ScheddConfig   `json:"schedds"`
+	Groups     []GroupConfig    `json:"groups"`
+	Auth       AuthConfig       `json:"auth"`
+	Pool       PoolConfig       `json:"pool"`
+	Accounting AccountingConfig `json:"accounting"`
+
+	Notifications NotificationConfig `json:"notifications"`
+}
+
+// AccountingConfig configures fair-share:  how the usage recorded for each group decays, and how it turns into the group's priority
+type AccountingConfig struct {
+	HalfLife       Duration `json:"half_life"`       // How long it takes recorded usage to decay by half.  Zero means DefaultAccounting.HalfLife
+	PriorityFactor float64  `json:"priority_factor"` // The priority factor of groups that don't set their own.  Zero means DefaultAccounting.PriorityFactor
+	Admins         []string `json:"admins"`          // Users who may change priority factors and reset usage
+}
+
+// DefaultAccounting holds the accounting defaults.  They match HTCondor's PRIORITY_HALFLIFE and DEFAULT_PRIO_FACTOR
+var DefaultAccounting = AccountingConfig{
+	HalfLife:       Duration(24 * time.Hour),
+	PriorityFactor: 1000,
+}
+
+// Or returns a, with any zero values replaced by those in defaults
+func (a AccountingConfig) Or(defaults AccountingConfig) AccountingConfig {
+	if a.HalfLife == 0 {
+		a.HalfLife = defaults.HalfLife
+	}
+	if a.PriorityFactor == 0 {
+		a.PriorityFactor = defaults.PriorityFactor
+	}
+	if a.Admins == nil {
+		a.Admins = defaults.Admins
+	}
+	return a
+}
+
+// NotificationConfig configures where notifications about jobs submitted with --notify are delivered, and how failed deliveries
+// are retried.  If no sinks are configured, notifications are appended to an mbox file in the temporary directory
+type NotificationConfig struct {
+	Command      []string `json:"command"`       // Command run for each notification, with it as JSON on stdin
+	WebhookURL   string   `json:"webhook_url"`   // URL each notification is POSTed to as JSON
+	Mbox         string   `json:"mbox"`          // mbox file each notification is appended to as an email to the job's owner
+	Retries      int      `json:"retries"`       // How many times a failed delivery is retried.  Zero means 3; use -1 for no retries
+	RetryBackoff Duration `json:"retry_backoff"` // How long to wait before the first retry, doubling with each.  Zero means 1s
+	DeadLetter   string   `json:"dead_letter"`   // File that deliveries that failed every retry are appended to.  Blank means one in the temporary directory
+}
+
+// PoolConfig describes the simulated pool of execute slots that jobs run in
+type PoolConfig struct {
+	Sites []SiteConfig `json:"sites"`
+}
+
+// SiteConfig describes a site in the pool, which has a number of identical slots
+type SiteConfig struct {
+	Name  string `json:"name"`
+	Slots int    `json:"slots"`
+	// The resources of each slot.  Lifetime is the longest a job may run at the site.  Zero values mean none of
+	// that resource (or for Lifetime, no limit)
+	Resources
+}
+
+// AuthConfig configures how tokens are issued and verified.  Blank values mean that the defaults in the token package are used
+type AuthConfig struct {
+	Issuer  string `json:"issuer"`
+	KeyFile string `json:"key_file"` // The Ed25519 signing key, in PKCS #8 PEM format
+}
+
+// ScheddConfig is the configuration for a single schedd
+type ScheddConfig struct {
+	Name   string    `json:"name"`
+	Limits Resources `json:"limits"` // The most a single job submitted to the schedd may request.  Zero values mean no limit
+}
+
+// GroupConfig is the registry entry for a single group (experiment/VO)
+type GroupConfig struct {
+	Name           string        `json:"name"`
+	AllowedSchedds []string      `json:"allowed_schedds"` // If empty, all configured schedds are allowed
+	AllowedRoles   []string      `json:"allowed_roles"`   // If empty, only RoleAnalysis is allowed
+	Superusers     []string      `json:"superusers"`      // Users who may act on any of the group's jobs
+	Defaults       GroupDefaults `json:"defaults"`
+	PriorityFactor float64       `json:"priority_factor"` // Multiplies the group's real priority.  Zero means the accounting default
+	MaxRunning     int           `json:"max_running"`     // The most jobs the group may have running in the pool at once.  Zero means no limit
+}
+
+// GroupDefaults are attributes stamped on a group's jobs when they are not given at submit time.  Zero resource values mean
+// that DefaultResources are used
+type GroupDefaults struct {
+	Role string `json:"role"`
+	Resources
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	bothRoles := []string{RoleAnalysis, RoleProduction}
+	return &Config{
+		Schedds: []ScheddConfig{
+			{Name: "schedd1", Limits: Resources{MemoryMB: 32000, DiskKB: 100 * 1024 * 1024, CPUs: 16, GPUs: 4, Lifetime: Duration(96 * time.Hour)}},
+			{Name: "schedd2", Limits: Resources{MemoryMB: 16000, DiskKB: 50 * 1024 * 1024, CPUs: 8, GPUs: 1, Lifetime: Duration(48 * time.Hour)}},
+		},
+		Groups: []GroupConfig{
+			{Name: "fermilab", AllowedRoles: bothRoles},
+			{Name: "nova", AllowedRoles: bothRoles, Superusers: []string{"novapro"}},
+			{Name: "dune", AllowedRoles: bothRoles, Superusers: []string{"dunepro"}},
+			{Name: "mu2e", AllowedSchedds: []string{"schedd1"}, AllowedRoles: bothRoles, Superusers: []string{"mu2epro"}},
+			{Name: "uboone", AllowedSchedds: []string{"schedd2"}, AllowedRoles: bothRoles, Superusers: []string{"uboonepro"}},
+			{Name: "minerva", AllowedRoles: []string{RoleAnalysis}, Defaults: GroupDefaults{Resources: Resources{MemoryMB: 1000}}, MaxRunning: 5},
+		},
+		Pool: PoolConfig{
+			Sites: []SiteConfig{
+				{Name: "FermiGrid", Slots: 20, Resources: Resources{MemoryMB: 16000, DiskKB: 100 * 1024 * 1024, CPUs: 8}},
+				{Name: "Wisconsin", Slots: 10, Resources: Resources{MemoryMB: 8000, DiskKB: 50 * 1024 * 1024, CPUs: 4, Lifetime: Duration(24 * time.Hour)}},
+				{Name: "Nebraska", Slots: 4, Resources: Resources{MemoryMB: 32000, DiskKB: 100 * 1024 * 1024, CPUs: 8, GPUs: 2, Lifetime: Duration(48 * time.Hour)}},
+			},
+		},
+		Accounting: AccountingConfig{Admins: []string{"admin"}},
+	}
+}
+
+// Load reads the configuration from the JSON file at filename.  If filename is empty, the built-in configuration is returned
+func Load(filename string) (*Config, error) {
+	if filename == "" {
+		return Default(), nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	c := new(Config)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", filename, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// validate checks that the configuration is self-consistent
+func (c *Config) validate() error {
+	if len(c.Schedds) == 0 {
+		return errors.New("no schedds configured")
+	}
+	scheddNames := c.ScheddNames()
+	for _, s := range c.Schedds {
+		if err := s.Limits.validate(); err != nil {
+			return fmt.Errorf("schedd %s limits: %w", s.Name, err)
+		}
+	}
+	groupNames := make([]string, 0, len(c.Groups))
+	for _, g := range c.Groups {
+		switch {
+		case g.Name == "":
+			return errors.New("group with empty name")
+		case slices.Contains(groupNames, g.Name):
+			return fmt.Errorf("group %s is configured more than once", g.Name)
+		}
+		groupNames = append(groupNames, g.Name)
+		for _, r := range g.AllowedRoles {
+			if r != RoleAnalysis && r != RoleProduction {
+				return fmt.Errorf("group %s allows unknown role %s.  Known roles are %v", g.Name, r, []string{RoleAnalysis, RoleProduction})
+			}
+		}
+		for _, s := range g.AllowedSchedds {
+			if !slices.Contains(scheddNames, s) {
+				return fmt.Errorf("group %s allows unknown schedd %s", g.Name, s)
+			}
+		}
+		if g.Defaults.Role != "" {
+			if _, err := g.CanonicalRole(g.Defaults.Role); err != nil {
+				return fmt.Errorf("group %s default role: %w", g.Name, err)
+			}
+		}
+		if err := g.Defaults.Resources.validate(); err != nil {
+			return fmt.Errorf("group %s defaults: %w", g.Name, err)
+		}
+		if g.PriorityFactor < 0 {
+			return fmt.Errorf("group %s has a negative priority factor", g.Name)
+		}
+		if g.MaxRunning < 0 {
+			return fmt.Errorf("group %s has a negative max_running", g.Name)
+		}
+	}
+	siteNames := make([]string, 0, len(c.Pool.Sites))
+	for _, site := range c.Pool.Sites {
+		switch {
+		case site.Name == "" || strings.ContainsAny(site.Name, ",@"):
+			return fmt.Errorf("invalid site name %q", site.Name)
+		case slices.Contains(siteNames, site.Name):
+			return fmt.Errorf("site %s is configured more than once", site.Name)
+		case site.Slots < 0:
+			return fmt.Errorf("site %s has a negative number of slots", site.Name)
+		}
+		if err := site.Resources.validate(); err != nil {
+			return fmt.Errorf("site %s: %w", site.Name, err)
+		}
+		siteNames = append(siteNames, site.Name)
+	}
+	if c.Accounting.HalfLife < 0 || c.Accounting.PriorityFactor < 0 {
+		return errors.New("accounting half-life and priority factor must not be negative")
+	}
+	if err := c.Notifications.validate(); err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	return nil
+}
+
+func (n NotificationConfig) validate() error {
+	if n.Command != nil && (len(n.Command) == 0 || n.Command[0] == "") {
+		return errors.New("command must not be empty")
+	}
+	if n.WebhookURL != "" {
+		u, err := url.Parse(n.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook_url %q.  It must be an http or https URL", n.WebhookURL)
+		}
+	}
+	if n.Retries < -1 {
+		return errors.New("retries must be -1 or more")
+	}
+	if n.RetryBackoff < 0 {
+		return errors.New("retry_backoff must not be negative")
+	}
+	return nil
+}
+
+// Quotas returns the MaxRunning of each group that has one
+func (c *Config) Quotas() map[string]int {
+	quotas := make(map[string]int)
+	for _, g := range c.Groups {
+		if g.MaxRunning > 0 {
+			quotas[g.Name] = g.MaxRunning
+		}
+	}
+	return quotas
+}
+
+// PriorityFactor returns the configured priority factor of the group called name
+func (c *Config) PriorityFactor(name string) float64 {
+	for _, g := range c.Groups {
+		if g.Name == name && g.PriorityFactor > 0 {
+			return g.PriorityFactor
+		}
+	}
+	return c.Accounting.Or(DefaultAccounting).PriorityFactor
+}
+
+// SiteNames returns the names of all sites in the pool, in configuration order
+func (c *Config) SiteNames() []string {
+	names := make([]string, 0, len(c.Pool.Sites))
+	for _, s := range c.Pool.Sites {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// ScheddNames returns the names of all configured schedds, in configuration order
+func (c *Config) ScheddNames() []string {
+	names := make([]string, 0, len(c.Schedds))
+	for _, s := range c.Schedds {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Schedd looks up the schedd called name
+func (c *Config) Schedd(name string) (*ScheddConfig, error) {
+	for idx := range c.Schedds {
+		if c.Schedds[idx].Name == name {
+			return &c.Schedds[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v", name, c.ScheddNames())
+}
+
+// Group looks up the group called name in the registry.  If there is no such group, an *UnknownGroupError
+// is returned with suggestions for similarly-named groups
+func (c *Config) Group(name string) (*GroupConfig, error) {
+	for idx := range c.Groups {
+		if c.Groups[idx].Name == name {
+			return &c.Groups[idx], nil
+		}
+	}
+
+	names := make([]string, 0, len(c.Groups))
+	for _, g := range c.Groups {
+		names = append(names, g.Name)
+	}
+	return nil, &UnknownGroupError{Name: name, Suggestions: suggest(name, names)}
+}
+
+// Schedds returns the schedds the group may submit to, in configuration order
+func (g *GroupConfig) Schedds(c *Config) []string {
+	all := c.ScheddNames()
+	if len(g.AllowedSchedds) == 0 {
+		return all
+	}
+	allowed := make([]string, 0, len(g.AllowedSchedds))
+	for _, s := range all {
+		if slices.Contains(g.AllowedSchedds, s) {
+			allowed = append(allowed, s)
+		}
+	}
+	return allowed
+}
+
+// Roles returns the roles the group's jobs may be submitted with
+func (g *GroupConfig) Roles() []string {
+	if len(g.AllowedRoles) == 0 {
+		return []string{RoleAnalysis}
+	}
+	return g.AllowedRoles
+}
+
+// DefaultRole returns the role to use when none is given at submit time
+func (g *GroupConfig) DefaultRole() string {
+	if r, err := g.CanonicalRole(g.Defaults.Role); err == nil {
+		return r
+	}
+	return g.Roles()[0]
+}
+
+// Resources returns the resources the group's jobs request by default
+func (g *GroupConfig) Resources() Resources {
+	return g.Defaults.Resources.Or(DefaultResources)
+}
+
+// CanonicalRole checks that role (matched case-insensitively) is allowed for the group, and returns it spelled as in the registry
+func (g *GroupConfig) CanonicalRole(role string) (string, error) {
+	for _, r := range g.Roles() {
+		if strings.EqualFold(r, role) {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("role %s is not allowed for group %s.  Allowed roles are %v", role, g.Name, g.Roles())
+}
+
+// UnknownGroupError is returned when a group is not in the registry
+type UnknownGroupError struct {
+	Name        string
+	Suggestions []string
+}
+
+func (e *UnknownGroupError) Error() string {
+	msg := fmt.Sprintf("unknown group %q", e.Name)
+	switch len(e.Suggestions) {
+	case 0:
+		return msg
+	case 1:
+		return fmt.Sprintf("%s.  Did you mean '%s'?", msg, e.Suggestions[0])
+	default:
+		return fmt.Sprintf("%s.  Did you mean one of '%s'?", msg, strings.Join(e.Suggestions, "', '"))
+	}
+}
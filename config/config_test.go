@@ -0,0 +1,295 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	if err := Default().validate(); err != nil {
+		t.Errorf("Default configuration should be valid.  Got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("builtin", func(t *testing.T) {
+		c, err := Load("")
+		if err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if !slices.Equal(c.ScheddNames(), []string{"schedd1", "schedd2"}) {
+			t.Errorf("Got wrong schedds from builtin configuration: %v", c.ScheddNames())
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "config.json")
+		contents := `{"schedds": [{"name": "scheddA"}], "groups": [{"name": "icarus", "defaults": {"role": "analysis"}}]}`
+		if err := os.WriteFile(fn, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := Load(fn)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		g, err := c.Group("icarus")
+		if err != nil {
+			t.Fatalf("Should have found group icarus.  Got %v instead", err)
+		}
+		if !slices.Equal(g.Schedds(c), []string{"scheddA"}) {
+			t.Errorf("Group with no allowed schedds should be able to use all schedds.  Got %v", g.Schedds(c))
+		}
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "config.json")
+		contents := `{"schedds": [{"name": "scheddA"}], "groups": [{"name": "icarus", "allowed_schedds": ["scheddB"]}]}`
+		if err := os.WriteFile(fn, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(fn); err == nil || !strings.Contains(err.Error(), "unknown schedd scheddB") {
+			t.Errorf("Should have gotten error indicating unknown schedd.  Got %v instead", err)
+		}
+	})
+}
+
+func TestLoadGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		contents  string
+		errSubstr string
+	}{
+		{"valid", `{"schedds": [{"name": "scheddA"}], "groups": [{"name": "icarus", "allowed_roles": ["Analysis", "Production"]}, {"name": "nova"}]}`, ""},
+		{"duplicate group", `{"schedds": [{"name": "scheddA"}], "groups": [{"name": "icarus"}, {"name": "nova"}, {"name": "icarus"}]}`, "group icarus is configured more than once"},
+		{"unknown role", `{"schedds": [{"name": "scheddA"}], "groups": [{"name": "icarus", "allowed_roles": ["Analysis", "Prodution"]}]}`, "group icarus allows unknown role Prodution"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(fn, []byte(test.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(fn)
+			if test.errSubstr == "" {
+				if err != nil {
+					t.Errorf("Should have gotten nil error.  Got %v instead", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.errSubstr) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.errSubstr, err)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	c := Default()
+
+	t.Run("known", func(t *testing.T) {
+		g, err := c.Group("mu2e")
+		if err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if !slices.Equal(g.Schedds(c), []string{"schedd1"}) {
+			t.Errorf("mu2e should only be allowed on schedd1.  Got %v", g.Schedds(c))
+		}
+	})
+
+	t.Run("unknown with suggestion", func(t *testing.T) {
+		_, err := c.Group("nvoa")
+		var unknownErr *UnknownGroupError
+		if !errors.As(err, &unknownErr) {
+			t.Fatalf("Should have gotten UnknownGroupError.  Got %v instead", err)
+		}
+		if !slices.Equal(unknownErr.Suggestions, []string{"nova"}) {
+			t.Errorf("Should have gotten nova as a suggestion.  Got %v", unknownErr.Suggestions)
+		}
+		if !strings.Contains(err.Error(), "Did you mean 'nova'?") {
+			t.Errorf("Error message should contain suggestion.  Got %s", err)
+		}
+	})
+
+	t.Run("unknown without suggestion", func(t *testing.T) {
+		_, err := c.Group("cms")
+		if err == nil || strings.Contains(err.Error(), "Did you mean") {
+			t.Errorf("Should have gotten error with no suggestion.  Got %v instead", err)
+		}
+	})
+}
+
+func TestCanonicalRole(t *testing.T) {
+	g := GroupConfig{Name: "test", AllowedRoles: []string{RoleAnalysis, RoleProduction}}
+	r, err := g.CanonicalRole("production")
+	if err != nil || r != RoleProduction {
+		t.Errorf("Should have gotten %s and nil error.  Got %s, %v instead", RoleProduction, r, err)
+	}
+
+	g = GroupConfig{Name: "test"}
+	if _, err := g.CanonicalRole(RoleProduction); err == nil {
+		t.Error("Group with no allowed roles should only allow Analysis role")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"nova", "nova", 0},
+		{"nvoa", "nova", 1},
+		{"dune", "dun", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if d := editDistance(test.a, test.b); d != test.expected {
+			t.Errorf("editDistance(%s, %s): expected %d, got %d", test.a, test.b, test.expected, d)
+		}
+	}
+}
+
+func TestResources(t *testing.T) {
+	limits := Resources{MemoryMB: 4000, GPUs: 1}
+	if err := limits.Allows(DefaultResources); err != nil {
+		t.Errorf("Default resources should be within limits.  Got %v", err)
+	}
+	r := DefaultResources
+	r.GPUs = 2
+	if err := limits.Allows(r); err == nil || !strings.Contains(err.Error(), "GPUs") {
+		t.Errorf("Should have gotten error indicating too many GPUs.  Got %v instead", err)
+	}
+
+	g := GroupConfig{Name: "test", Defaults: GroupDefaults{Resources: Resources{MemoryMB: 1000}}}
+	expected := DefaultResources
+	expected.MemoryMB = 1000
+	if g.Resources() != expected {
+		t.Errorf("Expected group resources %+v, got %+v", expected, g.Resources())
+	}
+}
+
+func TestLoadResources(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "config.json")
+	contents := `{"schedds": [{"name": "scheddA", "limits": {"memory_mb": 8000, "lifetime": "long"}}]}`
+	if err := os.WriteFile(fn, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(fn)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	s, err := c.Schedd("scheddA")
+	if err != nil {
+		t.Fatalf("Should have found scheddA.  Got %v instead", err)
+	}
+	if s.Limits.MemoryMB != 8000 || s.Limits.Lifetime != Duration(23*time.Hour+30*time.Minute) {
+		t.Errorf("Got wrong limits: %+v", s.Limits)
+	}
+
+	contents = `{"schedds": [{"name": "scheddA", "limits": {"lifetime": "forever"}}]}`
+	if err := os.WriteFile(fn, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(fn); err == nil {
+		t.Error("Should have gotten an error for an invalid lifetime")
+	}
+}
+
+func TestLoadPool(t *testing.T) {
+	tests := []struct {
+		name       string
+		pool       string
+		shouldFail bool
+	}{
+		{"valid", `{"sites": [{"name": "SiteA", "slots": 2, "cpus": 4}]}`, false},
+		{"duplicate site", `{"sites": [{"name": "SiteA", "slots": 2}, {"name": "SiteA", "slots": 1}]}`, true},
+		{"negative slots", `{"sites": [{"name": "SiteA", "slots": -1}]}`, true},
+		{"bad site name", `{"sites": [{"name": "Site@A", "slots": 1}]}`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "config.json")
+			contents := `{"schedds": [{"name": "scheddA"}], "pool": ` + test.pool + `}`
+			if err := os.WriteFile(fn, []byte(contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := Load(fn)
+			if test.shouldFail {
+				if err == nil {
+					t.Error("Should have gotten an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if sites := c.SiteNames(); len(sites) != 1 || sites[0] != "SiteA" {
+				t.Errorf("Got wrong sites: %v", sites)
+			}
+		})
+	}
+}
+
+func TestPriorityFactor(t *testing.T) {
+	c := Default()
+	c.Groups = append(c.Groups, GroupConfig{Name: "icarus", PriorityFactor: 500})
+	if f := c.PriorityFactor("icarus"); f != 500 {
+		t.Errorf("Expected priority factor 500 for icarus.  Got %g", f)
+	}
+	if f := c.PriorityFactor("nova"); f != DefaultAccounting.PriorityFactor {
+		t.Errorf("Expected default priority factor for nova.  Got %g", f)
+	}
+	c.Accounting.PriorityFactor = 100
+	if f := c.PriorityFactor("nova"); f != 100 {
+		t.Errorf("Expected configured default priority factor for nova.  Got %g", f)
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	quotas := Default().Quotas()
+	if len(quotas) != 1 || quotas["minerva"] != 5 {
+		t.Errorf("Expected only minerva to have a quota of 5.  Got %v", quotas)
+	}
+}
+
+func TestLoadNotifications(t *testing.T) {
+	tests := []struct {
+		name      string
+		contents  string
+		errSubstr string
+	}{
+		{"valid", `{"schedds": [{"name": "scheddA"}], "notifications": {"command": ["notify-send"], "webhook_url": "https://example.com/hook", "retries": -1, "retry_backoff": "5s"}}`, ""},
+		{"empty command", `{"schedds": [{"name": "scheddA"}], "notifications": {"command": []}}`, "command must not be empty"},
+		{"bad webhook", `{"schedds": [{"name": "scheddA"}], "notifications": {"webhook_url": "example.com/hook"}}`, "invalid webhook_url"},
+		{"bad retries", `{"schedds": [{"name": "scheddA"}], "notifications": {"retries": -2}}`, "retries must be -1 or more"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(fn, []byte(test.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := Load(fn)
+			if test.errSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errSubstr) {
+					t.Errorf("Should have gotten error containing %q.  Got %v instead", test.errSubstr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if c.Notifications.Retries != -1 || time.Duration(c.Notifications.RetryBackoff) != 5*time.Second {
+				t.Errorf("Got wrong notification config %+v", c.Notifications)
+			}
+		})
+	}
+}
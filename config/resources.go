@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"fakeJobsub/units"
+)
+
+// Resources are the resources a job requests, or the limits on them, in HTCondor's units
+type Resources struct {
+	MemoryMB int      `json:"memory_mb"`
+	DiskKB   int      `json:"disk_kb"`
+	CPUs     int      `json:"cpus"`
+	GPUs     int      `json:"gpus"`
+	Lifetime Duration `json:"lifetime"`
+}
+
+// DefaultResources are requested by jobs when neither the submitter nor the group's defaults say otherwise.  They match jobsub_lite's defaults
+var DefaultResources = Resources{
+	MemoryMB: 2000,
+	DiskKB:   10 * 1024 * 1024,
+	CPUs:     1,
+	GPUs:     0,
+	Lifetime: Duration(8 * time.Hour),
+}
+
+// Or returns r, with any zero values replaced by those in defaults
+func (r Resources) Or(defaults Resources) Resources {
+	if r.MemoryMB == 0 {
+		r.MemoryMB = defaults.MemoryMB
+	}
+	if r.DiskKB == 0 {
+		r.DiskKB = defaults.DiskKB
+	}
+	if r.CPUs == 0 {
+		r.CPUs = defaults.CPUs
+	}
+	if r.GPUs == 0 {
+		r.GPUs = defaults.GPUs
+	}
+	if r.Lifetime == 0 {
+		r.Lifetime = defaults.Lifetime
+	}
+	return r
+}
+
+// Allows checks that the request is within the limits in r.  Zero limits mean no limit
+func (r Resources) Allows(request Resources) error {
+	switch {
+	case r.MemoryMB > 0 && request.MemoryMB > r.MemoryMB:
+		return fmt.Errorf("requested memory %dMB exceeds the maximum of %dMB", request.MemoryMB, r.MemoryMB)
+	case r.DiskKB > 0 && request.DiskKB > r.DiskKB:
+		return fmt.Errorf("requested disk %dKB exceeds the maximum of %dKB", request.DiskKB, r.DiskKB)
+	case r.CPUs > 0 && request.CPUs > r.CPUs:
+		return fmt.Errorf("requested %d CPUs exceeds the maximum of %d", request.CPUs, r.CPUs)
+	case r.GPUs > 0 && request.GPUs > r.GPUs:
+		return fmt.Errorf("requested %d GPUs exceeds the maximum of %d", request.GPUs, r.GPUs)
+	case r.Lifetime > 0 && request.Lifetime > r.Lifetime:
+		return fmt.Errorf("requested lifetime %s exceeds the maximum of %s", request.Lifetime, r.Lifetime)
+	}
+	return nil
+}
+
+func (r Resources) validate() error {
+	if r.MemoryMB < 0 || r.DiskKB < 0 || r.CPUs < 0 || r.GPUs < 0 || r.Lifetime < 0 {
+		return errors.New("resource values must not be negative")
+	}
+	return nil
+}
+
+// Duration is a time.Duration that is given in JSON as a string that units.ParseLifetime understands, like "8h" or "long"
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"8h\": %w", err)
+	}
+	v, err := units.ParseLifetime(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// suggest returns the candidates that are "close" to name, closest first.  Closeness is measured by the
+// edit distance, and we allow roughly one edit for every three characters of name
+func suggest(name string, candidates []string) []string {
+	maxDistance := max(len(name)/3, 1)
+
+	type match struct {
+		candidate string
+		distance  int
+	}
+	matches := make([]match, 0)
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(c))
+		if d <= maxDistance {
+			matches = append(matches, match{c, d})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return a.distance - b.distance })
+
+	s := make([]string, 0, len(matches))
+	for _, m := range matches {
+		s = append(s, m.candidate)
+	}
+	return s
+}
+
+// editDistance computes the optimal string alignment distance between a and b:  the Levenshtein distance, but
+// with the transposition of two adjacent characters ("nvoa" for "nova") counting as a single edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between the first i runes of a and the first j runes of b
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,287 @@
+// Package dag parses the subset of HTCondor DAGMan input files that fakeJobsub understands:
+//
+//	JOB name submitfile [DIR directory] [DONE]
+//	PARENT name ... CHILD name ...
+//	RETRY name retries [UNLESS-EXIT exitcode]
+//	VARS name key="value" ...
+//	PRIORITY name priority
+//	DONE name
+//
+// Keywords are case-insensitive, node names are not.  Lines starting with # are comments.  It also reads and writes
+// rescue DAGs, which record the nodes of a failed DAG that finished, so that resubmitting the DAG doesn't run them again
+package dag
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Node is a node (job) in a DAG
+type Node struct {
+	Name       string
+	SubmitFile string // As given in the DAG file, so relative to Dir
+	Dir        string // As given in the DAG file.  Blank means the DAG file's directory
+	Done       bool   // Whether the node already finished, so shouldn't be run
+	Parents    []string
+	Retries    int  // How many times to retry the node if it fails
+	UnlessExit *int // If set, don't retry the node if it fails with this exit code
+	Vars       map[string]string
+	Priority   int // Higher-priority nodes are submitted first
+}
+
+// DAG is a parsed DAG file
+type DAG struct {
+	Nodes []*Node // In the order they are defined
+}
+
+// Node returns the node called name, or nil if there is none
+func (d *DAG) Node(name string) *Node {
+	idx := slices.IndexFunc(d.Nodes, func(n *Node) bool { return n.Name == name })
+	if idx == -1 {
+		return nil
+	}
+	return d.Nodes[idx]
+}
+
+// varsRegexp matches one key="value" pair in a VARS line.  Quotes and backslashes in values are escaped with backslashes
+var varsRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)\s*=\s*"((?:[^"\\]|\\.)*)"\s*`)
+
+// varsEscapeRegexp matches an escaped character in a VARS value
+var varsEscapeRegexp = regexp.MustCompile(`\\(.)`)
+
+// Parse parses a DAG file from r
+func Parse(r io.Reader) (*DAG, error) {
+	d := &DAG{Nodes: make([]*Node, 0)}
+	if err := d.parse(r, false); err != nil {
+		return nil, err
+	}
+	if len(d.Nodes) == 0 {
+		return nil, errors.New("no JOB lines")
+	}
+	if err := d.checkCycles(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ParseFile parses the DAG file at filename
+func ParseFile(filename string) (*DAG, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse DAG file %s: %w", filename, err)
+	}
+	return d, nil
+}
+
+// parse parses the lines from r into d.  If rescue is true, only DONE lines are allowed, as in a rescue DAG
+func (d *DAG) parse(r io.Reader, rescue bool) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		keyword := strings.ToUpper(fields[0])
+		if rescue && keyword != "DONE" {
+			return fmt.Errorf("line %d: only DONE lines are allowed in a rescue DAG", lineNum)
+		}
+
+		var err error
+		switch keyword {
+		case "JOB":
+			err = d.parseJob(fields[1:])
+		case "PARENT":
+			err = d.parseParent(fields[1:])
+		case "RETRY":
+			err = d.parseRetry(fields[1:])
+		case "VARS":
+			err = d.parseVars(strings.TrimSpace(line[len(fields[0]):]))
+		case "PRIORITY":
+			err = d.parsePriority(fields[1:])
+		case "DONE":
+			var n *Node
+			if n, err = d.lookup(fields[1:], 1); err == nil {
+				n.Done = true
+			}
+		default:
+			err = fmt.Errorf("unsupported keyword %s", fields[0])
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// lookup returns the node named by the first of args, after checking that there are want args
+func (d *DAG) lookup(args []string, want int) (*Node, error) {
+	if len(args) != want {
+		return nil, fmt.Errorf("expected %d argument(s), got %d", want, len(args))
+	}
+	n := d.Node(args[0])
+	if n == nil {
+		return nil, fmt.Errorf("unknown node %s", args[0])
+	}
+	return n, nil
+}
+
+func (d *DAG) parseJob(args []string) error {
+	if len(args) < 2 {
+		return errors.New("JOB needs a node name and a submit file")
+	}
+	if d.Node(args[0]) != nil {
+		return fmt.Errorf("node %s is defined more than once", args[0])
+	}
+	n := &Node{Name: args[0], SubmitFile: args[1], Vars: make(map[string]string)}
+	for idx := 2; idx < len(args); idx++ {
+		switch strings.ToUpper(args[idx]) {
+		case "DIR":
+			if idx+1 >= len(args) {
+				return errors.New("DIR needs a directory")
+			}
+			idx++
+			n.Dir = args[idx]
+		case "DONE":
+			n.Done = true
+		default:
+			return fmt.Errorf("unsupported JOB option %s", args[idx])
+		}
+	}
+	d.Nodes = append(d.Nodes, n)
+	return nil
+}
+
+func (d *DAG) parseParent(args []string) error {
+	idx := slices.IndexFunc(args, func(a string) bool { return strings.EqualFold(a, "CHILD") })
+	if idx < 1 || idx == len(args)-1 {
+		return errors.New("expected PARENT node ... CHILD node ...")
+	}
+	parents, children := args[:idx], args[idx+1:]
+	for _, name := range append(slices.Clone(parents), children...) {
+		if d.Node(name) == nil {
+			return fmt.Errorf("unknown node %s", name)
+		}
+	}
+	for _, c := range children {
+		child := d.Node(c)
+		for _, p := range parents {
+			if !slices.Contains(child.Parents, p) {
+				child.Parents = append(child.Parents, p)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DAG) parseRetry(args []string) error {
+	if len(args) != 2 && len(args) != 4 {
+		return errors.New("expected RETRY node retries [UNLESS-EXIT exitcode]")
+	}
+	n, err := d.lookup(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	if n.Retries, err = strconv.Atoi(args[1]); err != nil || n.Retries < 0 {
+		return fmt.Errorf("invalid number of retries %q", args[1])
+	}
+	if len(args) == 4 {
+		if !strings.EqualFold(args[2], "UNLESS-EXIT") {
+			return fmt.Errorf("unsupported RETRY option %s", args[2])
+		}
+		code, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid exit code %q", args[3])
+		}
+		n.UnlessExit = &code
+	}
+	return nil
+}
+
+func (d *DAG) parseVars(rest string) error {
+	name, pairs, _ := strings.Cut(rest, " ")
+	n, err := d.lookup([]string{name}, 1)
+	if err != nil {
+		return err
+	}
+	pairs = strings.TrimSpace(pairs)
+	if pairs == "" {
+		return errors.New("VARS needs at least one key=\"value\" pair")
+	}
+	for pairs != "" {
+		m := varsRegexp.FindStringSubmatch(pairs)
+		if m == nil {
+			return fmt.Errorf("invalid VARS pair at %q", pairs)
+		}
+		n.Vars[m[1]] = varsEscapeRegexp.ReplaceAllString(m[2], "$1")
+		pairs = pairs[len(m[0]):]
+	}
+	return nil
+}
+
+func (d *DAG) parsePriority(args []string) error {
+	n, err := d.lookup(args, 2)
+	if err != nil {
+		return err
+	}
+	if n.Priority, err = strconv.Atoi(args[1]); err != nil {
+		return fmt.Errorf("invalid priority %q", args[1])
+	}
+	return nil
+}
+
+// checkCycles checks that no node is its own ancestor
+func (d *DAG) checkCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(n *Node) error
+	visit = func(n *Node) error {
+		switch state[n.Name] {
+		case visiting:
+			return fmt.Errorf("node %s depends on itself", n.Name)
+		case visited:
+			return nil
+		}
+		state[n.Name] = visiting
+		for _, p := range n.Parents {
+			if err := visit(d.Node(p)); err != nil {
+				return err
+			}
+		}
+		state[n.Name] = visited
+		return nil
+	}
+	for _, n := range d.Nodes {
+		if err := visit(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expand replaces the macros $(key) for each of the node's VARS in s.  $(JOB) is replaced with the node's name
+func (n *Node) Expand(s string) string {
+	pairs := []string{"$(JOB)", n.Name}
+	for k, v := range n.Vars {
+		pairs = append(pairs, "$("+k+")", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
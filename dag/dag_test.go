@@ -0,0 +1,111 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	contents := `# A diamond
+JOB A a.sub
+JOB B b.sub DIR sub
+JOB C c.sub
+job D d.sub DONE
+PARENT A CHILD B C
+PARENT B C CHILD D
+RETRY B 2
+RETRY C 1 UNLESS-EXIT 42
+VARS B run="7" label="say \"hi\""
+PRIORITY C 10
+`
+	d, err := Parse(strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if len(d.Nodes) != 4 || d.Nodes[3].Name != "D" {
+		t.Fatalf("Expected nodes A, B, C, D in order.  Got %+v", d.Nodes)
+	}
+
+	b, c, dd := d.Node("B"), d.Node("C"), d.Node("D")
+	if b.Dir != "sub" || b.Retries != 2 || b.UnlessExit != nil || !slices.Equal(b.Parents, []string{"A"}) {
+		t.Errorf("Got wrong node B: %+v", b)
+	}
+	if b.Vars["run"] != "7" || b.Vars["label"] != `say "hi"` {
+		t.Errorf("Got wrong VARS for B: %v", b.Vars)
+	}
+	if c.Retries != 1 || c.UnlessExit == nil || *c.UnlessExit != 42 || c.Priority != 10 {
+		t.Errorf("Got wrong node C: %+v", c)
+	}
+	if !dd.Done || !slices.Equal(dd.Parents, []string{"B", "C"}) {
+		t.Errorf("Got wrong node D: %+v", dd)
+	}
+	if s := b.Expand("out_$(JOB)_$(run).log"); s != "out_B_7.log" {
+		t.Errorf("Expected out_B_7.log, got %s", s)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"no jobs", "# nothing\n", "no JOB lines"},
+		{"duplicate", "JOB A a.sub\nJOB A b.sub\n", "defined more than once"},
+		{"unknown parent", "JOB A a.sub\nPARENT X CHILD A\n", "unknown node X"},
+		{"no child", "JOB A a.sub\nPARENT A\n", "expected PARENT"},
+		{"cycle", "JOB A a.sub\nJOB B b.sub\nPARENT A CHILD B\nPARENT B CHILD A\n", "depends on itself"},
+		{"bad retry", "JOB A a.sub\nRETRY A lots\n", "invalid number of retries"},
+		{"bad vars", "JOB A a.sub\nVARS A key=value\n", "invalid VARS pair"},
+		{"unsupported", "JOB A a.sub\nSPLICE S other.dag\n", "unsupported keyword SPLICE"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(test.contents))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestRescue(t *testing.T) {
+	dagFile := filepath.Join(t.TempDir(), "workflow.dag")
+	d, err := Parse(strings.NewReader("JOB A a.sub\nJOB B b.sub\nPARENT A CHILD B\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := LatestRescue(dagFile); err != nil || n != 0 {
+		t.Errorf("Expected no rescue DAG.  Got %d, %v", n, err)
+	}
+	for want := 1; want <= 2; want++ {
+		filename, err := WriteRescue(dagFile, []string{"A"})
+		if err != nil || filename != RescueFile(dagFile, want) {
+			t.Fatalf("Expected rescue DAG %s.  Got %s, %v", RescueFile(dagFile, want), filename, err)
+		}
+	}
+	if n, err := LatestRescue(dagFile); err != nil || n != 2 {
+		t.Errorf("Expected rescue DAG 2.  Got %d, %v", n, err)
+	}
+
+	if err := d.ApplyRescue(RescueFile(dagFile, 2)); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if !d.Node("A").Done || d.Node("B").Done {
+		t.Errorf("Only A should be done.  Got %+v, %+v", d.Node("A"), d.Node("B"))
+	}
+
+	// Rescue DAGs may only mark nodes done
+	bad := filepath.Join(t.TempDir(), "bad.rescue001")
+	if err := os.WriteFile(bad, []byte("JOB C c.sub\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ApplyRescue(bad); err == nil || !strings.Contains(err.Error(), "only DONE lines") {
+		t.Errorf("Should have gotten error indicating only DONE lines are allowed.  Got %v instead", err)
+	}
+}
@@ -0,0 +1,69 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxRescue is the highest rescue DAG number, as in DAGMan
+const maxRescue = 999
+
+// RescueFile returns the name of rescue DAG number n for the DAG file dagFile, like workflow.dag.rescue001
+func RescueFile(dagFile string, n int) string {
+	return fmt.Sprintf("%s.rescue%03d", dagFile, n)
+}
+
+// LatestRescue returns the number of the newest rescue DAG for dagFile, or 0 if there is none
+func LatestRescue(dagFile string) (int, error) {
+	latest := 0
+	for n := 1; n <= maxRescue; n++ {
+		_, err := os.Stat(RescueFile(dagFile, n))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		latest = n
+	}
+	return latest, nil
+}
+
+// ApplyRescue marks the nodes that the rescue DAG at filename records as done
+func (d *DAG) ApplyRescue(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := d.parse(f, true); err != nil {
+		return fmt.Errorf("could not parse rescue DAG %s: %w", filename, err)
+	}
+	return nil
+}
+
+// WriteRescue writes the next rescue DAG for dagFile, recording that the nodes in done finished.  It returns the rescue
+// DAG's file name
+func WriteRescue(dagFile string, done []string) (string, error) {
+	latest, err := LatestRescue(dagFile)
+	if err != nil {
+		return "", err
+	}
+	if latest >= maxRescue {
+		return "", fmt.Errorf("DAG %s already has %d rescue DAGs", dagFile, maxRescue)
+	}
+	filename := RescueFile(dagFile, latest+1)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Rescue DAG file, created after running\n#   the %s DAG file\n", dagFile)
+	fmt.Fprintf(&b, "# Nodes premarked DONE: %d\n\n", len(done))
+	for _, name := range done {
+		fmt.Fprintf(&b, "DONE %s\n", name)
+	}
+	if err := os.WriteFile(filename, []byte(b.String()), 0o644); err != nil {
+		return "", fmt.Errorf("could not write rescue DAG: %w", err)
+	}
+	return filename, nil
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var defaultAccountingFilename string = filepath.Join(os.TempDir(), "fakeJobsubAccountant.db")
+
+// AccountingDB is the fair-share accountant's DB, which is shared by all schedds
+type AccountingDB struct {
+	*sql.DB
+}
+
+// GroupUsage is a single row in the usage table
+type GroupUsage struct {
+	Group  string
+	Usage  float64 // Decayed usage, in core-hours, as of the last update
+	Factor float64 // Priority factor set with userprio.  Zero means the configured factor is used
+}
+
+// usageTable holds one row per group that has used the pool or had its priority factor set
+var usageTable = table{
+	name: "usage",
+	columns: []column{
+		{"grp", "STRING NOT NULL PRIMARY KEY"},
+		{"usage", "REAL NOT NULL DEFAULT 0"},
+		{"factor", "REAL NOT NULL DEFAULT 0"},
+	},
+}
+
+// accountantTable holds a single row with the time the usage was last updated
+var accountantTable = table{
+	name: "accountant",
+	columns: []column{
+		{"id", "INTEGER NOT NULL PRIMARY KEY CHECK (id = 0)"},
+		{"last_update", "INTEGER NOT NULL DEFAULT 0"},
+	},
+}
+
+var accountingTables = []table{usageTable, accountantTable}
+
+// CreateOrOpenAccountingDB opens the accounting DB file at filename or creates it if it doesn't exist
+func CreateOrOpenAccountingDB(filename string) (AccountingDB, error) {
+	var a AccountingDB
+
+	fn := defaultAccountingFilename
+	if filename != "" {
+		fn = filename
+	}
+
+	db, err := openSQLite(fn)
+	if err != nil {
+		return a, fmt.Errorf("could not open accounting database: %w", err)
+	}
+	a = AccountingDB{db}
+	if _, err := migrate(a.DB, accountingTables); err != nil {
+		return a, fmt.Errorf("could not create or migrate tables in accounting database: %w", err)
+	}
+	if _, err := a.exec("INSERT INTO accountant (id) VALUES (0) ON CONFLICT(id) DO NOTHING ;"); err != nil {
+		return a, fmt.Errorf("could not initialize accounting database: %w", err)
+	}
+	return a, nil
+}
+
+// LastUpdate returns when the usage was last updated.  If it never has been, the zero time is returned
+func (a AccountingDB) LastUpdate() (time.Time, error) {
+	query := "SELECT last_update FROM accountant WHERE id = 0 ;"
+	start := time.Now()
+	var last int64
+	err := a.DB.QueryRow(query).Scan(&last)
+	logQuery(slog.Default(), query, start, err)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(last, 0), nil
+}
+
+// Usage returns the usage rows for all groups, ordered by group
+func (a AccountingDB) Usage() (usage []GroupUsage, err error) {
+	query := "SELECT grp, usage, factor FROM usage ORDER BY grp ;"
+	start := time.Now()
+	defer func() { logQuery(slog.Default(), query, start, err, "rows", len(usage)) }()
+	rows, err := a.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage = make([]GroupUsage, 0)
+	for rows.Next() {
+		var u GroupUsage
+		if err := rows.Scan(&u.Group, &u.Usage, &u.Factor); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return usage, nil
+}
+
+const updateUsageStatement = "INSERT INTO usage (grp, usage) VALUES (?, ?) ON CONFLICT(grp) DO UPDATE SET usage = excluded.usage ;"
+
+// UpdateUsage sets the decayed usage of each group in usage, and records that the usage was updated at now
+func (a AccountingDB) UpdateUsage(usage map[string]float64, now time.Time) (err error) {
+	start := time.Now()
+	defer func() { logQuery(slog.Default(), updateUsageStatement, start, err, "groups", len(usage)) }()
+
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op if the transaction is committed
+
+	for group, u := range usage {
+		if _, err := tx.Exec(updateUsageStatement, group, u); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE accountant SET last_update = ? WHERE id = 0 ;", now.Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetFactor sets the priority factor of group.  A factor of zero means the configured factor is used
+func (a AccountingDB) SetFactor(group string, factor float64) error {
+	_, err := a.exec("INSERT INTO usage (grp, factor) VALUES (?, ?) ON CONFLICT(grp) DO UPDATE SET factor = excluded.factor ;", group, factor)
+	return err
+}
+
+// ResetUsage forgets all of group's usage
+func (a AccountingDB) ResetUsage(group string) error {
+	_, err := a.exec("UPDATE usage SET usage = 0 WHERE grp = ? ;", group)
+	return err
+}
+
+// exec runs query with args, logging it at debug level like the schedds' queries
+func (a AccountingDB) exec(query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := a.DB.Exec(query, args...)
+	logQuery(slog.Default(), query, start, err)
+	return result, err
+}
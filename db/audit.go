@@ -0,0 +1,143 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditRecord is a single row in the audit table:  an operation that changed, or tried to change, jobs
+type AuditRecord struct {
+	Time      time.Time
+	Action    string // submit, remove, hold, release, edit, or import
+	User      string // Who asked for the operation
+	Group     string // The group of the jobs, if they were found
+	ClusterID int
+	Procs     []int  // The jobs in the cluster the operation changed
+	Detail    string // What an edit set, like memory=4000, or who owns imported jobs
+	Command   string // The command line that asked for the operation
+	Result    string // AuditOK, or the error the operation failed with
+}
+
+// AuditOK is the result of an operation that succeeded
+const AuditOK = "ok"
+
+// AuditFilter selects audit records.  Zero fields match every record
+type AuditFilter struct {
+	Since  time.Time
+	User   string
+	Group  string
+	Action string
+}
+
+// auditTable holds one row per operation.  Rows are only ever inserted, which the triggers enforce
+var auditTable = table{
+	name: "audit",
+	columns: []column{
+		{"id", "INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT"},
+		{"time", "INTEGER NOT NULL"},
+		{"action", "STRING NOT NULL"},
+		{"user", "STRING NOT NULL"},
+		{"grp", "STRING NOT NULL"},
+		{"clusterid", "INTEGER NOT NULL"},
+		{"procs", "STRING NOT NULL DEFAULT ''"},
+		{"detail", "STRING NOT NULL DEFAULT ''"},
+		{"command", "STRING NOT NULL DEFAULT ''"},
+		{"result", "STRING NOT NULL"},
+	},
+	// For audit --since
+	indexes: []index{{"time", "time"}},
+	triggers: []trigger{
+		{"no_update", "BEFORE UPDATE", "SELECT RAISE(ABORT, 'the audit log is append-only');"},
+		{"no_delete", "BEFORE DELETE", "SELECT RAISE(ABORT, 'the audit log is append-only');"},
+	},
+}
+
+// InsertAuditRecord appends r to the audit log
+func (f FakeJobsubDB) InsertAuditRecord(r AuditRecord) error {
+	_, err := f.exec("INSERT INTO audit (time, action, user, grp, clusterid, procs, detail, command, result) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ;",
+		r.Time.UnixMicro(), r.Action, r.User, r.Group, r.ClusterID, FormatProcRanges(r.Procs), r.Detail, r.Command, r.Result)
+	return err
+}
+
+// AuditRecords returns the audit records that match filter, oldest first
+func (f FakeJobsubDB) AuditRecords(filter AuditFilter) ([]AuditRecord, error) {
+	query := "SELECT time, action, user, grp, clusterid, procs, detail, command, result FROM audit WHERE time >= ?"
+	args := []any{filter.Since.UnixMicro()}
+	for _, cond := range []struct{ col, value string }{{"user", filter.User}, {"grp", filter.Group}, {"action", filter.Action}} {
+		if cond.value != "" {
+			query += " AND " + cond.col + " = ?"
+			args = append(args, cond.value)
+		}
+	}
+	start := time.Now()
+	rows, err := f.DB.Query(query+" ORDER BY id ;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]AuditRecord, 0)
+	for rows.Next() {
+		var r AuditRecord
+		var t int64
+		var procs string
+		if err := rows.Scan(&t, &r.Action, &r.User, &r.Group, &r.ClusterID, &procs, &r.Detail, &r.Command, &r.Result); err != nil {
+			return nil, err
+		}
+		r.Time = time.UnixMicro(t)
+		if r.Procs, err = parseProcRanges(procs); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	f.logQuery(query, start, rows.Err(), "rows", len(records))
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return records, nil
+}
+
+// FormatProcRanges formats procs, in increasing order, as a comma-separated list of IDs and ranges of them, like 0-41,45
+func FormatProcRanges(procs []int) string {
+	parts := make([]string, 0)
+	for i := 0; i < len(procs); {
+		j := i
+		for j+1 < len(procs) && procs[j+1] == procs[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(procs[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", procs[i], procs[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseProcRanges parses a list of proc IDs formatted by FormatProcRanges
+func parseProcRanges(s string) ([]int, error) {
+	procs := make([]int, 0)
+	if s == "" {
+		return procs, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proc range %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid proc range %q", part)
+			}
+		}
+		for p := from; p <= to; p++ {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcRanges(t *testing.T) {
+	tests := map[string][]int{
+		"":          {},
+		"3":         {3},
+		"0-2":       {0, 1, 2},
+		"0-2,5,7-8": {0, 1, 2, 5, 7, 8},
+	}
+	for s, procs := range tests {
+		if got := FormatProcRanges(procs); got != s {
+			t.Errorf("Expected %v to be formatted as %q.  Got %q instead", procs, s, got)
+		}
+		if got, err := parseProcRanges(s); err != nil || !slices.Equal(got, procs) {
+			t.Errorf("Expected %q to be parsed as %v.  Got %v, %v instead", s, procs, got, err)
+		}
+	}
+	for _, s := range []string{"a", "3-1", "1-b"} {
+		if _, err := parseProcRanges(s); err == nil {
+			t.Errorf("Should have gotten an error for %q.  Got nil instead", s)
+		}
+	}
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	defer f.Close()
+	if err := f.InsertAuditRecord(AuditRecord{Time: time.Now(), Action: "submit", User: "alice", Group: "nova", ClusterID: 1, Procs: []int{0}, Result: AuditOK}); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	for _, stmt := range []string{"UPDATE audit SET user = 'mallory' ;", "DELETE FROM audit ;"} {
+		if _, err := f.DB.Exec(stmt); err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("Expected %s to fail because the audit log is append-only.  Got %v instead", stmt, err)
+		}
+	}
+	records, err := f.AuditRecords(AuditFilter{})
+	if err != nil || len(records) != 1 || records[0].User != "alice" {
+		t.Errorf("Expected alice's record to be left alone.  Got %v, %v instead", records, err)
+	}
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// DAG statuses
+const (
+	DAGRunning   = "Running"
+	DAGCompleted = "Completed"
+	DAGFailed    = "Failed"
+)
+
+// DAG node statuses
+const (
+	NodeWaiting   = "Waiting"   // Some of the node's parents haven't finished
+	NodeSubmitted = "Submitted" // The node's cluster is in the queue
+	NodeDone      = "Done"      // The node's cluster succeeded
+	NodeFailed    = "Failed"    // The node's cluster failed, and it has no retries left
+	NodeFutile    = "Futile"    // The node can never run, because one of its ancestors failed
+)
+
+// DAG is a single row in the dags table.  As in HTCondor, a DAG is identified by the cluster ID of its DAG manager, which
+// is taken from the same sequence as the clusters of ordinary jobs
+type DAG struct {
+	ClusterID int
+	File      string // Absolute path of the DAG file
+	Group     string
+	Owner     string
+	Status    string
+	QDate     time.Time
+	Rescue    string // Rescue DAG written when the DAG failed, if any
+}
+
+// DAGNode is a single row in the dag_nodes table
+type DAGNode struct {
+	DAGID      int // ClusterID of the DAG the node is in
+	Name       string
+	Status     string
+	ClusterID  int // The node's current cluster, or 0 if it hasn't been submitted
+	Attempts   int // How many times the node has been submitted
+	Retries    int
+	UnlessExit *int // If set, the node isn't retried if it fails with this exit code
+	Priority   int
+	Parents    []string
+	Job        Job // The cluster to submit for the node.  Its ClusterID and QDate are set when it is submitted
+}
+
+// dagsTable holds one row per DAG
+var dagsTable = table{
+	name: "dags",
+	columns: []column{
+		{"clusterid", "INTEGER NOT NULL PRIMARY KEY"},
+		{"file", "STRING NOT NULL"},
+		{"grp", "STRING NOT NULL"},
+		{"owner", "STRING NOT NULL"},
+		{"status", "STRING NOT NULL DEFAULT 'Running'"},
+		{"qdate", "INTEGER NOT NULL DEFAULT 0"},
+		{"rescue", "STRING NOT NULL DEFAULT ''"},
+	},
+	// For finding the running DAGs in each negotiation cycle
+	indexes: []index{{"status", "status"}},
+}
+
+// dagNodesTable holds one row per node in each DAG
+var dagNodesTable = table{
+	name: "dag_nodes",
+	columns: []column{
+		{"dagid", "INTEGER NOT NULL REFERENCES dags(clusterid)"},
+		{"node", "STRING NOT NULL"},
+		{"status", "STRING NOT NULL DEFAULT 'Waiting'"},
+		{"clusterid", "INTEGER NOT NULL DEFAULT 0"},
+		{"attempts", "INTEGER NOT NULL DEFAULT 0"},
+		{"retries", "INTEGER NOT NULL DEFAULT 0"},
+		{"unless_exit", "INTEGER"},
+		{"priority", "INTEGER NOT NULL DEFAULT 0"},
+		{"parents", "STRING NOT NULL DEFAULT ''"},
+		{"job", "STRING NOT NULL DEFAULT '{}'"}, // JSON-encoded Job
+	},
+	constraints: []string{"PRIMARY KEY (dagid, node)"},
+}
+
+// InsertDAG inserts a new DAG and all of its nodes into the database.  dag.ClusterID must already be allocated with
+// GetNextClusterID
+func (f FakeJobsubDB) InsertDAG(dag DAG, nodes []DAGNode) error {
+	tx, err := f.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op if the transaction is committed
+
+	_, err = tx.Exec("INSERT INTO dags (clusterid, file, grp, owner, status, qdate) VALUES (?, ?, ?, ?, ?, ?) ;",
+		dag.ClusterID, dag.File, dag.Group, dag.Owner, dag.Status, dag.QDate.Unix())
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO dag_nodes (dagid, node, status, clusterid, attempts, retries, unless_exit, priority, parents, job)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ;`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, n := range nodes {
+		job, err := json.Marshal(n.Job)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(dag.ClusterID, n.Name, n.Status, n.ClusterID, n.Attempts, n.Retries, n.UnlessExit, n.Priority,
+			strings.Join(n.Parents, ","), string(job))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DAGs returns the DAGs with the given status, oldest first
+func (f FakeJobsubDB) DAGs(status string) (dags []DAG, err error) {
+	query := "SELECT clusterid, file, grp, owner, status, qdate, rescue FROM dags WHERE status = ? ORDER BY clusterid ;"
+	start := time.Now()
+	defer func() { f.logQuery(query, start, err, "rows", len(dags)) }()
+	rows, err := f.DB.Query(query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dags = make([]DAG, 0)
+	for rows.Next() {
+		var d DAG
+		var qdate int64
+		if err := rows.Scan(&d.ClusterID, &d.File, &d.Group, &d.Owner, &d.Status, &qdate, &d.Rescue); err != nil {
+			return nil, err
+		}
+		d.QDate = time.Unix(qdate, 0)
+		dags = append(dags, d)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return dags, nil
+}
+
+// DAGNodes returns the nodes of the DAG with cluster ID dagID, in the order they were inserted
+func (f FakeJobsubDB) DAGNodes(dagID int) (nodes []DAGNode, err error) {
+	query := `
+		SELECT dagid, node, status, clusterid, attempts, retries, unless_exit, priority, parents, job
+		FROM dag_nodes WHERE dagid = ? ORDER BY rowid ;`
+	start := time.Now()
+	defer func() { f.logQuery(query, start, err, "rows", len(nodes)) }()
+	rows, err := f.DB.Query(query, dagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes = make([]DAGNode, 0)
+	for rows.Next() {
+		var n DAGNode
+		var unlessExit sql.NullInt64
+		var parents, job string
+		if err := rows.Scan(&n.DAGID, &n.Name, &n.Status, &n.ClusterID, &n.Attempts, &n.Retries, &unlessExit, &n.Priority, &parents, &job); err != nil {
+			return nil, err
+		}
+		if unlessExit.Valid {
+			code := int(unlessExit.Int64)
+			n.UnlessExit = &code
+		}
+		if parents != "" {
+			n.Parents = strings.Split(parents, ",")
+		}
+		if err := json.Unmarshal([]byte(job), &n.Job); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return nodes, nil
+}
+
+// UpdateDAGNode records the node's status, cluster, and attempts
+func (f FakeJobsubDB) UpdateDAGNode(n DAGNode) error {
+	_, err := f.exec("UPDATE dag_nodes SET status = ?, clusterid = ?, attempts = ? WHERE dagid = ? AND node = ? ;",
+		n.Status, n.ClusterID, n.Attempts, n.DAGID, n.Name)
+	return err
+}
+
+// SetDAGStatus sets the status of the DAG with cluster ID dagID, and the rescue DAG written for it, if any
+func (f FakeJobsubDB) SetDAGStatus(dagID int, status, rescue string) error {
+	_, err := f.exec("UPDATE dags SET status = ?, rescue = ? WHERE clusterid = ? ;", status, rescue, dagID)
+	return err
+}
+
+// dagNodeListColumns are the columns (keys) that can be requested from RetrieveDAGNodesFromDB, and the SQL expressions used to
+// get them.  The first defaultDAGNodeListColumns of them are returned if no columns are requested
+var dagNodeListColumns = []column{
+	{"dagid", "n.dagid"},
+	{"node", "n.node"},
+	{"status", "n.status"},
+	{"clusterid", "n.clusterid"},
+	{"attempts", "n.attempts"},
+	{"retries", "n.retries"},
+	{"priority", "n.priority"},
+	{"parents", "n.parents"},
+	{"group", "j.grp"},
+	{"owner", "j.owner"},
+	{"dagstatus", "j.status"},
+	{"file", "j.file"},
+	{"rescue", "j.rescue"},
+}
+
+const defaultDAGNodeListColumns = 6
+
+// DAGNodeListColumns returns the names of the columns (keys) that can be requested from RetrieveDAGNodesFromDB
+func DAGNodeListColumns() []string {
+	return namesOf(dagNodeListColumns)
+}
+
+// RetrieveDAGNodesFromDB lists the nodes of the DAGs that match filter, returning the cols requested (or the default columns, if
+// none are requested).  filter.ClusterID selects a DAG by its cluster ID.  Rows are identified by the DAG ID and node name.  If filter.ClusterID
+// is set and there is no such DAG, iterating the rows yields sql.ErrNoRows
+func (f FakeJobsubDB) RetrieveDAGNodesFromDB(filter Filter, cols ...string) (*Listing, error) {
+	return f.retrieveRows("dag_nodes n JOIN dags j ON n.dagid = j.clusterid", dagNodeID, dagNodeListColumns[:defaultDAGNodeListColumns], dagNodeListColumns, filter, cols)
+}
+
+// dagNodeID identifies the rows of RetrieveDAGNodesFromDB
+var dagNodeID = []string{"n.dagid", "n.node"}
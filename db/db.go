@@ -4,34 +4,182 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3" // the sqlite driver
 )
 
 var defaultFilename string = filepath.Join(os.TempDir(), "fakeJobsubDB.db")
 
-// FakeJobsubDB is a DB for this fake app
+// FakeJobsubDB is a DB for this fake app.  Use CreateOrOpenDB to get one
 type FakeJobsubDB struct {
 	*sql.DB
+	stmts  *stmtCache
+	logger *slog.Logger // Where queries are logged.  Nil means slog's default logger
 }
 
+// Job is a single row in the jobs table, i.e. a cluster of jobs
+type Job struct {
+	ClusterID int
+	Group     string
+	Num       int
+	Role      string
+	Owner     string
+	QDate     time.Time // When the cluster was submitted
+
+	// Resources requested by each job in the cluster
+	MemoryMB int
+	DiskKB   int
+	CPUs     int
+	GPUs     int
+	Lifetime time.Duration // Expected lifetime
+	Sites    []string      // Sites the jobs may run at.  Empty means any site
+
+	Runtime time.Duration // How long each job runs for in the simulated pool
+
+	Log         string   // Absolute path of the cluster's user log, or blank if there is none
+	OutputFiles []string // Names of the files each job generates when it completes in the simulated pool
+
+	// Retry policy:  a job that exits non-zero is put back in the queue up to MaxRetries times, if its exit code is one of
+	// RetryExitCodes (or RetryExitCodes is empty).  The nth retry waits RetryBackoff * 2^(n-1) after the job exited
+	MaxRetries     int
+	RetryExitCodes []int
+	RetryBackoff   time.Duration
+
+	SimExitCodes []int // The exit code of each attempt of each job in the simulated pool.  The last one repeats.  Empty means 0
+
+	Notify string // When to notify the owner about each job:  one of the Notify constants.  Blank means NotifyNever
+}
+
+// When to notify the owner of a job, like HTCondor's notification submit command
+const (
+	NotifyNever    = "never"
+	NotifyComplete = "complete" // When the job leaves the queue having completed, whatever its exit code
+	NotifyError    = "error"    // When the job leaves the queue having exited non-zero
+	NotifyAlways   = "always"   // Whenever the job starts, completes, is retried, held, released, or removed
+)
+
+// NotifyValues are the valid values of Job.Notify
+var NotifyValues = []string{NotifyNever, NotifyComplete, NotifyError, NotifyAlways}
+
+// column is a column definition in a table
+type column struct {
+	name       string
+	definition string
+}
+
+// table is a table definition.  Columns added to a table after it was first released must have a DEFAULT so that
+// they can be added to existing database files by migrate
+type table struct {
+	name        string
+	columns     []column
+	constraints []string
+	indexes     []index
+	triggers    []trigger
+}
+
+// index is an index on a table, named <table>_<name>.  columns is the comma-separated list of columns it is on
+type index struct {
+	name    string
+	columns string
+}
+
+// trigger is a trigger on a table, named <table>_<name>, which runs body when event (like BEFORE UPDATE) happens to a row
+type trigger struct {
+	name  string
+	event string
+	body  string
+}
+
+// indexStatements returns the statements that create t's indexes, if they don't exist
+func (t table) indexStatements() []string {
+	stmts := make([]string, 0, len(t.indexes))
+	for _, idx := range t.indexes {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_%[2]s ON %[1]s (%[3]s);", t.name, idx.name, idx.columns))
+	}
+	return stmts
+}
+
+// triggerStatements returns the statements that create t's triggers, if they don't exist
+func (t table) triggerStatements() []string {
+	stmts := make([]string, 0, len(t.triggers))
+	for _, tr := range t.triggers {
+		stmts = append(stmts, fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %[1]s_%[2]s %[3]s ON %[1]s BEGIN %[4]s END;", t.name, tr.name, tr.event, tr.body))
+	}
+	return stmts
+}
+
+func (t table) createStatement() string {
+	defs := make([]string, 0, len(t.columns)+len(t.constraints))
+	for _, col := range t.columns {
+		defs = append(defs, col.name+" "+col.definition)
+	}
+	defs = append(defs, t.constraints...)
+	return "CREATE TABLE IF NOT EXISTS " + t.name + " (\n" + strings.Join(defs, ",\n") + "\n);"
+}
+
+// jobsTable holds one row per cluster
+var jobsTable = table{
+	name: "jobs",
+	columns: []column{
+		{"clusterid", "INTEGER NOT NULL PRIMARY KEY"},
+		{"grp", "STRING NOT NULL"},
+		{"num", "INTEGER NOT NULL"},
+		{"role", "STRING NOT NULL DEFAULT 'Analysis'"},
+		{"owner", "STRING NOT NULL DEFAULT ''"},
+		{"qdate", "INTEGER NOT NULL DEFAULT 0"},
+		{"memory", "INTEGER NOT NULL DEFAULT 2000"},
+		{"disk", "INTEGER NOT NULL DEFAULT 10485760"},
+		{"cpus", "INTEGER NOT NULL DEFAULT 1"},
+		{"gpus", "INTEGER NOT NULL DEFAULT 0"},
+		{"lifetime", "INTEGER NOT NULL DEFAULT 28800"},
+		{"sites", "STRING NOT NULL DEFAULT ''"},
+		{"runtime", "INTEGER NOT NULL DEFAULT 60"},
+		{"log", "STRING NOT NULL DEFAULT ''"},
+		{"output_files", "STRING NOT NULL DEFAULT ''"},
+		{"max_retries", "INTEGER NOT NULL DEFAULT 0"},
+		{"retry_exit_codes", "STRING NOT NULL DEFAULT ''"},
+		{"retry_backoff", "INTEGER NOT NULL DEFAULT 0"},
+		{"sim_exit_codes", "STRING NOT NULL DEFAULT ''"},
+		{"notify", "STRING NOT NULL DEFAULT ''"},
+	},
+	// For listing by owner or group, and matching idle jobs oldest first
+	indexes: []index{{"owner", "owner"}, {"grp", "grp"}, {"qdate", "qdate"}},
+}
+
+// procsTable holds one row per job (proc) in each cluster
+var procsTable = table{
+	name: "procs",
+	columns: []column{
+		{"clusterid", "INTEGER NOT NULL REFERENCES jobs(clusterid)"},
+		{"procid", "INTEGER NOT NULL"},
+		{"status", "INTEGER NOT NULL DEFAULT 1"},
+		{"slot", "STRING NOT NULL DEFAULT ''"},       // The slot the job is running in, or last ran in
+		{"start_time", "INTEGER NOT NULL DEFAULT 0"}, // When the job last started running
+		{"end_time", "INTEGER NOT NULL DEFAULT 0"},   // When the running job will complete, or when it completed
+		{"reason", "STRING NOT NULL DEFAULT ''"},     // Why an idle job did not match in the last negotiation cycle
+		{"exitcode", "INTEGER NOT NULL DEFAULT 0"},
+		{"attempts", "INTEGER NOT NULL DEFAULT 0"},    // How many times the job has started running
+		{"retry_after", "INTEGER NOT NULL DEFAULT 0"}, // When an idle job being retried may next be matched
+	},
+	constraints: []string{"PRIMARY KEY (clusterid, procid)"},
+	// For finding the idle, running, or finished jobs in each negotiation cycle and in history
+	indexes: []index{{"status", "status"}},
+}
+
+var tables = []table{jobsTable, procsTable, dagsTable, dagNodesTable, auditTable}
+
 // CreateOrOpenDB opens the DB file at filename or creates it if it doesn't exist
 func CreateOrOpenDB(filename string) (FakeJobsubDB, error) {
 	var f FakeJobsubDB
 	var fn string
 
-	createTable := `
-CREATE TABLE jobs (
-clusterid INTEGER NOT NULL PRIMARY KEY, 
-grp STRING NOT NULL, 
-num INTEGER NOT NULL
-);`
-
 	fn = defaultFilename
 	if filename != "" {
 		fn = filename
@@ -47,158 +195,499 @@ num INTEGER NOT NULL
 	}
 
 	// Our file either doesn't exist or is fine, so try to open the DB
-	db, err := sql.Open("sqlite3", fn)
+	db, err := openSQLite(fn)
 	if err != nil {
 		return f, fmt.Errorf("could not open database: %w", err)
 	}
 
-	// If it's a new db, create the table
-	if newDB {
-		if _, err = db.Exec(createTable); err != nil {
-			return f, fmt.Errorf("could not create table in new database: %w", err)
+	f = FakeJobsubDB{DB: db, stmts: &stmtCache{stmts: make(map[string]*sql.Stmt)}}
+	f.log().Debug("opened database", "path", fn, "new", newDB)
+	// Create the tables if it's a new db.  Older database files may be missing tables or columns that were added
+	// since they were created, so migrate those
+	created, err := migrate(f.DB, tables)
+	if err != nil {
+		return f, fmt.Errorf("could not create or migrate tables in database: %w", err)
+	}
+	if slices.Contains(created, procsTable.name) {
+		// Clusters submitted before there was a procs table get idle procs
+		if err := f.backfillProcs(); err != nil {
+			return f, fmt.Errorf("could not create or migrate tables in database: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+// migrate creates any of tables that are missing from the database, and adds any columns and indexes that are missing from
+// existing tables.  It returns the names of the tables it created
+func migrate(d *sql.DB, tables []table) ([]string, error) {
+	created := make([]string, 0)
+	for _, t := range tables {
+		existing, err := columnNames(d, t.name)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(existing) == 0 {
+			if _, err := d.Exec(t.createStatement()); err != nil {
+				return nil, fmt.Errorf("could not create table %s: %w", t.name, err)
+			}
+			created = append(created, t.name)
+		}
+
+		for _, col := range t.columns {
+			if len(existing) == 0 || slices.Contains(existing, col.name) {
+				continue
+			}
+			if _, err := d.Exec("ALTER TABLE " + t.name + " ADD COLUMN " + col.name + " " + col.definition + ";"); err != nil {
+				return nil, fmt.Errorf("could not add column %s to table %s: %w", col.name, t.name, err)
+			}
+		}
+
+		for _, stmt := range t.indexStatements() {
+			if _, err := d.Exec(stmt); err != nil {
+				return nil, fmt.Errorf("could not create index on table %s: %w", t.name, err)
+			}
+		}
+		for _, stmt := range t.triggerStatements() {
+			if _, err := d.Exec(stmt); err != nil {
+				return nil, fmt.Errorf("could not create trigger on table %s: %w", t.name, err)
+			}
+		}
+	}
+	return created, nil
+}
+
+// columnNames returns the names of the columns in tableName.  If there is no such table, it returns an empty slice
+func columnNames(d *sql.DB, tableName string) ([]string, error) {
+	rows, err := d.Query("SELECT name FROM pragma_table_info(?);", tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
 		}
+		names = append(names, name)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return names, nil
+}
+
+// backfillProcs creates idle procs for every cluster that has none
+func (f FakeJobsubDB) backfillProcs() error {
+	backfill := `
+		WITH RECURSIVE seq(clusterid, procid, num) AS (
+			SELECT clusterid, 0, num FROM jobs
+			WHERE num > 0 AND clusterid NOT IN (SELECT clusterid FROM procs)
+			UNION ALL
+			SELECT clusterid, procid + 1, num FROM seq WHERE procid + 1 < num
+		)
+		INSERT INTO procs (clusterid, procid)
+		SELECT clusterid, procid FROM seq;
+`
+	if _, err := f.DB.Exec(backfill); err != nil {
+		return fmt.Errorf("could not backfill procs: %w", err)
 	}
+	return nil
+}
 
-	return FakeJobsubDB{db}, nil
+// InsertJobIntoDB inserts a new cluster, and all of its procs, into the database
+func (f FakeJobsubDB) InsertJobIntoDB(job Job) error {
+	return f.InsertJobsIntoDB([]Job{job})
 }
 
-// InsertJobIntoDB inserts a new job into the database
-func (f FakeJobsubDB) InsertJobIntoDB(clusterID int, group string, num int) error {
-	insertStatement := `
-		INSERT INTO jobs
-		VALUES (?, ?, ?)
+const insertJobStatement = `
+		INSERT INTO jobs (clusterid, grp, num, role, owner, qdate, memory, disk, cpus, gpus, lifetime, sites, runtime, log, output_files,
+			max_retries, retry_exit_codes, retry_backoff, sim_exit_codes, notify)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(clusterid) DO NOTHING;
 `
 
-	stmt, err := f.DB.Prepare(insertStatement)
+// insertProcsStatement inserts all of a cluster's idle procs in one statement, however many there are, the same way
+// backfillProcs does.  Its arguments are num (twice), clusterid, and the status
+const insertProcsStatement = `
+		WITH RECURSIVE seq(procid) AS (
+			SELECT 0 WHERE ? > 0
+			UNION ALL
+			SELECT procid + 1 FROM seq WHERE procid + 1 < ?
+		)
+		INSERT INTO procs (clusterid, procid, status)
+		SELECT ?, procid, ? FROM seq WHERE true
+		ON CONFLICT(clusterid, procid) DO NOTHING;
+`
+
+// InsertJobsIntoDB inserts new clusters, and all of their procs, into the database in a single transaction, so that it is
+// synced to disk once however many there are.  The statements are prepared once, and each cluster's procs are inserted by one
+func (f FakeJobsubDB) InsertJobsIntoDB(jobs []Job) (err error) {
+	start := time.Now()
+	defer func() { f.logQuery(insertJobStatement, start, err, "clusters", len(jobs)) }()
+
+	tx, err := f.DB.Begin()
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer tx.Rollback() // No-op if the transaction is committed
 
-	if _, err := stmt.Exec(clusterID, group, num); err != nil {
+	insertJob, err := f.txStmt(tx, insertJobStatement)
+	if err != nil {
+		return err
+	}
+	insertProcs, err := f.txStmt(tx, insertProcsStatement)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	for _, job := range jobs {
+		_, err = insertJob.Exec(job.ClusterID, job.Group, job.Num, job.Role, job.Owner, job.QDate.Unix(),
+			job.MemoryMB, job.DiskKB, job.CPUs, job.GPUs, int64(job.Lifetime.Seconds()), strings.Join(job.Sites, ","),
+			int64(job.Runtime.Seconds()), job.Log, strings.Join(job.OutputFiles, ","),
+			job.MaxRetries, joinInts(job.RetryExitCodes), int64(job.RetryBackoff.Seconds()), joinInts(job.SimExitCodes), job.Notify)
+		if err != nil {
+			return err
+		}
+
+		if _, err := insertProcs.Exec(job.Num, job.Num, job.ClusterID, Idle); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const importProcStatement = `
+		INSERT INTO procs (clusterid, procid, status, slot, start_time, end_time, reason, exitcode, attempts)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ;
+`
+
+// ImportJobs inserts clusters, and their procs, as they were on another schedd, into the database in a single transaction.
+// Unlike InsertJobsIntoDB, procs keep their IDs, statuses, and history, and nothing is inserted if any of the clusters exist,
+// as jobs or DAGs
+func (f FakeJobsubDB) ImportJobs(jobs []Job, procs []Proc) (err error) {
+	start := time.Now()
+	defer func() { f.logQuery(importProcStatement, start, err, "clusters", len(jobs), "procs", len(procs)) }()
+
+	tx, err := f.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op if the transaction is committed
+
+	insertJob, err := f.txStmt(tx, insertJobStatement)
+	if err != nil {
+		return err
+	}
+	insertProc, err := f.txStmt(tx, importProcStatement)
+	if err != nil {
+		return err
+	}
+	// DAGs take their cluster IDs from the same sequence as jobs
+	dagExists, err := f.txStmt(tx, "SELECT COUNT(*) FROM dags WHERE clusterid = ? ;")
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		var dags int
+		if err := dagExists.QueryRow(job.ClusterID).Scan(&dags); err != nil {
+			return err
+		}
+		if dags > 0 {
+			return fmt.Errorf("cluster %d already exists, as a DAG", job.ClusterID)
+		}
+		result, err := insertJob.Exec(job.ClusterID, job.Group, job.Num, job.Role, job.Owner, job.QDate.Unix(),
+			job.MemoryMB, job.DiskKB, job.CPUs, job.GPUs, int64(job.Lifetime.Seconds()), strings.Join(job.Sites, ","),
+			int64(job.Runtime.Seconds()), job.Log, strings.Join(job.OutputFiles, ","),
+			job.MaxRetries, joinInts(job.RetryExitCodes), int64(job.RetryBackoff.Seconds()), joinInts(job.SimExitCodes), job.Notify)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("cluster %d already exists", job.ClusterID)
+		}
+	}
+	for _, p := range procs {
+		if _, err := insertProc.Exec(p.ClusterID, p.ProcID, p.Status, p.Slot, unixOrZero(p.Start), unixOrZero(p.End), p.Reason,
+			p.ExitCode, p.Attempts); err != nil {
+			return fmt.Errorf("could not import job %d.%d: %w", p.ClusterID, p.ProcID, err)
+		}
+	}
 
+	return tx.Commit()
 }
 
-// RetrieveJobsFromDB lists jobs based on the cols requested and clusterID
-func (f FakeJobsubDB) RetrieveJobsFromDB(clusterID int, cols ...string) ([]string, error) {
-	lenCols := len(cols)
-	// Check our columns to make sure we don't have SQL injection attack.  If col is OK, then add a placeholder for the future query string
-	validCols := []string{"clusterid", "group", "num"}
+// unixOrZero returns t as a Unix time, or 0 if t is the zero time, which is how unset times are stored
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// jobSelectColumns are the columns selected from the jobs table (aliased as j) by scanJob
+const jobSelectColumns = "j.clusterid, j.grp, j.num, j.role, j.owner, j.qdate, j.memory, j.disk, j.cpus, j.gpus, j.lifetime, j.sites, j.runtime, j.log, j.output_files, j.max_retries, j.retry_exit_codes, j.retry_backoff, j.sim_exit_codes, j.notify"
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanJob scans jobSelectColumns, followed by extra, from row
+func scanJob(row scanner, extra ...any) (Job, error) {
+	var j Job
+	var qdate, lifetime, runtime, retryBackoff int64
+	var sites, outputFiles, retryExitCodes, simExitCodes string
+	dest := []any{&j.ClusterID, &j.Group, &j.Num, &j.Role, &j.Owner, &qdate, &j.MemoryMB, &j.DiskKB, &j.CPUs, &j.GPUs, &lifetime, &sites, &runtime, &j.Log, &outputFiles,
+		&j.MaxRetries, &retryExitCodes, &retryBackoff, &simExitCodes, &j.Notify}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return j, err
+	}
+	j.QDate = time.Unix(qdate, 0)
+	j.Lifetime = time.Duration(lifetime) * time.Second
+	j.Runtime = time.Duration(runtime) * time.Second
+	if sites != "" {
+		j.Sites = strings.Split(sites, ",")
+	}
+	if outputFiles != "" {
+		j.OutputFiles = strings.Split(outputFiles, ",")
+	}
+	j.RetryBackoff = time.Duration(retryBackoff) * time.Second
+	var err error
+	if j.RetryExitCodes, err = splitInts(retryExitCodes); err != nil {
+		return j, err
+	}
+	if j.SimExitCodes, err = splitInts(simExitCodes); err != nil {
+		return j, err
+	}
+	return j, nil
+}
+
+// GetJob returns the cluster with the given clusterID
+func (f FakeJobsubDB) GetJob(clusterID int) (Job, error) {
+	query := "SELECT " + jobSelectColumns + " FROM jobs j WHERE j.clusterid = ? ;"
+	start := time.Now()
+	j, err := scanJob(f.DB.QueryRow(query, clusterID))
+	f.logQuery(query, start, err)
+	return j, err
+}
+
+// Filter restricts which jobs RetrieveJobsFromDB and RetrieveProcsFromDB return, and in what order.  Zero values mean no
+// restriction
+type Filter struct {
+	ClusterID int
+	Owner     string
+	Group     string
+
+	Sort  []SortKey // Rows are sorted by these columns, then by the values that identify them
+	Limit int       // If positive, at most this many rows are returned
+
+	// After, if set, is the Key of a row (or a prefix of one).  Only rows whose keys come after it are returned, so that the
+	// Key of the last row of one page is where the next page starts.  AfterInclusive also returns rows whose keys start with After
+	After          []any
+	AfterInclusive bool
+}
+
+// where returns the WHERE clause for the filter, and the arguments for its placeholders.  The jobs table must be aliased as j
+func (filter Filter) where() (string, []any) {
+	conds := make([]string, 0)
+	args := make([]any, 0)
+	if filter.ClusterID > 0 {
+		conds = append(conds, "j.clusterid = ?")
+		args = append(args, filter.ClusterID)
+	}
+	if filter.Owner != "" {
+		conds = append(conds, "j.owner = ?")
+		args = append(args, filter.Owner)
+	}
+	if filter.Group != "" {
+		conds = append(conds, "j.grp = ?")
+		args = append(args, filter.Group)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// listColumns are the columns (keys) that can be requested from RetrieveJobsFromDB, and the SQL expressions used to get them.
+// The first defaultListColumns of them are returned if no columns are requested
+var listColumns = []column{
+	{"clusterid", "j.clusterid"},
+	{"group", "j.grp"},
+	{"num", "j.num"},
+	{"role", "j.role"},
+	{"owner", "j.owner"},
+	{"status", clusterStatusExpr},
+	{"memory", "j.memory"}, // MB
+	{"disk", "j.disk"},     // KB
+	{"cpus", "j.cpus"},
+	{"gpus", "j.gpus"},
+	{"lifetime", "j.lifetime"}, // seconds
+	{"sites", "j.sites"},
+	{"runtime", "j.runtime"}, // seconds
+	{"log", "j.log"},
+	{"output_files", "j.output_files"},
+	{"max_retries", "j.max_retries"},
+	{"retry_exit_codes", "j.retry_exit_codes"},
+	{"retry_backoff", "j.retry_backoff"}, // seconds
+	{"sim_exit_codes", "j.sim_exit_codes"},
+	{"notify", "j.notify"},
+}
+
+const defaultListColumns = 6
+
+// ListColumns returns the names of the columns (keys) that can be requested from RetrieveJobsFromDB
+func ListColumns() []string {
+	return namesOf(listColumns)
+}
+
+// clusterStatusExpr summarizes the status of a cluster's procs:  the cluster is held if any proc is held, otherwise
+// running if any proc is running, and so on
+var clusterStatusExpr = fmt.Sprintf(`(SELECT CASE
+		WHEN COUNT(*) = 0 THEN 'Unknown'
+		WHEN SUM(p.status = %[1]d) > 0 THEN '%[1]s'
+		WHEN SUM(p.status = %[2]d) > 0 THEN '%[2]s'
+		WHEN SUM(p.status = %[3]d) > 0 THEN '%[3]s'
+		WHEN SUM(p.status = %[4]d) > 0 THEN '%[4]s'
+		ELSE '%[5]s' END
+	FROM procs p WHERE p.clusterid = j.clusterid)`, Held, Running, Idle, Completed, Removed)
+
+// RetrieveJobsFromDB lists jobs that match filter, returning the cols requested (or the default columns, if none are requested).
+// Rows are identified by their cluster ID.  If filter.ClusterID is set and there is no such cluster, iterating the rows yields
+// sql.ErrNoRows
+func (f FakeJobsubDB) RetrieveJobsFromDB(filter Filter, cols ...string) (*Listing, error) {
+	return f.retrieveRows("jobs j", jobID, listColumns[:defaultListColumns], listColumns, filter, cols)
+}
+
+// jobID identifies the rows of RetrieveJobsFromDB
+var jobID = []string{"j.clusterid"}
+
+// retrieveRows selects cols (or defaultCols, if cols is empty) from the from clause, for rows matching filter, in the order
+// filter gives.  Each of cols must be one of validCols.  id are the expressions that identify a row.  The query runs as the rows
+// are iterated, so that they are never all in memory.  If filter.ClusterID is set and there are no rows, iterating them yields
+// sql.ErrNoRows
+func (f FakeJobsubDB) retrieveRows(from string, id []string, defaultCols, validCols []column, filter Filter, cols []string) (*Listing, error) {
+	if len(cols) == 0 {
+		cols = namesOf(defaultCols)
+	}
+
+	// Check our columns to make sure we don't have SQL injection attack.  If col is OK, then add its expression to the query
 	queryCols := make([]string, 0, len(cols))
 	for _, col := range cols {
-		if !slices.Contains(validCols, col) {
+		def, ok := definitionOf(validCols, col)
+		if !ok {
 			return nil, fmt.Errorf("invalid column: %s", col)
 		}
-
-		useCol := col
-		if col == "group" {
-			useCol = "grp"
-		}
-		queryCols = append(queryCols, useCol)
+		queryCols = append(queryCols, def)
+	}
+	keys, err := keyExprs(validCols, filter.Sort, id)
+	if err != nil {
+		return nil, err
 	}
 
-	jobRows := make([]string, 0)
+	listing := &Listing{Header: cols}
+
 	// Now that we know that all the cols are valid, prepare our statement
-	// Note:  Yes we can make this more intelligent, but I didn't want to overcomplicate the logic, since this is supposed to be a simple demo
-	switch {
-	// Default case - select *
-	case lenCols == 0 && clusterID == 0:
-		jobRows = append(jobRows, strings.Join(validCols, "\t")) // header
-		selectAll := "SELECT * FROM jobs ;"
-		rows, err := f.DB.Query(selectAll)
+	where, args := filter.where()
+	if filter.After != nil {
+		cond, afterArgs, err := afterCondition(keys, filter.Sort, filter.After, filter.AfterInclusive)
 		if err != nil {
 			return nil, err
 		}
-
-		var cid, num int
-		var grp string
-		for rows.Next() {
-			err := rows.Scan(&cid, &grp, &num)
-			if err != nil {
-				return nil, err
-			}
-			jobRows = append(jobRows, fmt.Sprintf("%d\t%s\t%d", cid, grp, num))
+		if where == "" {
+			where = " WHERE " + cond
+		} else {
+			where += " AND " + cond
 		}
-		if rows.Err() != nil {
-			return nil, rows.Err()
+		args = append(args, afterArgs...)
+	}
+	query := "SELECT " + strings.Join(append(queryCols, keys...), ", ") + " FROM " + from + where + orderBy(keys, filter.Sort)
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	listing.query, listing.args = query, args
+	listing.Rows = func(yield func(Row, error) bool) {
+		start := time.Now()
+		n := 0
+		var err error
+		defer func() { f.logQuery(query, start, err, "rows", n) }()
+		fail := func(e error) {
+			err = e
+			yield(Row{}, e)
 		}
-		return jobRows, nil
-	// Want only specific ID
-	case lenCols == 0 && clusterID > 0:
-		jobRows = append(jobRows, strings.Join(validCols, "\t")) // header
-		stmt, err := f.DB.Prepare("SELECT * FROM jobs WHERE clusterid = ? ;")
+
+		stmt, err := f.DB.Prepare(query + " ;")
 		if err != nil {
-			return nil, err
+			fail(err)
+			return
 		}
 		defer stmt.Close()
 
-		var cid, num int
-		var grp string
-		if err := stmt.QueryRow(clusterID).Scan(&cid, &grp, &num); err != nil {
-			return nil, err
-		}
-		jobRows = append(jobRows, fmt.Sprintf("%d\t%s\t%d", cid, grp, num))
-		return jobRows, nil
-
-	// Want certain columns for all jobs
-	case lenCols > 0 && clusterID == 0:
-		jobRows = append(jobRows, strings.Join(cols, "\t")) // header
-		query := "SELECT " + strings.Join(queryCols, ", ") + " FROM jobs;"
-		rows, err := f.DB.Query(query)
+		rows, err := stmt.Query(args...)
 		if err != nil {
-			return nil, err
+			fail(err)
+			return
 		}
+		defer rows.Close()
 
 		for rows.Next() {
-			resultRow, resultRowPtrs := prepareAnyRowAndPointerSlice(lenCols)
+			resultRow, resultRowPtrs := prepareAnyRowAndPointerSlice(len(cols) + len(keys))
 			if err := rows.Scan(resultRowPtrs...); err != nil {
-				return nil, err
+				fail(err)
+				return
 			}
 
-			rowStringSlice, err := populateRowStringFromAny(resultRow)
+			rowStringSlice, err := populateRowStringFromAny(resultRow[:len(cols)])
 			if err != nil {
-				return nil, err
+				fail(err)
+				return
 			}
 
-			jobRows = append(jobRows, strings.Join(rowStringSlice, "\t"))
+			n++
+			if !yield(Row{Values: rowStringSlice, Key: resultRow[len(cols):]}, nil) {
+				return
+			}
 		}
 		if rows.Err() != nil {
-			return nil, rows.Err()
+			fail(rows.Err())
+			return
 		}
-		return jobRows, nil
 
-	// Want certain columns for one cluster
-	case lenCols > 0 && clusterID > 0:
-		jobRows = append(jobRows, strings.Join(cols, "\t")) // header
-		stmt, err := f.DB.Prepare("SELECT " + strings.Join(queryCols, ", ") + " FROM jobs WHERE clusterid = ? ;")
-		if err != nil {
-			return nil, err
-		}
-		defer stmt.Close()
-
-		resultRow, resultRowPtrs := prepareAnyRowAndPointerSlice(lenCols)
-		if err := stmt.QueryRow(clusterID).Scan(resultRowPtrs...); err != nil {
-			return nil, err
+		// Want only specific ID, which doesn't exist
+		if filter.ClusterID > 0 && filter.After == nil && n == 0 {
+			yield(Row{}, sql.ErrNoRows)
 		}
+	}
+	return listing, nil
+}
 
-		rowStringSlice, err := populateRowStringFromAny(resultRow)
-		if err != nil {
-			return nil, err
-		}
+// definitionOf returns the SQL expression for the column named name among cols, and whether there is one
+func definitionOf(cols []column, name string) (string, bool) {
+	idx := slices.IndexFunc(cols, func(c column) bool { return c.name == name })
+	if idx == -1 {
+		return "", false
+	}
+	return cols[idx].definition, true
+}
 
-		jobRows = append(jobRows, strings.Join(rowStringSlice, "\t"))
-		return jobRows, nil
-	default:
-		return nil, errors.New("Invalid args to function: unhandled case")
+func namesOf(cols []column) []string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.name)
 	}
+	return names
 }
 
-// GetNextClusterID gets the highest clusterid
+// GetNextClusterID gets the next free clusterid.  DAGs take their IDs from the same sequence as clusters
 func (f FakeJobsubDB) GetNextClusterID() (int, error) {
 	maxClusterID, err := f.getMaxClusterID()
 	if err != nil {
@@ -209,17 +698,9 @@ func (f FakeJobsubDB) GetNextClusterID() (int, error) {
 }
 
 func (f FakeJobsubDB) getMaxClusterID() (int, error) {
-	isEmpty, err := f.isJobsTableEmpty()
-	if err != nil {
-		return 0, err
-	}
-	if isEmpty {
-		return 0, nil
-	}
-
 	query := `
-		SELECT MAX(clusterid)
-		FROM jobs;
+		SELECT COALESCE(MAX(clusterid), 0)
+		FROM (SELECT clusterid FROM jobs UNION ALL SELECT clusterid FROM dags);
 		`
 
 	stmt, err := f.DB.Prepare(query)
@@ -237,21 +718,32 @@ func (f FakeJobsubDB) getMaxClusterID() (int, error) {
 	return cid, nil
 }
 
-func (f FakeJobsubDB) isJobsTableEmpty() (bool, error) {
-	noRowsQuery := `
-		SELECT COUNT(clusterid)
-		FROM jobs;
-		`
+// Utility functions
 
-	var count int
-	err := f.DB.QueryRow(noRowsQuery).Scan(&count)
-	if err != nil {
-		return false, err
+// joinInts joins ints with commas, for storing in a STRING column
+func joinInts(ints []int) string {
+	strs := make([]string, 0, len(ints))
+	for _, i := range ints {
+		strs = append(strs, strconv.Itoa(i))
 	}
-	return count == 0, nil
+	return strings.Join(strs, ",")
 }
 
-// Utility functions
+// splitInts is the inverse of joinInts
+func splitInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ints := make([]int, 0)
+	for _, str := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
 
 // prepareAnyRowAndPointerSlice prepares and returns two slices:
 // 1) a slice of []any of length l, and
@@ -1,6 +1,16 @@
 package db
 
-import "testing"
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestPrepareAnyRowAndPointerSlice(t *testing.T) {
 	l := 5
@@ -18,4 +28,213 @@ func TestPrepareAnyRowAndPointerSlice(t *testing.T) {
 
 }
 
+func TestCreateOrOpenDBMigrate(t *testing.T) {
+	// Create a database file with the original jobs table, and make sure it is migrated when opened
+	fn := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec("CREATE TABLE jobs (clusterid INTEGER NOT NULL PRIMARY KEY, grp STRING NOT NULL, num INTEGER NOT NULL);"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec("INSERT INTO jobs VALUES (1, 'fermilab', 2);"); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	f, err := CreateOrOpenDB(fn)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if err := f.InsertJobIntoDB(Job{ClusterID: 2, Group: "nova", Num: 3, Role: "Production"}); err != nil {
+		t.Errorf("Could not insert job into migrated database: %s", err)
+	}
+
+	expected := []string{"clusterid\tgroup\tnum\trole\tstatus", "1\tfermilab\t2\tAnalysis\tIdle", "2\tnova\t3\tProduction\tIdle"}
+	listing, err := f.RetrieveJobsFromDB(Filter{}, "clusterid", "group", "num", "role", "status")
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	rows, err := listing.Collect()
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if !slices.Equal(rows, expected) {
+		t.Errorf("Got wrong result.  Expected %v, got %v", expected, rows)
+	}
+}
+
+func TestCreateOrOpenDBSpecialCharacters(t *testing.T) {
+	// Each of these would end the file name, or start an escape, in an unescaped SQLite URI
+	for _, name := range []string{"what?.db", "hash#tag.db", "100%.db", "two words.db"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := CreateOrOpenDB(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			defer f.Close()
+			if err := f.InsertJobIntoDB(Job{ClusterID: 1, Group: "nova", Num: 1, Role: "Analysis"}); err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if files, _ := filepath.Glob(filepath.Join(dir, "*")); !slices.Contains(files, filepath.Join(dir, name)) {
+				t.Errorf("Expected the database to be created as %s.  Got %v instead", name, files)
+			}
+		})
+	}
+}
+
+func TestInsertJobsIntoDB(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var mode string
+	if err := f.QueryRow("PRAGMA journal_mode ;").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("Database should be in WAL mode.  Got %q, %v", mode, err)
+	}
+
+	jobs := []Job{{ClusterID: 1, Group: "fermilab", Num: 3, Role: "Analysis"}, {ClusterID: 2, Group: "nova", Num: 0, Role: "Analysis"}, {ClusterID: 3, Group: "dune", Num: 1, Role: "Analysis"}}
+	if err := f.InsertJobsIntoDB(jobs); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	for _, job := range jobs {
+		procs, err := f.ClusterProcs(job.ClusterID)
+		if err != nil || len(procs) != job.Num {
+			t.Errorf("Cluster %d should have %d procs.  Got %d, %v", job.ClusterID, job.Num, len(procs), err)
+		}
+		for i, p := range procs {
+			if p.ProcID != i || p.Status != Idle {
+				t.Errorf("Expected idle proc %d.  Got %+v", i, p)
+			}
+		}
+	}
+
+	// Inserting an existing cluster again is a no-op, and doesn't stop the rest of the batch
+	if err := f.InsertJobsIntoDB([]Job{{ClusterID: 1, Group: "fermilab", Num: 3}, {ClusterID: 4, Group: "nova", Num: 2}}); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if procs, err := f.ClusterProcs(1); err != nil || len(procs) != 3 {
+		t.Errorf("Cluster 1 should still have 3 procs.  Got %d, %v", len(procs), err)
+	}
+	if procs, err := f.ClusterProcs(4); err != nil || len(procs) != 2 {
+		t.Errorf("Cluster 4 should have 2 procs.  Got %d, %v", len(procs), err)
+	}
+}
+
+func TestImportJobs(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	start := time.Unix(1700000000, 0)
+	job := Job{ClusterID: 12, Group: "nova", Num: 2, Role: "Analysis", Owner: "alice", QDate: start, MemoryMB: 4000}
+	procs := []Proc{
+		{Job: job, ProcID: 3, Status: Running, Slot: "slot1@FermiGrid", Start: start, End: start.Add(time.Hour), Attempts: 2},
+		{Job: job, ProcID: 7, Status: Held, Reason: "Out of memory"},
+	}
+	if err := f.ImportJobs([]Job{job}, procs); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	got, err := f.ClusterProcs(12)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Cluster 12 should have 2 procs.  Got %d, %v", len(got), err)
+	}
+	if p := got[0]; p.ProcID != 3 || p.Status != Running || p.Slot != "slot1@FermiGrid" || !p.End.Equal(start.Add(time.Hour)) || p.Attempts != 2 || p.MemoryMB != 4000 {
+		t.Errorf("Got wrong running proc %+v", p)
+	}
+	if p := got[1]; p.ProcID != 7 || p.Status != Held || p.Reason != "Out of memory" || p.Start.Unix() != 0 {
+		t.Errorf("Got wrong held proc %+v", p)
+	}
+	if next, err := f.GetNextClusterID(); err != nil || next != 13 {
+		t.Errorf("Next cluster ID should be 13.  Got %d, %v", next, err)
+	}
+
+	// Importing a cluster that exists imports nothing
+	other := Job{ClusterID: 13, Group: "nova", Num: 1, Role: "Analysis"}
+	if err := f.ImportJobs([]Job{other, job}, []Proc{{Job: other, Status: Idle}}); err == nil {
+		t.Error("Should have gotten an error importing an existing cluster")
+	}
+	if procs, err := f.ClusterProcs(13); err != nil || len(procs) != 0 {
+		t.Errorf("Cluster 13 should not have been imported.  Got %v, %v", procs, err)
+	}
+
+	// Nor does importing a cluster whose ID a DAG has
+	if err := f.InsertDAG(DAG{ClusterID: 14, File: "/data/test.dag", Group: "nova", Owner: "alice", QDate: start}, nil); err != nil {
+		t.Fatal(err)
+	}
+	dagClash := Job{ClusterID: 14, Group: "nova", Num: 1, Role: "Analysis"}
+	if err := f.ImportJobs([]Job{dagClash}, []Proc{{Job: dagClash, Status: Idle}}); err == nil || !strings.Contains(err.Error(), "cluster 14 already exists") {
+		t.Errorf("Should have gotten error indicating cluster 14 exists.  Got %v instead", err)
+	}
+	if procs, err := f.ClusterProcs(14); err != nil || len(procs) != 0 {
+		t.Errorf("Cluster 14 should not have been imported.  Got %v, %v", procs, err)
+	}
+}
+
+func TestQueriesAreLogged(t *testing.T) {
+	var b bytes.Buffer
+	d, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	f := d.WithLogger(slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if err := f.InsertJobIntoDB(Job{ClusterID: 1, Group: "nova", Num: 2, Role: "Analysis", Owner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []struct {
+		name string
+		run  func() error
+	}{
+		{"GetJob", func() error { _, err := f.GetJob(1); return err }},
+		{"GetProc", func() error { _, err := f.GetProc(1, 0); return err }},
+		{"ProcExists", func() error { _, err := f.ProcExists(1, 0); return err }},
+		{"ClusterProcs", func() error { _, err := f.ClusterProcs(1); return err }},
+		{"UpdateJob", func() error { return f.UpdateJob(1, "memory", 4000) }},
+		{"RetrieveTotalsFromDB", func() error { _, _, err := f.RetrieveTotalsFromDB(Filter{}, "owner"); return err }},
+		{"DAGs", func() error { _, err := f.DAGs(DAGRunning); return err }},
+		{"DAGNodes", func() error { _, err := f.DAGNodes(1); return err }},
+		{"SetDAGStatus", func() error { return f.SetDAGStatus(1, DAGRunning, "") }},
+	}
+	for _, q := range queries {
+		t.Run(q.name, func(t *testing.T) {
+			b.Reset()
+			if err := q.run(); err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if !strings.Contains(b.String(), "msg=\"ran query\"") || !strings.Contains(b.String(), "elapsed=") {
+				t.Errorf("Expected the query to be logged with how long it took.  Got %q", b.String())
+			}
+		})
+	}
+}
+
+// BenchmarkInsertJobIntoDB measures how fast big clusters are submitted.  Each iteration inserts a new cluster of n procs
+func BenchmarkInsertJobIntoDB(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		b.Run(fmt.Sprintf("%dprocs", n), func(b *testing.B) {
+			f, err := CreateOrOpenDB(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer f.Close()
+
+			b.ResetTimer()
+			for i := range b.N {
+				if err := f.InsertJobIntoDB(Job{ClusterID: i + 1, Group: "fermilab", Num: n, Role: "Analysis"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "procs/s")
+		})
+	}
+}
+
 // There should be other tests to ensure that the database is opened or created properly, that the various db-changing/retrieving methods work correctly, etc.
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// SortKey orders a listing by one of its columns
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// String returns k in the form ParseSort accepts
+func (k SortKey) String() string {
+	if k.Desc {
+		return "-" + k.Column
+	}
+	return k.Column
+}
+
+// ParseSort parses a comma-separated list of columns to sort by, like "clusterid,-num".  A leading - sorts by that column in
+// descending order.  Whether the columns exist is checked when the listing is retrieved, since that depends on what is listed
+func ParseSort(s string) ([]SortKey, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	keys := make([]SortKey, 0)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		key := SortKey{Column: strings.TrimPrefix(field, "-")}
+		key.Desc = key.Column != field
+		if key.Column == "" {
+			return nil, fmt.Errorf("invalid sort %q: empty column", s)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Row is a row of a listing.  Key holds the row's values of the columns the listing is sorted by, followed by the values that
+// identify the row (like its cluster ID and proc ID).  Rows are listed in order of their keys, as compared by CompareKeys
+type Row struct {
+	Values []string
+	Key    []any
+}
+
+// Listing is the result of listing jobs, procs, or DAG nodes:  the names of the columns requested, and the rows that matched.
+// Rows are read from the database as they are iterated.  Each iteration runs the query again, so it may see rows that changed
+// in between
+type Listing struct {
+	Header []string
+	Rows   iter.Seq2[Row, error]
+
+	query string // The SQL query that Rows runs, for QueryPlan
+	args  []any
+}
+
+// Lines yields the listing as lines of tab-separated values, the first of which is the header.  The header is only yielded
+// once the first row (or the end of the rows) has been read, so that a listing that fails from the start yields nothing but the
+// error
+func (l *Listing) Lines() iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		next, stop := iter.Pull2(l.Rows)
+		defer stop()
+
+		row, err, ok := next()
+		if err != nil {
+			yield("", err)
+			return
+		}
+		if !yield(strings.Join(l.Header, "\t"), nil) {
+			return
+		}
+		for ; ok; row, err, ok = next() {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			if !yield(strings.Join(row.Values, "\t"), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect returns all of Lines.  It is for listings that are known to be small
+func (l *Listing) Collect() ([]string, error) {
+	lines := make([]string, 0)
+	for line, err := range l.Lines() {
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// QueryPlan returns SQLite's plan for the query that iterating the listing's rows runs, as a tree like the sqlite3 shell's
+// .eqp output.  The rows are not read
+func (f FakeJobsubDB) QueryPlan(l *Listing) ([]string, error) {
+	rows, err := f.DB.Query("EXPLAIN QUERY PLAN "+l.query+" ;", l.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Each step of the plan has the ID of the step it is part of
+	type step struct {
+		id     int
+		detail string
+	}
+	children := make(map[int][]step)
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		children[parent] = append(children[parent], step{id, detail})
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	plan := []string{"QUERY PLAN"}
+	var walk func(parent int, indent string)
+	walk = func(parent int, indent string) {
+		for i, s := range children[parent] {
+			branch, next := "|--", "|  "
+			if i == len(children[parent])-1 {
+				branch, next = "`--", "   "
+			}
+			plan = append(plan, indent+branch+s.detail)
+			walk(s.id, indent+next)
+		}
+	}
+	walk(0, "")
+	return plan, nil
+}
+
+// CompareKeys compares the row keys a and b from listings sorted by sort, returning -1 if a comes first, 1 if b does, and 0 if
+// they are equal.  If one key is a prefix of the other, only the prefix is compared.  Like SQLite, numbers come before strings
+func CompareKeys(sort []SortKey, a, b []any) int {
+	for i := range min(len(a), len(b)) {
+		c := compareValues(a[i], b[i])
+		if i < len(sort) && sort[i].Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+		return -1
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+		return 1
+	}
+	return 0
+}
+
+// keyExprs returns the SQL expressions for the keys of rows from the from clause:  those of the sort columns, which must be
+// among validCols, followed by id
+func keyExprs(validCols []column, sort []SortKey, id []string) ([]string, error) {
+	exprs := make([]string, 0, len(sort)+len(id))
+	for _, key := range sort {
+		def, ok := definitionOf(validCols, key.Column)
+		if !ok {
+			return nil, fmt.Errorf("invalid sort column: %s", key.Column)
+		}
+		exprs = append(exprs, def)
+	}
+	return append(exprs, id...), nil
+}
+
+// orderBy returns the ORDER BY clause that lists rows in order of the keys given by exprs
+func orderBy(exprs []string, sort []SortKey) string {
+	terms := make([]string, 0, len(exprs))
+	for i, expr := range exprs {
+		if i < len(sort) && sort[i].Desc {
+			expr += " DESC"
+		}
+		terms = append(terms, expr)
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// afterCondition returns a condition that holds for rows whose keys, given by exprs, come after key, which may be a prefix of
+// them, along with the arguments for its placeholders.  Rows whose keys start with key are included if inclusive is set
+func afterCondition(exprs []string, sort []SortKey, key []any, inclusive bool) (string, []any, error) {
+	if len(key) > len(exprs) {
+		return "", nil, errors.New("invalid position:  too many values")
+	}
+	if len(key) == 0 {
+		return "1", nil, nil
+	}
+	var cond string
+	var args []any
+	for i := len(key) - 1; i >= 0; i-- {
+		switch key[i].(type) {
+		case int64, string:
+		default:
+			return "", nil, fmt.Errorf("invalid position:  value %v is type %T", key[i], key[i])
+		}
+		op := ">"
+		if i < len(sort) && sort[i].Desc {
+			op = "<"
+		}
+
+		// The last value is compared on its own, so that SQLite can use an index to find where the rows start
+		if i == len(key)-1 {
+			if inclusive {
+				op += "="
+			}
+			cond, args = fmt.Sprintf("%s %s ?", exprs[i], op), []any{key[i]}
+			continue
+		}
+		cond = fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s))", exprs[i], op, cond)
+		args = append([]any{key[i], key[i]}, args...)
+	}
+	return cond, args, nil
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		s          string
+		expected   []SortKey
+		shouldFail bool
+	}{
+		{"", nil, false},
+		{"clusterid", []SortKey{{"clusterid", false}}, false},
+		{"clusterid, -num", []SortKey{{"clusterid", false}, {"num", true}}, false},
+		{"num,", nil, true},
+		{"-", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			keys, err := ParseSort(test.s)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %v instead", keys)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if !slices.Equal(keys, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, keys)
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	rows := func(n int, err error) func(yield func(Row, error) bool) {
+		return func(yield func(Row, error) bool) {
+			for i := range n {
+				if !yield(Row{Values: []string{fmt.Sprint(i), "alice"}}, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(Row{}, err)
+			}
+		}
+	}
+	errRows := errors.New("rows failed")
+
+	tests := []struct {
+		name     string
+		n        int
+		err      error
+		expected []string
+	}{
+		{"rows", 2, nil, []string{"clusterid\towner", "0\talice", "1\talice"}},
+		{"no rows", 0, nil, []string{"clusterid\towner"}},
+		{"error after rows", 1, errRows, []string{"clusterid\towner", "0\talice"}},
+		{"error at first row", 0, errRows, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Listing{Header: []string{"clusterid", "owner"}, Rows: rows(test.n, test.err)}
+			lines := make([]string, 0)
+			var err error
+			for line, lineErr := range l.Lines() {
+				if lineErr != nil {
+					err = lineErr
+					break
+				}
+				lines = append(lines, line)
+			}
+			if !errors.Is(err, test.err) || !slices.Equal(lines, test.expected) {
+				t.Errorf("Expected lines %q and error %v.  Got %q, %v instead", test.expected, test.err, lines, err)
+			}
+		})
+	}
+}
+
+func TestRetrieveJobsSorted(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, num := range []int{3, 1, 3, 2} {
+		if err := f.InsertJobIntoDB(Job{ClusterID: i + 1, Group: "fermilab", Num: num, Role: "Analysis"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort := []SortKey{{"num", true}}
+
+	list := func(filter Filter) string {
+		listing, err := f.RetrieveJobsFromDB(filter, "clusterid")
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		rows, err := listing.Collect()
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		return strings.Join(rows[1:], ",")
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected string // Cluster IDs listed
+	}{
+		{"unsorted rows are in ID order", Filter{}, "1,2,3,4"},
+		{"descending, with ties in ID order", Filter{Sort: sort}, "1,3,4,2"},
+		{"limit", Filter{Sort: sort, Limit: 2}, "1,3"},
+		{"after a row", Filter{Sort: sort, Limit: 2, After: []any{int64(3), int64(3)}}, "4,2"},
+		{"after sort values", Filter{Sort: sort, After: []any{int64(3)}}, "4,2"},
+		{"from sort values", Filter{Sort: sort, After: []any{int64(3)}, AfterInclusive: true}, "1,3,4,2"},
+		{"after the last row", Filter{ClusterID: 2, After: []any{int64(2)}}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ids := list(test.filter); ids != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, ids)
+			}
+		})
+	}
+
+	t.Run("rows are streamed", func(t *testing.T) {
+		listing, err := f.RetrieveJobsFromDB(Filter{}, "clusterid")
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		// Rows added before the listing is iterated are listed, and stopping early releases the query so the table can be written
+		if err := f.InsertJobIntoDB(Job{ClusterID: 5, Group: "fermilab", Num: 1, Role: "Analysis"}); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range listing.Rows {
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if n++; n == 2 {
+				break
+			}
+		}
+		if err := f.UpdateJob(5, "memory", 4000); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if ids := list(Filter{After: []any{int64(4)}}); ids != "5" {
+			t.Errorf("Expected 5, got %s", ids)
+		}
+	})
+
+	t.Run("invalid sort column", func(t *testing.T) {
+		if _, err := f.RetrieveJobsFromDB(Filter{Sort: []SortKey{{"grp; DROP TABLE jobs", false}}}); err == nil {
+			t.Error("Should have gotten an error for an invalid sort column")
+		}
+	})
+
+	t.Run("keys are compared like SQLite", func(t *testing.T) {
+		if c := CompareKeys(sort, []any{int64(3), int64(1)}, []any{int64(2), int64(0)}); c != -1 {
+			t.Errorf("Expected -1, got %d", c)
+		}
+		if c := CompareKeys(nil, []any{int64(9)}, []any{"a"}); c != -1 {
+			t.Errorf("Numbers should come before strings.  Got %d", c)
+		}
+	})
+}
+
+func TestQueryPlan(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name     string
+		list     func() (*Listing, error)
+		expected string // Expected to be in the plan
+	}{
+		{"jobs by owner", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Owner: "me"}) }, "INDEX jobs_owner (owner=?)"},
+		{"jobs by group", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Group: "nova"}) }, "INDEX jobs_grp (grp=?)"},
+		{"cluster", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{ClusterID: 3}) }, "USING INTEGER PRIMARY KEY"},
+		{"deep page", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{After: []any{int64(900)}}) }, "PRIMARY KEY (rowid>?)"},
+		{"history by owner", func() (*Listing, error) { return f.RetrieveHistoryFromDB(Filter{Owner: "me"}) }, "INDEX jobs_owner (owner=?)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listing, err := test.list()
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			plan, err := f.QueryPlan(listing)
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if plan[0] != "QUERY PLAN" || !strings.Contains(strings.Join(plan, "\n"), test.expected) {
+				t.Errorf("Expected plan using %q.  Got %q", test.expected, plan)
+			}
+		})
+	}
+}
+
+const benchmarkQueueSize = 1000000
+
+// seedBenchmarkQueue creates a queue of benchmarkQueueSize clusters of 1-4 procs from 1000 owners in 20 groups, with a quarter of
+// the clusters completed
+func seedBenchmarkQueue(b *testing.B) FakeJobsubDB {
+	f, err := CreateOrOpenDB(filepath.Join(b.TempDir(), "queue.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { f.Close() })
+
+	start := time.Now()
+	jobs := make([]Job, 0, 10000)
+	for i := range benchmarkQueueSize {
+		jobs = append(jobs, Job{ClusterID: i + 1, Group: fmt.Sprintf("group%d", i%20), Num: 1 + i%4, Role: "Analysis",
+			Owner: fmt.Sprintf("user%d", i%1000), QDate: start.Add(time.Duration(i) * time.Second)})
+		if len(jobs) == cap(jobs) {
+			if err := f.InsertJobsIntoDB(jobs); err != nil {
+				b.Fatalf("Could not seed queue: %s", err)
+			}
+			jobs = jobs[:0]
+		}
+	}
+	if _, err := f.Exec("UPDATE procs SET status = ? WHERE clusterid % 4 = 0 ;", Completed); err != nil {
+		b.Fatalf("Could not seed queue: %s", err)
+	}
+	return f
+}
+
+// BenchmarkList measures how long listing takes for common filters on a queue of a million clusters.  Each iteration reads all
+// of the rows listed.  The queue is seeded once, since a benchmark with sub-benchmarks only runs once
+func BenchmarkList(b *testing.B) {
+	if testing.Short() {
+		b.Skip("Seeding the queue takes too long for -short")
+	}
+	f := seedBenchmarkQueue(b)
+	sortByNum := []SortKey{{"num", true}}
+
+	benchmarks := []struct {
+		name string
+		list func() (*Listing, error)
+	}{
+		{"cluster", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{ClusterID: 500000}) }},
+		{"owner", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Owner: "user42"}) }},
+		{"owner procs", func() (*Listing, error) { return f.RetrieveProcsFromDB(Filter{Owner: "user42"}) }},
+		{"owner history", func() (*Listing, error) { return f.RetrieveHistoryFromDB(Filter{Owner: "user42"}) }},
+		{"group first page", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Group: "group7", Limit: 100}) }},
+		{"first page", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Limit: 100}) }},
+		{"deep page", func() (*Listing, error) {
+			return f.RetrieveJobsFromDB(Filter{Limit: 100, After: []any{int64(900000)}})
+		}},
+		{"sorted first page", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Sort: sortByNum, Limit: 100}) }},
+		{"owner sorted", func() (*Listing, error) { return f.RetrieveJobsFromDB(Filter{Owner: "user42", Sort: sortByNum}) }},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for range b.N {
+				listing, err := bm.list()
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, err := range listing.Rows {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
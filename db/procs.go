@@ -0,0 +1,352 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// JobStatus is the status of a single job (proc).  The values match HTCondor's JobStatus attribute
+type JobStatus int
+
+// Job statuses
+const (
+	Idle      JobStatus = 1
+	Running   JobStatus = 2
+	Removed   JobStatus = 3
+	Completed JobStatus = 4
+	Held      JobStatus = 5
+)
+
+func (s JobStatus) String() string {
+	switch s {
+	case Idle:
+		return "Idle"
+	case Running:
+		return "Running"
+	case Removed:
+		return "Removed"
+	case Completed:
+		return "Completed"
+	case Held:
+		return "Held"
+	default:
+		return fmt.Sprintf("JobStatus(%d)", int(s))
+	}
+}
+
+// AllProcs can be passed as a procID to operate on all procs in a cluster
+const AllProcs = -1
+
+// SetProcStatus sets the status of proc procID (or all procs, if procID is AllProcs) in the cluster to status, but only
+// for procs whose current status is one of from.  It returns the IDs of the procs that were changed
+func (f FakeJobsubDB) SetProcStatus(clusterID, procID int, from []JobStatus, status JobStatus) ([]int, error) {
+	changed := make([]int, 0)
+	if len(from) == 0 {
+		return changed, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(from)-1) + "?"
+	query := "UPDATE procs SET status = ? WHERE clusterid = ? AND status IN (" + placeholders + ")"
+	args := []any{status, clusterID}
+	for _, s := range from {
+		args = append(args, s)
+	}
+	if procID != AllProcs {
+		query += " AND procid = ?"
+		args = append(args, procID)
+	}
+
+	stmt, err := f.stmt(query + " RETURNING procid ;")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		changed = append(changed, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// ProcExists reports whether the cluster has a proc procID
+func (f FakeJobsubDB) ProcExists(clusterID, procID int) (bool, error) {
+	query := "SELECT COUNT(*) FROM procs WHERE clusterid = ? AND procid = ? ;"
+	start := time.Now()
+	var count int
+	err := f.DB.QueryRow(query, clusterID, procID).Scan(&count)
+	f.logQuery(query, start, err)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// EditableColumns are the keys that can be changed with UpdateJob
+var EditableColumns = []string{"role", "memory", "disk", "cpus", "gpus", "lifetime"}
+
+// UpdateJob sets key to value for the cluster.  key must be one of EditableColumns.  If there is no such cluster, the error
+// wraps sql.ErrNoRows
+func (f FakeJobsubDB) UpdateJob(clusterID int, key string, value any) error {
+	if !slices.Contains(EditableColumns, key) {
+		return fmt.Errorf("column %s cannot be edited.  Editable columns are %v", key, EditableColumns)
+	}
+
+	idx := slices.IndexFunc(listColumns, func(c column) bool { return c.name == key })
+	col := strings.TrimPrefix(listColumns[idx].definition, "j.")
+	result, err := f.exec("UPDATE jobs SET "+col+" = ? WHERE clusterid = ? ;", value, clusterID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update cluster %d: %w", clusterID, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no such cluster %d: %w", clusterID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Proc is a single job (proc) in a cluster, along with its cluster's attributes
+type Proc struct {
+	Job
+	ProcID   int
+	Status   JobStatus
+	Slot     string
+	Start    time.Time
+	End      time.Time
+	Reason   string
+	ExitCode int
+
+	Attempts   int       // How many times the job has started running
+	RetryAfter time.Time // When the idle job may next be matched, if it is being retried.  Zero otherwise
+}
+
+// simExitCode returns the exit code of the proc's current attempt in the simulated pool
+func (p Proc) simExitCode() int {
+	if len(p.SimExitCodes) == 0 {
+		return 0
+	}
+	return p.SimExitCodes[min(max(p.Attempts, 1), len(p.SimExitCodes))-1]
+}
+
+// procSelectColumns are the columns selected from the procs table (aliased as p) by scanProc
+const procSelectColumns = "p.procid, p.status, p.slot, p.start_time, p.end_time, p.reason, p.exitcode, p.attempts, p.retry_after"
+
+func scanProc(row scanner) (Proc, error) {
+	var p Proc
+	var start, end, retryAfter int64
+	job, err := scanJob(row, &p.ProcID, &p.Status, &p.Slot, &start, &end, &p.Reason, &p.ExitCode, &p.Attempts, &retryAfter)
+	if err != nil {
+		return p, err
+	}
+	p.Job = job
+	p.Start = time.Unix(start, 0)
+	p.End = time.Unix(end, 0)
+	if retryAfter > 0 {
+		p.RetryAfter = time.Unix(retryAfter, 0)
+	}
+	return p, nil
+}
+
+// GetProc returns proc procID of the cluster with the given clusterID
+func (f FakeJobsubDB) GetProc(clusterID, procID int) (Proc, error) {
+	query := "SELECT " + jobSelectColumns + ", " + procSelectColumns + `
+		FROM procs p JOIN jobs j ON p.clusterid = j.clusterid
+		WHERE p.clusterid = ? AND p.procid = ? ;`
+	start := time.Now()
+	p, err := scanProc(f.DB.QueryRow(query, clusterID, procID))
+	f.logQuery(query, start, err)
+	return p, err
+}
+
+// Procs returns all procs with the given status, oldest cluster first
+func (f FakeJobsubDB) Procs(status JobStatus) ([]Proc, error) {
+	query := "SELECT " + jobSelectColumns + ", " + procSelectColumns + `
+		FROM procs p JOIN jobs j ON p.clusterid = j.clusterid
+		WHERE p.status = ?
+		ORDER BY j.qdate, p.clusterid, p.procid ;`
+	return f.queryProcs(query, status)
+}
+
+// ClusterProcs returns all of the procs in the cluster with the given clusterID, in order
+func (f FakeJobsubDB) ClusterProcs(clusterID int) ([]Proc, error) {
+	query := "SELECT " + jobSelectColumns + ", " + procSelectColumns + `
+		FROM procs p JOIN jobs j ON p.clusterid = j.clusterid
+		WHERE p.clusterid = ?
+		ORDER BY p.procid ;`
+	return f.queryProcs(query, clusterID)
+}
+
+func (f FakeJobsubDB) queryProcs(query string, args ...any) (procs []Proc, err error) {
+	start := time.Now()
+	defer func() { f.logQuery(query, start, err, "rows", len(procs)) }()
+	rows, err := f.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	procs = make([]Proc, 0)
+	for rows.Next() {
+		p, err := scanProc(rows)
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, p)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return procs, nil
+}
+
+// StartProc marks the idle proc as running in slot from start until end, and counts the attempt.  It returns false if the
+// proc was not idle
+func (f FakeJobsubDB) StartProc(clusterID, procID int, slot string, start, end time.Time) (bool, error) {
+	result, err := f.exec(
+		`UPDATE procs SET status = ?, slot = ?, start_time = ?, end_time = ?, reason = '', attempts = attempts + 1, retry_after = 0
+		WHERE clusterid = ? AND procid = ? AND status = ? ;`,
+		Running, slot, start.Unix(), end.Unix(), clusterID, procID, Idle,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CompleteProcs marks running procs whose end time is at or before now as completed, with the exit code that the cluster's
+// SimExitCodes give for the attempt, and returns them.  They are all marked in one transaction
+func (f FakeJobsubDB) CompleteProcs(now time.Time) ([]Proc, error) {
+	due := make([]Proc, 0)
+	running, err := f.Procs(Running)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := f.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // No-op if the transaction is committed
+	complete, err := f.txStmt(tx, "UPDATE procs SET status = ?, exitcode = ? WHERE clusterid = ? AND procid = ? AND status = ? ;")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range running {
+		if p.End.After(now) {
+			continue
+		}
+		p.ExitCode = p.simExitCode()
+		result, err := complete.Exec(Completed, p.ExitCode, p.ClusterID, p.ProcID, Running)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n > 0 {
+			p.Status = Completed
+			due = append(due, p)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// RequeueProc puts the completed proc back in the queue to be retried, not before retryAfter.  reason says why it is idle.
+// It returns false if the proc was not completed
+func (f FakeJobsubDB) RequeueProc(clusterID, procID int, retryAfter time.Time, reason string) (bool, error) {
+	result, err := f.exec(
+		"UPDATE procs SET status = ?, retry_after = ?, reason = ? WHERE clusterid = ? AND procid = ? AND status = ? ;",
+		Idle, retryAfter.Unix(), reason, clusterID, procID, Completed,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SetProcReason records why the idle proc did not match in the last negotiation cycle
+func (f FakeJobsubDB) SetProcReason(clusterID, procID int, reason string) error {
+	_, err := f.exec("UPDATE procs SET reason = ? WHERE clusterid = ? AND procid = ? ;", reason, clusterID, procID)
+	return err
+}
+
+// procListColumns are the columns (keys) that can be requested from RetrieveProcsFromDB, and the SQL expressions used to get them.
+// The first defaultProcListColumns of them are returned if no columns are requested
+var procListColumns = []column{
+	{"clusterid", "p.clusterid"},
+	{"procid", "p.procid"},
+	{"group", "j.grp"},
+	{"owner", "j.owner"},
+	{"status", procStatusExpr},
+	{"site", "CASE WHEN instr(p.slot, '@') > 0 THEN substr(p.slot, instr(p.slot, '@') + 1) ELSE '' END"},
+	{"slot", "p.slot"},
+	{"reason", "p.reason"},
+	{"start", "p.start_time"},
+	{"end", "p.end_time"},
+	{"exitcode", "p.exitcode"},
+	{"attempts", "p.attempts"},
+	{"retry_after", "p.retry_after"},
+}
+
+const defaultProcListColumns = 8
+
+// ProcListColumns returns the names of the columns (keys) that can be requested from RetrieveProcsFromDB
+func ProcListColumns() []string {
+	return namesOf(procListColumns)
+}
+
+var procStatusExpr = fmt.Sprintf(`CASE p.status
+		WHEN %[1]d THEN '%[1]s'
+		WHEN %[2]d THEN '%[2]s'
+		WHEN %[3]d THEN '%[3]s'
+		WHEN %[4]d THEN '%[4]s'
+		WHEN %[5]d THEN '%[5]s'
+		ELSE 'Unknown' END`, Idle, Running, Removed, Completed, Held)
+
+// RetrieveProcsFromDB lists the individual jobs (procs) in clusters that match filter, returning the cols requested (or the default
+// columns, if none are requested).  Rows are identified by their cluster ID and proc ID.  If filter.ClusterID is set and there is no such cluster,
+// iterating the rows yields sql.ErrNoRows
+func (f FakeJobsubDB) RetrieveProcsFromDB(filter Filter, cols ...string) (*Listing, error) {
+	return f.retrieveRows("procs p JOIN jobs j ON p.clusterid = j.clusterid", procID, procListColumns[:defaultProcListColumns], procListColumns, filter, cols)
+}
+
+// historyColumns are the columns (keys) returned by RetrieveHistoryFromDB if none are requested.  Any of procListColumns may be
+// requested
+var historyColumns = []string{"clusterid", "procid", "owner", "status", "attempts", "exitcode", "end"}
+
+// RetrieveHistoryFromDB is like RetrieveProcsFromDB, but lists only the jobs that have left the queue, because they completed
+// or were removed, like condor_history
+func (f FakeJobsubDB) RetrieveHistoryFromDB(filter Filter, cols ...string) (*Listing, error) {
+	defaultCols := make([]column, 0, len(historyColumns))
+	for _, name := range historyColumns {
+		defaultCols = append(defaultCols, procListColumns[slices.IndexFunc(procListColumns, func(c column) bool { return c.name == name })])
+	}
+	// Most procs have usually left the queue, so the index on status would only slow history down.  The unary + keeps SQLite
+	// from using it, so that it finds the owner's or group's clusters first instead
+	from := fmt.Sprintf("(SELECT * FROM procs WHERE +status IN (%d, %d)) p JOIN jobs j ON p.clusterid = j.clusterid", Completed, Removed)
+	return f.retrieveRows(from, procID, defaultCols, procListColumns, filter, cols)
+}
+
+// procID identifies the rows of RetrieveProcsFromDB and RetrieveHistoryFromDB
+var procID = []string{"p.clusterid", "p.procid"}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSetProcStatus(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.InsertJobIntoDB(Job{ClusterID: 1, Group: "nova", Num: 4, Role: "Analysis", Owner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := f.SetProcStatus(1, 2, []JobStatus{Idle}, Running)
+	if err != nil || !slices.Equal(changed, []int{2}) {
+		t.Errorf("Should have changed proc 2 with nil error.  Got %v, %v instead", changed, err)
+	}
+
+	// Only the idle procs should be held
+	changed, err = f.SetProcStatus(1, AllProcs, []JobStatus{Idle}, Held)
+	if err != nil || !slices.Equal(changed, []int{0, 1, 3}) {
+		t.Errorf("Should have changed procs 0, 1, and 3 with nil error.  Got %v, %v instead", changed, err)
+	}
+
+	changed, err = f.SetProcStatus(1, AllProcs, nil, Removed)
+	if err != nil || len(changed) != 0 {
+		t.Errorf("Should have changed no procs with nil error.  Got %v, %v instead", changed, err)
+	}
+}
+
+func TestJobStatusString(t *testing.T) {
+	if Held.String() != "Held" {
+		t.Errorf("Expected Held, got %s", Held)
+	}
+	if JobStatus(42).String() != "JobStatus(42)" {
+		t.Errorf("Expected JobStatus(42), got %s", JobStatus(42))
+	}
+}
+
+func TestUpdateJob(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.InsertJobIntoDB(Job{ClusterID: 1, Group: "nova", Num: 1, Role: "Analysis", Owner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UpdateJob(1, "memory", 4000); err != nil {
+		t.Errorf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if err := f.UpdateJob(2, "memory", 4000); !errors.Is(err, sql.ErrNoRows) || err.Error() != "no such cluster 2: "+sql.ErrNoRows.Error() {
+		t.Errorf("Should have gotten error indicating there is no cluster 2.  Got %v instead", err)
+	}
+	if err := f.UpdateJob(1, "owner", "bob"); err == nil {
+		t.Error("Should have gotten an error editing a column that is not editable.  Got nil instead")
+	}
+}
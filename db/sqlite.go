@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// busyTimeout is how long a connection waits for another process (like a negotiation cycle in another fakeJobsub) to finish
+// writing before giving up with SQLITE_BUSY
+const busyTimeout = 5 * time.Second
+
+// openSQLite opens the SQLite database file fn.  It is opened in WAL mode, so that listing jobs doesn't block writes and each
+// commit doesn't have to sync the whole database.  Transactions take the write lock when they begin, so that two writers can't
+// deadlock upgrading their read locks
+func openSQLite(fn string) (*sql.DB, error) {
+	return sql.Open("sqlite3", sqliteDSN(fn))
+}
+
+// sqliteDSN returns the SQLite URI filename that opens fn as openSQLite does.  fn is escaped, so that a ?, #, or % in it is
+// part of the file name rather than the start of the parameters
+func sqliteDSN(fn string) string {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	params.Set("_txlock", "immediate")
+	u := url.URL{Scheme: "file", Opaque: (&url.URL{Path: fn}).EscapedPath(), RawQuery: params.Encode()}
+	return u.String()
+}
+
+// stmtCache holds the prepared statements for queries that are run often, so that they are only prepared once
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// stmt returns the prepared statement for query, preparing it if this is the first time it has been run
+func (f FakeJobsubDB) stmt(query string) (*sql.Stmt, error) {
+	f.stmts.mu.Lock()
+	defer f.stmts.mu.Unlock()
+	if stmt, ok := f.stmts.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := f.DB.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	f.stmts.stmts[query] = stmt
+	return stmt, nil
+}
+
+// exec runs query, which is prepared once, with args
+func (f FakeJobsubDB) exec(query string, args ...any) (sql.Result, error) {
+	stmt, err := f.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	result, err := stmt.Exec(args...)
+	f.logQuery(query, start, err)
+	return result, err
+}
+
+// txStmt returns the prepared statement for query for use in tx
+func (f FakeJobsubDB) txStmt(tx *sql.Tx, query string) (*sql.Stmt, error) {
+	stmt, err := f.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Stmt(stmt), nil
+}
+
+// WithLogger returns f, logging its queries to logger
+func (f FakeJobsubDB) WithLogger(logger *slog.Logger) FakeJobsubDB {
+	f.logger = logger
+	return f
+}
+
+// log returns the logger f logs to, which is slog's default logger unless WithLogger gave it another
+func (f FakeJobsubDB) log() *slog.Logger {
+	if f.logger == nil {
+		return slog.Default()
+	}
+	return f.logger
+}
+
+// logQuery logs at debug level how long query took since start, and its error if it failed.  attrs are added to the record
+func (f FakeJobsubDB) logQuery(query string, start time.Time, err error, attrs ...any) {
+	logQuery(f.log(), query, start, err, attrs...)
+}
+
+// logQuery logs query to logger as FakeJobsubDB.logQuery does
+func logQuery(logger *slog.Logger, query string, start time.Time, err error, attrs ...any) {
+	attrs = append([]any{"query", strings.Join(strings.Fields(query), " "), "elapsed", time.Since(start)}, attrs...)
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	logger.Debug("ran query", attrs...)
+}
+
+// Close closes the prepared statements and the database
+func (f FakeJobsubDB) Close() error {
+	f.stmts.mu.Lock()
+	errs := make([]error, 0)
+	for query, stmt := range f.stmts.stmts {
+		errs = append(errs, stmt.Close())
+		delete(f.stmts.stmts, query)
+	}
+	f.stmts.mu.Unlock()
+	return errors.Join(append(errs, f.DB.Close())...)
+}
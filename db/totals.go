@@ -0,0 +1,118 @@
+package db
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Totals are aggregate counts of the clusters and jobs (procs) in a queue
+type Totals struct {
+	Key      string // The value of the column the totals are grouped by, or blank if they aren't grouped
+	Clusters int
+	Jobs     int
+	Num      int // Sum of the clusters' num
+	ByStatus map[JobStatus]int
+}
+
+// Add adds other's counts to t
+func (t *Totals) Add(other Totals) {
+	t.Clusters += other.Clusters
+	t.Jobs += other.Jobs
+	t.Num += other.Num
+	if t.ByStatus == nil {
+		t.ByStatus = make(map[JobStatus]int)
+	}
+	for status, n := range other.ByStatus {
+		t.ByStatus[status] += n
+	}
+}
+
+// totalsGroupByColumns are the columns that RetrieveTotalsFromDB can group by, and the SQL expressions for them
+var totalsGroupByColumns = map[string]string{
+	"clusterid": "j.clusterid",
+	"group":     "j.grp",
+	"owner":     "j.owner",
+	"status":    procStatusExpr,
+}
+
+// TotalsGroupByColumns returns the names of the columns that RetrieveTotalsFromDB can group by
+func TotalsGroupByColumns() []string {
+	return slices.Sorted(maps.Keys(totalsGroupByColumns))
+}
+
+// totalsStatuses are the statuses counted in Totals.ByStatus
+var totalsStatuses = []JobStatus{Idle, Running, Removed, Completed, Held}
+
+// RetrieveTotalsFromDB counts the clusters and jobs that match filter, grouped by the column groupBy, which must be one of
+// TotalsGroupByColumns or blank for a single group.  The groups are sorted by key.  A cluster whose jobs are in more than one
+// group (as when grouping by status) is counted, along with its num, in each of them, but only once in the overall total,
+// which is also returned
+func (f FakeJobsubDB) RetrieveTotalsFromDB(filter Filter, groupBy string) (totals []Totals, total Totals, err error) {
+	total = Totals{ByStatus: make(map[JobStatus]int)}
+	key := "''"
+	if groupBy != "" {
+		var ok bool
+		if key, ok = totalsGroupByColumns[groupBy]; !ok {
+			return nil, total, fmt.Errorf("invalid group-by column: %s.  Choose from %v", groupBy, TotalsGroupByColumns())
+		}
+	}
+
+	// First count each cluster's jobs by key and status, then add those up by key, and overall.  rn and rn_all pick one row
+	// per cluster and key, and per cluster, so that each cluster's num is only counted once per group, and once overall
+	statusSums := ""
+	for _, status := range totalsStatuses {
+		statusSums += fmt.Sprintf(", COALESCE(SUM(CASE WHEN status = %d THEN jobs ELSE 0 END), 0)", status)
+	}
+	where, args := filter.where()
+	query := `
+		WITH c AS (
+			SELECT j.clusterid AS clusterid, j.num AS num, ` + key + ` AS k, p.status AS status, COUNT(*) AS jobs,
+				ROW_NUMBER() OVER (PARTITION BY j.clusterid, ` + key + `) AS rn,
+				ROW_NUMBER() OVER (PARTITION BY j.clusterid) AS rn_all
+			FROM procs p JOIN jobs j ON p.clusterid = j.clusterid` + where + `
+			GROUP BY j.clusterid, k, p.status
+		)
+		SELECT * FROM (
+			SELECT 0, k, COUNT(DISTINCT clusterid), SUM(jobs), SUM(CASE WHEN rn = 1 THEN num ELSE 0 END)` + statusSums + `
+			FROM c GROUP BY k
+			UNION ALL
+			SELECT 1, '', COUNT(DISTINCT clusterid), COALESCE(SUM(jobs), 0), COALESCE(SUM(CASE WHEN rn_all = 1 THEN num ELSE 0 END), 0)` + statusSums + `
+			FROM c
+		) ORDER BY 1, 2 ;`
+
+	start := time.Now()
+	defer func() { f.logQuery(query, start, err, "groups", len(totals)) }()
+	rows, err := f.DB.Query(query, args...)
+	if err != nil {
+		return nil, total, err
+	}
+	defer rows.Close()
+
+	totals = make([]Totals, 0)
+	for rows.Next() {
+		var isTotal bool
+		t := Totals{ByStatus: make(map[JobStatus]int)}
+		counts := make([]int, len(totalsStatuses))
+		dest := []any{&isTotal, &t.Key, &t.Clusters, &t.Jobs, &t.Num}
+		for idx := range counts {
+			dest = append(dest, &counts[idx])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, total, err
+		}
+		for idx, status := range totalsStatuses {
+			t.ByStatus[status] = counts[idx]
+		}
+		if isTotal {
+			total = t
+			continue
+		}
+		totals = append(totals, t)
+	}
+	if rows.Err() != nil {
+		return nil, total, rows.Err()
+	}
+	return totals, total, nil
+}
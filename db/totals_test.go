@@ -0,0 +1,80 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRetrieveTotalsFromDB(t *testing.T) {
+	f, err := CreateOrOpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []Job{
+		{ClusterID: 1, Group: "nova", Num: 3, Owner: "alice"},
+		{ClusterID: 2, Group: "nova", Num: 1, Owner: "bob"},
+		{ClusterID: 3, Group: "dune", Num: 2, Owner: "alice"},
+	}
+	for _, j := range jobs {
+		if err := f.InsertJobIntoDB(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Cluster 1 has a running job and two idle ones.  Cluster 3 is held
+	if _, err := f.SetProcStatus(1, 0, []JobStatus{Idle}, Running); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.SetProcStatus(3, AllProcs, []JobStatus{Idle}, Held); err != nil {
+		t.Fatal(err)
+	}
+
+	type counts struct {
+		key                             string
+		clusters, jobs, num, idle, held int
+	}
+	tests := []struct {
+		name     string
+		filter   Filter
+		groupBy  string
+		expected []counts
+		total    counts
+	}{
+		{"total", Filter{}, "", []counts{{"", 3, 6, 6, 3, 2}}, counts{"", 3, 6, 6, 3, 2}},
+		{"by group", Filter{}, "group", []counts{{"dune", 1, 2, 2, 0, 2}, {"nova", 2, 4, 4, 3, 0}}, counts{"", 3, 6, 6, 3, 2}},
+		{"by status", Filter{}, "status", []counts{{"Held", 1, 2, 2, 0, 2}, {"Idle", 2, 3, 4, 3, 0}, {"Running", 1, 1, 3, 0, 0}}, counts{"", 3, 6, 6, 3, 2}},
+		{"by cluster", Filter{}, "clusterid", []counts{{"1", 1, 3, 3, 2, 0}, {"2", 1, 1, 1, 1, 0}, {"3", 1, 2, 2, 0, 2}}, counts{"", 3, 6, 6, 3, 2}},
+		{"by owner for alice", Filter{Owner: "alice"}, "owner", []counts{{"alice", 2, 5, 5, 2, 2}}, counts{"", 2, 5, 5, 2, 2}},
+		{"no matches", Filter{ClusterID: 42}, "", []counts{}, counts{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			totals, total, err := f.RetrieveTotalsFromDB(test.filter, test.groupBy)
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			// A cluster is only counted once in the overall total, even if its jobs are in more than one group
+			if got := (counts{total.Key, total.Clusters, total.Jobs, total.Num, total.ByStatus[Idle], total.ByStatus[Held]}); got != test.total {
+				t.Errorf("Expected total %+v, got %+v", test.total, got)
+			}
+			got := make([]counts, 0, len(totals))
+			for _, tot := range totals {
+				got = append(got, counts{tot.Key, tot.Clusters, tot.Jobs, tot.Num, tot.ByStatus[Idle], tot.ByStatus[Held]})
+			}
+			if len(got) != len(test.expected) {
+				t.Fatalf("Expected %+v, got %+v", test.expected, got)
+			}
+			for idx := range got {
+				if got[idx] != test.expected[idx] {
+					t.Errorf("Expected %+v, got %+v", test.expected, got)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid group-by", func(t *testing.T) {
+		if _, _, err := f.RetrieveTotalsFromDB(Filter{}, "memory"); err == nil {
+			t.Error("Should have gotten an error for an invalid group-by column")
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/units"
+)
+
+// Formats that submit --emit can write a submission in
+const (
+	emitCondor = "condor" // An HTCondor submit description file, for condor_submit
+	emitJobsub = "jobsub" // A jobsub_submit command line
+)
+
+var emitFormats = []string{emitCondor, emitJobsub}
+
+// parseEmit parses the format given to submit --emit.  Only one may be given, since a submit description and a command line
+// can't be used as the same file
+func parseEmit(s string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(s))
+	if format != "" && !slices.Contains(emitFormats, format) {
+		return "", fmt.Errorf("invalid --emit %s.  Choose one of %v", s, emitFormats)
+	}
+	return format, nil
+}
+
+// emitSubmission returns job, whose jobs run executable, in format, which is one of emitFormats
+func emitSubmission(format string, job db.Job, executable string) (string, error) {
+	if executable == "" {
+		return "", errors.New("--emit needs --executable, the script the jobs run on the grid")
+	}
+	// The grid doesn't run executables from the submitter's working directory, so relative paths are made absolute
+	if !strings.Contains(executable, "://") {
+		var err error
+		if executable, err = filepath.Abs(executable); err != nil {
+			return "", fmt.Errorf("invalid --executable: %w", err)
+		}
+	}
+	switch format {
+	case emitCondor:
+		return condorSubmitDescription(job, executable), nil
+	case emitJobsub:
+		return jobsubCommandLine(job, executable), nil
+	default:
+		return "", fmt.Errorf("invalid --emit %s.  Choose from %v", format, emitFormats)
+	}
+}
+
+// condorSubmitDescription returns the HTCondor submit description that asks for the same jobs as job.  Memory is in MB and
+// disk in KB, HTCondor's default units, so that fakeJobsub submit --submit-file reads it back as the same submission
+func condorSubmitDescription(job db.Job, executable string) string {
+	var b strings.Builder
+	line := func(key string, value any) { fmt.Fprintf(&b, "%s = %v\n", key, value) }
+
+	fmt.Fprintf(&b, "# Submit description for %d job(s) of group %s (role %s), from fakeJobsub submit\n", job.Num, job.Group, job.Role)
+	line("universe", "vanilla")
+	line("executable", executable)
+	line("accounting_group", "group_"+job.Group)
+	line("accounting_group_user", job.Owner)
+	line("request_memory", job.MemoryMB)
+	line("request_disk", job.DiskKB)
+	line("request_cpus", job.CPUs)
+	if job.GPUs > 0 {
+		line("request_gpus", job.GPUs)
+	}
+	line("+JOB_EXPECTED_MAX_LIFETIME", int64(job.Lifetime.Seconds()))
+	if len(job.Sites) > 0 {
+		line("+DESIRED_Sites", strconv.Quote(strings.Join(job.Sites, ",")))
+	}
+	if job.Log != "" {
+		line("log", job.Log)
+	}
+	if len(job.OutputFiles) > 0 {
+		line("transfer_output_files", strings.Join(job.OutputFiles, ","))
+	}
+	if job.MaxRetries > 0 {
+		line("max_retries", job.MaxRetries)
+		if len(job.RetryExitCodes) > 0 {
+			line("retry_until", retryUntil(job.RetryExitCodes))
+		}
+		if job.RetryBackoff > 0 {
+			fmt.Fprintf(&b, "# HTCondor has no equivalent of --retry-backoff %s\n", job.RetryBackoff)
+		}
+	}
+	if job.Notify != "" {
+		line("notification", condorNotification(job.Notify))
+	}
+	b.WriteString("# Only fakeJobsub's simulated pool uses these.  HTCondor ignores them\n")
+	line("+SimRuntime", strconv.Quote(job.Runtime.String()))
+	if len(job.SimExitCodes) > 0 {
+		line("+SimExitCodes", strconv.Quote(joinInts(job.SimExitCodes)))
+	}
+	fmt.Fprintf(&b, "queue %d\n", job.Num)
+	return b.String()
+}
+
+// jobsubCommandLine returns the jobsub_submit command line that asks for the same jobs as job.  Submit commands that
+// jobsub_submit has no flag for are passed with --lines
+func jobsubCommandLine(job db.Job, executable string) string {
+	args := []string{"jobsub_submit", "-G", job.Group}
+	if job.Role != config.RoleAnalysis {
+		args = append(args, "--role", job.Role)
+	}
+	if job.Num != 1 {
+		args = append(args, "-N", strconv.Itoa(job.Num))
+	}
+	args = append(args,
+		"--memory", units.FormatSize(int64(job.MemoryMB)*units.MB),
+		"--disk", units.FormatSize(int64(job.DiskKB)*units.KB),
+		"--cpu", strconv.Itoa(job.CPUs),
+	)
+	if job.GPUs > 0 {
+		args = append(args, "--gpu", strconv.Itoa(job.GPUs))
+	}
+	args = append(args, "--expected-lifetime", units.FormatLifetime(job.Lifetime))
+	if len(job.Sites) > 0 {
+		args = append(args, "--site", strings.Join(job.Sites, ","))
+	}
+	switch job.Notify {
+	case db.NotifyNever:
+		args = append(args, "--mail_never")
+	case db.NotifyError:
+		args = append(args, "--mail_on_error")
+	case db.NotifyAlways:
+		args = append(args, "--mail_always")
+	case db.NotifyComplete:
+		args = append(args, "--lines", "notification="+condorNotification(job.Notify))
+	}
+
+	lines := make([]string, 0)
+	if job.Log != "" {
+		lines = append(lines, "log="+job.Log)
+	}
+	if len(job.OutputFiles) > 0 {
+		lines = append(lines, "transfer_output_files="+strings.Join(job.OutputFiles, ","))
+	}
+	if job.MaxRetries > 0 {
+		lines = append(lines, "max_retries="+strconv.Itoa(job.MaxRetries))
+		if len(job.RetryExitCodes) > 0 {
+			lines = append(lines, "retry_until="+retryUntil(job.RetryExitCodes))
+		}
+	}
+	lines = append(lines, "+SimRuntime="+strconv.Quote(job.Runtime.String()))
+	if len(job.SimExitCodes) > 0 {
+		lines = append(lines, "+SimExitCodes="+strconv.Quote(joinInts(job.SimExitCodes)))
+	}
+	for _, l := range lines {
+		args = append(args, "--lines", l)
+	}
+
+	if !strings.Contains(executable, "://") {
+		executable = "file://" + executable
+	}
+	args = append(args, executable)
+
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ") + "\n"
+}
+
+// condorNotification returns the value of HTCondor's notification submit command for the --notify setting notify
+func condorNotification(notify string) string {
+	return strings.ToUpper(notify[:1]) + notify[1:]
+}
+
+// retryUntil returns the HTCondor retry_until expression that stops retrying jobs that exit with a code other than codes
+func retryUntil(codes []int) string {
+	return fmt.Sprintf("!member(ExitCode, {%s})", strings.ReplaceAll(joinInts(codes), ",", ", "))
+}
+
+func joinInts(ints []int) string {
+	strs := make([]string, 0, len(ints))
+	for _, i := range ints {
+		strs = append(strs, strconv.Itoa(i))
+	}
+	return strings.Join(strs, ",")
+}
+
+// shellQuote quotes s for a POSIX shell, if it needs quoting
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:,=+@%", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
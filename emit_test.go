@@ -0,0 +1,196 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/db"
+	"fakeJobsub/submitfile"
+)
+
+func TestParseEmit(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   string
+		shouldFail bool
+	}{
+		{"", "", false},
+		{"condor", "condor", false},
+		{"Jobsub", "jobsub", false},
+		{"condor,jobsub", "", true},
+		{"slurm", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			format, err := parseEmit(test.input)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %v instead", format)
+				}
+				return
+			}
+			if err != nil || format != test.expected {
+				t.Errorf("Expected %v and nil error.  Got %v, %v instead", test.expected, format, err)
+			}
+		})
+	}
+}
+
+// emitTestJob is a cluster that uses every submit flag that --emit writes
+var emitTestJob = db.Job{
+	Group:    "nova",
+	Num:      3,
+	Role:     "Production",
+	Owner:    "novapro",
+	MemoryMB: 2048,
+	DiskKB:   1024 * 1024,
+	CPUs:     2,
+	GPUs:     1,
+	Lifetime: 8 * time.Hour,
+	Sites:    []string{"FermiGrid", "Wisconsin"},
+	Runtime:  5 * time.Minute,
+	Log:      "/data/job.log",
+
+	OutputFiles:    []string{"hist.root"},
+	MaxRetries:     2,
+	RetryExitCodes: []int{1, 2},
+	SimExitCodes:   []int{1, 0},
+	Notify:         db.NotifyComplete,
+}
+
+func TestCondorSubmitDescription(t *testing.T) {
+	f, err := submitfile.Parse(strings.NewReader(condorSubmitDescription(emitTestJob, "/data/run.sh")))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error parsing the submit description.  Got %v instead", err)
+	}
+	if f.Queue != 3 {
+		t.Errorf("Expected to queue 3 jobs.  Got %d instead", f.Queue)
+	}
+	expected := map[string]string{
+		"executable":                 "/data/run.sh",
+		"accounting_group":           "group_nova",
+		"accounting_group_user":      "novapro",
+		"request_memory":             "2048",
+		"request_disk":               "1048576",
+		"request_cpus":               "2",
+		"request_gpus":               "1",
+		"+JOB_EXPECTED_MAX_LIFETIME": "28800",
+		"+DESIRED_Sites":             "FermiGrid,Wisconsin",
+		"log":                        "/data/job.log",
+		"transfer_output_files":      "hist.root",
+		"max_retries":                "2",
+		"retry_until":                "!member(ExitCode, {1, 2})",
+		"notification":               "Complete",
+		"+SimRuntime":                "5m0s",
+		"+SimExitCodes":              "1,0",
+	}
+	for key, value := range expected {
+		if got, ok := f.Get(key); !ok || got != value {
+			t.Errorf("Expected %s = %s.  Got %q instead", key, value, got)
+		}
+	}
+}
+
+func TestJobsubCommandLine(t *testing.T) {
+	expected := `jobsub_submit -G nova --role Production -N 3 --memory 2GB --disk 1GB --cpu 2 --gpu 1 --expected-lifetime 8h ` +
+		`--site FermiGrid,Wisconsin --lines notification=Complete --lines log=/data/job.log --lines transfer_output_files=hist.root ` +
+		`--lines max_retries=2 --lines 'retry_until=!member(ExitCode, {1, 2})' --lines '+SimRuntime="5m0s"' ` +
+		`--lines '+SimExitCodes="1,0"' file:///data/run.sh` + "\n"
+	if got := jobsubCommandLine(emitTestJob, "/data/run.sh"); got != expected {
+		t.Errorf("Expected %s.  Got %s instead", expected, got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"--memory", "--memory"},
+		{"file:///data/run.sh", "file:///data/run.sh"},
+		{"", "''"},
+		{"two words", "'two words'"},
+		{"it's", `'it'\''s'`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := shellQuote(test.input); got != test.expected {
+				t.Errorf("Expected %s.  Got %s instead", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunSubmitDryRun(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	setupToken(t, "nova")
+
+	t.Run("dry run submits nothing", func(t *testing.T) {
+		args := []string{"fakeJobsub", "submit", "--group", "nova", "--num", "2", "--dry-run", "--emit", "jobsub", "--executable", "run.sh"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if dbs, _ := filepath.Glob(filepath.Join(tmp, "fakeJobsubSchedd_schedd*")); len(dbs) != 0 {
+			t.Errorf("Dry run should not have created schedd databases.  Got %v", dbs)
+		}
+	})
+
+	t.Run("submit and write the submit description to a file", func(t *testing.T) {
+		subFile := filepath.Join(t.TempDir(), "job.sub")
+		args := []string{"fakeJobsub", "submit", "--group", "nova", "--num", "2", "--emit", "condor", "--emit-file", subFile,
+			"--executable", "run.sh", "--schedd", "schedd1"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		f, err := submitfile.ParseFile(subFile)
+		if err != nil || f.Queue != 2 {
+			t.Errorf("Should have written a submit description for 2 jobs.  Got %+v, %v", f, err)
+		}
+
+		// It can be submitted here as is
+		args = []string{"fakeJobsub", "submit", "--group", "nova", "--submit-file", subFile, "--dry-run"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error submitting the emitted submit description.  Got %v instead", err)
+		}
+	})
+
+	t.Run("emit file that can't be written submits nothing", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		setupToken(t, "nova")
+		args := []string{"fakeJobsub", "submit", "--group", "nova", "--emit", "condor", "--emit-file", filepath.Join(tmp, "nonexistent", "job.sub"),
+			"--executable", "run.sh", "--schedd", "schedd1"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "could not write --emit-file") {
+			t.Errorf("Should have gotten error indicating the --emit-file could not be written.  Got %v instead", err)
+		}
+		if dbs, _ := filepath.Glob(filepath.Join(tmp, "fakeJobsubSchedd_schedd*")); len(dbs) != 0 {
+			t.Errorf("Should not have submitted anything.  Got %v", dbs)
+		}
+	})
+
+	t.Run("emit file without emit", func(t *testing.T) {
+		args := []string{"fakeJobsub", "submit", "--group", "nova", "--dry-run", "--emit-file", filepath.Join(t.TempDir(), "job.sub")}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--emit-file needs --emit") {
+			t.Errorf("Should have gotten error indicating --emit is needed.  Got %v instead", err)
+		}
+	})
+
+	t.Run("emit without executable", func(t *testing.T) {
+		args := []string{"fakeJobsub", "submit", "--group", "nova", "--dry-run", "--emit", "jobsub"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--emit needs --executable") {
+			t.Errorf("Should have gotten error indicating --executable is needed.  Got %v instead", err)
+		}
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		args := []string{"fakeJobsub", "submit", "--group", "nova", "--emit", "slurm", "--executable", "run.sh"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "invalid --emit") {
+			t.Errorf("Should have gotten error indicating invalid --emit.  Got %v instead", err)
+		}
+	})
+}
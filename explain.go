@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/db"
+)
+
+// printQueryPlans writes the plan each of schedds' databases would use to list the rows matching filter with list, instead of
+// listing them.  after is the --after cursor, if any, since it changes the query each schedd runs
+func printQueryPlans(w io.Writer, schedds []*condor.Schedd, filter db.Filter, after string, list func(*condor.Schedd, db.Filter) (*db.Listing, error)) error {
+	var cursor *pageCursor
+	if after != "" {
+		var err error
+		if cursor, err = decodeCursor(after, filter.Sort); err != nil {
+			return err
+		}
+	}
+
+	// The rows of each listing are never read, so this doesn't run the queries
+	listings, err := queryListings(schedds, func(schedd *condor.Schedd) (*db.Listing, error) {
+		return list(schedd, pageFilter(filter, cursor, schedd.Name))
+	})
+	if err != nil {
+		return fmt.Errorf("could not explain listing: %w", err)
+	}
+
+	for _, schedd := range schedds {
+		plan, err := schedd.QueryPlan(listings[schedd.Name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", schedd.Name, err)
+		}
+		fmt.Fprintln(w, schedd.Name)
+		for _, line := range plan {
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
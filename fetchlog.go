@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/token"
+)
+
+// runFetchlog runs the fetchlog subcommand, which downloads the output of jobs as a tar.gz file, like jobsub_fetchlog.
+// args are the arguments after "fetchlog"
+func runFetchlog(cfg *config.Config, args []string) error {
+	c := newJobCommand("fetchlog", "fetch the logs of")
+	destDir := c.flags.String("destdir", ".", "Directory to write the tar.gz file to")
+	partial := c.flags.Bool("partial", false, "Also fetch the output that running jobs have written so far")
+	schedd, id, r, err := c.setup(cfg, args, token.ScopeRead)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*destDir, 0o755); err != nil {
+		return fmt.Errorf("could not create --destdir: %w", err)
+	}
+	filename := filepath.Join(*destDir, id.String()+".tar.gz")
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+
+	names, err := schedd.FetchLog(r, id, *partial, time.Now(), f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filename)
+		return fmt.Errorf("could not fetch logs: %w", err)
+	}
+
+	fmt.Printf("Downloaded %d file(s) for %s to %s\n", len(names), id, filename)
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+// runHistory runs the history subcommand, which lists the jobs that have left the queue, like condor_history.  args are the
+// arguments after "history"
+func runHistory(cfg *config.Config, args []string) error {
+	historyCmd := flag.NewFlagSet("history", flag.ContinueOnError)
+	historyKeys := historyCmd.String("keys", "", fmt.Sprintf("Comma-separated list of keys to query.  One or more of %v", db.ProcListColumns()))
+	historyClusterID := historyCmd.Int("clusterid", 0, "ClusterID to query. Must also specify --schedd.")
+	historySchedd := historyCmd.String("schedd", "", "schedd to query from.  If blank, will query all configured schedds")
+	historyUser := historyCmd.String("user", "", "Only list jobs owned by this user")
+	historyMe := historyCmd.Bool("me", false, "Only list jobs owned by me (the token subject, or the current OS user if there is no token)")
+
+	if err := historyCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	if *historyClusterID != 0 && *historySchedd == "" {
+		return errors.New("must set --schedd flag if --clusterid is specified")
+	}
+
+	filter := db.Filter{ClusterID: *historyClusterID, Owner: *historyUser}
+	if *historyMe {
+		if *historyUser != "" {
+			return errors.New("only one of --user and --me may be specified")
+		}
+		var err error
+		if filter.Owner, err = whoami(cfg); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0)
+	if *historyKeys != "" {
+		for _, key := range strings.Split(*historyKeys, ",") {
+			keys = append(keys, strings.TrimSpace(key))
+		}
+	}
+
+	names := cfg.ScheddNames()
+	if *historySchedd != "" {
+		if !slices.Contains(names, *historySchedd) {
+			return fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v or do not set the --schedd flag", *historySchedd, names)
+		}
+		names = []string{*historySchedd}
+	}
+	schedds, err := getSchedds(names)
+	if err != nil {
+		return err
+	}
+	rows, err := listJobsFromSchedds(schedds, func(s *condor.Schedd) (*db.Listing, error) { return s.History(filter, keys...) })
+	if err != nil {
+		return err
+	}
+	return printLines(rows)
+}
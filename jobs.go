@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/token"
+)
+
+// jobCommand holds the flags common to the subcommands that act on existing jobs
+type jobCommand struct {
+	flags  *flag.FlagSet
+	jobID  *string
+	schedd *string
+}
+
+func newJobCommand(name, verb string) *jobCommand {
+	c := &jobCommand{flags: flag.NewFlagSet(name, flag.ContinueOnError)}
+	c.jobID = c.flags.String("jobid", "", fmt.Sprintf("Job to %s, like 12@schedd1 for a whole cluster or 12.0@schedd1 for a single job", verb))
+	c.schedd = c.flags.String("schedd", "", "schedd the job is on, if not given in --jobid")
+	return c
+}
+
+// setup parses args, checks that the bearer token grants scope for the job's group, and returns the schedd and job to act on, and who is acting.
+// The schedd sends notifications to the configured notifier, which the caller should wait for
+func (c *jobCommand) setup(cfg *config.Config, args []string, scope string) (*condor.Schedd, condor.JobID, condor.Requester, error) {
+	var id condor.JobID
+	var r condor.Requester
+
+	if err := c.flags.Parse(args); err != nil {
+		return nil, id, r, errParseFlags
+	}
+	if *c.jobID == "" {
+		return nil, id, r, errors.New("--jobid must be specified")
+	}
+
+	id, err := condor.ParseJobID(*c.jobID)
+	if err != nil {
+		return nil, id, r, err
+	}
+	switch {
+	case id.Schedd == "" && *c.schedd == "":
+		return nil, id, r, errors.New("must give the schedd either in --jobid or with --schedd")
+	case id.Schedd != "" && *c.schedd != "" && id.Schedd != *c.schedd:
+		return nil, id, r, fmt.Errorf("--jobid schedd %s and --schedd %s do not match", id.Schedd, *c.schedd)
+	case id.Schedd == "":
+		id.Schedd = *c.schedd
+	}
+	if !slices.Contains(cfg.ScheddNames(), id.Schedd) {
+		return nil, id, r, fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v", id.Schedd, cfg.ScheddNames())
+	}
+
+	schedd, err := condor.GetSchedd(id.Schedd)
+	if err != nil {
+		return nil, id, r, fmt.Errorf("could not get schedd: %w", err)
+	}
+	job, err := schedd.Job(id)
+	if err != nil {
+		return nil, id, r, err
+	}
+
+	// The token must be for the job's group, and to change a Production job it must authorize Production too
+	production := job.Role == config.RoleProduction && scope != token.ScopeRead
+	claims, err := verifyToken(cfg, job.Group, production, scope)
+	if err != nil {
+		return nil, id, r, fmt.Errorf("not authorized to %s: %w", c.flags.Name(), err)
+	}
+	r = requesterFor(cfg, claims.Subject)
+
+	schedd.Notifier = newNotifier(cfg)
+	return schedd, id, r, nil
+}
+
+// requesterFor returns the condor.Requester for user, including the groups that user is a superuser of
+func requesterFor(cfg *config.Config, user string) condor.Requester {
+	r := condor.Requester{User: user}
+	for _, g := range cfg.Groups {
+		if slices.Contains(g.Superusers, user) {
+			r.SuperuserOf = append(r.SuperuserOf, g.Name)
+		}
+	}
+	return r
+}
+
+// runRemove runs the rm subcommand.  args are the arguments after "rm"
+func runRemove(cfg *config.Config, args []string) error {
+	c := newJobCommand("rm", "remove")
+	schedd, id, r, err := c.setup(cfg, args, token.ScopeCancel)
+	if err != nil {
+		return err
+	}
+
+	defer waitForNotifications(schedd.Notifier)
+	n, err := schedd.Remove(r, id)
+	if err != nil {
+		return fmt.Errorf("could not remove job: %w", err)
+	}
+	fmt.Printf("Removed %d job(s) in %s\n", n, id)
+	return nil
+}
+
+// runHold runs the hold subcommand.  args are the arguments after "hold"
+func runHold(cfg *config.Config, args []string) error {
+	c := newJobCommand("hold", "hold")
+	schedd, id, r, err := c.setup(cfg, args, token.ScopeModify)
+	if err != nil {
+		return err
+	}
+
+	defer waitForNotifications(schedd.Notifier)
+	n, err := schedd.Hold(r, id)
+	if err != nil {
+		return fmt.Errorf("could not hold job: %w", err)
+	}
+	fmt.Printf("Held %d job(s) in %s\n", n, id)
+	return nil
+}
+
+// runRelease runs the release subcommand.  args are the arguments after "release"
+func runRelease(cfg *config.Config, args []string) error {
+	c := newJobCommand("release", "release")
+	schedd, id, r, err := c.setup(cfg, args, token.ScopeModify)
+	if err != nil {
+		return err
+	}
+
+	defer waitForNotifications(schedd.Notifier)
+	n, err := schedd.Release(r, id)
+	if err != nil {
+		return fmt.Errorf("could not release job: %w", err)
+	}
+	fmt.Printf("Released %d job(s) in %s\n", n, id)
+	return nil
+}
+
+// runEdit runs the edit subcommand.  args are the arguments after "edit"
+func runEdit(cfg *config.Config, args []string) error {
+	c := newJobCommand("edit", "edit")
+	key := c.flags.String("key", "", fmt.Sprintf("Key to edit.  One of %v", db.EditableColumns))
+	value := c.flags.String("value", "", "New value for the key")
+	schedd, id, r, err := c.setup(cfg, args, token.ScopeModify)
+	if err != nil {
+		return err
+	}
+	if id.ProcID != db.AllProcs {
+		return errors.New("edit applies to whole clusters.  Give --jobid as cluster@schedd")
+	}
+
+	var newValue any = *value
+	switch *key {
+	case "":
+		return errors.New("--key must be specified")
+	case "role":
+		// The new role must be allowed for the group, and the token must authorize it
+		job, err := schedd.Job(id)
+		if err != nil {
+			return err
+		}
+		group, err := cfg.Group(job.Group)
+		if err != nil {
+			return err
+		}
+		role, err := group.CanonicalRole(*value)
+		if err != nil {
+			return err
+		}
+		if _, err := verifyToken(cfg, group.Name, role == config.RoleProduction, token.ScopeModify); err != nil {
+			return fmt.Errorf("not authorized to set role %s: %w", role, err)
+		}
+		newValue = role
+	case "memory", "disk", "cpus", "gpus", "lifetime":
+		// The new request must be within the schedd's limits
+		v, err := parseResourceValue(*key, *value)
+		if err != nil {
+			return err
+		}
+		if *key == "cpus" && v < 1 {
+			return errors.New("must request at least 1 CPU")
+		}
+		job, err := schedd.Job(id)
+		if err != nil {
+			return err
+		}
+		scheddConfig, err := cfg.Schedd(id.Schedd)
+		if err != nil {
+			return err
+		}
+		resources := jobResources(job)
+		setResource(&resources, *key, v)
+		if err := scheddConfig.Limits.Allows(resources); err != nil {
+			return fmt.Errorf("schedd %s cannot accept edit: %w", id.Schedd, err)
+		}
+		newValue = v
+	}
+
+	if err := schedd.Edit(r, id, *key, newValue); err != nil {
+		return fmt.Errorf("could not edit job: %w", err)
+	}
+	fmt.Printf("Set %s = %v for %s\n", *key, newValue, id)
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+// listOptions are the flags of list, which admin explain takes too
+type listOptions struct {
+	keys      string
+	clusterID int
+	schedd    string
+	user      string
+	me        bool
+	group     string
+	procs     bool
+	dag       bool
+	totals    bool
+	groupBy   string
+	watch     time.Duration
+	sort      string
+	limit     int
+	after     string
+	explain   bool
+}
+
+// addFlags defines the flags of list in fs, setting opts
+func (opts *listOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&opts.keys, "keys", "", "Comma-separated list of keys to query")
+	fs.IntVar(&opts.clusterID, "clusterid", 0, "ClusterID to query. Must also specify --schedd.")
+	fs.StringVar(&opts.schedd, "schedd", "", "schedd to query from.  If blank, will query all configured schedds")
+	fs.StringVar(&opts.user, "user", "", "Only list jobs owned by this user")
+	fs.BoolVar(&opts.me, "me", false, "Only list jobs owned by me (the token subject, or the current OS user if there is no token)")
+	fs.StringVar(&opts.group, "group", "", "Only list jobs of this group")
+	fs.BoolVar(&opts.procs, "procs", false, "List individual jobs instead of clusters, showing which slot running jobs are in and why idle jobs are not matching")
+	fs.BoolVar(&opts.dag, "dag", false, "List the nodes of DAGs instead of clusters.  --clusterid then selects a DAG by its cluster ID")
+	fs.BoolVar(&opts.totals, "totals", false, "Only print the number of jobs in each status on each schedd, like condor_q -totals")
+	fs.StringVar(&opts.groupBy, "group-by", "", fmt.Sprintf("Print the number of clusters and jobs in each status for each value of this column instead of listing jobs.  One of %v", append(db.TotalsGroupByColumns(), groupBySchedd)))
+	fs.DurationVar(&opts.watch, "watch", 0, "Redraw the list every interval, like 5s, highlighting rows whose status changed, until interrupted with Ctrl-C")
+	fs.StringVar(&opts.sort, "sort", "", "Comma-separated list of keys to sort by, like clusterid,-num.  A leading - sorts in descending order.  Rows from all schedds are merged into one table")
+	fs.IntVar(&opts.limit, "limit", 0, "List at most this many rows, merged from all schedds")
+	fs.StringVar(&opts.after, "after", "", "List the page of rows after this cursor, which is printed at the end of the previous page")
+	fs.BoolVar(&opts.explain, "explain", false, "Print each schedd's query plan for the listing instead of listing jobs, like admin explain")
+}
+
+// runList lists the jobs (or procs, or DAG nodes) that opts select, or prints their totals or the query plans for listing them
+func runList(cfg *config.Config, opts listOptions) error {
+	slog.Debug("list", "keys", opts.keys, "clusterid", opts.clusterID, "schedd", opts.schedd, "user", opts.user, "me", opts.me,
+		"group", opts.group, "procs", opts.procs, "dag", opts.dag, "watch", opts.watch, "totals", opts.totals,
+		"group-by", opts.groupBy, "sort", opts.sort, "limit", opts.limit, "after", opts.after, "explain", opts.explain)
+
+	if opts.procs && opts.dag {
+		return errors.New("only one of --procs and --dag may be specified")
+	}
+	if opts.watch < 0 {
+		return errors.New("--watch must not be negative")
+	}
+	summarize := opts.totals || opts.groupBy != ""
+	if summarize && (opts.procs || opts.dag || opts.keys != "" || opts.watch != 0) {
+		return errors.New("--totals and --group-by may not be used with --procs, --dag, --keys, or --watch")
+	}
+	paged := opts.sort != "" || opts.limit != 0 || opts.after != ""
+	if paged && (summarize || opts.watch != 0) {
+		return errors.New("--sort, --limit, and --after may not be used with --totals, --group-by, or --watch")
+	}
+	if opts.explain && (summarize || opts.watch != 0) {
+		return errors.New("--explain may not be used with --totals, --group-by, or --watch")
+	}
+	if opts.limit < 0 {
+		return errors.New("--limit must not be negative")
+	}
+	if opts.groupBy != "" && opts.groupBy != groupBySchedd && !slices.Contains(db.TotalsGroupByColumns(), opts.groupBy) {
+		return fmt.Errorf("invalid --group-by %s.  Choose from %v", opts.groupBy, append(db.TotalsGroupByColumns(), groupBySchedd))
+	}
+
+	// Stop and return an error if we specified --clusterid but not --schedd
+	if opts.clusterID != 0 && opts.schedd == "" {
+		return errors.New("must set --schedd flag if --clusterid is specified")
+	}
+
+	schedds := cfg.ScheddNames()
+	filter := db.Filter{ClusterID: opts.clusterID, Owner: opts.user, Group: opts.group, Limit: opts.limit}
+	var err error
+	if filter.Sort, err = db.ParseSort(opts.sort); err != nil {
+		return err
+	}
+	if opts.me {
+		if opts.user != "" {
+			return errors.New("only one of --user and --me may be specified")
+		}
+		if filter.Owner, err = whoami(cfg); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0)
+	if opts.keys != "" {
+		keysRaw := strings.Split(opts.keys, ",")
+		keys = make([]string, 0, len(keysRaw))
+		for _, key := range keysRaw {
+			keys = append(keys, strings.TrimSpace(key))
+		}
+	}
+
+	// List clusters, the individual jobs in them, or DAG nodes
+	list := func(schedd *condor.Schedd, filter db.Filter) (*db.Listing, error) {
+		switch {
+		case opts.procs:
+			return schedd.ListProcs(filter, keys...)
+		case opts.dag:
+			return schedd.ListDAGNodes(filter, keys...)
+		}
+		return schedd.List(filter, keys...)
+	}
+	listAll := func(schedd *condor.Schedd) (*db.Listing, error) { return list(schedd, filter) }
+
+	// Keep listing until interrupted
+	watch := func(scheddObjs []*condor.Schedd) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return watchList(ctx, os.Stdout, opts.watch, func(ctx context.Context) ([]string, error) {
+			rows, err := listJobsFromSchedds(scheddObjs, listAll)
+			if err != nil {
+				return nil, err
+			}
+			return collectLines(ctx, rows)
+		})
+	}
+
+	// We're running query on one schedd
+	if opts.schedd != "" {
+		if !slices.Contains(schedds, opts.schedd) {
+			return fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v or do not set the --schedd flag", opts.schedd, schedds)
+		}
+
+		schedd, err := condor.GetSchedd(opts.schedd)
+		if err != nil {
+			return fmt.Errorf("could not get schedd: %w", err)
+		}
+		if opts.watch > 0 {
+			return watch([]*condor.Schedd{schedd})
+		}
+		if summarize {
+			return printTotals(os.Stdout, []*condor.Schedd{schedd}, filter, opts.groupBy)
+		}
+		if opts.explain {
+			return printQueryPlans(os.Stdout, []*condor.Schedd{schedd}, filter, opts.after, list)
+		}
+		if paged {
+			return printPage(os.Stdout, []*condor.Schedd{schedd}, filter, opts.after, list)
+		}
+
+		// The schedd's errors already say that the jobs could not be listed
+		listing, err := list(schedd, filter)
+		if err != nil {
+			return err
+		}
+		// Print our rows
+		return printLines(listing.Lines())
+	}
+
+	// Don't have specific schedd - query them all!
+	scheddObjs, err := getSchedds(schedds)
+	if err != nil {
+		return err
+	}
+	if opts.watch > 0 {
+		return watch(scheddObjs)
+	}
+	if summarize {
+		return printTotals(os.Stdout, scheddObjs, filter, opts.groupBy)
+	}
+	if opts.explain {
+		return printQueryPlans(os.Stdout, scheddObjs, filter, opts.after, list)
+	}
+	if paged {
+		return printPage(os.Stdout, scheddObjs, filter, opts.after, list)
+	}
+	rows, err := listJobsFromSchedds(scheddObjs, listAll)
+	if err != nil {
+		return err
+	}
+	// Print the rows as they come!
+	return printLines(rows)
+}
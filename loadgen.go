@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/token"
+)
+
+// The operations loadgen performs
+const (
+	opSubmit = "submit"
+	opList   = "list"
+	opRemove = "rm"
+)
+
+// runLoadgen runs the loadgen subcommand, which submits jobs (and optionally lists and removes them) at a steady rate from many
+// concurrent clients, then reports the throughput it achieved and the latency of each kind of operation.  args are the arguments
+// after "loadgen"
+func runLoadgen(cfg *config.Config, args []string) error {
+	loadgenCmd := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	loadgenRate := loadgenCmd.String("rate", "10/s", "Operations to start per second (like 50/s), minute (like 600/m), or hour")
+	loadgenDuration := loadgenCmd.Duration("duration", time.Minute, "How long to generate load for")
+	loadgenGroups := loadgenCmd.String("groups", "", "Comma-separated list of groups to submit jobs for, picked randomly for each submission.  If blank, all configured groups the bearer token authorizes are used")
+	loadgenNumDist := loadgenCmd.String("num-dist", "fixed", "Distribution of the number of jobs in each cluster:  fixed (always --num), uniform (1 to 2*num-1), or lognormal (median --num, with a long tail)")
+	loadgenNum := loadgenCmd.Int("num", 1, "Number of jobs in each cluster, as used by --num-dist")
+	loadgenListFraction := loadgenCmd.Float64("list-fraction", 0, "Fraction of operations that list the loadgen user's jobs on a schedd instead of submitting")
+	loadgenRemoveFraction := loadgenCmd.Float64("rm-fraction", 0, "Fraction of operations that remove a cluster loadgen submitted earlier instead of submitting")
+	loadgenConcurrency := loadgenCmd.Int("concurrency", 500, "Most operations in flight at once.  Operations due while this many are in flight are dropped and counted")
+	loadgenLatency := loadgenCmd.String("latency", "real", "Simulated schedd latency:  real (as fakeJobsub submit and list sleep) or zero, to measure the database alone")
+	loadgenSeed := loadgenCmd.Int64("seed", 0, "Seed for picking groups, schedds, and cluster sizes.  If 0, a random seed is used")
+	loadgenMetricsListen := loadgenCmd.String("metrics-listen", "", "If set, serve metrics at this address, like localhost:9118, at /metrics while running")
+
+	if err := loadgenCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+
+	rate, err := parseRate(*loadgenRate)
+	if err != nil {
+		return err
+	}
+	if *loadgenDuration <= 0 {
+		return errors.New("--duration must be positive")
+	}
+	dist, err := parseNumDist(*loadgenNumDist, *loadgenNum)
+	if err != nil {
+		return err
+	}
+	if *loadgenListFraction < 0 || *loadgenRemoveFraction < 0 || *loadgenListFraction+*loadgenRemoveFraction > 1 {
+		return errors.New("--list-fraction and --rm-fraction must not be negative, and must add up to at most 1")
+	}
+	if *loadgenConcurrency < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+	var latency condor.Latency
+	switch *loadgenLatency {
+	case "real":
+		latency = condor.DefaultLatency
+	case "zero":
+	default:
+		return fmt.Errorf("invalid --latency %q.  Must be real or zero", *loadgenLatency)
+	}
+	seed := *loadgenSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	groups, owner, err := loadgenAuthorizedGroups(cfg, *loadgenGroups)
+	if err != nil {
+		return err
+	}
+	g, err := newLoadgen(cfg, groups, owner, dist, latency, seed)
+	if err != nil {
+		return err
+	}
+	g.listFraction, g.removeFraction = *loadgenListFraction, *loadgenRemoveFraction
+	g.concurrency = *loadgenConcurrency
+
+	// Ctrl-C stops the load early, but the operations so far are still reported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *loadgenMetricsListen != "" {
+		shutdown, err := serveMetrics(cfg, *loadgenMetricsListen)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+
+	fmt.Printf("Generating load at %s/s for %s as %s on groups %v (seed %d).  Press Ctrl-C to stop early\n",
+		strconv.FormatFloat(rate, 'f', -1, 64), *loadgenDuration, owner, groups, seed)
+	report := g.run(ctx, rate, *loadgenDuration)
+	report.print(os.Stdout)
+	return nil
+}
+
+// parseRate parses a rate like 50/s, 600/m, or 10/h, returning it per second.  A bare number is per second
+func parseRate(s string) (float64, error) {
+	n, unit, found := strings.Cut(s, "/")
+	per := time.Second
+	if found {
+		switch unit {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return 0, fmt.Errorf("invalid --rate %q:  unit must be s, m, or h", s)
+		}
+	}
+	rate, err := strconv.ParseFloat(n, 64)
+	if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+		return 0, fmt.Errorf("invalid --rate %q:  must be a positive number per second, minute, or hour, like 50/s", s)
+	}
+	return rate / per.Seconds(), nil
+}
+
+// numDist is the distribution of the number of jobs in the clusters loadgen submits
+type numDist struct {
+	kind string
+	n    int
+}
+
+// lognormalSigma is the standard deviation of the log of the lognormal number of jobs.  With it, about one cluster in 20 has more
+// than five times the median number of jobs
+const lognormalSigma = 1.0
+
+// parseNumDist returns the distribution called kind, whose typical number of jobs is n
+func parseNumDist(kind string, n int) (numDist, error) {
+	if n < 1 {
+		return numDist{}, errors.New("--num must be at least 1")
+	}
+	switch kind {
+	case "fixed", "uniform", "lognormal":
+		return numDist{kind, n}, nil
+	}
+	return numDist{}, fmt.Errorf("invalid --num-dist %q.  Must be fixed, uniform, or lognormal", kind)
+}
+
+// sample returns a number of jobs drawn from d using r.  It is always at least 1
+func (d numDist) sample(r *rand.Rand) int {
+	switch d.kind {
+	case "uniform":
+		return 1 + r.Intn(2*d.n-1)
+	case "lognormal":
+		return max(1, int(math.Round(float64(d.n)*math.Exp(lognormalSigma*r.NormFloat64()))))
+	}
+	return d.n
+}
+
+// loadgen generates load on the schedds.  Its random choices are all made by the goroutine that runs it
+type loadgen struct {
+	groups         []string
+	jobs           map[string]db.Job           // The job submitted for each group, but for its number of jobs
+	schedds        map[string][]*condor.Schedd // The schedds each group may submit to
+	byName         map[string]*condor.Schedd
+	owner          string
+	dist           numDist
+	listFraction   float64
+	removeFraction float64
+	concurrency    int
+	rng            *rand.Rand
+
+	mu        sync.Mutex
+	submitted []condor.JobID // Clusters submitted and not yet removed
+}
+
+// loadgenAuthorizedGroups returns the groups in requested, a comma-separated list, and the owner of the jobs loadgen submits
+// for them.  Like submit, the bearer token must authorize submitting jobs with each group's default role, and its subject owns
+// the jobs.  If requested is blank, the groups are the configured ones the token authorizes
+func loadgenAuthorizedGroups(cfg *config.Config, requested string) ([]string, string, error) {
+	names := make([]string, 0)
+	if requested != "" {
+		names = strings.Split(requested, ",")
+	} else {
+		for _, group := range cfg.Groups {
+			names = append(names, group.Name)
+		}
+	}
+
+	groups := make([]string, 0, len(names))
+	var owner string
+	for _, name := range names {
+		group, err := cfg.Group(strings.TrimSpace(name))
+		if err != nil {
+			return nil, "", err
+		}
+		claims, err := verifyToken(cfg, group.Name, group.DefaultRole() == config.RoleProduction, token.ScopeCreate)
+		if err != nil {
+			if requested == "" && errors.Is(err, token.ErrGroup) {
+				continue
+			}
+			return nil, "", fmt.Errorf("not authorized to submit for group %s: %w", group.Name, err)
+		}
+		groups = append(groups, group.Name)
+		owner = claims.Subject
+	}
+	if len(groups) == 0 {
+		return nil, "", errors.New("not authorized to submit:  the bearer token does not authorize any configured group")
+	}
+
+	if owner == "" {
+		var err error
+		if owner, err = currentUsername(); err != nil {
+			return nil, "", err
+		}
+	}
+	return groups, owner, nil
+}
+
+// newLoadgen returns a loadgen that submits jobs with the default resources of each of groups, owned by owner, to the schedds each
+// group may use, which have the simulated latency given.  Its random choices are seeded with seed
+func newLoadgen(cfg *config.Config, groups []string, owner string, dist numDist, latency condor.Latency, seed int64) (*loadgen, error) {
+	g := &loadgen{
+		jobs:        make(map[string]db.Job, len(groups)),
+		schedds:     make(map[string][]*condor.Schedd, len(groups)),
+		byName:      make(map[string]*condor.Schedd),
+		owner:       owner,
+		dist:        dist,
+		concurrency: 1,
+		rng:         rand.New(rand.NewSource(seed)),
+	}
+
+	// Each schedd is opened once, so its submissions are serialized, and shared by the groups that use it
+	for _, name := range groups {
+		group, err := cfg.Group(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if g.jobs[group.Name].Group != "" {
+			continue
+		}
+		job, err := newJob(cfg, group, group.DefaultRole(), jobSpec{num: 1, runtime: time.Minute}, "")
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		job.Owner = owner
+		accepting, err := scheddsAccepting(cfg, group.Schedds(cfg), "", jobResources(job))
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		for _, scheddName := range accepting {
+			schedd, ok := g.byName[scheddName]
+			if !ok {
+				if schedd, err = condor.GetSchedd(scheddName); err != nil {
+					return nil, fmt.Errorf("could not get schedd: %w", err)
+				}
+				schedd.Latency = latency
+				g.byName[scheddName] = schedd
+			}
+			g.schedds[group.Name] = append(g.schedds[group.Name], schedd)
+		}
+		g.groups = append(g.groups, group.Name)
+		g.jobs[group.Name] = job
+	}
+	return g, nil
+}
+
+// loadOp is one operation for a loadgen client to perform
+type loadOp struct {
+	kind   string
+	schedd *condor.Schedd
+	job    db.Job       // For opSubmit
+	id     condor.JobID // For opRemove
+}
+
+// next picks the next operation.  A removal is only picked if there is a cluster left to remove; otherwise a job is submitted
+func (g *loadgen) next() loadOp {
+	group := g.groups[g.rng.Intn(len(g.groups))]
+	schedds := g.schedds[group]
+	op := loadOp{kind: opSubmit, schedd: schedds[g.rng.Intn(len(schedds))]}
+
+	switch x := g.rng.Float64(); {
+	case x < g.listFraction:
+		op.kind = opList
+		return op
+	case x < g.listFraction+g.removeFraction:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if len(g.submitted) > 0 {
+			i := g.rng.Intn(len(g.submitted))
+			op.kind, op.id = opRemove, g.submitted[i]
+			op.schedd = g.byName[op.id.Schedd]
+			g.submitted = slices.Delete(g.submitted, i, i+1)
+			return op
+		}
+	}
+	op.job = g.jobs[group]
+	op.job.Num = g.dist.sample(g.rng)
+	return op
+}
+
+// do performs op
+func (g *loadgen) do(op loadOp) error {
+	switch op.kind {
+	case opList:
+		listing, err := op.schedd.List(db.Filter{Owner: g.owner, Limit: 100})
+		if err != nil {
+			return err
+		}
+		for _, err := range listing.Rows {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case opRemove:
+		_, err := op.schedd.Remove(condor.Requester{User: g.owner}, op.id)
+		return err
+	}
+	job, err := op.schedd.SubmitJob(op.job)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.submitted = append(g.submitted, condor.JobID{ClusterID: job.ClusterID, ProcID: db.AllProcs, Schedd: op.schedd.Name})
+	g.mu.Unlock()
+	return nil
+}
+
+// run starts operations at rate per second for duration, or until ctx is done, without waiting for earlier operations to finish.
+// It waits for the operations in flight, then reports on them
+func (g *loadgen) run(ctx context.Context, rate float64, duration time.Duration) *loadReport {
+	report := newLoadReport(rate, duration)
+	inFlight := make(chan struct{}, g.concurrency)
+	var wg sync.WaitGroup
+
+	// Each tick starts the operations that have come due since the last, so the rate holds even when it is faster than the ticker
+	start := time.Now()
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(min(time.Duration(float64(time.Second)/rate), 10*time.Millisecond))
+	defer ticker.Stop()
+	started := 0
+
+dispatch:
+	for {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case <-deadline.C:
+			break dispatch
+		case <-ticker.C:
+		}
+
+		for due := int(time.Since(start).Seconds() * rate); started < due; started++ {
+			op := g.next()
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				report.drop(op.kind)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				opStart := time.Now()
+				err := g.do(op)
+				report.record(op.kind, time.Since(opStart), err)
+			}()
+		}
+	}
+	report.loadTime = time.Since(start)
+	wg.Wait()
+	report.elapsed = time.Since(start)
+	return report
+}
+
+// loadReport collects the results of a loadgen run.  It is safe for concurrent use
+type loadReport struct {
+	rate     float64
+	duration time.Duration
+	loadTime time.Duration // How long operations were started for
+	elapsed  time.Duration // Including the wait for the last operations to finish
+
+	mu  sync.Mutex
+	ops map[string]*opStats
+}
+
+// opStats holds the results of one kind of operation
+type opStats struct {
+	latencies []time.Duration // Of the operations that succeeded
+	errors    int
+	lastError error
+	dropped   int // Operations not started because too many were in flight
+}
+
+func newLoadReport(rate float64, duration time.Duration) *loadReport {
+	return &loadReport{rate: rate, duration: duration, ops: make(map[string]*opStats)}
+}
+
+func (r *loadReport) stats(kind string) *opStats {
+	s, ok := r.ops[kind]
+	if !ok {
+		s = &opStats{}
+		r.ops[kind] = s
+	}
+	return s
+}
+
+// record records that an operation of kind took latency, and failed with err if it isn't nil
+func (r *loadReport) record(kind string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats(kind)
+	if err != nil {
+		s.errors++
+		s.lastError = err
+		return
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+// drop records that an operation of kind was not started
+func (r *loadReport) drop(kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats(kind).dropped++
+}
+
+// percentile returns the pth percentile of sorted, by the nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// print writes the report to w:  the throughput achieved, then a table of the count, errors, and latency percentiles of each kind
+// of operation, then the last error of each kind that had any
+func (r *loadReport) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	completed, failed, dropped := 0, 0, 0
+	for _, s := range r.ops {
+		completed += len(s.latencies)
+		failed += s.errors
+		dropped += s.dropped
+	}
+	started := completed + failed + dropped
+	fmt.Fprintf(w, "\nStarted %d operations in %s (%.2f/s of %.2f/s requested for %s):  %d succeeded, %d failed, %d dropped\n",
+		started, r.loadTime.Round(time.Millisecond), float64(started)/r.loadTime.Seconds(), r.rate, r.duration, completed, failed, dropped)
+	fmt.Fprintf(w, "Achieved throughput:  %.2f successful operations/s over %s, including the wait for the last to finish\n\n",
+		float64(completed)/r.elapsed.Seconds(), r.elapsed.Round(time.Millisecond))
+
+	fmt.Fprintln(w, "op\tok\terrors\tdropped\tp50\tp90\tp99\tmax")
+	kinds := []string{opSubmit, opList, opRemove}
+	for _, kind := range kinds {
+		s, ok := r.ops[kind]
+		if !ok {
+			continue
+		}
+		slices.Sort(s.latencies)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n", kind, len(s.latencies), s.errors, s.dropped,
+			percentile(s.latencies, 50).Round(time.Microsecond), percentile(s.latencies, 90).Round(time.Microsecond),
+			percentile(s.latencies, 99).Round(time.Microsecond), percentile(s.latencies, 100).Round(time.Microsecond))
+	}
+	for _, kind := range kinds {
+		if s, ok := r.ops[kind]; ok && s.lastError != nil {
+			fmt.Fprintf(w, "\nLast %s error:  %s\n", kind, s.lastError)
+		}
+	}
+}
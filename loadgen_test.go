@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+)
+
+func TestParseRate(t *testing.T) {
+	type testCase struct {
+		rate     string
+		expected float64
+	}
+	for _, test := range []testCase{
+		{"50/s", 50},
+		{"50", 50},
+		{"600/m", 10},
+		{"1800/h", 0.5},
+		{"2.5/s", 2.5},
+	} {
+		t.Run(test.rate, func(t *testing.T) {
+			rate, err := parseRate(test.rate)
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if rate != test.expected {
+				t.Errorf("Expected rate %v.  Got %v instead", test.expected, rate)
+			}
+		})
+	}
+
+	for _, rate := range []string{"", "0/s", "-5/s", "fast", "50/d", "50/"} {
+		t.Run("invalid "+rate, func(t *testing.T) {
+			if _, err := parseRate(rate); err == nil {
+				t.Error("Should have gotten an error.  Got nil instead")
+			}
+		})
+	}
+}
+
+func TestNumDist(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	const samples = 10000
+
+	t.Run("fixed", func(t *testing.T) {
+		d, err := parseNumDist("fixed", 7)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		for range samples {
+			if n := d.sample(r); n != 7 {
+				t.Fatalf("Expected 7 jobs.  Got %d instead", n)
+			}
+		}
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		d, _ := parseNumDist("uniform", 5)
+		total := 0
+		for range samples {
+			n := d.sample(r)
+			if n < 1 || n > 9 {
+				t.Fatalf("Expected 1 to 9 jobs.  Got %d instead", n)
+			}
+			total += n
+		}
+		if mean := float64(total) / samples; mean < 4.8 || mean > 5.2 {
+			t.Errorf("Expected a mean of about 5 jobs.  Got %v instead", mean)
+		}
+	})
+
+	t.Run("lognormal", func(t *testing.T) {
+		d, _ := parseNumDist("lognormal", 10)
+		below, most := 0, 0
+		for range samples {
+			n := d.sample(r)
+			if n < 1 {
+				t.Fatalf("Expected at least 1 job.  Got %d instead", n)
+			}
+			if n < 10 {
+				below++
+			}
+			most = max(most, n)
+		}
+		if frac := float64(below) / samples; frac < 0.45 || frac > 0.55 {
+			t.Errorf("Expected about half of the clusters to have fewer than the median 10 jobs.  Got %v instead", frac)
+		}
+		if most < 50 {
+			t.Errorf("Expected a long tail of big clusters.  Biggest was %d jobs", most)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := parseNumDist("normal", 1); err == nil {
+			t.Error("Should have gotten an error for an unknown distribution.  Got nil instead")
+		}
+		if _, err := parseNumDist("fixed", 0); err == nil {
+			t.Error("Should have gotten an error for --num 0.  Got nil instead")
+		}
+	})
+}
+
+func TestLoadgen(t *testing.T) {
+	cfg := config.Default()
+	owner := "loadgen-test-" + t.Name()
+	d, _ := parseNumDist("uniform", 3)
+	g, err := newLoadgen(cfg, []string{"nova", "dune"}, owner, d, condor.Latency{}, 1)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	g.listFraction, g.removeFraction, g.concurrency = 0.2, 0.2, 50
+
+	report := g.run(context.Background(), 200, 500*time.Millisecond)
+
+	for _, kind := range []string{opSubmit, opList, opRemove} {
+		s, ok := report.ops[kind]
+		if !ok || len(s.latencies) == 0 {
+			t.Errorf("Expected some successful %s operations", kind)
+			continue
+		}
+		if s.errors != 0 {
+			t.Errorf("Should have gotten no %s errors.  Got %d, the last %v", kind, s.errors, s.lastError)
+		}
+	}
+
+	// Every cluster submitted but not removed is still in the queue, and has a distinct cluster ID
+	seen := make(map[condor.JobID]bool)
+	for _, id := range g.submitted {
+		if seen[id] {
+			t.Errorf("Cluster %s was submitted twice", id)
+		}
+		seen[id] = true
+		job, err := g.byName[id.Schedd].Job(id)
+		if err != nil {
+			t.Errorf("Should have found cluster %s.  Got %v instead", id, err)
+			continue
+		}
+		if job.Owner != owner {
+			t.Errorf("Expected cluster %s to be owned by %s.  Got %s instead", id, owner, job.Owner)
+		}
+	}
+
+	var b bytes.Buffer
+	report.print(&b)
+	for _, want := range []string{"Achieved throughput", "op\tok\terrors\tdropped\tp50\tp90\tp99\tmax", "\nsubmit\t", "\nlist\t", "\nrm\t"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected report to contain %q.  Got:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestLoadgenAuthorizedGroups(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	cfg := config.Default()
+	setupToken(t, "nova", "--subject", "loadgen-test")
+
+	tests := []struct {
+		name      string
+		requested string
+		expected  []string
+		errSubstr string
+	}{
+		{"groups the token authorizes", "", []string{"nova"}, ""},
+		{"authorized group", "nova", []string{"nova"}, ""},
+		{"unauthorized group", "nova,dune", nil, "not authorized to submit for group dune"},
+		{"unknown group", "nope", nil, "nope"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			groups, owner, err := loadgenAuthorizedGroups(cfg, test.requested)
+			if test.errSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errSubstr) {
+					t.Errorf("Should have gotten error containing %q.  Got %v instead", test.errSubstr, err)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(groups, test.expected) || owner != "loadgen-test" {
+				t.Errorf("Expected groups %v owned by loadgen-test and nil error.  Got %v, %s, %v instead", test.expected, groups, owner, err)
+			}
+		})
+	}
+
+	t.Run("no token", func(t *testing.T) {
+		t.Setenv("BEARER_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+		if _, _, err := loadgenAuthorizedGroups(cfg, ""); err == nil || !strings.Contains(err.Error(), "not authorized to submit") {
+			t.Errorf("Should have gotten error indicating loadgen is not authorized.  Got %v instead", err)
+		}
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 0, 100)
+	for i := range 100 {
+		sorted = append(sorted, time.Duration(i+1)*time.Millisecond)
+	}
+	for p, expected := range map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := percentile(sorted, p); got != expected {
+			t.Errorf("Expected p%v to be %s.  Got %s instead", p, expected, got)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("Expected p50 of nothing to be 0.  Got %s instead", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log formats for --log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// setupLogging parses the global flags, which configure logging and come before the subcommand in args, the arguments after
+// the program name.  It makes slog's default logger write to w as they say, and returns the rest of args and the logger's
+// level, which subcommands may lower
+func setupLogging(args []string, w io.Writer) ([]string, *slog.LevelVar, error) {
+	globalCmd := flag.NewFlagSet("fakeJobsub", flag.ContinueOnError)
+	logLevel := globalCmd.String("log-level", "warn", "Level of the diagnostics logged to stderr:  debug, info, warn, or error")
+	logFormat := globalCmd.String("log-format", logFormatText, "Format of the diagnostics logged to stderr:  text, or json for one JSON object per line")
+	globalCmd.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: fakeJobsub [--log-level level] [--log-format text|json] subcommand [flags]")
+		globalCmd.PrintDefaults()
+	}
+	if err := globalCmd.Parse(args); err != nil {
+		return nil, nil, errParseFlags
+	}
+
+	level := new(slog.LevelVar)
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return nil, nil, fmt.Errorf("invalid --log-level %s.  Choose from debug, info, warn, or error", *logLevel)
+	}
+	logger, err := newLogger(w, *logFormat, level)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.SetDefault(logger)
+	return globalCmd.Args(), level, nil
+}
+
+// newLogger returns a logger that writes records at level or above to w, in format
+func newLogger(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, errors.New("--log-format must be text or json")
+}
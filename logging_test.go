@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSetupLogging(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	tests := []struct {
+		name     string
+		args     []string
+		rest     []string
+		expected string // In what is logged at debug level, or the error
+		err      bool
+	}{
+		{"defaults", []string{"list", "--verbose"}, []string{"list", "--verbose"}, "", false},
+		{"debug text", []string{"--log-level", "debug", "list"}, []string{"list"}, `level=DEBUG msg=hello`, false},
+		{"json", []string{"--log-format=json", "--log-level=DEBUG", "submit", "--num", "2"}, []string{"submit", "--num", "2"}, `"msg":"hello"`, false},
+		{"invalid level", []string{"--log-level", "loud", "list"}, nil, "invalid --log-level loud", true},
+		{"invalid format", []string{"--log-format", "xml", "list"}, nil, "--log-format must be text or json", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			rest, _, err := setupLogging(test.args, &b)
+			if test.err {
+				if err == nil || !strings.Contains(err.Error(), test.expected) {
+					t.Errorf("Expected error containing %q.  Got %v instead", test.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if !slices.Equal(rest, test.rest) {
+				t.Errorf("Expected remaining args %v.  Got %v instead", test.rest, rest)
+			}
+			slog.Debug("hello")
+			if !strings.Contains(b.String(), test.expected) || (test.expected == "" && b.Len() != 0) {
+				t.Errorf("Expected log containing %q.  Got %q instead", test.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestVerboseLogsAtDebugLevel(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	if err := run([]string{"fakeJobsub", "list", "--verbose", "--totals"}); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Expected --verbose to log at debug level")
+	}
+}
+
+func TestAdminExplainKeepsLogLevel(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	if err := run([]string{"fakeJobsub", "--log-level", "debug", "admin", "explain", "--schedd", "schedd1"}); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Expected admin explain to keep logging at the --log-level given")
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"math/rand"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"slices"
-	"strings"
+	"time"
 
 	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/token"
 )
 
 var (
@@ -17,14 +22,12 @@ var (
 	errUsage      = errors.New("usage called")
 )
 
-var schedds = []string{"schedd1", "schedd2"}
-
 func main() {
 	if err := run(os.Args); err != nil {
 		if errors.Is(err, errParseFlags) {
 			os.Exit(2)
 		}
-		fmt.Printf("Error running fakeJobsub: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Error running fakeJobsub: %s\n", err)
 		os.Exit(1)
 	}
 }
@@ -32,27 +35,86 @@ func main() {
 // Note - by making run depend on args, I now can TEST it!
 // This is the main reason folks sometimes split out a "run" function from the main function - since main isn't really that testable as is.
 func run(args []string) error {
+	// The global flags, which configure logging, come before the subcommand
+	var rest []string
+	if len(args) > 1 {
+		rest = args[1:]
+	}
+	rest, logLevel, err := setupLogging(rest, os.Stderr)
+	if err != nil {
+		return err
+	}
+	args = append([]string{"fakeJobsub"}, rest...)
+
+	// The group registry and the list of schedds come from the configuration
+	cfg, err := config.Load(os.Getenv(config.EnvVar))
+	if err != nil {
+		return fmt.Errorf("could not load configuration: %w", err)
+	}
+	schedds := cfg.ScheddNames()
+
 	// Flags
 	submitCmd := flag.NewFlagSet("submit", flag.ContinueOnError)
 	submitNum := submitCmd.Int("num", 1, "Number of jobs to submit")
 	submitGroup := submitCmd.String("group", "", "Group/Experiment")
+	submitRole := submitCmd.String("role", "", "Role to submit jobs with (Analysis, Production).  If blank, the group's default role is used")
 	submitSchedd := submitCmd.String("schedd", "", "schedd to submit to.  If blank, one will be randomly chosen")
-	submitVerbose := submitCmd.Bool("verbose", false, "Verbose mode")
+	submitMemory := submitCmd.String("memory", "", "Memory to request for each job, like 2GB or 500MB (default unit MB).  If blank, the group's default is used")
+	submitDisk := submitCmd.String("disk", "", "Disk to request for each job, like 10GB (default unit KB).  If blank, the group's default is used")
+	submitCPU := submitCmd.String("cpu", "", "Number of CPUs to request for each job.  If blank, the group's default is used")
+	submitGPU := submitCmd.String("gpu", "", "Number of GPUs to request for each job.  If blank, the group's default is used")
+	submitLifetime := submitCmd.String("expected-lifetime", "", "Expected lifetime of each job:  short, medium, long, or a duration like 8h.  If blank, the group's default is used")
+	submitSites := submitCmd.String("site", "", "Comma-separated list of sites the jobs may run at.  If blank, the jobs may run at any site")
+	submitRuntime := submitCmd.Duration("sim-runtime", time.Minute, "How long each job runs for in the simulated pool")
+	submitOutputFiles := submitCmd.String("sim-output-files", "", "Comma-separated names of files each job generates when it completes in the simulated pool, which fetchlog returns")
+	submitMaxRetries := submitCmd.Int("max-retries", 0, "Number of times to retry each job that exits non-zero")
+	submitRetryOnExitCodes := submitCmd.String("retry-on-exit-codes", "", "Comma-separated exit codes to retry jobs on.  If blank, jobs are retried on any non-zero exit code")
+	submitRetryBackoff := submitCmd.Duration("retry-backoff", 0, "How long to wait before the first retry of a job.  The wait doubles with each retry")
+	submitSimExitCodes := submitCmd.String("sim-exit-codes", "", "Comma-separated exit codes of each job's first, second, ... attempt in the simulated pool.  The last one repeats.  If blank, jobs exit 0")
+	submitLog := submitCmd.String("log", "", "File to write the cluster's HTCondor job event log (user log) to")
+	submitFile := submitCmd.String("submit-file", "", "HTCondor submit description file to read log, request_memory, request_disk, request_cpus, request_gpus, +DESIRED_Sites, +JOB_EXPECTED_MAX_LIFETIME, transfer_output_files, +SimRuntime, max_retries, +SimExitCodes, notification, executable, and queue from.  Flags given on the command line take precedence")
+	submitNotify := submitCmd.String("notify", "", fmt.Sprintf("When to notify you about each job, through the configured notification sinks.  One of %v.  If blank, you are not notified", db.NotifyValues))
+	submitDryRun := submitCmd.Bool("dry-run", false, "Check the submission, including the group's limits and your token, but do not submit it")
+	submitEmit := submitCmd.String("emit", "", fmt.Sprintf("Format to also write the submission in, for the real grid:  one of %v.  condor writes an HTCondor submit description, and jobsub a jobsub_submit command line", emitFormats))
+	submitEmitFile := submitCmd.String("emit-file", "", "File to write the --emit submission to.  If blank, it is written to stdout, and what submit says about the submission to stderr")
+	submitExecutable := submitCmd.String("executable", "", "Script the jobs run on the real grid.  fakeJobsub does not run it, but --emit needs it")
+	submitVerbose := submitCmd.Bool("verbose", false, "Verbose mode:  log at debug level, like --log-level debug")
 
 	listCmd := flag.NewFlagSet("list", flag.ContinueOnError)
-	listKeys := listCmd.String("keys", "", "Comma-separated list of keys to query")
-	listClusterID := listCmd.Int("clusterid", 0, "ClusterID to query. Must also specify --schedd.")
-	listSchedd := listCmd.String("schedd", "", "schedd to query from.  If blank, will query all configured schedds")
-	listVerbose := listCmd.Bool("verbose", false, "Verbose mode")
+	var listOpts listOptions
+	listOpts.addFlags(listCmd)
+	listVerbose := listCmd.Bool("verbose", false, "Verbose mode:  log at debug level, like --log-level debug")
 
 	// Map of our flagsets to their names.  Very contrived.  Gives us something like {"submit": submitCmd, "list": listCmd}
 	flagSetMap := make(map[string]*flag.FlagSet, 0)
 	flagSetMap[submitCmd.Name()] = submitCmd
 	flagSetMap[listCmd.Name()] = listCmd
 
+	// The other subcommands live in their own files, and handle their own flags
+	otherCommands := map[string]func(*config.Config, []string) error{
+		"admin":      runAdmin,
+		"audit":      runAudit,
+		"token":      runToken,
+		"rm":         runRemove,
+		"serve":      runServe,
+		"hold":       runHold,
+		"release":    runRelease,
+		"edit":       runEdit,
+		"negotiate":  runNegotiate,
+		"replay":     runReplay,
+		"userprio":   runUserprio,
+		"analyze":    runAnalyze,
+		"fetchlog":   runFetchlog,
+		"history":    runHistory,
+		"loadgen":    runLoadgen,
+		"submit-dag": runSubmitDAG,
+	}
+	subcommandNames := []string{submitCmd.Name(), listCmd.Name()}
+	subcommandNames = append(subcommandNames, slices.Sorted(maps.Keys(otherCommands))...)
+
 	// Parse args
 	if len(args) < 2 {
-		fmt.Fprintf(os.Stderr, "fakeJobsub must be run with the \"submit\" or \"list\" subcommand\n\n")
+		fmt.Fprintf(os.Stderr, "fakeJobsub must be run with one of the subcommands %v\n\n", subcommandNames)
 		submitCmd.Usage()
 		listCmd.Usage()
 		return errUsage
@@ -60,9 +122,13 @@ func run(args []string) error {
 
 	subcommand := args[1]
 
+	if runOther, ok := otherCommands[subcommand]; ok {
+		return runOther(cfg, args[2:])
+	}
+
 	flSet, ok := flagSetMap[subcommand]
 	if !ok {
-		fmt.Println("Invalid subcommand.  Must run fakeJobsub with the \"submit\" or \"list\" subcommand.")
+		fmt.Printf("Invalid subcommand.  Must run fakeJobsub with one of the subcommands %v.\n", subcommandNames)
 		submitCmd.Usage()
 		listCmd.Usage()
 		return errors.New("invalid subcommand")
@@ -71,109 +137,141 @@ func run(args []string) error {
 	if err := flSet.Parse(args[2:]); err != nil {
 		return errParseFlags
 	}
+	if *submitVerbose || *listVerbose {
+		logLevel.Set(slog.LevelDebug)
+	}
 
 	// Subcommand logic
 	switch subcommand {
 	case submitCmd.Name():
-		if err := checkSubmitForGroup(*submitGroup); err != nil {
-			return errors.New("--group must be specified")
+		if *submitFile != "" {
+			if err := applySubmitFile(submitCmd, *submitFile); err != nil {
+				return err
+			}
 		}
 
-		if *submitVerbose {
-			fmt.Printf("num = %d\n", *submitNum)
-			fmt.Printf("group = %s\n", *submitGroup)
-			fmt.Printf("schedd = %s\n", *submitSchedd)
+		emit, err := parseEmit(*submitEmit)
+		if err != nil {
+			return err
 		}
 
-		// Pick a schedd based on --schedd
-		var scheddName string
-		switch {
-		case *submitSchedd == "":
-			// Randomly pick a schedd
-			scheddName = schedds[rand.Intn(len(schedds))]
-		case !slices.Contains(schedds, *submitSchedd):
-			// Randomly pick a schedd
-			fmt.Printf("Given schedd %s is not in the list of configured schedds: %v.  Picking one randomly.\n", *submitSchedd, schedds)
-			scheddName = schedds[rand.Intn(len(schedds))]
-		default:
-			// Use the schedd given
-			scheddName = *submitSchedd
+		if err := checkSubmitForGroup(*submitGroup); err != nil {
+			return errors.New("--group must be specified")
 		}
 
-		schedd, err := condor.GetSchedd(scheddName)
+		// Only registered groups may submit
+		group, err := cfg.Group(*submitGroup)
 		if err != nil {
-			return fmt.Errorf("could not get schedd: %w", err)
+			return err
 		}
 
-		if err := schedd.Submit(*submitGroup, *submitNum); err != nil {
-			return fmt.Errorf("could not submit job: %w", err)
+		role := group.DefaultRole()
+		if *submitRole != "" {
+			if role, err = group.CanonicalRole(*submitRole); err != nil {
+				return err
+			}
 		}
-		fmt.Println("Submitted job(s) successfully")
-		return nil
 
-	case listCmd.Name():
-		if *listVerbose {
-			fmt.Printf("keys = %s\n", *listKeys)
-			fmt.Printf("clusterID = %d\n", *listClusterID)
-			fmt.Printf("schedd = %s\n", *listSchedd)
+		job, err := newJob(cfg, group, role, jobSpec{
+			num:         *submitNum,
+			memory:      *submitMemory,
+			disk:        *submitDisk,
+			cpu:         *submitCPU,
+			gpu:         *submitGPU,
+			lifetime:    *submitLifetime,
+			sites:       *submitSites,
+			runtime:     *submitRuntime,
+			outputFiles: *submitOutputFiles,
+			log:         *submitLog,
+
+			maxRetries:       *submitMaxRetries,
+			retryOnExitCodes: *submitRetryOnExitCodes,
+			retryBackoff:     *submitRetryBackoff,
+			simExitCodes:     *submitSimExitCodes,
+
+			notify: *submitNotify,
+		}, "")
+		if err != nil {
+			return err
 		}
+		resources := jobResources(job)
+
+		slog.Debug("submit", "num", *submitNum, "group", *submitGroup, "role", role, "schedd", *submitSchedd,
+			"resources", fmt.Sprintf("%+v", resources), "sites", job.Sites, "sim-runtime", job.Runtime, "log", job.Log,
+			"sim-output-files", job.OutputFiles, "max-retries", job.MaxRetries, "retry-on-exit-codes", job.RetryExitCodes,
+			"retry-backoff", job.RetryBackoff, "sim-exit-codes", job.SimExitCodes, "notify", job.Notify, "dry-run", *submitDryRun,
+			"emit", emit, "emit-file", *submitEmitFile, "executable", *submitExecutable)
 
-		// Stop and return an error if we specified --clusterid but not --schedd
-		if *listClusterID != 0 && *listSchedd == "" {
-			return errors.New("must set --schedd flag if --clusterid is specified")
+		// Pick a schedd based on --schedd and the schedds the group is allowed to use that can accept the resource request
+		accepting, err := scheddsAccepting(cfg, group.Schedds(cfg), *submitSchedd, resources)
+		if err != nil {
+			return err
+		}
+		scheddName, err := chooseSchedd(*submitSchedd, schedds, accepting)
+		if err != nil {
+			return fmt.Errorf("could not choose schedd for group %s: %w", group.Name, err)
 		}
+		slog.Info("chose schedd", "schedd", scheddName, "group", group.Name, "requested", *submitSchedd, "accepting", accepting)
 
-		keys := make([]string, 0)
-		if *listKeys != "" {
-			keysRaw := strings.Split(*listKeys, ",")
-			keys = make([]string, 0, len(keysRaw))
-			for _, key := range keysRaw {
-				keys = append(keys, strings.TrimSpace(key))
+		// The bearer token must authorize this submission.  Its subject owns the jobs
+		claims, err := verifyToken(cfg, group.Name, role == config.RoleProduction, token.ScopeCreate)
+		if err != nil {
+			return fmt.Errorf("not authorized to submit: %w", err)
+		}
+		owner := claims.Subject
+		if owner == "" {
+			if owner, err = currentUsername(); err != nil {
+				return err
 			}
 		}
 
-		// We're running query on one schedd
-		if *listSchedd != "" {
-			if !slices.Contains(schedds, *listSchedd) {
-				return fmt.Errorf("invalid schedd: %s.  Please choose from valid schedds %v or do not set the --schedd flag", *listSchedd, schedds)
-			}
+		job.Owner = owner
 
-			schedd, err := condor.GetSchedd(*listSchedd)
-			if err != nil {
-				return fmt.Errorf("could not get schedd: %w", err)
+		// Make the submission for the real grid, and write any --emit-file, before submitting it, so that nothing is submitted
+		// if it can't be made.  If it is written to stdout, everything else goes to stderr, so that stdout can be used as is
+		var emitted string
+		status := io.Writer(os.Stdout)
+		if emit != "" {
+			if emitted, err = emitSubmission(emit, job, *submitExecutable); err != nil {
+				return err
 			}
-
-			rows, err := schedd.List(*listClusterID, keys...)
-			if err != nil {
-				return fmt.Errorf("could not list jobs: %w", err)
+			if job.RetryBackoff > 0 {
+				slog.Warn("HTCondor and jobsub_submit have no equivalent of --retry-backoff, so the emitted submission does not back off", "retry-backoff", job.RetryBackoff)
 			}
-
-			// Print our rows
-			for _, row := range rows {
-				fmt.Println(row)
+			if *submitEmitFile == "" {
+				status = os.Stderr
+			} else if err := os.WriteFile(*submitEmitFile, []byte(emitted), 0o644); err != nil {
+				return fmt.Errorf("could not write --emit-file: %w", err)
 			}
-			return nil
+		} else if *submitEmitFile != "" {
+			return errors.New("--emit-file needs --emit")
 		}
 
-		// Don't have specific schedd - query them all!
-		scheddObjs := make([]*condor.Schedd, 0, len(schedds))
-		for _, s := range schedds {
-			schedd, err := condor.GetSchedd(s)
+		if *submitDryRun {
+			fmt.Fprintf(status, "Dry run:  would have submitted %d jobs for group %s (role %s) as %s on schedd %s\n", job.Num, job.Group, job.Role, owner, scheddName)
+		} else {
+			schedd, err := condor.GetSchedd(scheddName)
 			if err != nil {
 				return fmt.Errorf("could not get schedd: %w", err)
 			}
-			scheddObjs = append(scheddObjs, schedd)
-		}
-		rows, err := listJobsFromSchedds(scheddObjs, keys...)
-		if err != nil {
-			return fmt.Errorf("could not list jobs from all schedds: %w", err)
+			queued, err := schedd.SubmitJob(job)
+			if err != nil {
+				if *submitEmitFile != "" {
+					os.Remove(*submitEmitFile)
+				}
+				return err
+			}
+			fmt.Fprintf(status, "Submitted %d jobs to cluster %d for group %s (role %s) on schedd %s\n", queued.Num, queued.ClusterID, queued.Group, queued.Role, scheddName)
+			fmt.Fprintln(status, "Submitted job(s) successfully")
 		}
 
-		// Print the rows!
-		for _, row := range rows {
-			fmt.Println(row)
+		if emit != "" && *submitEmitFile == "" {
+			fmt.Print(emitted)
 		}
 		return nil
+
+	case listCmd.Name():
+		return runList(cfg, listOpts)
 	}
 	return nil
 }
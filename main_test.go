@@ -1,13 +1,36 @@
 package main
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/token"
+	"fakeJobsub/userlog"
 )
 
+// setupToken issues a token for group into a temporary $BEARER_TOKEN_FILE, so that the test can submit jobs
+func setupToken(t *testing.T, group string, extraArgs ...string) {
+	t.Setenv("BEARER_TOKEN", "")
+	t.Setenv("BEARER_TOKEN_FILE", filepath.Join(t.TempDir(), "token"))
+	args := append([]string{"fakeJobsub", "token", "issue", "--group", group}, extraArgs...)
+	if err := run(args); err != nil {
+		t.Fatalf("Could not issue test token: %s", err)
+	}
+}
+
 func TestRun(t *testing.T) {
 	var args []string
+	setupToken(t, "fermilab")
 
 	t.Run("Test 0:  Nothing given at all", func(t *testing.T) {
 		args = []string{}
@@ -137,4 +160,770 @@ func TestRun(t *testing.T) {
 		}
 	},
 	)
+
+	t.Run("Test 15: submit with an unknown group", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "nvoa"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "Did you mean 'nova'?") {
+			t.Errorf("Should have gotten error suggesting the nova group. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 16: submit with a role the group may not use", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "minerva", "--role", "Production"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "not allowed for group minerva") {
+			t.Errorf("Should have gotten error indicating that the role was not allowed. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 17: submit to a schedd the group may not use", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "mu2e", "--schedd", "schedd2"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "may not submit to schedd schedd2") {
+			t.Errorf("Should have gotten error indicating that the schedd was not allowed. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 18: submit with resources over the schedd's limits", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--schedd", "schedd2", "--memory", "20GB"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "exceeds the maximum") {
+			t.Errorf("Should have gotten error indicating that the memory request was too large. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 19: submit with an invalid lifetime", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--expected-lifetime", "forever"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "invalid lifetime") {
+			t.Errorf("Should have gotten error indicating that the lifetime was invalid. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 20: submit with resources", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--memory", "4GB", "--disk", "1GB", "--cpu", "2", "--gpu", "1", "--expected-lifetime", "long"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 21: list resource keys", func(t *testing.T) {
+		args = []string{"fakeJobsub", "list", "--schedd", "schedd1", "--keys", "clusterid,memory,disk,cpus,gpus,lifetime"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 22: submit to invalid site", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--site", "FermiGrid,CERN"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "invalid site CERN") {
+			t.Errorf("Should have gotten invalid site error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Test 23: submit to site, negotiate, and list procs", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--schedd", "schedd2", "--site", "Wisconsin", "--sim-runtime", "1h"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "negotiate"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "list", "--procs", "--keys", "clusterid,procid,status,site,reason"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+}
+
+func TestRunRetries(t *testing.T) {
+	var args []string
+	setupToken(t, "fermilab")
+
+	t.Run("submit with a retry policy, negotiate, and show history", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--schedd", "schedd2", "--max-retries", "2", "--retry-on-exit-codes", "1,2",
+			"--retry-backoff", "1m", "--sim-exit-codes", "1,0", "--sim-runtime", "1s"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "negotiate", "--cycles", "2", "--interval", "1s"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "history", "--schedd", "schedd2", "--me"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "list", "--procs", "--schedd", "schedd2", "--keys", "clusterid,procid,status,attempts,exitcode,reason"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("never retry exit code 0", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--max-retries", "1", "--retry-on-exit-codes", "0,1"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "never retried") {
+			t.Errorf("Should have gotten error indicating exit code 0 is never retried.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("negative retries", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--max-retries", "-1"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--max-retries") {
+			t.Errorf("Should have gotten error about --max-retries.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("history of an unknown schedd", func(t *testing.T) {
+		args = []string{"fakeJobsub", "history", "--schedd", "schedd42"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "invalid schedd") {
+			t.Errorf("Should have gotten invalid schedd error.  Got %v instead", err)
+		}
+	},
+	)
+}
+
+func TestRunTotals(t *testing.T) {
+	var args []string
+	setupToken(t, "fermilab")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string // Expected error, or blank for none
+	}{
+		{"totals for all schedds", []string{"--totals"}, ""},
+		{"totals by status for one schedd", []string{"--group-by", "status", "--schedd", "schedd1", "--totals"}, ""},
+		{"totals by schedd for me", []string{"--group-by", "schedd", "--me"}, ""},
+		{"invalid group-by", []string{"--group-by", "memory"}, "invalid --group-by memory"},
+		{"totals with procs", []string{"--totals", "--procs"}, "may not be used with"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args = append([]string{"fakeJobsub", "list"}, test.args...)
+			err := run(args)
+			if test.expected == "" && err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+
+	t.Run("summary line", func(t *testing.T) {
+		totals := db.Totals{Clusters: 2, Jobs: 5, ByStatus: map[db.JobStatus]int{db.Idle: 3, db.Running: 2}}
+		expected := "Total for schedd1: 5 jobs; 0 completed, 0 removed, 3 idle, 2 running, 0 held (2 clusters)"
+		if line := summaryLine("Total for schedd1", totals); line != expected {
+			t.Errorf("Expected %q, got %q", expected, line)
+		}
+	})
+}
+
+func TestRunListPages(t *testing.T) {
+	var args []string
+
+	// Submit clusters to two schedds as a unique user, so that only they are listed
+	owner := fmt.Sprintf("testuser%d", time.Now().UnixNano())
+	setupToken(t, "fermilab", "--subject", owner)
+	for _, submit := range []struct {
+		schedd string
+		num    string
+	}{{"schedd1", "2"}, {"schedd1", "4"}, {"schedd2", "4"}} {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--num", submit.num, "--schedd", submit.schedd}
+		if err := run(args); err != nil {
+			t.Fatalf("Could not submit test jobs: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string // Expected error, or blank for none
+	}{
+		{"sort and limit", []string{"--sort", "-num,owner", "--limit", "2", "--me"}, ""},
+		{"sort procs", []string{"--sort", "-procid", "--procs", "--me", "--schedd", "schedd2"}, ""},
+		{"invalid sort column", []string{"--sort", "bogus"}, "invalid sort column"},
+		{"negative limit", []string{"--limit", "-1"}, "--limit must not be negative"},
+		{"limit with watch", []string{"--limit", "1", "--watch", "1s"}, "may not be used with"},
+		{"invalid cursor", []string{"--after", "bogus"}, "invalid --after cursor"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args = append([]string{"fakeJobsub", "list"}, test.args...)
+			err := run(args)
+			if test.expected == "" && err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+
+	t.Run("pages are merged across schedds", func(t *testing.T) {
+		schedds, err := getSchedds([]string{"schedd2", "schedd1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := func(schedd *condor.Schedd, filter db.Filter) (*db.Listing, error) { return schedd.List(filter, "num") }
+		filter := db.Filter{Owner: owner, Sort: []db.SortKey{{Column: "num", Desc: true}}, Limit: 1}
+
+		// Ties are broken by schedd name
+		var after string
+		got := make([]string, 0)
+		for range 4 {
+			var b bytes.Buffer
+			if err := printPage(&b, schedds, filter, after, list); err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			if lines[0] != "schedd\tnum" {
+				t.Errorf("Expected header with schedd column.  Got %q", lines[0])
+			}
+			got = append(got, lines[1])
+			_, after, _ = strings.Cut(lines[len(lines)-1], "--after ")
+			if after == "" {
+				break
+			}
+		}
+		expected := []string{"schedd1\t4", "schedd2\t4", "schedd1\t2"}
+		if !slices.Equal(got, expected) {
+			t.Errorf("Expected pages %q, got %q", expected, got)
+		}
+	})
+}
+
+func TestRunAdminExplain(t *testing.T) {
+	var args []string
+	setupToken(t, "fermilab")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string // Expected error, or blank for none
+	}{
+		{"no admin subcommand", []string{"admin"}, errUsage.Error()},
+		{"invalid admin subcommand", []string{"admin", "bogus"}, "invalid admin subcommand"},
+		{"explain list", []string{"admin", "explain", "list", "--me", "--group", "fermilab"}, ""},
+		{"explain page of procs", []string{"admin", "explain", "--procs", "--schedd", "schedd1", "--sort", "-start", "--limit", "10"}, ""},
+		{"explain with list --explain", []string{"list", "--explain", "--dag"}, ""},
+		{"explain totals", []string{"admin", "explain", "--totals"}, "--explain may not be used with"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args = append([]string{"fakeJobsub"}, test.args...)
+			err := run(args)
+			if test.expected == "" && err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestRunAdminImport(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	setupToken(t, "nova", "--subject", "importer")
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump.json")
+	contents := `[{"ClusterId": 500, "ProcId": 0, "JobStatus": 1, "Owner": "alice", "AcctGroup": "group_nova", "Cmd": "/grid/run.sh"}]`
+	if err := os.WriteFile(dump, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	badGroup := filepath.Join(dir, "badgroup.json")
+	if err := os.WriteFile(badGroup, []byte(strings.ReplaceAll(contents, "group_nova", "group_bogus")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	duneDump := filepath.Join(dir, "dune.json")
+	if err := os.WriteFile(duneDump, []byte(strings.ReplaceAll(contents, "group_nova", "group_dune")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string // Expected error, or blank for none
+	}{
+		{"no schedd", []string{dump}, "--schedd must be specified"},
+		{"invalid schedd", []string{"--schedd", "schedd9", dump}, "invalid schedd"},
+		{"no dump", []string{"--schedd", "schedd1"}, "exactly one dump file"},
+		{"missing dump", []string{"--schedd", "schedd1", filepath.Join(dir, "nonexistent.json")}, "no such file"},
+		{"unknown group", []string{"--schedd", "schedd1", badGroup}, "bogus"},
+		{"unauthorized group", []string{"--schedd", "schedd1", duneDump}, "not authorized to import jobs of group dune"},
+		{"import", []string{"--schedd", "schedd1", dump}, ""},
+		{"import again", []string{"--schedd", "schedd1", dump}, "cluster 500 already exists"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := run(append([]string{"fakeJobsub", "admin", "import"}, test.args...))
+			if test.expected == "" && err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+
+	// The import is audited as the token's subject
+	schedd, err := condor.GetSchedd("schedd1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := schedd.Audit(db.AuditFilter{Action: "import", User: "importer"})
+	if err != nil || len(records) == 0 || records[0].ClusterID != 500 || records[0].Result != db.AuditOK {
+		t.Errorf("Expected the import of cluster 500 to be audited as importer.  Got %v, %v", records, err)
+	}
+}
+
+func TestRunSubmitFile(t *testing.T) {
+	var args []string
+	setupToken(t, "fermilab")
+	dir := t.TempDir()
+
+	subFile := filepath.Join(dir, "job.sub")
+	contents := "log = " + filepath.Join(dir, "job.log") + "\nrequest_cpus = 2\n+DESIRED_Sites = \"FermiGrid\"\nqueue 3\n"
+	if err := os.WriteFile(subFile, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("submit file with flags taking precedence", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--submit-file", subFile, "--num", "2"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		events, err := userlog.ReadFile(filepath.Join(dir, "job.log"))
+		if err != nil || len(events) != 2 || events[0].Code() != userlog.CodeSubmit {
+			t.Errorf("Should have gotten 2 submit events in the user log.  Got %v, %v", events, err)
+		}
+	},
+	)
+
+	t.Run("missing submit file", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--submit-file", filepath.Join(dir, "nonexistent.sub")}
+		if err := run(args); err == nil {
+			t.Error("Should have gotten an error for a missing submit file")
+		}
+	},
+	)
+
+	t.Run("log in a directory that doesn't exist", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--log", filepath.Join(dir, "nonexistent", "job.log")}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "could not open user log") {
+			t.Errorf("Should have gotten error indicating the user log could not be opened.  Got %v instead", err)
+		}
+	},
+	)
+}
+
+func TestRunSubmitDAG(t *testing.T) {
+	var args []string
+	setupToken(t, "fermilab")
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"workflow.dag": "JOB gen gen.sub\nJOB sim sim.sub\nPARENT gen CHILD sim\nVARS sim run=\"7\"\nRETRY sim 2\n",
+		"gen.sub":      "executable = gen.sh\nlog = gen.log\n+SimRuntime = 10s\nqueue\n",
+		"sim.sub":      "executable = /usr/bin/sim\narguments = --run $(run)\nlog = sim_$(run).log\nrequest_cpus = 2\nqueue 2\n",
+		"bad.dag":      "JOB gen gen.sub\nJOB huge huge.sub\n",
+		"huge.sub":     "request_memory = 1000TB\nqueue\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("submit a DAG, negotiate, and list its nodes", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit-dag", filepath.Join(dir, "workflow.dag"), "--group", "fermilab", "--schedd", "schedd1"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if events, err := userlog.ReadFile(filepath.Join(dir, "gen.log")); err != nil || len(events) != 1 {
+			t.Errorf("Should have gotten 1 submit event for the gen node.  Got %v, %v", events, err)
+		}
+		args = []string{"fakeJobsub", "negotiate"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "list", "--dag", "--schedd", "schedd1", "--keys", "dagid,node,status,clusterid,retries"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("DAG file after the flags", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit-dag", "--group", "fermilab", filepath.Join(dir, "workflow.dag")}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("no DAG file", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit-dag", "--group", "fermilab"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "must give a DAG file") {
+			t.Errorf("Should have gotten error indicating a DAG file is needed.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("node no schedd can accept", func(t *testing.T) {
+		args = []string{"fakeJobsub", "submit-dag", filepath.Join(dir, "bad.dag"), "--group", "fermilab"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "node huge") {
+			t.Errorf("Should have gotten error about node huge.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("list --dag and --procs together", func(t *testing.T) {
+		args = []string{"fakeJobsub", "list", "--dag", "--procs"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "only one of --procs and --dag") {
+			t.Errorf("Should have gotten error indicating --procs and --dag conflict.  Got %v instead", err)
+		}
+	},
+	)
+}
+
+func TestRunToken(t *testing.T) {
+	var args []string
+
+	t.Run("No token subcommand", func(t *testing.T) {
+		args = []string{"fakeJobsub", "token"}
+		if err := run(args); !errors.Is(err, errUsage) {
+			t.Errorf("Should have gotten errUsage.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Issue for unknown group", func(t *testing.T) {
+		args = []string{"fakeJobsub", "token", "issue", "--group", "nvoa", "--out", filepath.Join(t.TempDir(), "token")}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "unknown group") {
+			t.Errorf("Should have gotten error indicating unknown group.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Submit with no token", func(t *testing.T) {
+		t.Setenv("BEARER_TOKEN", "")
+		t.Setenv("BEARER_TOKEN_FILE", filepath.Join(t.TempDir(), "doesnotexist"))
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "could not find bearer token") {
+			t.Errorf("Should have gotten error indicating that there was no token.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Submit with token for another group", func(t *testing.T) {
+		setupToken(t, "nova")
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab"}
+		if err := run(args); !errors.Is(err, token.ErrGroup) {
+			t.Errorf("Should have gotten token.ErrGroup.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Submit with Production role and Analysis token", func(t *testing.T) {
+		setupToken(t, "fermilab")
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab", "--role", "Production"}
+		if err := run(args); !errors.Is(err, token.ErrGroup) {
+			t.Errorf("Should have gotten token.ErrGroup.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Submit with token missing compute.create", func(t *testing.T) {
+		setupToken(t, "fermilab", "--scope", "compute.read")
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab"}
+		if err := run(args); !errors.Is(err, token.ErrScope) {
+			t.Errorf("Should have gotten token.ErrScope.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Submit with expired token", func(t *testing.T) {
+		setupToken(t, "fermilab", "--lifetime", "-1h")
+		args = []string{"fakeJobsub", "submit", "--group", "fermilab"}
+		if err := run(args); !errors.Is(err, token.ErrExpired) {
+			t.Errorf("Should have gotten token.ErrExpired.  Got %v instead", err)
+		}
+	},
+	)
+}
+
+func TestRunJobCommands(t *testing.T) {
+	var args []string
+
+	// Submit a cluster as a unique user, so we can find it again
+	owner := fmt.Sprintf("testuser%d", time.Now().UnixNano())
+	setupToken(t, "nova", "--subject", owner)
+	args = []string{"fakeJobsub", "submit", "--group", "nova", "--num", "2", "--schedd", "schedd1"}
+	if err := run(args); err != nil {
+		t.Fatalf("Could not submit test jobs: %s", err)
+	}
+	schedd, err := condor.GetSchedd("schedd1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listing, err := schedd.List(db.Filter{Owner: owner}, "clusterid")
+	if err != nil {
+		t.Fatalf("Could not list submitted test jobs: %s", err)
+	}
+	rows, err := listing.Collect()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Could not find submitted test jobs: %v, %v", rows, err)
+	}
+	jobID := rows[1] + "@schedd1"
+
+	t.Run("rm with no --jobid", func(t *testing.T) {
+		args = []string{"fakeJobsub", "rm"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--jobid must be specified") {
+			t.Errorf("Should have gotten error indicating --jobid must be specified.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("rm with no schedd", func(t *testing.T) {
+		args = []string{"fakeJobsub", "rm", "--jobid", rows[1]}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "must give the schedd") {
+			t.Errorf("Should have gotten error indicating the schedd must be given.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("list --me", func(t *testing.T) {
+		args = []string{"fakeJobsub", "list", "--schedd", "schedd1", "--me", "--user", "someone"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "only one of --user and --me") {
+			t.Errorf("Should have gotten error indicating --user and --me conflict.  Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "list", "--schedd", "schedd1", "--me"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("list --me with an expired token", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", owner, "--lifetime", "-1h")
+		args = []string{"fakeJobsub", "list", "--schedd", "schedd1", "--me"}
+		if err := run(args); !errors.Is(err, token.ErrExpired) {
+			t.Errorf("Should have gotten token.ErrExpired.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("list --me with no token", func(t *testing.T) {
+		t.Setenv("BEARER_TOKEN", "")
+		t.Setenv("BEARER_TOKEN_FILE", filepath.Join(t.TempDir(), "doesnotexist"))
+		args = []string{"fakeJobsub", "list", "--schedd", "schedd1", "--me"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error, listing the OS user's jobs.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("hold as another user", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", "someoneelse")
+		args = []string{"fakeJobsub", "hold", "--jobid", jobID}
+		if err := run(args); !errors.Is(err, condor.ErrPermissionDenied) {
+			t.Errorf("Should have gotten condor.ErrPermissionDenied.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("hold and release as group superuser", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", "novapro")
+		args = []string{"fakeJobsub", "hold", "--jobid", jobID}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+		args = []string{"fakeJobsub", "release", "--jobid", jobID}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("rm and hold with a token for another group", func(t *testing.T) {
+		setupToken(t, "dune", "--subject", owner)
+		for _, cmd := range []string{"rm", "hold"} {
+			args = []string{"fakeJobsub", cmd, "--jobid", jobID}
+			if err := run(args); !errors.Is(err, token.ErrGroup) {
+				t.Errorf("%s: Should have gotten token.ErrGroup.  Got %v instead", cmd, err)
+			}
+		}
+	},
+	)
+
+	t.Run("rm without compute.cancel scope", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", owner, "--scope", "compute.create")
+		args = []string{"fakeJobsub", "rm", "--jobid", jobID}
+		if err := run(args); !errors.Is(err, token.ErrScope) {
+			t.Errorf("Should have gotten token.ErrScope.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("edit cpus to 0", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", owner)
+		args = []string{"fakeJobsub", "edit", "--jobid", jobID, "--key", "cpus", "--value", "0"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "must request at least 1 CPU") {
+			t.Errorf("Should have gotten error indicating at least 1 CPU must be requested.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("edit role as owner", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", owner)
+		args = []string{"fakeJobsub", "edit", "--jobid", jobID, "--key", "role", "--value", "Production"}
+		if err := run(args); !errors.Is(err, token.ErrGroup) {
+			t.Errorf("Should have gotten token.ErrGroup since the token doesn't authorize Production.  Got %v instead", err)
+		}
+		setupToken(t, "nova", "--subject", owner, "--role", "Production")
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("analyze a whole cluster", func(t *testing.T) {
+		args = []string{"fakeJobsub", "analyze", "--jobid", jobID}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "single jobs") {
+			t.Errorf("Should have gotten error indicating analyze applies to single jobs.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("analyze", func(t *testing.T) {
+		args = []string{"fakeJobsub", "analyze", "--jobid", rows[1] + ".1@schedd1"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("fetchlog of idle jobs", func(t *testing.T) {
+		args = []string{"fakeJobsub", "fetchlog", "--jobid", jobID, "--destdir", t.TempDir()}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "no output") {
+			t.Errorf("Should have gotten error indicating there is no output.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("rm as owner", func(t *testing.T) {
+		// The cluster's role was edited to Production above, so an Analysis token is not enough
+		setupToken(t, "nova", "--subject", owner)
+		args = []string{"fakeJobsub", "rm", "--jobid", jobID}
+		if err := run(args); !errors.Is(err, token.ErrGroup) {
+			t.Errorf("Should have gotten token.ErrGroup since the token doesn't authorize Production.  Got %v instead", err)
+		}
+		setupToken(t, "nova", "--subject", owner, "--role", "Production")
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error. Got %v instead", err)
+		}
+	},
+	)
+}
+
+func TestRunUserprio(t *testing.T) {
+	var args []string
+	// Use a fresh accountant
+	t.Setenv("TMPDIR", t.TempDir())
+
+	t.Run("Show priorities", func(t *testing.T) {
+		args = []string{"fakeJobsub", "userprio"}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Unknown group", func(t *testing.T) {
+		args = []string{"fakeJobsub", "userprio", "--group", "nvoa"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "unknown group") {
+			t.Errorf("Should have gotten error indicating unknown group.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Non-admin can't set factor", func(t *testing.T) {
+		setupToken(t, "nova", "--subject", "novapro")
+		args = []string{"fakeJobsub", "userprio", "--group", "nova", "--setfactor", "10"}
+		if err := run(args); !errors.Is(err, condor.ErrPermissionDenied) {
+			t.Errorf("Should have gotten ErrPermissionDenied.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Admin can set factor", func(t *testing.T) {
+		setupToken(t, "fermilab", "--subject", "admin")
+		args = []string{"fakeJobsub", "userprio", "--group", "nova", "--setfactor", "10"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		accountant, err := condor.GetAccountant(config.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		prios, err := accountant.Priorities(time.Now(), []string{"nova"})
+		if err != nil || len(prios) != 1 || prios[0].Factor != 10 {
+			t.Errorf("nova's priority factor should be 10.  Got %+v, %v", prios, err)
+		}
+	},
+	)
+
+	t.Run("Setfactor must be positive", func(t *testing.T) {
+		args = []string{"fakeJobsub", "userprio", "--group", "nova", "--setfactor", "0"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--setfactor must be positive") {
+			t.Errorf("Should have gotten error indicating --setfactor must be positive.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Admin can reset factor", func(t *testing.T) {
+		setupToken(t, "fermilab", "--subject", "admin")
+		args = []string{"fakeJobsub", "userprio", "--group", "nova", "--resetfactor"}
+		if err := run(args); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		accountant, err := condor.GetAccountant(config.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		configured := config.Default().PriorityFactor("nova")
+		prios, err := accountant.Priorities(time.Now(), []string{"nova"})
+		if err != nil || len(prios) != 1 || prios[0].Factor != configured {
+			t.Errorf("nova's priority factor should be the configured %v.  Got %+v, %v", configured, prios, err)
+		}
+	},
+	)
+
+	t.Run("Setfactor and resetfactor", func(t *testing.T) {
+		args = []string{"fakeJobsub", "userprio", "--group", "nova", "--setfactor", "10", "--resetfactor"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "only one of --setfactor and --resetfactor") {
+			t.Errorf("Should have gotten error indicating --setfactor and --resetfactor conflict.  Got %v instead", err)
+		}
+	},
+	)
+
+	t.Run("Setfactor without group", func(t *testing.T) {
+		args = []string{"fakeJobsub", "userprio", "--setfactor", "10"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--group must be specified") {
+			t.Errorf("Should have gotten error indicating --group must be specified.  Got %v instead", err)
+		}
+	},
+	)
 }
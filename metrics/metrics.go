@@ -0,0 +1,280 @@
+// Package metrics keeps counters and histograms and writes them, along with gauges collected when they are scraped, in the
+// Prometheus text exposition format (version 0.0.4).  It implements just what fakeJobsub needs of a Prometheus client library
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of histograms of latencies in seconds.  They cover the simulated latencies
+// of a schedd, which are a few seconds, as well as the milliseconds an operation takes without them
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds metrics, and writes them in the order they were registered
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+}
+
+// family is a metric with a name, which may have a series of samples for each combination of its labels' values
+type family interface {
+	write(w *bufio.Writer) error
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make([]family, 0)}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families = append(r.families, f)
+}
+
+// Write writes every metric in r to w
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if err := f.write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler returns an HTTP handler that serves the metrics in registries, like a Prometheus /metrics endpoint
+func Handler(registries ...*Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Write to a buffer first, so that a gauge that can't be collected gives an error rather than a partial scrape
+		var b strings.Builder
+		for _, r := range registries {
+			if err := r.Write(&b); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		io.WriteString(w, b.String())
+	})
+}
+
+// desc describes a metric family
+type desc struct {
+	name   string
+	help   string
+	kind   string // counter, gauge, or histogram
+	labels []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// writeSample writes one sample of the metric name, whose labels have values, followed by any extra label
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	pairs := make([]string, 0, len(labels)+1)
+	for i, label := range labels {
+		pairs = append(pairs, label+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if extraLabel != "" {
+		pairs = append(pairs, extraLabel+`="`+extraValue+`"`)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// seriesKey joins label values into a map key.  \xff can't appear in valid UTF-8, so different values can't collide
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func checkValues(name string, labels, values []string) {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, but got %d values", name, labels, len(values)))
+	}
+}
+
+// Counter is a counter with labels, which has a value for each combination of their values
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+	series map[string][]string
+}
+
+// Counter registers and returns a counter called name
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, values: make(map[string]float64), series: make(map[string][]string)}
+	r.register(c)
+	return c
+}
+
+// Inc adds 1 to the counter with the label values given, in the order the labels were registered
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the counter with the label values given
+func (c *Counter) Add(v float64, values ...string) {
+	checkValues(c.name, c.labels, values)
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can't decrease", c.name))
+	}
+	key := seriesKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.series[key]; !ok {
+		c.series[key] = slices.Clone(values)
+	}
+	c.values[key] += v
+}
+
+// Value returns the value of the counter with the label values given
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[seriesKey(values)]
+}
+
+func (c *Counter) write(w *bufio.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, key := range slices.Sorted(maps.Keys(c.series)) {
+		writeSample(w, c.name, c.labels, c.series[key], "", "", c.values[key])
+	}
+	return nil
+}
+
+// Histogram is a histogram with labels, which counts observations in buckets for each combination of their values
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // Observations in each bucket, not counting those in lower buckets
+	count  uint64
+	sum    float64
+}
+
+// Histogram registers and returns a histogram called name, whose buckets have the upper bounds given, in increasing order
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s are not in increasing order", name))
+	}
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+// Observe adds the observation v to the histogram with the label values given
+func (h *Histogram) Observe(v float64, values ...string) {
+	checkValues(h.name, h.labels, values)
+	key := seriesKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations in the histogram with the label values given
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[seriesKey(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatValue(le), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.count))
+	}
+	return nil
+}
+
+// Sample is the value of a gauge for one combination of its labels' values
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// gaugeFunc is a gauge whose samples are collected when it is written
+type gaugeFunc struct {
+	desc
+	collect func() ([]Sample, error)
+}
+
+// GaugeFunc registers a gauge called name, whose samples collect returns each time it is scraped
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func() ([]Sample, error)) {
+	r.register(&gaugeFunc{desc: desc{name, help, "gauge", labels}, collect: collect})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) error {
+	samples, err := g.collect()
+	if err != nil {
+		return fmt.Errorf("could not collect %s: %w", g.name, err)
+	}
+	g.writeHeader(w)
+	for _, s := range samples {
+		checkValues(g.name, g.labels, s.Values)
+		writeSample(w, g.name, g.labels, s.Values, "", "", s.Value)
+	}
+	return nil
+}
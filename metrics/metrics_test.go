@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_requests_total", "Requests, by code.", "code")
+	h := r.Histogram("test_duration_seconds", "How long requests took.", []float64{0.1, 1}, "path")
+	r.GaugeFunc("test_queue", "Jobs in the queue.\nBy status.", []string{"status"}, func() ([]Sample, error) {
+		return []Sample{{Values: []string{"idle"}, Value: 3}, {Values: []string{`say "hi"\`}, Value: 0.5}}, nil
+	})
+
+	c.Inc("500")
+	c.Add(2, "200")
+	h.Observe(0.05, "/")
+	h.Observe(0.1, "/")
+	h.Observe(0.5, "/")
+	h.Observe(7, "/")
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	expected := `# HELP test_requests_total Requests, by code.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 2
+test_requests_total{code="500"} 1
+# HELP test_duration_seconds How long requests took.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{path="/",le="0.1"} 2
+test_duration_seconds_bucket{path="/",le="1"} 3
+test_duration_seconds_bucket{path="/",le="+Inf"} 4
+test_duration_seconds_sum{path="/"} 7.65
+test_duration_seconds_count{path="/"} 4
+# HELP test_queue Jobs in the queue.\nBy status.
+# TYPE test_queue gauge
+test_queue{status="idle"} 3
+test_queue{status="say \"hi\"\\"} 0.5
+`
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+	if v := c.Value("200"); v != 2 {
+		t.Errorf("Expected counter to be 2.  Got %v instead", v)
+	}
+	if n := h.Count("/"); n != 4 {
+		t.Errorf("Expected 4 observations.  Got %d instead", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("test_total", "A counter.").Inc()
+
+	t.Run("scrape", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "\ntest_total 1\n") {
+			t.Errorf("Expected test_total 1.  Got %d:\n%s", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("Got wrong content type %q", ct)
+		}
+	})
+
+	t.Run("gauge that can't be collected", func(t *testing.T) {
+		broken := NewRegistry()
+		broken.GaugeFunc("test_broken", "A gauge.", nil, func() ([]Sample, error) { return nil, errors.New("database is locked") })
+		rec := httptest.NewRecorder()
+		Handler(r, broken).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "could not collect test_broken: database is locked") {
+			t.Errorf("Expected an error.  Got %d:\n%s", rec.Code, rec.Body.String())
+		}
+	})
+}
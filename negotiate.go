@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/notify"
+)
+
+// runNegotiate runs the negotiate subcommand, which runs negotiation cycles on the simulated pool.  args are the arguments after "negotiate"
+func runNegotiate(cfg *config.Config, args []string) error {
+	negotiateCmd := flag.NewFlagSet("negotiate", flag.ContinueOnError)
+	negotiateCycles := negotiateCmd.Int("cycles", 1, "Number of negotiation cycles to run")
+	negotiateInterval := negotiateCmd.Duration("interval", 10*time.Second, "Time to wait between negotiation cycles")
+
+	if err := negotiateCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	if *negotiateCycles < 1 {
+		return errors.New("--cycles must be at least 1")
+	}
+
+	n, notifier, err := newNegotiator(cfg)
+	if err != nil {
+		return err
+	}
+	defer waitForNotifications(notifier)
+
+	for cycle := range *negotiateCycles {
+		if cycle > 0 {
+			time.Sleep(*negotiateInterval)
+		}
+		now := time.Now()
+		result, err := n.Cycle(now)
+		if err != nil {
+			return fmt.Errorf("negotiation cycle failed: %w", err)
+		}
+		printCycle(now, result)
+	}
+	return nil
+}
+
+// printCycle prints the result of the negotiation cycle at now
+func printCycle(now time.Time, result condor.CycleResult) {
+	fmt.Printf("%s: %d job(s) completed (%d retried), %d DAG node(s) submitted, %d job(s) matched, %d job(s) still idle\n",
+		now.Format(time.DateTime), result.Completed, result.Retried, result.DAGNodes, result.Matched, result.Idle)
+}
+
+// newNegotiator returns a negotiator for the configured pool and all of the configured schedds, and the notifier the schedds
+// send notifications to.  Wait for the notifier before exiting
+func newNegotiator(cfg *config.Config) (*condor.Negotiator, *notify.Notifier, error) {
+	schedds, err := getSchedds(cfg.ScheddNames())
+	if err != nil {
+		return nil, nil, err
+	}
+	notifier := newNotifier(cfg)
+	for _, s := range schedds {
+		s.Notifier = notifier
+	}
+	accountant, err := condor.GetAccountant(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get accountant: %w", err)
+	}
+	return &condor.Negotiator{Pool: condor.NewPool(cfg.Pool), Schedds: schedds, Accountant: accountant, Quotas: cfg.Quotas()}, notifier, nil
+}
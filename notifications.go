@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/notify"
+)
+
+// newNotifier returns the notifier configured in cfg.  If no sinks are configured, notifications are appended to an mbox file
+// in the temporary directory
+func newNotifier(cfg *config.Config) *notify.Notifier {
+	c := cfg.Notifications
+	n := &notify.Notifier{Retries: c.Retries, Backoff: time.Duration(c.RetryBackoff), DeadLetter: c.DeadLetter}
+	switch {
+	case n.Retries == 0:
+		n.Retries = notify.DefaultRetries
+	case n.Retries < 0:
+		n.Retries = 0
+	}
+	if n.Backoff == 0 {
+		n.Backoff = notify.DefaultBackoff
+	}
+	if n.DeadLetter == "" {
+		n.DeadLetter = filepath.Join(os.TempDir(), "fakeJobsubDeadLetter.jsonl")
+	}
+
+	if len(c.Command) > 0 {
+		n.Sinks = append(n.Sinks, notify.Command{Args: c.Command})
+	}
+	if c.WebhookURL != "" {
+		n.Sinks = append(n.Sinks, notify.Webhook{URL: c.WebhookURL})
+	}
+	if c.Mbox != "" || len(n.Sinks) == 0 {
+		mbox := c.Mbox
+		if mbox == "" {
+			mbox = filepath.Join(os.TempDir(), "fakeJobsub.mbox")
+		}
+		n.Sinks = append(n.Sinks, &notify.Mbox{Path: mbox})
+	}
+	return n
+}
+
+// notifyShutdownTimeout is how long commands wait for the notifications under way to be delivered before they exit
+const notifyShutdownTimeout = 30 * time.Second
+
+// waitForNotifications waits for n to deliver the notifications under way, for at most notifyShutdownTimeout.  Those that it
+// hasn't delivered by then are written to its dead-letter file
+func waitForNotifications(n *notify.Notifier) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyShutdownTimeout)
+	defer cancel()
+	if err := n.Wait(ctx); err != nil {
+		slog.Warn("gave up waiting for notifications to be delivered", "timeout", notifyShutdownTimeout, "dead_letter", n.DeadLetter)
+	}
+}
+
+// parseNotify parses the --notify setting s, which is one of db.NotifyValues, in any case like HTCondor's notification
+func parseNotify(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if v := strings.ToLower(s); slices.Contains(db.NotifyValues, v) {
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid --notify %s.  Choose from %v", s, db.NotifyValues)
+}
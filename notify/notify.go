@@ -0,0 +1,226 @@
+// Package notify tells job owners when their jobs change state, like the email jobsub_lite and HTCondor send when jobs
+// complete.  A Notifier delivers each notification to every one of its sinks:  a local command, an HTTP webhook, or an mbox
+// file that stands in for a mail server.  Failed deliveries are retried with exponential backoff, and those that fail every
+// retry are appended to a dead-letter file
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events that jobs are notified of
+const (
+	EventStarted   = "started"
+	EventCompleted = "completed" // The job left the queue after it ran
+	EventRetried   = "retried"   // The job exited non-zero, and was put back in the queue by its retry policy
+	EventHeld      = "held"
+	EventReleased  = "released"
+	EventRemoved   = "removed"
+)
+
+// Notification is what a sink is told about a job
+type Notification struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	JobID    string    `json:"job_id"` // Like 12.0@schedd1
+	Owner    string    `json:"owner"`
+	Group    string    `json:"group"`
+	ExitCode *int      `json:"exit_code,omitempty"` // For completed and retried jobs
+	Reason   string    `json:"reason,omitempty"`    // Why the job was held, released, or removed
+}
+
+// Subject summarizes n in a line, like the subject of an email
+func (n Notification) Subject() string {
+	s := fmt.Sprintf("fakeJobsub job %s %s", n.JobID, n.Event)
+	if n.ExitCode != nil {
+		s += fmt.Sprintf(" with exit code %d", *n.ExitCode)
+	}
+	return s
+}
+
+// Sink is somewhere notifications are delivered
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, n Notification) error
+}
+
+// Defaults for Notifier
+const (
+	DefaultRetries = 3
+	DefaultBackoff = time.Second
+	DefaultTimeout = 10 * time.Second
+)
+
+// Notifier delivers notifications to its sinks in the background.  Call Wait before exiting so that none are lost.  Once a
+// Wait has given up, every notification is dead-lettered without being delivered
+type Notifier struct {
+	Sinks      []Sink
+	Retries    int           // How many times a failed delivery is retried
+	Backoff    time.Duration // How long to wait before the first retry.  The wait doubles with each retry
+	Timeout    time.Duration // How long each attempt may take.  Zero means DefaultTimeout
+	DeadLetter string        // File that deliveries that failed every retry are appended to, one JSON object per line
+	Logger     *slog.Logger  // Nil means slog's default logger
+
+	wg           sync.WaitGroup
+	deadLetterMu sync.Mutex
+
+	// Deliveries stop when stopCtx is cancelled, which Wait does when it gives up on them
+	stopOnce sync.Once
+	stopCtx  context.Context
+	stop     context.CancelFunc
+}
+
+// DeadLetter is a line of the dead-letter file:  a notification that could not be delivered to a sink
+type DeadLetter struct {
+	Time         time.Time    `json:"time"` // When the last attempt failed
+	Sink         string       `json:"sink"`
+	Attempts     int          `json:"attempts"`
+	Error        string       `json:"error"` // Why the last attempt failed
+	Notification Notification `json:"notification"`
+}
+
+// Notify delivers note to each of n's sinks in the background
+func (n *Notifier) Notify(note Notification) {
+	for _, sink := range n.Sinks {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.deliver(sink, note)
+		}()
+	}
+}
+
+// Wait waits for the deliveries that are under way, including their retries, to finish.  If ctx is done first, it stops them,
+// waits for the notifications they hadn't delivered to be written to the dead-letter file, and returns ctx's error
+func (n *Notifier) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	n.stopped()
+	n.stop()
+	<-done
+	return ctx.Err()
+}
+
+// stopped returns the context that is cancelled when Wait gives up on the deliveries under way
+func (n *Notifier) stopped() context.Context {
+	n.stopOnce.Do(func() { n.stopCtx, n.stop = context.WithCancel(context.Background()) })
+	return n.stopCtx
+}
+
+func (n *Notifier) log() *slog.Logger {
+	if n.Logger == nil {
+		return slog.Default()
+	}
+	return n.Logger
+}
+
+// deliver delivers note to sink, retrying if it fails, and writes it to the dead-letter file if every attempt fails or Wait
+// stops it first
+func (n *Notifier) deliver(sink Sink, note Notification) {
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	stopped := n.stopped()
+	backoff := n.Backoff
+	var err error
+	attempts := 0
+	for attempts <= n.Retries && stopped.Err() == nil {
+		if attempts > 0 {
+			select {
+			case <-stopped.Done():
+				continue
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		attempts++
+		ctx, cancel := context.WithTimeout(stopped, timeout)
+		err = sink.Deliver(ctx, note)
+		cancel()
+		if err == nil {
+			n.log().Debug("delivered notification", "sink", sink.Name(), "job", note.JobID, "event", note.Event, "attempts", attempts)
+			return
+		}
+		n.log().Debug("could not deliver notification", "sink", sink.Name(), "job", note.JobID, "event", note.Event, "attempt", attempts, "error", err)
+	}
+	if stopped.Err() != nil {
+		if err == nil {
+			err = errors.New("not attempted")
+		}
+		err = fmt.Errorf("stopped waiting at shutdown: %w", err)
+	}
+
+	n.log().Warn("giving up on notification", "sink", sink.Name(), "job", note.JobID, "event", note.Event, "attempts", attempts,
+		"error", err, "dead_letter", n.DeadLetter)
+	if n.DeadLetter == "" {
+		return
+	}
+	letter := DeadLetter{Time: time.Now(), Sink: sink.Name(), Attempts: attempts, Error: err.Error(), Notification: note}
+	if err := n.writeDeadLetter(letter); err != nil {
+		n.log().Error("could not write dead letter", "path", n.DeadLetter, "job", note.JobID, "error", err)
+	}
+}
+
+func (n *Notifier) writeDeadLetter(letter DeadLetter) error {
+	b, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	n.deadLetterMu.Lock()
+	defer n.deadLetterMu.Unlock()
+	return appendFile(n.DeadLetter, append(b, '\n'))
+}
+
+// ReadDeadLetters reads the dead-letter file at path.  If there is no such file, nothing has been dead-lettered
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []DeadLetter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, 0)
+	for i, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if line == "" {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(line), &letter); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, i+1, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// appendFile appends b to the file at path, creating it if it doesn't exist, in a single write
+func appendFile(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
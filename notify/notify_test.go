@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakySink fails the first failures deliveries, and records the rest
+type flakySink struct {
+	failures  int
+	mu        sync.Mutex
+	attempts  int
+	delivered []Notification
+}
+
+func (f *flakySink) Name() string { return "flaky" }
+
+func (f *flakySink) Deliver(_ context.Context, n Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("unavailable")
+	}
+	f.delivered = append(f.delivered, n)
+	return nil
+}
+
+func exitCode(code int) *int { return &code }
+
+func TestNotifierRetries(t *testing.T) {
+	note := Notification{Time: time.Now(), Event: EventCompleted, JobID: "1.0@schedd1", Owner: "alice", Group: "nova", ExitCode: exitCode(0)}
+
+	tests := []struct {
+		name          string
+		failures      int
+		expectedDead  int
+		expectedTries int
+	}{
+		{"first try", 0, 0, 1},
+		{"after retries", 2, 0, 3},
+		{"dead letter", 5, 1, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+			sink := &flakySink{failures: test.failures}
+			n := &Notifier{Sinks: []Sink{sink}, Retries: 2, Backoff: time.Millisecond, DeadLetter: deadLetter}
+			n.Notify(note)
+			if err := n.Wait(context.Background()); err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+
+			if sink.attempts != test.expectedTries {
+				t.Errorf("Expected %d attempts.  Got %d instead", test.expectedTries, sink.attempts)
+			}
+			letters, err := ReadDeadLetters(deadLetter)
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if len(letters) != test.expectedDead {
+				t.Fatalf("Expected %d dead letter(s).  Got %v instead", test.expectedDead, letters)
+			}
+			if test.expectedDead > 0 {
+				l := letters[0]
+				if l.Sink != "flaky" || l.Attempts != 3 || l.Error != "unavailable" || l.Notification.JobID != note.JobID || *l.Notification.ExitCode != 0 {
+					t.Errorf("Got wrong dead letter %+v", l)
+				}
+			} else if len(sink.delivered) != 1 || sink.delivered[0].JobID != note.JobID {
+				t.Errorf("Expected the notification to be delivered.  Got %v instead", sink.delivered)
+			}
+		})
+	}
+}
+
+// blockingSink blocks each delivery until its context is done
+type blockingSink struct{}
+
+func (blockingSink) Name() string { return "blocking" }
+
+func (blockingSink) Deliver(ctx context.Context, _ Notification) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestNotifierWaitDeadline(t *testing.T) {
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	n := &Notifier{Sinks: []Sink{blockingSink{}}, Retries: 5, Backoff: time.Hour, Timeout: time.Hour, DeadLetter: deadLetter}
+	n.Notify(Notification{Event: EventHeld, JobID: "1.0@schedd1", Owner: "alice"})
+	n.Notify(Notification{Event: EventHeld, JobID: "2.0@schedd1", Owner: "alice"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Should have gotten context.DeadlineExceeded.  Got %v instead", err)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("Wait should have given up at its deadline.  Waited %s", waited)
+	}
+
+	// What was still being delivered is dead-lettered
+	letters, err := ReadDeadLetters(deadLetter)
+	if err != nil || len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters.  Got %v, %v", letters, err)
+	}
+	for _, l := range letters {
+		if l.Sink != "blocking" || l.Attempts != 1 || !strings.Contains(l.Error, "stopped waiting at shutdown") {
+			t.Errorf("Got wrong dead letter %+v", l)
+		}
+	}
+
+	// And so is anything after
+	n.Notify(Notification{Event: EventReleased, JobID: "1.0@schedd1", Owner: "alice"})
+	if err := n.Wait(context.Background()); err != nil {
+		t.Errorf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if letters, err := ReadDeadLetters(deadLetter); err != nil || len(letters) != 3 {
+		t.Errorf("Expected 3 dead letters.  Got %v, %v", letters, err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got Notification
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.Unmarshal(b, &got) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	w := Webhook{URL: server.URL + "/hook"}
+	note := Notification{Event: EventHeld, JobID: "2.1@schedd2", Owner: "bob", Reason: "by user bob"}
+	if err := w.Deliver(context.Background(), note); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if got.JobID != note.JobID || got.Event != EventHeld || got.Reason != note.Reason || got.ExitCode != nil {
+		t.Errorf("Webhook got wrong notification %+v", got)
+	}
+
+	status = http.StatusInternalServerError
+	if err := w.Deliver(context.Background(), note); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Should have gotten an error for a 500 response.  Got %v instead", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	c := Command{Args: []string{"sh", "-c", `cat > "$1"; echo "$FAKEJOBSUB_EVENT $FAKEJOBSUB_JOBID $FAKEJOBSUB_EXIT_CODE" >> "$1"`, "sh", out}}
+	note := Notification{Event: EventCompleted, JobID: "3.0@schedd1", ExitCode: exitCode(7)}
+	if err := c.Deliver(context.Background(), note); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"job_id":"3.0@schedd1"`) || !strings.HasSuffix(string(b), "completed 3.0@schedd1 7\n") {
+		t.Errorf("Command got wrong notification:  %s", b)
+	}
+
+	failing := Command{Args: []string{"sh", "-c", "echo nope; exit 3"}}
+	if err := failing.Deliver(context.Background(), note); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("Should have gotten an error with the command's output.  Got %v instead", err)
+	}
+}
+
+func TestMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mbox")
+	m := &Mbox{Path: path}
+	at := time.Date(2024, 6, 10, 16, 8, 23, 0, time.UTC)
+	notes := []Notification{
+		{Time: at, Event: EventCompleted, JobID: "1.0@schedd1", Owner: "alice", Group: "nova", ExitCode: exitCode(1)},
+		{Time: at, Event: EventRemoved, JobID: "1.1@schedd1", Owner: "alice", Group: "nova", Reason: "by user novapro\nFrom here"},
+	}
+	for _, note := range notes {
+		if err := m.Deliver(context.Background(), note); err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if n := strings.Count(s, "\nFrom ") + 1; !strings.HasPrefix(s, "From fakejobsub@localhost Mon Jun 10 16:08:23 2024\n") || n != 2 {
+		t.Errorf("Expected 2 messages.  Got %d in:\n%s", n, s)
+	}
+	for _, expected := range []string{"To: alice\n", "Subject: fakeJobsub job 1.0@schedd1 completed with exit code 1\n", "Subject: fakeJobsub job 1.1@schedd1 removed\n", "\n>From here\n"} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected mbox to contain %q.  Got:\n%s", expected, s)
+		}
+	}
+}
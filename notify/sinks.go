@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Command is a sink that runs a local command for each notification, with the notification as JSON on its stdin, and in
+// FAKEJOBSUB_* environment variables
+type Command struct {
+	Args []string // The command and its arguments
+}
+
+func (c Command) Name() string { return "command" }
+
+func (c Command) Deliver(ctx context.Context, n Notification) error {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Env = append(os.Environ(),
+		"FAKEJOBSUB_EVENT="+n.Event,
+		"FAKEJOBSUB_JOBID="+n.JobID,
+		"FAKEJOBSUB_OWNER="+n.Owner,
+		"FAKEJOBSUB_GROUP="+n.Group,
+	)
+	if n.ExitCode != nil {
+		cmd.Env = append(cmd.Env, "FAKEJOBSUB_EXIT_CODE="+strconv.Itoa(*n.ExitCode))
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if out := strings.TrimSpace(string(out)); out != "" {
+			return fmt.Errorf("%s: %w: %s", c.Args[0], err, out)
+		}
+		return fmt.Errorf("%s: %w", c.Args[0], err)
+	}
+	return nil
+}
+
+// Webhook is a sink that POSTs each notification, as JSON, to URL.  Any response other than 2xx is a failure
+type Webhook struct {
+	URL    string
+	Client *http.Client // Nil means http.DefaultClient
+}
+
+func (w Webhook) Name() string { return "webhook" }
+
+func (w Webhook) Deliver(ctx context.Context, n Notification) error {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Mbox is a sink that stands in for a mail server:  each notification is appended to the mbox file at Path as an email to
+// the job's owner, which mail readers like mutt -f can open
+type Mbox struct {
+	Path string
+	From string // Sender address.  Blank means MboxFrom
+
+	mu sync.Mutex // Held while a message is appended
+}
+
+// MboxFrom is the default sender of notification emails
+const MboxFrom = "fakejobsub@localhost"
+
+func (m *Mbox) Name() string { return "mbox" }
+
+func (m *Mbox) Deliver(_ context.Context, n Notification) error {
+	from := m.From
+	if from == "" {
+		from = MboxFrom
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From %s %s\n", from, n.Time.UTC().Format(time.ANSIC))
+	fmt.Fprintf(&b, "From: fakeJobsub <%s>\n", from)
+	fmt.Fprintf(&b, "To: %s\n", n.Owner)
+	fmt.Fprintf(&b, "Date: %s\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Subject: %s\n\n", n.Subject())
+	fmt.Fprintf(&b, "Job:    %s\nGroup:  %s\nEvent:  %s\nTime:   %s\n", n.JobID, n.Group, n.Event, n.Time.Format(time.DateTime))
+	if n.ExitCode != nil {
+		fmt.Fprintf(&b, "Exit:   %d\n", *n.ExitCode)
+	}
+	if n.Reason != "" {
+		// Lines starting with "From " would start a new message, so they are quoted as mboxrd does
+		fmt.Fprintf(&b, "Reason: %s\n", strings.ReplaceAll(n.Reason, "\nFrom ", "\n>From "))
+	}
+	b.WriteString("\n")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return appendFile(m.Path, []byte(b.String()))
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/db"
+)
+
+// Rows listed from several schedds with --sort, --limit, or --after are merged into one table, in order of their sort values, then
+// the name of the schedd they came from, then the values that identify them on that schedd (like their cluster IDs).  That order
+// is total, so a page always starts where the last one ended no matter how the rows are spread over the schedds
+
+// pageCursor marks where a page of a merged listing ended:  the schedd its last row came from and that row's Key.  The cursor only
+// makes sense for the sort it was listed with, so that is recorded too
+type pageCursor struct {
+	Sort   string `json:"sort"`
+	Schedd string `json:"schedd"`
+	Key    []any  `json:"key"`
+}
+
+// encode returns c in the form --after accepts
+func (c pageCursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor is the inverse of pageCursor.encode.  sort is the current --sort, which must be the one the cursor was listed with
+func decodeCursor(s string, sort []db.SortKey) (*pageCursor, error) {
+	errInvalid := errors.New("invalid --after cursor")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalid
+	}
+	var c pageCursor
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || len(c.Key) <= len(sort) {
+		return nil, errInvalid
+	}
+	if c.Sort != sortString(sort) {
+		return nil, fmt.Errorf("--after cursor is for --sort %q, not %q", c.Sort, sortString(sort))
+	}
+
+	// Listings' keys hold only integers and strings
+	for i, v := range c.Key {
+		switch v := v.(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				return nil, errInvalid
+			}
+			c.Key[i] = n
+		case string:
+		default:
+			return nil, errInvalid
+		}
+	}
+	return &c, nil
+}
+
+func sortString(sort []db.SortKey) string {
+	keys := make([]string, 0, len(sort))
+	for _, key := range sort {
+		keys = append(keys, key.String())
+	}
+	return strings.Join(keys, ",")
+}
+
+// filter returns the filter for listing the rows after c from the schedd named schedd.  Rows on the cursor's schedd must come
+// after its row.  Rows on schedds merged after it may have the same sort values as its row, and rows on schedds merged before
+// it must have later sort values
+func (c *pageCursor) filter(filter db.Filter, schedd string) db.Filter {
+	values := c.Key[:len(filter.Sort)]
+	switch {
+	case schedd == c.Schedd:
+		filter.After = c.Key
+	case schedd > c.Schedd:
+		filter.After, filter.AfterInclusive = values, true
+	default:
+		filter.After = values
+	}
+	return filter
+}
+
+// pageFilter returns the filter for listing a page from the schedd named schedd, after cursor if it isn't nil.  One more row
+// than the page holds is listed, so we know whether there is another page
+func pageFilter(filter db.Filter, cursor *pageCursor, schedd string) db.Filter {
+	if cursor != nil {
+		filter = cursor.filter(filter, schedd)
+	}
+	if filter.Limit > 0 {
+		filter.Limit++
+	}
+	return filter
+}
+
+// mergedRow is a row of a listing merged from several schedds
+type mergedRow struct {
+	schedd string
+	row    db.Row
+}
+
+// compareMerged compares the rows a and b from listings sorted by sort, in the order that merged listings are in
+func compareMerged(sort []db.SortKey, a, b mergedRow) int {
+	n := len(sort)
+	if c := db.CompareKeys(sort, a.row.Key[:n], b.row.Key[:n]); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.schedd, b.schedd); c != 0 {
+		return c
+	}
+	return db.CompareKeys(nil, a.row.Key[n:], b.row.Key[n:])
+}
+
+// mergeHeap holds the next row of each listing being merged.  It implements heap.Interface
+type mergeHeap struct {
+	sort  []db.SortKey
+	heads []mergedRow
+	next  map[string]func() (db.Row, error, bool) // Pulls the row after the head of each schedd's listing
+}
+
+func (h *mergeHeap) Len() int           { return len(h.heads) }
+func (h *mergeHeap) Less(i, j int) bool { return compareMerged(h.sort, h.heads[i], h.heads[j]) < 0 }
+func (h *mergeHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *mergeHeap) Push(x any)         { h.heads = append(h.heads, x.(mergedRow)) }
+func (h *mergeHeap) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+// mergeListings merges listings, which are each sorted by sort, keyed by the name of the schedd they came from.  Only the next
+// row of each listing is held in memory, so rows are read from the schedds only as fast as the merged rows are iterated
+func mergeListings(listings map[string]*db.Listing, sort []db.SortKey) iter.Seq2[mergedRow, error] {
+	return func(yield func(mergedRow, error) bool) {
+		h := &mergeHeap{sort: sort, next: make(map[string]func() (db.Row, error, bool), len(listings))}
+		for schedd, listing := range listings {
+			next, stop := iter.Pull2(listing.Rows)
+			defer stop()
+			h.next[schedd] = next
+			row, err, ok := next()
+			if err != nil {
+				yield(mergedRow{}, fmt.Errorf("%s: %w", schedd, err))
+				return
+			}
+			if ok {
+				h.heads = append(h.heads, mergedRow{schedd, row})
+			}
+		}
+		heap.Init(h)
+
+		for h.Len() > 0 {
+			head := h.heads[0]
+			if !yield(head, nil) {
+				return
+			}
+			row, err, ok := h.next[head.schedd]()
+			switch {
+			case err != nil:
+				yield(mergedRow{}, fmt.Errorf("%s: %w", head.schedd, err))
+				return
+			case ok:
+				h.heads[0] = mergedRow{head.schedd, row}
+				heap.Fix(h, 0)
+			default:
+				heap.Pop(h)
+			}
+		}
+	}
+}
+
+// printPage lists one page of rows from schedds with list, merging them as described above, and writes them to w as a single
+// table with a column for the schedd.  filter.Sort and filter.Limit give the order and size of the page, and after, if not
+// blank, the cursor from the end of the previous one.  If there are more rows, the cursor for the next page is written last
+func printPage(w io.Writer, schedds []*condor.Schedd, filter db.Filter, after string, list func(*condor.Schedd, db.Filter) (*db.Listing, error)) error {
+	var cursor *pageCursor
+	if after != "" {
+		var err error
+		if cursor, err = decodeCursor(after, filter.Sort); err != nil {
+			return err
+		}
+	}
+
+	listings, err := queryListings(schedds, func(schedd *condor.Schedd) (*db.Listing, error) {
+		return list(schedd, pageFilter(filter, cursor, schedd.Name))
+	})
+	if err != nil {
+		return err
+	}
+
+	// As with db.Listing.Lines, the header is only written once there is a row, or it is clear that there are none, so that a
+	// schedd failing doesn't leave a header with no rows
+	header := strings.Join(append([]string{"schedd"}, listings[schedds[0].Name].Header...), "\t")
+	var last mergedRow
+	n := 0
+	for r, err := range mergeListings(listings, filter.Sort) {
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			fmt.Fprintln(w, header)
+		}
+		if filter.Limit > 0 && n == filter.Limit {
+			// There is another page, which starts after the last row we wrote
+			next, err := pageCursor{Sort: sortString(filter.Sort), Schedd: last.schedd, Key: last.row.Key}.encode()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\nMore rows follow.  For the next page, add --after %s\n", next)
+			return nil
+		}
+		fmt.Fprintln(w, strings.Join(append([]string{r.schedd}, r.row.Values...), "\t"))
+		last, n = r, n+1
+	}
+	if n == 0 {
+		fmt.Fprintln(w, header)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"testing"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/db"
+)
+
+func TestMergeListings(t *testing.T) {
+	sort := []db.SortKey{{Column: "num", Desc: true}}
+	row := func(num, clusterID int64) db.Row {
+		return db.Row{Values: []string{}, Key: []any{num, clusterID}}
+	}
+	rows := func(rows ...db.Row) iter.Seq2[db.Row, error] {
+		return func(yield func(db.Row, error) bool) {
+			for _, row := range rows {
+				if !yield(row, nil) {
+					return
+				}
+			}
+		}
+	}
+	listings := func() map[string]*db.Listing {
+		return map[string]*db.Listing{
+			"schedd1": {Rows: rows(row(5, 2), row(3, 1), row(1, 3))},
+			"schedd2": {Rows: rows(row(4, 1), row(3, 2))},
+			"schedd3": {Rows: rows()},
+		}
+	}
+
+	t.Run("merged in order", func(t *testing.T) {
+		var got []string
+		for r, err := range mergeListings(listings(), sort) {
+			if err != nil {
+				t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+			}
+			got = append(got, fmt.Sprintf("%s:%d", r.schedd, r.row.Key[1]))
+		}
+		expected := "schedd1:2 schedd2:1 schedd1:1 schedd2:2 schedd1:3"
+		if strings.Join(got, " ") != expected {
+			t.Errorf("Expected %s, got %s", expected, strings.Join(got, " "))
+		}
+	})
+
+	t.Run("error from a schedd", func(t *testing.T) {
+		l := listings()
+		l["schedd2"].Rows = func(yield func(db.Row, error) bool) {
+			if yield(row(4, 1), nil) {
+				yield(db.Row{}, errors.New("database is locked"))
+			}
+		}
+		var err error
+		for _, err = range mergeListings(l, sort) {
+			if err != nil {
+				break
+			}
+		}
+		if err == nil || err.Error() != "schedd2: database is locked" {
+			t.Errorf("Should have gotten schedd2's error.  Got %v instead", err)
+		}
+	})
+}
+
+func TestPrintPage(t *testing.T) {
+	schedds := []*condor.Schedd{{Name: "schedd1"}, {Name: "schedd2"}}
+	row := func(clusterID int64) db.Row {
+		return db.Row{Values: []string{fmt.Sprint(clusterID)}, Key: []any{clusterID}}
+	}
+	sort := []db.SortKey{{Column: "clusterid"}}
+
+	tests := []struct {
+		name     string
+		rows     map[string][]db.Row
+		failing  string // The schedd whose listing fails after its rows
+		expected string
+	}{
+		{
+			name:     "rows",
+			rows:     map[string][]db.Row{"schedd1": {row(1), row(3)}, "schedd2": {row(2)}},
+			expected: "schedd\tclusterid\nschedd1\t1\nschedd2\t2\nschedd1\t3\n",
+		},
+		{
+			name:     "no rows",
+			expected: "schedd\tclusterid\n",
+		},
+		{
+			name:     "schedd fails before any rows",
+			rows:     map[string][]db.Row{"schedd1": {row(1)}},
+			failing:  "schedd2",
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := func(schedd *condor.Schedd, _ db.Filter) (*db.Listing, error) {
+				rows := func(yield func(db.Row, error) bool) {
+					for _, r := range test.rows[schedd.Name] {
+						if !yield(r, nil) {
+							return
+						}
+					}
+					if schedd.Name == test.failing {
+						yield(db.Row{}, errors.New("database is locked"))
+					}
+				}
+				return &db.Listing{Header: []string{"clusterid"}, Rows: rows}, nil
+			}
+
+			var b bytes.Buffer
+			err := printPage(&b, schedds, db.Filter{Sort: sort}, "", list)
+			if test.failing != "" {
+				if err == nil || !strings.Contains(err.Error(), "database is locked") {
+					t.Errorf("Should have gotten the failing schedd's error.  Got %v instead", err)
+				}
+			} else if err != nil {
+				t.Errorf("Should have gotten nil error.  Got %v instead", err)
+			}
+			if b.String() != test.expected {
+				t.Errorf("Expected %q.  Got %q instead", test.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestPageCursor(t *testing.T) {
+	sort := []db.SortKey{{Column: "num", Desc: true}}
+	c := pageCursor{Sort: "-num", Schedd: "schedd2", Key: []any{int64(3), int64(7)}}
+	s, err := c.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		decoded, err := decodeCursor(s, sort)
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if decoded.Schedd != "schedd2" || decoded.Key[0] != int64(3) || decoded.Key[1] != int64(7) {
+			t.Errorf("Expected %+v, got %+v", c, decoded)
+		}
+
+		// The schedds on either side of the cursor's continue from the sort values, including ties only after it
+		if f := decoded.filter(db.Filter{Sort: sort}, "schedd1"); len(f.After) != 1 || f.AfterInclusive {
+			t.Errorf("schedd1 should list rows with sort values after the cursor's.  Got %+v", f)
+		}
+		if f := decoded.filter(db.Filter{Sort: sort}, "schedd2"); len(f.After) != 2 || f.AfterInclusive {
+			t.Errorf("schedd2 should list rows after the cursor's.  Got %+v", f)
+		}
+		if f := decoded.filter(db.Filter{Sort: sort}, "schedd3"); len(f.After) != 1 || !f.AfterInclusive {
+			t.Errorf("schedd3 should list rows with the cursor's sort values or later.  Got %+v", f)
+		}
+	})
+
+	t.Run("different sort", func(t *testing.T) {
+		if _, err := decodeCursor(s, nil); err == nil || !strings.Contains(err.Error(), "is for --sort") {
+			t.Errorf("Should have gotten error indicating the cursor is for another sort.  Got %v instead", err)
+		}
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		if _, err := decodeCursor("not a cursor", sort); err == nil {
+			t.Error("Should have gotten an error for an invalid cursor")
+		}
+	})
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"math/rand"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/swf"
+)
+
+// runReplay runs the replay subcommand, which submits the jobs of a workload trace in the Standard Workload Format to the
+// schedds with the trace's timing, sped up, and runs negotiation cycles so that they run and finish in the simulated pool.  args
+// are the arguments after "replay"
+func runReplay(cfg *config.Config, args []string) error {
+	replayCmd := flag.NewFlagSet("replay", flag.ContinueOnError)
+	replaySpeedup := replayCmd.String("speedup", "1x", "How many times faster than the trace to replay it, like 100x.  Runtimes are scaled too")
+	replayGroups := replayCmd.String("groups", "", "Comma-separated list of groups to submit the trace's jobs for.  The trace's group IDs are mapped onto them in order.  If blank, all configured groups are used")
+	replayCycleInterval := replayCmd.Duration("cycle-interval", time.Second, "Time to wait between negotiation cycles")
+	replayNegotiate := replayCmd.Bool("negotiate", true, "Run negotiation cycles, and wait for the replayed jobs to finish.  Turn off if fakeJobsub negotiate is running elsewhere")
+	replayMaxJobs := replayCmd.Int("max-jobs", 0, "Replay at most this many jobs from the start of the trace.  If 0, all of them are replayed")
+	replaySeed := replayCmd.Int64("seed", 0, "Seed for picking the schedd of each job.  If 0, a random seed is used")
+	replayMetricsListen := replayCmd.String("metrics-listen", "", "If set, serve metrics at this address, like localhost:9118, at /metrics while running")
+	replayCmd.Usage = func() {
+		fmt.Fprintln(replayCmd.Output(), "Usage: fakeJobsub replay [flags] trace.swf")
+		replayCmd.PrintDefaults()
+	}
+
+	if err := replayCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	if replayCmd.NArg() != 1 {
+		replayCmd.Usage()
+		return errors.New("replay takes exactly one SWF trace file")
+	}
+	speedup, err := parseSpeedup(*replaySpeedup)
+	if err != nil {
+		return err
+	}
+	if *replayCycleInterval <= 0 {
+		return errors.New("--cycle-interval must be positive")
+	}
+	if *replayMaxJobs < 0 {
+		return errors.New("--max-jobs must not be negative")
+	}
+	seed := *replaySeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	trace, err := swf.ParseFile(replayCmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *replayMaxJobs > 0 && len(trace) > *replayMaxJobs {
+		trace = trace[:*replayMaxJobs]
+	}
+
+	groups := make([]string, 0)
+	for _, group := range cfg.Groups {
+		groups = append(groups, group.Name)
+	}
+	if *replayGroups != "" {
+		groups = strings.Split(*replayGroups, ",")
+	}
+	r, err := newReplayer(cfg, groups, speedup, seed)
+	if err != nil {
+		return err
+	}
+	if *replayNegotiate {
+		n, notifier, err := newNegotiator(cfg)
+		if err != nil {
+			return err
+		}
+		defer waitForNotifications(notifier)
+		r.negotiator = n
+	}
+
+	events, skipped := r.plan(trace)
+	fmt.Printf("Replaying %d of the %d jobs in %s at %sx on groups %v (seed %d).  Press Ctrl-C to stop early\n",
+		len(trace)-skipped.total(), len(trace), replayCmd.Arg(0), strconv.FormatFloat(speedup, 'f', -1, 64), r.groups, seed)
+	skipped.print()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *replayMetricsListen != "" {
+		shutdown, err := serveMetrics(cfg, *replayMetricsListen)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+	return r.run(ctx, events, *replayCycleInterval)
+}
+
+// parseSpeedup parses a speedup like 100x or 100
+func parseSpeedup(s string) (float64, error) {
+	speedup, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speedup <= 0 || speedup > 1e9 {
+		return 0, fmt.Errorf("invalid --speedup %q:  must be a positive number, like 100x", s)
+	}
+	return speedup, nil
+}
+
+// replayer replays a trace on the schedds
+type replayer struct {
+	cfg        *config.Config
+	groups     []string
+	schedds    map[string][]*condor.Schedd // The schedds each group may submit to
+	byName     map[string]*condor.Schedd
+	pool       *condor.Pool
+	speedup    float64
+	rng        *rand.Rand
+	negotiator *condor.Negotiator // If nil, the replayer only submits and removes jobs
+}
+
+// newReplayer returns a replayer that submits jobs for groups, speedup times faster than the trace, picking schedds with seed.
+// The trace's timing takes the place of the schedds' simulated latency, so they have none
+func newReplayer(cfg *config.Config, groups []string, speedup float64, seed int64) (*replayer, error) {
+	r := &replayer{
+		cfg:     cfg,
+		schedds: make(map[string][]*condor.Schedd, len(groups)),
+		byName:  make(map[string]*condor.Schedd),
+		pool:    condor.NewPool(cfg.Pool),
+		speedup: speedup,
+		rng:     rand.New(rand.NewSource(seed)),
+	}
+	for _, name := range groups {
+		group, err := cfg.Group(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := r.schedds[group.Name]; ok {
+			continue
+		}
+		for _, scheddName := range group.Schedds(cfg) {
+			schedd, ok := r.byName[scheddName]
+			if !ok {
+				if schedd, err = condor.GetSchedd(scheddName); err != nil {
+					return nil, fmt.Errorf("could not get schedd: %w", err)
+				}
+				schedd.Latency = condor.Latency{}
+				r.byName[scheddName] = schedd
+			}
+			r.schedds[group.Name] = append(r.schedds[group.Name], schedd)
+		}
+		r.groups = append(r.groups, group.Name)
+	}
+	if len(r.groups) == 0 {
+		return nil, errors.New("no groups to replay the trace's jobs for")
+	}
+	return r, nil
+}
+
+// replayJob is a job from the trace, as it is submitted
+type replayJob struct {
+	trace  swf.Job
+	job    db.Job
+	schedd *condor.Schedd
+	id     condor.JobID // Set once the job is submitted
+}
+
+// replayEvent is the submission or removal of a job, at offset from the start of the replay
+type replayEvent struct {
+	offset time.Duration
+	remove bool
+	job    *replayJob
+}
+
+// scale returns the time that d in the trace takes in the replay
+func (r *replayer) scale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / r.speedup)
+}
+
+// convert returns the job that replays j from the trace, submitted to one of the schedds its group may use.  If j can't be
+// replayed, the reason why is returned as an error
+func (r *replayer) convert(j swf.Job) (*replayJob, error) {
+	// The trace's IDs are arbitrary, so groups are handed out in order of group ID, falling back to user ID
+	groupID := j.Group
+	if groupID < 0 {
+		groupID = j.User
+	}
+	group, err := r.cfg.Group(r.groups[max(groupID, 0)%len(r.groups)])
+	if err != nil {
+		return nil, err
+	}
+
+	// Cancelled jobs only ran until they were cancelled, so they are given the time they asked for, and removed in time
+	run := j.Run
+	if run < 0 || (j.Status == swf.StatusCancelled && j.RequestedTime > 0) {
+		run = j.RequestedTime
+	}
+	if run < 0 {
+		if j.Status != swf.StatusCancelled {
+			return nil, errors.New("unknown runtime")
+		}
+		run = 0
+	}
+
+	// The simulated pool keeps runtimes in whole seconds, so the replayed runtime is rounded up to one
+	runtime := max(r.scale(run).Round(time.Second), time.Second)
+	job, err := newJob(r.cfg, group, group.DefaultRole(), jobSpec{num: 1, runtime: runtime}, "")
+	if err != nil {
+		return nil, err
+	}
+	job.Owner = "user" + strconv.Itoa(max(j.User, 0))
+	job.CPUs = j.Procs()
+	if kb := j.MemoryKB(); kb > 0 {
+		job.MemoryMB = (kb*job.CPUs + 1023) / 1024
+	}
+	if j.RequestedTime > 0 {
+		job.Lifetime = max(r.scale(j.RequestedTime).Round(time.Second), runtime)
+	}
+	if j.Status == swf.StatusFailed {
+		job.SimExitCodes = []int{1}
+	}
+
+	accepting, err := scheddsAccepting(r.cfg, group.Schedds(r.cfg), "", jobResources(job))
+	if err != nil {
+		return nil, errors.New("too big for any schedd")
+	}
+	if !slices.ContainsFunc(r.pool.Slots, func(s condor.Slot) bool { return s.Matches(job) }) {
+		return nil, errors.New("no slot in the pool can run it")
+	}
+	return &replayJob{trace: j, job: job, schedd: r.byName[accepting[r.rng.Intn(len(accepting))]]}, nil
+}
+
+// skipReasons counts the jobs of a trace that can't be replayed by why
+type skipReasons map[string]int
+
+func (s skipReasons) total() int {
+	total := 0
+	for _, n := range s {
+		total += n
+	}
+	return total
+}
+
+func (s skipReasons) print() {
+	for _, reason := range slices.Sorted(maps.Keys(s)) {
+		fmt.Printf("Skipping %d job(s):  %s\n", s[reason], reason)
+	}
+}
+
+// plan returns the events that replay trace, in the order they happen, and the jobs that can't be replayed.  Each job is
+// submitted when it was in the trace, relative to the first.  The trace's cancelled jobs are removed by their owners when they
+// were cancelled, if they haven't finished by then.  Other jobs finish by themselves, when they have run as long as they did
+// in the trace
+func (r *replayer) plan(trace []swf.Job) ([]replayEvent, skipReasons) {
+	events := make([]replayEvent, 0, len(trace))
+	skipped := make(skipReasons)
+	if len(trace) == 0 {
+		return events, skipped
+	}
+	first := slices.MinFunc(trace, func(a, b swf.Job) int { return cmp.Compare(a.Submit, b.Submit) }).Submit
+	for _, j := range trace {
+		job, err := r.convert(j)
+		if err != nil {
+			skipped[err.Error()]++
+			continue
+		}
+		submit := r.scale(j.Submit - first)
+		events = append(events, replayEvent{offset: submit, job: job})
+		if j.Status == swf.StatusCancelled {
+			events = append(events, replayEvent{offset: submit + r.scale(max(j.Wait, 0)+max(j.Run, 0)), remove: true, job: job})
+		}
+	}
+	slices.SortStableFunc(events, func(a, b replayEvent) int { return cmp.Compare(a.offset, b.offset) })
+	return events, skipped
+}
+
+// replayStats counts what a replay has done
+type replayStats struct {
+	submitted, removed, failed int
+	lastError                  error
+}
+
+// fire submits or removes the job of e
+func (r *replayer) fire(e replayEvent, stats *replayStats) {
+	j := e.job
+	if e.remove {
+		if j.id.ClusterID == 0 {
+			return // It was never submitted
+		}
+		n, err := j.schedd.Remove(condor.Requester{User: j.job.Owner}, j.id)
+		if err != nil {
+			stats.failed++
+			stats.lastError = err
+			return
+		}
+		stats.removed += n
+		return
+	}
+	queued, err := j.schedd.SubmitJob(j.job)
+	if err != nil {
+		stats.failed++
+		stats.lastError = err
+		return
+	}
+	j.id = condor.JobID{ClusterID: queued.ClusterID, ProcID: db.AllProcs, Schedd: j.schedd.Name}
+	stats.submitted++
+}
+
+// inQueue returns the jobs that are still idle or running.  Each schedd's clusters are counted in one query
+func inQueue(jobs []*replayJob) ([]*replayJob, error) {
+	byCluster := make(map[*condor.Schedd]map[string]db.Totals)
+	left := make([]*replayJob, 0, len(jobs))
+	for _, j := range jobs {
+		clusters, ok := byCluster[j.schedd]
+		if !ok {
+			totals, _, err := j.schedd.Totals(db.Filter{}, "clusterid")
+			if err != nil {
+				return nil, err
+			}
+			clusters = make(map[string]db.Totals, len(totals))
+			for _, t := range totals {
+				clusters[t.Key] = t
+			}
+			byCluster[j.schedd] = clusters
+		}
+		t := clusters[strconv.Itoa(j.id.ClusterID)]
+		if t.ByStatus[db.Idle]+t.ByStatus[db.Running] > 0 {
+			left = append(left, j)
+		}
+	}
+	return left, nil
+}
+
+// run fires events at their offsets from now, running a negotiation cycle every interval if the replayer has a negotiator, and
+// printing progress after each.  When all of the events have fired, it waits for the replayed jobs to leave the queue, if it is
+// negotiating.  It stops early when ctx is done
+func (r *replayer) run(ctx context.Context, events []replayEvent, interval time.Duration) error {
+	var stats replayStats
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	next := 0
+	var draining []*replayJob // Once every event has fired, the submitted jobs that may still be in the queue
+	for {
+		if next == len(events) && draining == nil {
+			if r.negotiator == nil {
+				break
+			}
+			draining = make([]*replayJob, 0)
+			for _, e := range events {
+				if !e.remove && e.job.id.ClusterID != 0 {
+					draining = append(draining, e.job)
+				}
+			}
+		}
+		if draining != nil && len(draining) == 0 {
+			break
+		}
+
+		var due <-chan time.Time
+		if next < len(events) {
+			due = time.After(time.Until(start.Add(events[next].offset)))
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("Interrupted")
+			return r.summarize(stats, len(events), time.Since(start))
+		case <-due:
+			for ; next < len(events) && events[next].offset <= time.Since(start); next++ {
+				r.fire(events[next], &stats)
+			}
+		case now := <-ticker.C:
+			var result condor.CycleResult
+			if r.negotiator != nil {
+				var err error
+				if result, err = r.negotiator.Cycle(now); err != nil {
+					return fmt.Errorf("negotiation cycle failed: %w", err)
+				}
+			}
+			if draining != nil {
+				var err error
+				if draining, err = inQueue(draining); err != nil {
+					return fmt.Errorf("could not check replayed jobs: %w", err)
+				}
+			}
+			traceTime := time.Duration(float64(now.Sub(start)) * r.speedup).Round(time.Second)
+			fmt.Printf("%s (trace +%s): %d job(s) submitted and %d removed so far, %d completed, %d matched, %d still idle\n",
+				now.Format(time.DateTime), traceTime, stats.submitted, stats.removed, result.Completed, result.Matched, result.Idle)
+		}
+	}
+	return r.summarize(stats, len(events), time.Since(start))
+}
+
+// summarize prints what a replay did in elapsed
+func (r *replayer) summarize(stats replayStats, events int, elapsed time.Duration) error {
+	fmt.Printf("Replay took %s (%s of trace time):  %d job(s) submitted, %d removed, %d failed\n", elapsed.Round(time.Millisecond),
+		time.Duration(float64(elapsed)*r.speedup).Round(time.Second), stats.submitted, stats.removed, stats.failed)
+	if stats.failed > 0 {
+		return fmt.Errorf("%d of %d replay event(s) failed, the last with: %w", stats.failed, events, stats.lastError)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/swf"
+)
+
+func TestParseSpeedup(t *testing.T) {
+	for s, expected := range map[string]float64{"100x": 100, "100": 100, "0.5x": 0.5} {
+		speedup, err := parseSpeedup(s)
+		if err != nil || speedup != expected {
+			t.Errorf("Expected speedup %v for %q.  Got %v, %v instead", expected, s, speedup, err)
+		}
+	}
+	for _, s := range []string{"", "x", "0x", "-2x", "fast"} {
+		if _, err := parseSpeedup(s); err == nil {
+			t.Errorf("Should have gotten an error for %q.  Got nil instead", s)
+		}
+	}
+}
+
+func TestReplayPlan(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	r, err := newReplayer(config.Default(), []string{"nova", "dune"}, 100, 1)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	trace := []swf.Job{
+		{Number: 1, Submit: 1000 * time.Second, Wait: 10 * time.Second, Run: time.Hour, RequestedProcs: 4, RequestedTime: 2 * time.Hour, RequestedMemoryKB: 1024 * 1024, Status: swf.StatusCompleted, User: 3, Group: 1},
+		{Number: 2, Submit: 1030 * time.Second, Wait: -1, Run: 30 * time.Second, AllocatedProcs: -1, RequestedProcs: -1, RequestedMemoryKB: -1, UsedMemoryKB: -1, RequestedTime: -1, Status: swf.StatusFailed, User: 5, Group: 2},
+		{Number: 3, Submit: 1100 * time.Second, Wait: 100 * time.Second, Run: 500 * time.Second, RequestedProcs: 1, RequestedTime: -1, Status: swf.StatusCancelled, User: 3, Group: -1},
+		{Number: 4, Submit: 1200 * time.Second, Run: -1, RequestedTime: -1, RequestedProcs: 1, Status: swf.StatusCompleted},
+		{Number: 5, Submit: 1300 * time.Second, Run: time.Minute, RequestedProcs: 64, RequestedTime: -1, Status: swf.StatusCompleted},
+		{Number: 6, Submit: 1400 * time.Second, Run: time.Minute, RequestedProcs: 12, RequestedTime: -1, Status: swf.StatusCompleted},
+	}
+	events, skipped := r.plan(trace)
+
+	t.Run("jobs that can't be replayed are skipped", func(t *testing.T) {
+		expected := skipReasons{"unknown runtime": 1, "too big for any schedd": 1, "no slot in the pool can run it": 1}
+		if len(skipped) != len(expected) || skipped.total() != 3 {
+			t.Fatalf("Expected skipped jobs %v.  Got %v instead", expected, skipped)
+		}
+		for reason, n := range expected {
+			if skipped[reason] != n {
+				t.Errorf("Expected %d job(s) skipped with %q.  Got %v instead", n, reason, skipped)
+			}
+		}
+	})
+
+	t.Run("events are scaled and in order", func(t *testing.T) {
+		type event struct {
+			offset time.Duration
+			remove bool
+			number int
+		}
+		got := make([]event, 0)
+		for _, e := range events {
+			got = append(got, event{e.offset, e.remove, e.job.trace.Number})
+		}
+		expected := []event{{0, false, 1}, {300 * time.Millisecond, false, 2}, {time.Second, false, 3}, {7 * time.Second, true, 3}}
+		if !slices.Equal(got, expected) {
+			t.Errorf("Expected events %v.  Got %v instead", expected, got)
+		}
+	})
+
+	t.Run("jobs are converted", func(t *testing.T) {
+		first, second := events[0].job.job, events[1].job.job
+		if first.Group != "dune" || first.Owner != "user3" || first.CPUs != 4 || first.MemoryMB != 4096 {
+			t.Errorf("Got wrong job for trace job 1: %+v", first)
+		}
+		if first.Runtime != 36*time.Second {
+			t.Errorf("Expected an hour to replay in 36s.  Got %s instead", first.Runtime)
+		}
+		if first.Lifetime != 72*time.Second {
+			t.Errorf("Expected the requested 2 hours to be scaled to a 72s lifetime.  Got %s instead", first.Lifetime)
+		}
+		if second.Group != "nova" || second.CPUs != 1 || second.MemoryMB != config.DefaultResources.MemoryMB || !slices.Equal(second.SimExitCodes, []int{1}) {
+			t.Errorf("Got wrong job for trace job 2: %+v", second)
+		}
+		if second.Runtime != time.Second {
+			t.Errorf("Expected runtime to be rounded up to 1s.  Got %s instead", second.Runtime)
+		}
+		if third := events[2].job.job; third.Group != "dune" {
+			t.Errorf("Expected trace job 3, which has no group, to go by its user ID.  Got group %s instead", third.Group)
+		}
+	})
+}
+
+func TestReplayRun(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	cfg := config.Default()
+	r, err := newReplayer(cfg, []string{"nova"}, 3600, 1)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	schedds, err := getSchedds(cfg.ScheddNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.negotiator = &condor.Negotiator{Pool: condor.NewPool(cfg.Pool), Schedds: schedds}
+
+	trace := []swf.Job{
+		{Number: 1, Submit: 0, Run: time.Hour, RequestedProcs: 1, RequestedTime: -1, Status: swf.StatusCompleted, User: 1},
+		{Number: 2, Submit: 360 * time.Second, Run: time.Hour, RequestedProcs: 1, RequestedTime: -1, Status: swf.StatusFailed, User: 2},
+		{Number: 3, Submit: 360 * time.Second, Wait: -1, Run: time.Hour, RequestedProcs: 1, RequestedTime: 10 * time.Hour, Status: swf.StatusCancelled, User: 1},
+	}
+	events, _ := r.plan(trace)
+	if err := r.run(context.Background(), events, 50*time.Millisecond); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	// The replay waits for its jobs to leave the queue.  The first two ran for a second each, and the third was removed before
+	// it could finish
+	expected := map[int]db.JobStatus{1: db.Completed, 2: db.Completed, 3: db.Removed}
+	for _, e := range events {
+		listing, err := e.job.schedd.History(db.Filter{ClusterID: e.job.id.ClusterID}, "status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, err := listing.Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := expected[e.job.trace.Number]
+		if len(lines) != 2 || lines[1] != want.String() {
+			t.Errorf("Expected trace job %d to be %s.  Got %v instead", e.job.trace.Number, want, lines)
+		}
+	}
+}
+
+func TestRunReplay(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	trace := filepath.Join(t.TempDir(), "trace.swf")
+	contents := "; Version: 2.2\n1 0 0 60 1 -1 -1 1 -1 -1 1 1 1 -1 1 -1 -1 -1\n"
+	if err := os.WriteFile(trace, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("replay a trace", func(t *testing.T) {
+		args := []string{"fakeJobsub", "replay", "--speedup", "60x", "--cycle-interval", "100ms", "--groups", "nova", trace}
+		if err := run(args); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+	})
+
+	t.Run("no trace", func(t *testing.T) {
+		args := []string{"fakeJobsub", "replay", "--speedup", "60x"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "exactly one SWF trace file") {
+			t.Errorf("Should have gotten error indicating a trace is required.  Got %v instead", err)
+		}
+	})
+
+	t.Run("missing trace", func(t *testing.T) {
+		args := []string{"fakeJobsub", "replay", filepath.Join(t.TempDir(), "missing.swf")}
+		if err := run(args); err == nil {
+			t.Error("Should have gotten an error for a missing trace.  Got nil instead")
+		}
+	})
+}
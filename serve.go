@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/metrics"
+)
+
+// runServe runs the serve subcommand, which runs the pool as a daemon:  it runs negotiation cycles until it is interrupted, and
+// serves metrics for Prometheus to scrape.  args are the arguments after "serve"
+func runServe(cfg *config.Config, args []string) error {
+	serveCmd := flag.NewFlagSet("serve", flag.ContinueOnError)
+	serveListen := serveCmd.String("listen", "localhost:9118", "Address to serve metrics on, at /metrics.  Use :9118 to serve them on every interface")
+	serveInterval := serveCmd.Duration("interval", 10*time.Second, "Time to wait between negotiation cycles")
+	serveNegotiate := serveCmd.Bool("negotiate", true, "Run negotiation cycles.  Turn off to only serve metrics, if fakeJobsub negotiate is running elsewhere")
+
+	if err := serveCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	if *serveInterval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdown, err := serveMetrics(cfg, *serveListen)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	if !*serveNegotiate {
+		<-ctx.Done()
+		return nil
+	}
+	n, notifier, err := newNegotiator(cfg)
+	if err != nil {
+		return err
+	}
+	defer waitForNotifications(notifier)
+	ticker := time.NewTicker(*serveInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		result, err := n.Cycle(now)
+		if err != nil {
+			return fmt.Errorf("negotiation cycle failed: %w", err)
+		}
+		printCycle(now, result)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveMetrics serves the metrics of the configured schedds in the background at http://addr/metrics:  the number of jobs on each
+// by group and status, and the metrics of the schedd operations this process performs.  The returned function stops serving
+func serveMetrics(cfg *config.Config, addr string) (func(), error) {
+	handler, err := metricsHandler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	// Listen first, so that an address that is in use is reported as an error rather than in the background
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not serve metrics: %w", err)
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(l)
+	fmt.Printf("Serving metrics at http://%s/metrics\n", l.Addr())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// metricsHandler returns the handler for /metrics.  The schedds are opened separately from any that the caller uses, without
+// simulated latency, so that scrapes aren't slowed down or counted in the schedd metrics
+func metricsHandler(cfg *config.Config) (http.Handler, error) {
+	schedds, err := getSchedds(cfg.ScheddNames())
+	if err != nil {
+		return nil, err
+	}
+	for _, schedd := range schedds {
+		schedd.Latency = condor.Latency{}
+	}
+
+	r := metrics.NewRegistry()
+	r.GaugeFunc("fakejobsub_jobs", "Jobs on each schedd, by group and status.  Completed and removed jobs are counted until they are cleaned up.",
+		[]string{"schedd", "group", "status"}, func() ([]metrics.Sample, error) {
+			samples := make([]metrics.Sample, 0)
+			for _, schedd := range schedds {
+				totals, _, err := schedd.Totals(db.Filter{}, "group")
+				if err != nil {
+					return nil, err
+				}
+				for _, t := range totals {
+					for _, status := range totalsStatuses {
+						samples = append(samples, metrics.Sample{
+							Values: []string{schedd.Name, t.Key, strings.ToLower(status.String())},
+							Value:  float64(t.ByStatus[status]),
+						})
+					}
+				}
+			}
+			return samples, nil
+		})
+	return metrics.Handler(condor.Metrics, r), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	cfg := config.Default()
+	handler, err := metricsHandler(cfg)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	schedd, err := condor.GetSchedd("schedd2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedd.Latency = condor.Latency{}
+	job, err := schedd.SubmitJob(db.Job{Group: "uboone", Num: 4, Role: config.RoleAnalysis, Owner: "alice", Runtime: time.Minute})
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if _, err := schedd.Hold(condor.Requester{User: "alice"}, condor.JobID{ClusterID: job.ClusterID, ProcID: 0, Schedd: "schedd2"}); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200.  Got %d:\n%s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{
+		"# TYPE fakejobsub_jobs gauge\n",
+		`fakejobsub_jobs{schedd="schedd2",group="uboone",status="idle"} 3`,
+		`fakejobsub_jobs{schedd="schedd2",group="uboone",status="held"} 1`,
+		`fakejobsub_jobs{schedd="schedd2",group="uboone",status="running"} 0`,
+		`fakejobsub_submitted_jobs_total{schedd="schedd2",group="uboone"} `,
+		`fakejobsub_schedd_submit_duration_seconds_bucket{schedd="schedd2",le="+Inf"} `,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expected metrics to contain %q.  Got:\n%s", want, rec.Body.String())
+		}
+	}
+	if strings.Contains(rec.Body.String(), `fakejobsub_jobs{schedd="schedd1"`) {
+		t.Errorf("Expected no jobs on schedd1.  Got:\n%s", rec.Body.String())
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	cfg := config.Default()
+
+	t.Run("serve", func(t *testing.T) {
+		shutdown, err := serveMetrics(cfg, "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+		}
+		shutdown()
+	})
+
+	t.Run("address in use", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		if _, err := serveMetrics(cfg, l.Addr().String()); err == nil || !strings.Contains(err.Error(), "could not serve metrics") {
+			t.Errorf("Should have gotten error indicating metrics could not be served.  Got %v instead", err)
+		}
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		args := []string{"fakeJobsub", "serve", "--interval", "0s"}
+		if err := run(args); err == nil || !strings.Contains(err.Error(), "--interval must be positive") {
+			t.Errorf("Should have gotten error indicating --interval must be positive.  Got %v instead", err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/dag"
+	"fakeJobsub/db"
+	"fakeJobsub/submitfile"
+	"fakeJobsub/token"
+)
+
+// runSubmitDAG runs the submit-dag subcommand, which submits a DAG of clusters described by a DAGMan input file, like
+// condor_submit_dag.  args are the arguments after "submit-dag"
+func runSubmitDAG(cfg *config.Config, args []string) error {
+	submitDAGCmd := flag.NewFlagSet("submit-dag", flag.ContinueOnError)
+	submitDAGGroup := submitDAGCmd.String("group", "", "Group/Experiment")
+	submitDAGRole := submitDAGCmd.String("role", "", "Role to submit the DAG's jobs with (Analysis, Production).  If blank, the group's default role is used")
+	submitDAGSchedd := submitDAGCmd.String("schedd", "", "schedd to submit the DAG to.  If blank, one will be randomly chosen")
+
+	// The DAG file may be given before or after the flags
+	var dagFile string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		dagFile, args = args[0], args[1:]
+	}
+	if err := submitDAGCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	switch {
+	case dagFile == "" && submitDAGCmd.NArg() == 1:
+		dagFile = submitDAGCmd.Arg(0)
+	case submitDAGCmd.NArg() > 0:
+		return fmt.Errorf("unexpected arguments %v", submitDAGCmd.Args())
+	case dagFile == "":
+		return errors.New("must give a DAG file, like fakeJobsub submit-dag --group mygroup workflow.dag")
+	}
+
+	if err := checkSubmitForGroup(*submitDAGGroup); err != nil {
+		return errors.New("--group must be specified")
+	}
+	group, err := cfg.Group(*submitDAGGroup)
+	if err != nil {
+		return err
+	}
+	role := group.DefaultRole()
+	if *submitDAGRole != "" {
+		if role, err = group.CanonicalRole(*submitDAGRole); err != nil {
+			return err
+		}
+	}
+
+	// Rescue DAGs are written next to the DAG file by the negotiator, which doesn't run in this directory
+	if dagFile, err = filepath.Abs(dagFile); err != nil {
+		return fmt.Errorf("invalid DAG file: %w", err)
+	}
+	d, err := dag.ParseFile(dagFile)
+	if err != nil {
+		return err
+	}
+	// As in DAGMan, the newest rescue DAG says which nodes already finished
+	rescue, err := dag.LatestRescue(dagFile)
+	if err != nil {
+		return fmt.Errorf("could not look for rescue DAGs: %w", err)
+	}
+	if rescue > 0 {
+		if err := d.ApplyRescue(dag.RescueFile(dagFile, rescue)); err != nil {
+			return err
+		}
+		fmt.Printf("Running rescue DAG %d\n", rescue)
+	}
+
+	// Every node runs on the DAG's schedd, so it must accept all of them
+	accepting := group.Schedds(cfg)
+	nodes := make([]db.DAGNode, 0, len(d.Nodes))
+	for _, n := range d.Nodes {
+		job, err := nodeJob(cfg, group, role, filepath.Dir(dagFile), n)
+		if err != nil {
+			return fmt.Errorf("node %s: %w", n.Name, err)
+		}
+		if accepting, err = scheddsAccepting(cfg, accepting, *submitDAGSchedd, jobResources(job)); err != nil {
+			return fmt.Errorf("node %s: %w", n.Name, err)
+		}
+		node := db.DAGNode{
+			Name:       n.Name,
+			Retries:    n.Retries,
+			UnlessExit: n.UnlessExit,
+			Priority:   n.Priority,
+			Parents:    n.Parents,
+			Job:        job,
+		}
+		if n.Done {
+			node.Status = db.NodeDone
+		}
+		nodes = append(nodes, node)
+	}
+	scheddName, err := chooseSchedd(*submitDAGSchedd, cfg.ScheddNames(), accepting)
+	if err != nil {
+		return fmt.Errorf("could not choose schedd for group %s: %w", group.Name, err)
+	}
+
+	// The bearer token must authorize this submission.  Its subject owns the DAG and its jobs
+	claims, err := verifyToken(cfg, group.Name, role == config.RoleProduction, token.ScopeCreate)
+	if err != nil {
+		return fmt.Errorf("not authorized to submit: %w", err)
+	}
+	owner := claims.Subject
+	if owner == "" {
+		if owner, err = currentUsername(); err != nil {
+			return err
+		}
+	}
+	for idx := range nodes {
+		nodes[idx].Job.Owner = owner
+	}
+
+	schedd, err := condor.GetSchedd(scheddName)
+	if err != nil {
+		return fmt.Errorf("could not get schedd: %w", err)
+	}
+	cid, err := schedd.SubmitDAG(db.DAG{File: dagFile, Group: group.Name, Owner: owner}, nodes, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Submitted DAG %d with %d node(s) for group %s (role %s) on schedd %s\n", cid, len(nodes), group.Name, role, scheddName)
+	return nil
+}
+
+// nodeJob returns the cluster described by the DAG node's submit file.  The node's VARS are substituted into the submit
+// file's values.  Relative paths are relative to the node's directory, which is relative to dagDir
+func nodeJob(cfg *config.Config, group *config.GroupConfig, role, dagDir string, n *dag.Node) (db.Job, error) {
+	dir := n.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(dagDir, dir)
+	}
+	submitFile := n.SubmitFile
+	if !filepath.IsAbs(submitFile) {
+		submitFile = filepath.Join(dir, submitFile)
+	}
+	sf, err := submitfile.ParseFile(submitFile)
+	if err != nil {
+		return db.Job{}, err
+	}
+
+	spec := jobSpec{num: sf.Queue, runtime: time.Minute}
+	for name, command := range submitFileFlags {
+		if value, ok := sf.Get(command); ok {
+			if err := spec.set(name, n.Expand(value)); err != nil {
+				return db.Job{}, fmt.Errorf("invalid %s in submit file %s: %w", command, submitFile, err)
+			}
+		}
+	}
+	return newJob(cfg, group, role, spec, dir)
+}
@@ -0,0 +1,102 @@
+// Package submitfile parses the subset of HTCondor submit description files that fakeJobsub understands:  "key = value"
+// commands, custom "+Attribute = value" attributes, comments, line continuations, and a final "queue" statement
+package submitfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// File is a parsed submit description file
+type File struct {
+	commands map[string]string // Keys are lowercased, as submit commands are case-insensitive
+	Queue    int               // How many jobs the queue statement asks for
+}
+
+// Get returns the value of the submit command key (which is case-insensitive), and whether it was set.  Custom attributes
+// are looked up with their leading "+", like Get("+DESIRED_Sites").  Quotes around values are removed
+func (f *File) Get(key string) (string, bool) {
+	v, ok := f.commands[strings.ToLower(key)]
+	return v, ok
+}
+
+// Parse parses a submit description file from r
+func Parse(r io.Reader) (*File, error) {
+	f := &File{commands: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	queued := false
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		// Join continued lines
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			lineNum++
+			line = strings.TrimSuffix(line, "\\") + " " + strings.TrimSpace(scanner.Text())
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if queued {
+			return nil, fmt.Errorf("line %d: nothing may follow the queue statement", lineNum)
+		}
+
+		if fields := strings.Fields(line); strings.EqualFold(fields[0], "queue") {
+			switch len(fields) {
+			case 1:
+				f.Queue = 1
+			case 2:
+				n, err := strconv.Atoi(fields[1])
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("line %d: invalid queue count %q", lineNum, fields[1])
+				}
+				f.Queue = n
+			default:
+				return nil, fmt.Errorf("line %d: only \"queue\" and \"queue N\" are supported", lineNum)
+			}
+			queued = true
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key = value\", got %q", lineNum, line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" || key == "+" {
+			return nil, fmt.Errorf("line %d: missing key", lineNum)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		f.commands[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !queued {
+		return nil, errors.New("no queue statement")
+	}
+	return f, nil
+}
+
+// ParseFile parses the submit description file at filename
+func ParseFile(filename string) (*File, error) {
+	r, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse submit file %s: %w", filename, err)
+	}
+	return f, nil
+}
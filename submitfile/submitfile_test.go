@@ -0,0 +1,63 @@
+package submitfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	contents := `# A typical submit file
+executable = /bin/true
+Log        = job_$(Cluster).log
+request_memory = 4GB
+request_disk = \
+    10GB
++DESIRED_Sites = "FermiGrid,Nebraska"
+queue 5
+`
+	f, err := Parse(strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if f.Queue != 5 {
+		t.Errorf("Expected queue 5, got %d", f.Queue)
+	}
+
+	expected := map[string]string{
+		"log":            "job_$(Cluster).log",
+		"REQUEST_MEMORY": "4GB",
+		"request_disk":   "10GB",
+		"+desired_sites": "FermiGrid,Nebraska",
+	}
+	for key, value := range expected {
+		if v, ok := f.Get(key); !ok || v != value {
+			t.Errorf("Expected %s = %q.  Got %q, %t", key, value, v, ok)
+		}
+	}
+	if _, ok := f.Get("request_cpus"); ok {
+		t.Error("request_cpus should not be set")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"no queue", "executable = /bin/true\n", "no queue statement"},
+		{"bad queue", "queue lots\n", "invalid queue count"},
+		{"queue from", "queue name from names.txt\n", "only \"queue\" and \"queue N\""},
+		{"after queue", "queue\nexecutable = /bin/true\n", "nothing may follow"},
+		{"no equals", "executable /bin/true\nqueue\n", "expected \"key = value\""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(test.contents))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+}
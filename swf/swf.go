@@ -0,0 +1,133 @@
+// Package swf parses workload traces in the Standard Workload Format (SWF) of the Parallel Workloads Archive.  Each line of a
+// trace is a job, given by 18 whitespace-separated fields:
+//
+//	number submit wait run allocated-procs avg-cpu used-memory requested-procs requested-time requested-memory
+//	status user group executable queue partition preceding-job think-time
+//
+// Times are in seconds, the submit time since the start of the trace, and memory is in KB per processor.  -1 means a value
+// is unknown.  Lines starting with ; are header comments, and are skipped
+package swf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The job statuses SWF records.  Traces may also use 2, 3, and 4 for the parts of jobs that were checkpointed or swapped out
+const (
+	StatusFailed    = 0
+	StatusCompleted = 1
+	StatusCancelled = 5
+)
+
+// Job is a job in a trace.  Times, memory, and IDs are negative if they are unknown
+type Job struct {
+	Number            int
+	Submit            time.Duration // Since the start of the trace
+	Wait              time.Duration // From submission until the job started
+	Run               time.Duration
+	AllocatedProcs    int
+	UsedMemoryKB      int // Per processor
+	RequestedProcs    int
+	RequestedTime     time.Duration
+	RequestedMemoryKB int // Per processor
+	Status            int
+	User              int
+	Group             int
+}
+
+// fields is the number of fields on each line
+const fields = 18
+
+// Parse parses the trace from r
+func Parse(r io.Reader) ([]Job, error) {
+	jobs := make([]Job, 0)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		job, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ParseFile parses the trace at filename
+func ParseFile(filename string) ([]Job, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	jobs, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse SWF trace %s: %w", filename, err)
+	}
+	return jobs, nil
+}
+
+func parseLine(line string) (Job, error) {
+	f := strings.Fields(line)
+	if len(f) != fields {
+		return Job{}, fmt.Errorf("expected %d fields, got %d", fields, len(f))
+	}
+
+	// Some traces record times and memory with fractions, so every field is parsed as a number and rounded
+	values := make([]int, fields)
+	for i, s := range f {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Job{}, fmt.Errorf("field %d: invalid number %q", i+1, s)
+		}
+		values[i] = int(math.Round(v))
+	}
+	seconds := func(v int) time.Duration { return time.Duration(v) * time.Second }
+	return Job{
+		Number:            values[0],
+		Submit:            seconds(values[1]),
+		Wait:              seconds(values[2]),
+		Run:               seconds(values[3]),
+		AllocatedProcs:    values[4],
+		UsedMemoryKB:      values[6],
+		RequestedProcs:    values[7],
+		RequestedTime:     seconds(values[8]),
+		RequestedMemoryKB: values[9],
+		Status:            values[10],
+		User:              values[11],
+		Group:             values[12],
+	}, nil
+}
+
+// Procs returns the number of processors the job requested, or if that is unknown, the number it was allocated.  It is at
+// least 1
+func (j Job) Procs() int {
+	if j.RequestedProcs > 0 {
+		return j.RequestedProcs
+	}
+	return max(j.AllocatedProcs, 1)
+}
+
+// MemoryKB returns the memory the job requested per processor, or if that is unknown, the memory it used.  It is not positive if
+// both are unknown
+func (j Job) MemoryKB() int {
+	if j.RequestedMemoryKB > 0 {
+		return j.RequestedMemoryKB
+	}
+	return j.UsedMemoryKB
+}
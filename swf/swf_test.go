@@ -0,0 +1,73 @@
+package swf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	contents := `; Version: 2.2
+; Computer: Example cluster
+; MaxProcs: 128
+
+    1        0   10   3600    4  -1  2048    4   7200  4096  1  3  1  -1  1  -1  -1  -1
+    2       30    0     60.4  1  -1    -1   -1     -1    -1  0  5  2  -1  1  -1  -1  -1
+    3       45   -1     -1    -1 -1    -1    2    600    -1  5  3  1  -1  1  -1  -1  -1
+`
+	jobs, err := Parse(strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("Expected 3 jobs.  Got %d instead", len(jobs))
+	}
+
+	expected := Job{
+		Number: 1, Submit: 0, Wait: 10 * time.Second, Run: time.Hour, AllocatedProcs: 4, UsedMemoryKB: 2048, RequestedProcs: 4,
+		RequestedTime: 2 * time.Hour, RequestedMemoryKB: 4096, Status: StatusCompleted, User: 3, Group: 1,
+	}
+	if jobs[0] != expected {
+		t.Errorf("Expected job %+v.  Got %+v instead", expected, jobs[0])
+	}
+	if jobs[1].Run != time.Minute || jobs[1].Status != StatusFailed || jobs[1].User != 5 {
+		t.Errorf("Got wrong job 2: %+v", jobs[1])
+	}
+	if jobs[2].Run >= 0 || jobs[2].Status != StatusCancelled {
+		t.Errorf("Expected job 3 to be cancelled with an unknown runtime.  Got %+v instead", jobs[2])
+	}
+
+	t.Run("processors and memory fall back to what was used", func(t *testing.T) {
+		if n := jobs[0].Procs(); n != 4 {
+			t.Errorf("Expected 4 processors.  Got %d instead", n)
+		}
+		if n := jobs[1].Procs(); n != 1 {
+			t.Errorf("Expected 1 processor.  Got %d instead", n)
+		}
+		if m := jobs[0].MemoryKB(); m != 4096 {
+			t.Errorf("Expected 4096 KB of memory.  Got %d instead", m)
+		}
+		if m := jobs[1].MemoryKB(); m > 0 {
+			t.Errorf("Expected unknown memory.  Got %d instead", m)
+		}
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"too few fields", "1 0 10 3600\n", "line 1: expected 18 fields, got 4"},
+		{"not a number", "; header\n1 0 10 3600 4 -1 2048 4 7200 4096 1 alice 1 -1 1 -1 -1 -1\n", `line 2: field 12: invalid number "alice"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(test.contents))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultKeyFile is where the signing key lives if the configuration does not say otherwise
+func DefaultKeyFile() string {
+	return filepath.Join(os.TempDir(), "fakeJobsub_signing_key.pem")
+}
+
+// LoadKey reads the Ed25519 signing key from the PEM file at filename
+func LoadKey(filename string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in signing key file %s", filename)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse signing key: %w", err)
+	}
+	key, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key in %s is not an Ed25519 key", filename)
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey reads the signing key from filename, generating and saving a new one if the file doesn't exist
+func LoadOrCreateKey(filename string) (ed25519.PrivateKey, error) {
+	key, err := LoadKey(filename)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	_, key, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate signing key: %w", err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal signing key: %w", err)
+	}
+	if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0o600); err != nil {
+		return nil, fmt.Errorf("could not save signing key: %w", err)
+	}
+	return key, nil
+}
+
+// DefaultPath is where a token is stored if $BEARER_TOKEN_FILE is not set, following the WLCG Bearer Token Discovery
+// specification:  $XDG_RUNTIME_DIR/bt_u<uid> if $XDG_RUNTIME_DIR is set, otherwise /tmp/bt_u<uid>
+func DefaultPath() string {
+	name := fmt.Sprintf("bt_u%d", os.Getuid())
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join("/tmp", name)
+}
+
+// Find locates the bearer token, following the WLCG Bearer Token Discovery specification:  $BEARER_TOKEN,
+// then the file at $BEARER_TOKEN_FILE, then the file at DefaultPath()
+func Find() (string, error) {
+	if t := strings.TrimSpace(os.Getenv("BEARER_TOKEN")); t != "" {
+		return t, nil
+	}
+
+	fn := os.Getenv("BEARER_TOKEN_FILE")
+	if fn == "" {
+		fn = DefaultPath()
+	}
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return "", fmt.Errorf("could not find bearer token: %w", err)
+	}
+	t := strings.TrimSpace(string(b))
+	if t == "" {
+		return "", fmt.Errorf("bearer token file %s is empty", fn)
+	}
+	return t, nil
+}
+
+// Save writes the token to filename, readable only by the user
+func Save(filename, raw string) error {
+	return os.WriteFile(filename, []byte(raw+"\n"), 0o600)
+}
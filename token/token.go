@@ -0,0 +1,201 @@
+// Package token issues and verifies JSON Web Tokens that look like the SciTokens/WLCG tokens that jobsub_lite uses.
+// Tokens are signed with a local Ed25519 key, so everything here works offline
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes used by fakeJobsub, from the WLCG token profile
+const (
+	ScopeCreate = "compute.create"
+	ScopeRead   = "compute.read"
+	ScopeModify = "compute.modify"
+	ScopeCancel = "compute.cancel"
+)
+
+// DefaultScopes are the scopes a token gets if none are requested
+var DefaultScopes = []string{ScopeCreate, ScopeRead, ScopeModify, ScopeCancel}
+
+const (
+	// DefaultIssuer is the issuer used if the configuration does not give one
+	DefaultIssuer = "https://fakejobsub.local"
+	// DefaultLifetime is how long issued tokens are valid for if not otherwise specified
+	DefaultLifetime = 3 * time.Hour
+
+	wlcgVersion  = "1.0"
+	anyAudience  = "https://wlcg.cern.ch/jwt/v1/any"
+	signingAlg   = "EdDSA"
+	allowedSkew  = 30 * time.Second
+	productionID = "production"
+)
+
+// Errors returned by Verify.  All of them wrap ErrInvalidToken
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrSignature    = fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	ErrExpired      = fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	ErrNotYetValid  = fmt.Errorf("%w: token is not yet valid", ErrInvalidToken)
+	ErrIssuer       = fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	ErrGroup        = fmt.Errorf("%w: group not authorized", ErrInvalidToken)
+	ErrScope        = fmt.Errorf("%w: missing scope", ErrInvalidToken)
+)
+
+// Claims are the claims in a token
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  string   `json:"aud,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf"`
+	Expiry    int64    `json:"exp"`
+	ID        string   `json:"jti,omitempty"`
+	Scope     string   `json:"scope"`       // Space-separated
+	Groups    []string `json:"wlcg.groups"` // Like "/nova" or "/nova/production"
+	Version   string   `json:"wlcg.ver"`
+}
+
+// NewClaims returns the claims for a token issued now by issuer to subject, for group, with the given scopes and lifetime.
+// If production is true, the token also authorizes the Production role for the group
+func NewClaims(issuer, subject, group string, production bool, scopes []string, lifetime time.Duration) *Claims {
+	now := time.Now()
+	groups := []string{"/" + group}
+	if production {
+		groups = append(groups, "/"+group+"/"+productionID)
+	}
+	return &Claims{
+		Issuer:    issuer,
+		Subject:   subject,
+		Audience:  anyAudience,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		Expiry:    now.Add(lifetime).Unix(),
+		ID:        fmt.Sprintf("%x", now.UnixNano()),
+		Scope:     strings.Join(scopes, " "),
+		Groups:    groups,
+		Version:   wlcgVersion,
+	}
+}
+
+// Scopes returns the token's scopes
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// ExpiresAt returns the expiry time of the token
+func (c *Claims) ExpiresAt() time.Time {
+	return time.Unix(c.Expiry, 0)
+}
+
+// Requirements are what a token must satisfy to be accepted by Verify
+type Requirements struct {
+	Issuer     string
+	Group      string
+	Production bool // Whether the token must authorize the Production role for Group
+	Scope      string
+	Now        time.Time // If zero, time.Now() is used
+}
+
+// Issue signs claims with key and returns the encoded token
+func Issue(key ed25519.PrivateKey, claims *Claims) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": signingAlg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(header) + "." + encode(payload)
+	sig := ed25519.Sign(key, []byte(signingInput))
+	return signingInput + "." + encode(sig), nil
+}
+
+// Parse checks the signature of raw against key and returns its claims.  It does not check any of the claims; use Verify for that
+func Parse(raw string, key ed25519.PublicKey) (*Claims, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: token should have 3 parts, has %d", ErrInvalidToken, len(parts))
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJSON(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: could not decode header: %s", ErrInvalidToken, err)
+	}
+	if header.Alg != signingAlg {
+		return nil, fmt.Errorf("%w: unsupported signing algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode signature: %s", ErrInvalidToken, err)
+	}
+	if !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrSignature
+	}
+
+	c := new(Claims)
+	if err := decodeJSON(parts[1], c); err != nil {
+		return nil, fmt.Errorf("%w: could not decode claims: %s", ErrInvalidToken, err)
+	}
+	return c, nil
+}
+
+// Verify parses raw, checking its signature against key, and then checks that its claims meet req
+func Verify(raw string, key ed25519.PublicKey, req Requirements) (*Claims, error) {
+	c, err := Parse(raw, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := req.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	switch {
+	case now.After(time.Unix(c.Expiry, 0).Add(allowedSkew)):
+		return nil, fmt.Errorf("%w (expired at %s)", ErrExpired, c.ExpiresAt().Format(time.RFC3339))
+	case now.Add(allowedSkew).Before(time.Unix(c.NotBefore, 0)):
+		return nil, ErrNotYetValid
+	case req.Issuer != "" && c.Issuer != req.Issuer:
+		return nil, fmt.Errorf("%w: got %s, expected %s", ErrIssuer, c.Issuer, req.Issuer)
+	}
+
+	if req.Group != "" {
+		if !slices.Contains(c.Groups, "/"+req.Group) {
+			return nil, fmt.Errorf("%w: token is not valid for group %s", ErrGroup, req.Group)
+		}
+		if req.Production && !slices.Contains(c.Groups, "/"+req.Group+"/"+productionID) {
+			return nil, fmt.Errorf("%w: token is not valid for the Production role of group %s", ErrGroup, req.Group)
+		}
+	}
+
+	if req.Scope != "" && !slices.Contains(c.Scopes(), req.Scope) {
+		return nil, fmt.Errorf("%w: token does not have scope %s", ErrScope, req.Scope)
+	}
+
+	return c, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,123 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) ed25519.PrivateKey {
+	key, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "key.pem"))
+	if err != nil {
+		t.Fatalf("Could not create test key: %s", err)
+	}
+	return key
+}
+
+func TestIssueAndParse(t *testing.T) {
+	key := testKey(t)
+	claims := NewClaims(DefaultIssuer, "testuser", "nova", true, DefaultScopes, time.Hour)
+	raw, err := Issue(key, claims)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	parsed, err := Parse(raw, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if parsed.Subject != "testuser" || !slices.Equal(parsed.Groups, []string{"/nova", "/nova/production"}) {
+		t.Errorf("Got wrong claims back: %+v", parsed)
+	}
+
+	// A token signed by some other key should be rejected
+	otherKey := testKey(t)
+	if _, err := Parse(raw, otherKey.Public().(ed25519.PublicKey)); !errors.Is(err, ErrSignature) {
+		t.Errorf("Should have gotten ErrSignature.  Got %v instead", err)
+	}
+
+	// So should a tampered token
+	parts := strings.Split(raw, ".")
+	tampered := parts[0] + "." + encode([]byte(`{"sub":"someoneelse"}`)) + "." + parts[2]
+	if _, err := Parse(tampered, key.Public().(ed25519.PublicKey)); !errors.Is(err, ErrSignature) {
+		t.Errorf("Should have gotten ErrSignature.  Got %v instead", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key := testKey(t)
+	pub := key.Public().(ed25519.PublicKey)
+	raw, err := Issue(key, NewClaims(DefaultIssuer, "testuser", "nova", false, []string{ScopeCreate}, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		req         Requirements
+		expectedErr error
+	}{
+		{"valid", Requirements{Issuer: DefaultIssuer, Group: "nova", Scope: ScopeCreate}, nil},
+		{"expired", Requirements{Now: time.Now().Add(2 * time.Hour)}, ErrExpired},
+		{"not yet valid", Requirements{Now: time.Now().Add(-time.Hour)}, ErrNotYetValid},
+		{"wrong issuer", Requirements{Issuer: "https://someone.else"}, ErrIssuer},
+		{"wrong group", Requirements{Group: "dune"}, ErrGroup},
+		{"no production role", Requirements{Group: "nova", Production: true}, ErrGroup},
+		{"missing scope", Requirements{Scope: ScopeCancel}, ErrScope},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := Verify(raw, pub, test.req)
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("Expected error %v, got %v", test.expectedErr, err)
+			}
+			if test.expectedErr != nil && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("All verification errors should wrap ErrInvalidToken.  Got %v", err)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "token")
+	if err := Save(fn, "abc.def.ghi"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("BEARER_TOKEN", func(t *testing.T) {
+		t.Setenv("BEARER_TOKEN", "xyz")
+		t.Setenv("BEARER_TOKEN_FILE", fn)
+		if tok, err := Find(); err != nil || tok != "xyz" {
+			t.Errorf("Should have gotten token from $BEARER_TOKEN.  Got %s, %v instead", tok, err)
+		}
+	})
+
+	t.Run("BEARER_TOKEN_FILE", func(t *testing.T) {
+		t.Setenv("BEARER_TOKEN", "")
+		t.Setenv("BEARER_TOKEN_FILE", fn)
+		if tok, err := Find(); err != nil || tok != "abc.def.ghi" {
+			t.Errorf("Should have gotten token from $BEARER_TOKEN_FILE.  Got %s, %v instead", tok, err)
+		}
+	})
+
+	t.Run("default location", func(t *testing.T) {
+		t.Setenv("BEARER_TOKEN", "")
+		t.Setenv("BEARER_TOKEN_FILE", "")
+		t.Setenv("XDG_RUNTIME_DIR", dir)
+		if _, err := Find(); err == nil {
+			t.Error("Should have gotten an error since there is no token in the default location")
+		}
+		if err := Save(DefaultPath(), "default.token.here"); err != nil {
+			t.Fatal(err)
+		}
+		if tok, err := Find(); err != nil || tok != "default.token.here" {
+			t.Errorf("Should have gotten token from default location.  Got %s, %v instead", tok, err)
+		}
+	})
+}
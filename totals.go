@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/db"
+)
+
+// groupBySchedd is the --group-by value that totals each schedd separately
+const groupBySchedd = "schedd"
+
+// totalsStatuses are the statuses in the order condor_q summarizes them
+var totalsStatuses = []db.JobStatus{db.Completed, db.Removed, db.Idle, db.Running, db.Held}
+
+// printTotals writes the totals of the jobs on schedds that match filter to w.  If groupBy is blank, it writes a summary line
+// for each schedd (and one for all of them), like condor_q -totals.  Otherwise it writes a table with a row for each value
+// of the column groupBy, which is one of db.TotalsGroupByColumns or groupBySchedd, and a row with the totals
+func printTotals(w io.Writer, schedds []*condor.Schedd, filter db.Filter, groupBy string) error {
+	// Each schedd counts its own jobs, by group-by column unless we're grouping by schedd
+	column := groupBy
+	if groupBy == groupBySchedd {
+		column = ""
+	}
+	results := make([][]db.Totals, len(schedds))
+	scheddTotals := make([]db.Totals, len(schedds))
+	errs := make([]error, len(schedds))
+	var wg sync.WaitGroup
+	for idx, schedd := range schedds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[idx], scheddTotals[idx], errs[idx] = schedd.Totals(filter, column)
+			if errs[idx] != nil {
+				errs[idx] = fmt.Errorf("%s: %w", schedd.Name, errs[idx])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("could not get totals from schedds: %w", err)
+	}
+
+	// Clusters live on one schedd, so the schedds' totals add up without double counting
+	all := db.Totals{Key: "Total"}
+	for _, t := range scheddTotals {
+		all.Add(t)
+	}
+	if groupBy == "" {
+		for idx, schedd := range schedds {
+			fmt.Fprintln(w, summaryLine("Total for "+schedd.Name, scheddTotals[idx]))
+		}
+		if len(schedds) > 1 {
+			fmt.Fprintln(w, summaryLine("Total for all schedds", all))
+		}
+		return nil
+	}
+
+	// Add up the groups across schedds
+	groups := make(map[string]*db.Totals)
+	for idx, schedd := range schedds {
+		for _, r := range results[idx] {
+			if groupBy == groupBySchedd {
+				r.Key = schedd.Name
+			}
+			if groups[r.Key] == nil {
+				groups[r.Key] = &db.Totals{Key: r.Key}
+			}
+			groups[r.Key].Add(r)
+		}
+	}
+	header := []string{groupBy, "clusters", "jobs", "num"}
+	for _, status := range totalsStatuses {
+		header = append(header, strings.ToLower(status.String()))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		fmt.Fprintln(w, totalsRow(*groups[key]))
+	}
+	fmt.Fprintln(w, totalsRow(all))
+	return nil
+}
+
+// summaryLine summarizes t like the last line of condor_q's output
+func summaryLine(prefix string, t db.Totals) string {
+	counts := make([]string, 0, len(totalsStatuses))
+	for _, status := range totalsStatuses {
+		counts = append(counts, fmt.Sprintf("%d %s", t.ByStatus[status], strings.ToLower(status.String())))
+	}
+	return fmt.Sprintf("%s: %d jobs; %s (%d clusters)", prefix, t.Jobs, strings.Join(counts, ", "), t.Clusters)
+}
+
+// totalsRow formats t as a tab-separated row of the table written by printTotals
+func totalsRow(t db.Totals) string {
+	cells := []string{t.Key, fmt.Sprint(t.Clusters), fmt.Sprint(t.Jobs), fmt.Sprint(t.Num)}
+	for _, status := range totalsStatuses {
+		cells = append(cells, fmt.Sprint(t.ByStatus[status]))
+	}
+	return strings.Join(cells, "\t")
+}
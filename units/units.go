@@ -0,0 +1,124 @@
+// Package units parses the human-friendly sizes and durations that jobsub_lite accepts for resource requests, like 2GB or 8h
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Size units, in bytes.  Like HTCondor, we use powers of 1024
+const (
+	B  int64 = 1
+	KB       = 1024 * B
+	MB       = 1024 * KB
+	GB       = 1024 * MB
+	TB       = 1024 * GB
+)
+
+var sizeUnits = map[string]int64{
+	"B":  B,
+	"K":  KB,
+	"KB": KB,
+	"M":  MB,
+	"MB": MB,
+	"G":  GB,
+	"GB": GB,
+	"T":  TB,
+	"TB": TB,
+}
+
+// ParseSize parses a size like 2GB, 500MB, or 1.5G into bytes.  If s has no unit, it is in units of defaultUnit
+func ParseSize(s string, defaultUnit int64) (int64, error) {
+	num, unit := splitNumber(s)
+	if num == "" {
+		return 0, fmt.Errorf("invalid size %q: must start with a number", s)
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q: %q is not a non-negative number", s, num)
+	}
+
+	multiplier := defaultUnit
+	if unit != "" {
+		var ok bool
+		if multiplier, ok = sizeUnits[strings.ToUpper(unit)]; !ok {
+			return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, unit)
+		}
+	}
+	return int64(v * float64(multiplier)), nil
+}
+
+// FormatSize formats bytes in the largest unit that divides it exactly, like 2GB or 1536MB, so that ParseSize parses it back
+func FormatSize(bytes int64) string {
+	for _, unit := range []string{"TB", "GB", "MB", "KB"} {
+		if size := sizeUnits[unit]; bytes != 0 && bytes%size == 0 {
+			return fmt.Sprintf("%d%s", bytes/size, unit)
+		}
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
+
+// Named lifetimes accepted by ParseLifetime, from jobsub_lite's --expected-lifetime
+var namedLifetimes = map[string]time.Duration{
+	"short":  3 * time.Hour,
+	"medium": 8 * time.Hour,
+	"long":   23*time.Hour + 30*time.Minute,
+}
+
+var lifetimeUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParseLifetime parses an expected job lifetime:  one of short, medium, or long; a number followed by one of the units s, m,
+// h, or d; or a bare number of seconds
+func ParseLifetime(s string) (time.Duration, error) {
+	if d, ok := namedLifetimes[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return d, nil
+	}
+
+	num, unit := splitNumber(s)
+	if num == "" {
+		return 0, fmt.Errorf("invalid lifetime %q: must be short, medium, long, or a number with an optional unit (s, m, h, d)", s)
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid lifetime %q: %q is not a positive number", s, num)
+	}
+
+	multiplier := time.Second
+	if unit != "" {
+		var ok bool
+		if multiplier, ok = lifetimeUnits[strings.ToLower(unit)]; !ok {
+			return 0, fmt.Errorf("invalid lifetime %q: unknown unit %q", s, unit)
+		}
+	}
+	return time.Duration(v * float64(multiplier)), nil
+}
+
+// FormatLifetime formats d, rounded down to the second, in the largest of ParseLifetime's units that divides it exactly, like
+// 8h or 90m, so that ParseLifetime parses it back
+func FormatLifetime(d time.Duration) string {
+	secs := int64(d / time.Second)
+	for _, unit := range []string{"d", "h", "m"} {
+		if size := int64(lifetimeUnits[unit] / time.Second); secs != 0 && secs%size == 0 {
+			return fmt.Sprintf("%d%s", secs/size, unit)
+		}
+	}
+	return fmt.Sprintf("%ds", secs)
+}
+
+// splitNumber splits s into its leading number and the (trimmed) rest
+func splitNumber(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if idx == -1 {
+		return s, ""
+	}
+	return s[:idx], strings.TrimSpace(s[idx:])
+}
@@ -0,0 +1,121 @@
+package units
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input       string
+		defaultUnit int64
+		expected    int64
+		shouldFail  bool
+	}{
+		{"2GB", MB, 2 * GB, false},
+		{"500MB", MB, 500 * MB, false},
+		{"500mb", MB, 500 * MB, false},
+		{"1.5G", MB, 3 * GB / 2, false},
+		{"2000", MB, 2000 * MB, false},
+		{"10 KB", MB, 10 * KB, false},
+		{"2000", KB, 2000 * KB, false},
+		{"GB", MB, 0, true},
+		{"2XB", MB, 0, true},
+		{"", MB, 0, true},
+		{"1.2.3GB", MB, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			v, err := ParseSize(test.input, test.defaultUnit)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %d instead", v)
+				}
+				return
+			}
+			if err != nil || v != test.expected {
+				t.Errorf("Expected %d and nil error.  Got %d, %v instead", test.expected, v, err)
+			}
+		})
+	}
+}
+
+func TestParseLifetime(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   time.Duration
+		shouldFail bool
+	}{
+		{"short", 3 * time.Hour, false},
+		{"Medium", 8 * time.Hour, false},
+		{"long", 23*time.Hour + 30*time.Minute, false},
+		{"8h", 8 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"2d", 48 * time.Hour, false},
+		{"3600", time.Hour, false},
+		{"1.5h", 90 * time.Minute, false},
+		{"0h", 0, true},
+		{"forever", 0, true},
+		{"8y", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			v, err := ParseLifetime(test.input)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %s instead", v)
+				}
+				return
+			}
+			if err != nil || v != test.expected {
+				t.Errorf("Expected %s and nil error.  Got %s, %v instead", test.expected, v, err)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{2 * GB, "2GB"},
+		{1536 * MB, "1536MB"},
+		{10 * KB, "10KB"},
+		{100, "100B"},
+		{0, "0B"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			if s := FormatSize(test.bytes); s != test.expected {
+				t.Errorf("Expected %s.  Got %s instead", test.expected, s)
+			}
+			if v, err := ParseSize(FormatSize(test.bytes), B); err != nil || v != test.bytes {
+				t.Errorf("Expected %s to parse back to %d.  Got %d, %v instead", test.expected, test.bytes, v, err)
+			}
+		})
+	}
+}
+
+func TestFormatLifetime(t *testing.T) {
+	tests := []struct {
+		input    time.Duration
+		expected string
+	}{
+		{48 * time.Hour, "2d"},
+		{8 * time.Hour, "8h"},
+		{23*time.Hour + 30*time.Minute, "1410m"},
+		{90*time.Second + 500*time.Millisecond, "90s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			if s := FormatLifetime(test.input); s != test.expected {
+				t.Errorf("Expected %s.  Got %s instead", test.expected, s)
+			}
+		})
+	}
+}
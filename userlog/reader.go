@@ -0,0 +1,155 @@
+package userlog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerRegexp matches an event's header line
+var headerRegexp = regexp.MustCompile(`^(\d{3}) \((\d+)\.(\d+)\.(\d+)\) (\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) (.*)$`)
+
+// Reader reads events from a user log
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader returns a Reader that reads events from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the next event in the log.  At the end of the log, it returns io.EOF.  If the log ends in the middle of
+// an event (say, because it is still being written), io.ErrUnexpectedEOF is returned
+func (r *Reader) Next() (Event, error) {
+	// Skip any blank lines between events
+	var header string
+	for {
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		r.line++
+		header = r.scanner.Text()
+		if strings.TrimSpace(header) != "" {
+			break
+		}
+	}
+	headerLine := r.line
+
+	m := headerRegexp.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("line %d: invalid event header %q", headerLine, header)
+	}
+	var h EventHeader
+	code, _ := strconv.Atoi(m[1])
+	h.Cluster, _ = strconv.Atoi(m[2])
+	h.Proc, _ = strconv.Atoi(m[3])
+	h.Subproc, _ = strconv.Atoi(m[4])
+	t, err := time.ParseInLocation(timeFormat, m[5], time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid event time: %w", headerLine, err)
+	}
+	h.Time = t
+	description := m[6]
+
+	body := make([]string, 0)
+	for {
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.ErrUnexpectedEOF
+		}
+		r.line++
+		line := r.scanner.Text()
+		if line == endOfEvent {
+			break
+		}
+		body = append(body, strings.TrimPrefix(line, "\t"))
+	}
+
+	e, err := parseEvent(Code(code), h, description, body)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", headerLine, err)
+	}
+	return e, nil
+}
+
+// parseEvent builds the event of type code from its parts
+func parseEvent(code Code, h EventHeader, description string, body []string) (Event, error) {
+	firstLine := ""
+	if len(body) > 0 {
+		firstLine = strings.TrimSpace(body[0])
+	}
+
+	switch code {
+	case CodeSubmit:
+		return SubmitEvent{EventHeader: h, Host: strings.TrimPrefix(description, "Job submitted from host: ")}, nil
+	case CodeExecute:
+		e := ExecuteEvent{EventHeader: h, Host: strings.TrimPrefix(description, "Job executing on host: ")}
+		for _, line := range body {
+			if name, ok := strings.CutPrefix(strings.TrimSpace(line), "SlotName: "); ok {
+				e.SlotName = name
+			}
+		}
+		return e, nil
+	case CodeTerminated:
+		e := TerminatedEvent{EventHeader: h}
+		if _, err := fmt.Sscanf(firstLine, "(1) Normal termination (return value %d)", &e.ReturnValue); err == nil {
+			e.Normal = true
+		} else if _, err := fmt.Sscanf(firstLine, "(0) Abnormal termination (signal %d)", &e.Signal); err != nil {
+			return nil, fmt.Errorf("invalid termination line %q", firstLine)
+		}
+		return e, nil
+	case CodeHeld:
+		e := HeldEvent{EventHeader: h, Reason: firstLine}
+		if len(body) > 1 {
+			if _, err := fmt.Sscanf(strings.TrimSpace(body[1]), "Code %d Subcode %d", &e.HoldCode, &e.HoldSubcode); err != nil {
+				return nil, fmt.Errorf("invalid hold code line %q", body[1])
+			}
+		}
+		return e, nil
+	case CodeReleased:
+		return ReleasedEvent{EventHeader: h, Reason: firstLine}, nil
+	case CodeAborted:
+		return AbortedEvent{EventHeader: h, Reason: firstLine}, nil
+	default:
+		return UnknownEvent{EventHeader: h, EventCode: code, Description: description, Lines: body}, nil
+	}
+}
+
+// ReadAll reads all of the events from r
+func ReadAll(r io.Reader) ([]Event, error) {
+	reader := NewReader(r)
+	events := make([]Event, 0)
+	for {
+		e, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// ReadFile reads all of the events from the user log at filename
+func ReadFile(filename string) ([]Event, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadAll(f)
+}
@@ -0,0 +1,183 @@
+// Package userlog writes and reads HTCondor job event logs ("user logs"), like
+//
+//	000 (012.000.000) 2024-05-01 12:00:00 Job submitted from host: <127.0.0.1:9618?alias=schedd1>
+//	...
+//
+// Each event starts with a header line giving the event code, the job, and when the event happened, and ends with a
+// line holding only "...".  Any lines in between are the event's body, and are indented with tabs
+package userlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Code is the number that identifies each type of event
+type Code int
+
+// Event codes, as in HTCondor's ULogEventNumber
+const (
+	CodeSubmit     Code = 0
+	CodeExecute    Code = 1
+	CodeTerminated Code = 5
+	CodeAborted    Code = 9
+	CodeHeld       Code = 12
+	CodeReleased   Code = 13
+)
+
+// HoldCodeUserRequest is HTCondor's HoldReasonCode for jobs held with condor_hold
+const HoldCodeUserRequest = 1
+
+// timeFormat is the format of event times, which are in local time
+const timeFormat = "2006-01-02 15:04:05"
+
+// endOfEvent is the line that ends every event
+const endOfEvent = "..."
+
+// EventHeader is what every event records:  which job it happened to, and when
+type EventHeader struct {
+	Cluster int
+	Proc    int
+	Subproc int
+	Time    time.Time
+}
+
+// Header returns the event's header
+func (h EventHeader) Header() EventHeader {
+	return h
+}
+
+// Event is a single event in a user log
+type Event interface {
+	Code() Code
+	Header() EventHeader
+	description() string // The rest of the header line after the time
+	body() []string      // The lines between the header line and endOfEvent, without their leading tab
+}
+
+// SubmitEvent is logged when a job is submitted
+type SubmitEvent struct {
+	EventHeader
+	Host string // The sinful string of the schedd, like <127.0.0.1:9618?alias=schedd1>
+}
+
+func (e SubmitEvent) Code() Code          { return CodeSubmit }
+func (e SubmitEvent) description() string { return "Job submitted from host: " + e.Host }
+func (e SubmitEvent) body() []string      { return nil }
+
+// ExecuteEvent is logged when a job starts running
+type ExecuteEvent struct {
+	EventHeader
+	Host     string // The sinful string of the execute host
+	SlotName string
+}
+
+func (e ExecuteEvent) Code() Code          { return CodeExecute }
+func (e ExecuteEvent) description() string { return "Job executing on host: " + e.Host }
+func (e ExecuteEvent) body() []string {
+	if e.SlotName == "" {
+		return nil
+	}
+	return []string{"SlotName: " + e.SlotName}
+}
+
+// TerminatedEvent is logged when a job finishes, either by exiting or by being killed by a signal
+type TerminatedEvent struct {
+	EventHeader
+	Normal      bool // Whether the job exited, rather than being killed by a signal
+	ReturnValue int  // The exit code, if Normal
+	Signal      int  // The signal that killed the job, if not Normal
+}
+
+func (e TerminatedEvent) Code() Code          { return CodeTerminated }
+func (e TerminatedEvent) description() string { return "Job terminated." }
+func (e TerminatedEvent) body() []string {
+	lines := make([]string, 0, 9)
+	if e.Normal {
+		lines = append(lines, fmt.Sprintf("(1) Normal termination (return value %d)", e.ReturnValue))
+	} else {
+		lines = append(lines, fmt.Sprintf("(0) Abnormal termination (signal %d)", e.Signal))
+	}
+	for _, usage := range []string{"Run Remote", "Run Local", "Total Remote", "Total Local"} {
+		lines = append(lines, "\tUsr 0 00:00:00, Sys 0 00:00:00  -  "+usage+" Usage")
+	}
+	for _, bytes := range []string{"Run Bytes Sent", "Run Bytes Received", "Total Bytes Sent", "Total Bytes Received"} {
+		lines = append(lines, "0  -  "+bytes+" By Job")
+	}
+	return lines
+}
+
+// HeldEvent is logged when a job is held
+type HeldEvent struct {
+	EventHeader
+	Reason      string
+	HoldCode    int // HoldReasonCode
+	HoldSubcode int // HoldReasonSubCode
+}
+
+func (e HeldEvent) Code() Code          { return CodeHeld }
+func (e HeldEvent) description() string { return "Job was held." }
+func (e HeldEvent) body() []string {
+	return []string{e.Reason, fmt.Sprintf("Code %d Subcode %d", e.HoldCode, e.HoldSubcode)}
+}
+
+// ReleasedEvent is logged when a held job is released
+type ReleasedEvent struct {
+	EventHeader
+	Reason string
+}
+
+func (e ReleasedEvent) Code() Code          { return CodeReleased }
+func (e ReleasedEvent) description() string { return "Job was released." }
+func (e ReleasedEvent) body() []string      { return []string{e.Reason} }
+
+// AbortedEvent is logged when a job is removed
+type AbortedEvent struct {
+	EventHeader
+	Reason string
+}
+
+func (e AbortedEvent) Code() Code          { return CodeAborted }
+func (e AbortedEvent) description() string { return "Job was aborted." }
+func (e AbortedEvent) body() []string      { return []string{e.Reason} }
+
+// UnknownEvent is an event of a type this package doesn't understand.  The reader returns these rather than failing
+type UnknownEvent struct {
+	EventHeader
+	EventCode   Code
+	Description string
+	Lines       []string
+}
+
+func (e UnknownEvent) Code() Code          { return e.EventCode }
+func (e UnknownEvent) description() string { return e.Description }
+func (e UnknownEvent) body() []string      { return e.Lines }
+
+// Format returns the event as it appears in a user log, including the final "...\n"
+func Format(e Event) string {
+	h := e.Header()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%03d (%03d.%03d.%03d) %s %s\n", e.Code(), h.Cluster, h.Proc, h.Subproc, h.Time.Local().Format(timeFormat), e.description())
+	for _, line := range e.body() {
+		b.WriteString("\t" + line + "\n")
+	}
+	b.WriteString(endOfEvent + "\n")
+	return b.String()
+}
+
+// Append appends events to the user log at filename, creating it if it doesn't exist
+func Append(filename string, events ...Event) error {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open user log: %w", err)
+	}
+	for _, e := range events {
+		if _, err := f.WriteString(Format(e)); err != nil {
+			f.Close()
+			return fmt.Errorf("could not write to user log: %w", err)
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,114 @@
+package userlog
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	h := EventHeader{Cluster: 12, Proc: 3, Time: when}
+	events := []Event{
+		SubmitEvent{EventHeader: h, Host: "<127.0.0.1:9618?alias=schedd1>"},
+		ExecuteEvent{EventHeader: h, Host: "<127.0.0.1:9618?alias=FermiGrid>", SlotName: "slot1@FermiGrid"},
+		HeldEvent{EventHeader: h, Reason: "via condor_hold (by user alice)", HoldCode: HoldCodeUserRequest},
+		ReleasedEvent{EventHeader: h, Reason: "via condor_release (by user alice)"},
+		TerminatedEvent{EventHeader: h, Normal: true, ReturnValue: 3},
+		TerminatedEvent{EventHeader: h, Signal: 9},
+		AbortedEvent{EventHeader: h, Reason: "via condor_rm (by user alice)"},
+	}
+
+	fn := filepath.Join(t.TempDir(), "job.log")
+	if err := Append(fn, events[:3]...); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if err := Append(fn, events[3:]...); err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+
+	got, err := ReadFile(fn)
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("Expected %+v, got %+v", events, got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	e := SubmitEvent{EventHeader: EventHeader{Cluster: 12, Time: when}, Host: "<127.0.0.1:9618?alias=schedd1>"}
+	expected := "000 (012.000.000) 2024-05-01 12:00:00 Job submitted from host: <127.0.0.1:9618?alias=schedd1>\n...\n"
+	if s := Format(e); s != expected {
+		t.Errorf("Expected %q, got %q", expected, s)
+	}
+}
+
+func TestReadHTCondorLog(t *testing.T) {
+	// A log like HTCondor writes, including an event type this package doesn't know
+	log := `000 (4567.000.000) 2024-05-01 12:00:00 Job submitted from host: <131.225.1.2:9618?addrs=131.225.1.2-9618&alias=jobsub01.fnal.gov>
+...
+001 (4567.000.000) 2024-05-01 12:01:00 Job executing on host: <131.225.3.4:9618?addrs=131.225.3.4-9618>
+	SlotName: slot1_3@fnpc123.fnal.gov
+...
+006 (4567.000.000) 2024-05-01 12:06:00 Image size of job updated: 2000
+	1  -  MemoryUsage of job (MB)
+	1024  -  ResidentSetSize of job (KB)
+...
+005 (4567.000.000) 2024-05-01 13:00:00 Job terminated.
+	(0) Abnormal termination (signal 9)
+	(0) No core file
+		Usr 0 00:00:00, Sys 0 00:00:00  -  Run Remote Usage
+...
+`
+	events, err := ReadAll(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("Should have gotten nil error.  Got %v instead", err)
+	}
+	codes := make([]Code, 0)
+	for _, e := range events {
+		codes = append(codes, e.Code())
+	}
+	if !reflect.DeepEqual(codes, []Code{CodeSubmit, CodeExecute, 6, CodeTerminated}) {
+		t.Fatalf("Got wrong event codes: %v", codes)
+	}
+	if e, ok := events[1].(ExecuteEvent); !ok || e.SlotName != "slot1_3@fnpc123.fnal.gov" || e.Header().Cluster != 4567 {
+		t.Errorf("Got wrong execute event: %+v", events[1])
+	}
+	if e, ok := events[2].(UnknownEvent); !ok || e.Description != "Image size of job updated: 2000" || len(e.Lines) != 2 {
+		t.Errorf("Got wrong unknown event: %+v", events[2])
+	}
+	if e, ok := events[3].(TerminatedEvent); !ok || e.Normal || e.Signal != 9 {
+		t.Errorf("Got wrong terminated event: %+v", events[3])
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      string
+		expected string
+	}{
+		{"truncated", "000 (001.000.000) 2024-05-01 12:00:00 Job submitted from host: <127.0.0.1:9618>\n", "unexpected EOF"},
+		{"bad header", "this is not a user log\n...\n", "line 1: invalid event header"},
+		{"bad termination", "005 (001.000.000) 2024-05-01 12:00:00 Job terminated.\n\tsomething else\n...\n", "invalid termination line"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadAll(strings.NewReader(test.log))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Should have gotten error containing %q.  Got %v instead", test.expected, err)
+			}
+		})
+	}
+
+	if _, err := NewReader(strings.NewReader("")).Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Should have gotten io.EOF for an empty log.  Got %v instead", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+
+	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/token"
+)
+
+// runUserprio runs the userprio subcommand, which shows (and lets admins edit) the fair-share priorities of groups, like
+// condor_userprio.  args are the arguments after "userprio"
+func runUserprio(cfg *config.Config, args []string) error {
+	userprioCmd := flag.NewFlagSet("userprio", flag.ContinueOnError)
+	userprioGroup := userprioCmd.String("group", "", "Only show this group, or the group to edit with --setfactor, --resetfactor, or --resetusage")
+	userprioSetFactor := userprioCmd.Float64("setfactor", 0, "Set the group's priority factor, which must be positive.  Requires --group")
+	userprioResetFactor := userprioCmd.Bool("resetfactor", false, "Restore the group's configured priority factor.  Requires --group")
+	userprioResetUsage := userprioCmd.Bool("resetusage", false, "Forget the group's usage.  Requires --group")
+
+	if err := userprioCmd.Parse(args); err != nil {
+		return errParseFlags
+	}
+	// --setfactor 0 is an invalid factor, not the absence of one
+	setFactor := false
+	userprioCmd.Visit(func(f *flag.Flag) { setFactor = setFactor || f.Name == "setfactor" })
+
+	groupNames := make([]string, 0, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		groupNames = append(groupNames, g.Name)
+	}
+	if *userprioGroup != "" {
+		if _, err := cfg.Group(*userprioGroup); err != nil {
+			return err
+		}
+	}
+
+	accountant, err := condor.GetAccountant(cfg)
+	if err != nil {
+		return fmt.Errorf("could not get accountant: %w", err)
+	}
+
+	// Edits are for admins only
+	editing := setFactor || *userprioResetFactor || *userprioResetUsage
+	if editing {
+		if *userprioGroup == "" {
+			return errors.New("--group must be specified with --setfactor, --resetfactor, or --resetusage")
+		}
+		if setFactor && *userprioResetFactor {
+			return errors.New("only one of --setfactor and --resetfactor may be specified")
+		}
+		if setFactor && *userprioSetFactor <= 0 {
+			return errors.New("--setfactor must be positive")
+		}
+		claims, err := verifyToken(cfg, "", false, token.ScopeModify)
+		if err != nil {
+			return fmt.Errorf("not authorized to edit priorities: %w", err)
+		}
+		if !slices.Contains(cfg.Accounting.Admins, claims.Subject) {
+			return fmt.Errorf("%w:  %s is not an admin", condor.ErrPermissionDenied, claims.Subject)
+		}
+	}
+	if setFactor {
+		if err := accountant.SetFactor(*userprioGroup, *userprioSetFactor); err != nil {
+			return fmt.Errorf("could not set priority factor: %w", err)
+		}
+		fmt.Printf("Set the priority factor of %s to %g\n", *userprioGroup, *userprioSetFactor)
+	}
+	if *userprioResetFactor {
+		if err := accountant.ResetFactor(*userprioGroup); err != nil {
+			return fmt.Errorf("could not reset priority factor: %w", err)
+		}
+		fmt.Printf("Restored the configured priority factor of %s\n", *userprioGroup)
+	}
+	if *userprioResetUsage {
+		if err := accountant.ResetUsage(*userprioGroup); err != nil {
+			return fmt.Errorf("could not reset usage: %w", err)
+		}
+		fmt.Printf("Reset the usage of %s\n", *userprioGroup)
+	}
+	if editing {
+		return nil
+	}
+
+	now := time.Now()
+	priorities, err := accountant.Priorities(now, groupNames)
+	if err != nil {
+		return fmt.Errorf("could not get priorities: %w", err)
+	}
+	last, err := accountant.LastUpdate()
+	if err != nil {
+		return fmt.Errorf("could not get priorities: %w", err)
+	}
+
+	if last.IsZero() {
+		fmt.Println("Last Priority Update: never")
+	} else {
+		fmt.Printf("Last Priority Update: %s\n", last.Format(time.DateTime))
+	}
+	fmt.Printf("%-12s %18s %13s %15s %18s\n", "Group", "Effective Priority", "Real Priority", "Priority Factor", "Usage (core-hours)")
+	for _, p := range priorities {
+		if *userprioGroup != "" && p.Group != *userprioGroup {
+			continue
+		}
+		fmt.Printf("%-12s %18.2f %13.2f %15.2f %18.2f\n", p.Group, p.EffectivePriority, p.RealPriority, p.Factor, p.Usage)
+	}
+	return nil
+}
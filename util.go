@@ -1,11 +1,25 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"iter"
+	"log/slog"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"fakeJobsub/condor"
+	"fakeJobsub/config"
+	"fakeJobsub/db"
+	"fakeJobsub/submitfile"
+	"fakeJobsub/units"
 )
 
 func checkSubmitForGroup(group string) error {
@@ -15,31 +29,477 @@ func checkSubmitForGroup(group string) error {
 	return nil
 }
 
-// listJobsFromSchedds concurrently queries all elements in schedds and returns
+// submitFileFlags maps the submit flags that can be given in a submit description file to the submit commands that set them
+var submitFileFlags = map[string]string{
+	"log":               "log",
+	"memory":            "request_memory",
+	"disk":              "request_disk",
+	"cpu":               "request_cpus",
+	"gpu":               "request_gpus",
+	"site":              "+DESIRED_Sites",
+	"expected-lifetime": "+JOB_EXPECTED_MAX_LIFETIME",
+	"sim-output-files":  "transfer_output_files",
+	"sim-runtime":       "+SimRuntime",
+	"max-retries":       "max_retries",
+	"sim-exit-codes":    "+SimExitCodes",
+	"notify":            "notification",
+	"executable":        "executable",
+}
+
+// applySubmitFile sets the flags in submitCmd that weren't given on the command line from the submit description file at filename
+func applySubmitFile(submitCmd *flag.FlagSet, filename string) error {
+	sf, err := submitfile.ParseFile(filename)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	submitCmd.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	set := func(name, value string) error {
+		if given[name] {
+			return nil
+		}
+		if err := submitCmd.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s in submit file %s: %w", value, name, filename, err)
+		}
+		return nil
+	}
+	for name, command := range submitFileFlags {
+		if value, ok := sf.Get(command); ok {
+			if err := set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return set("num", strconv.Itoa(sf.Queue))
+}
+
+// jobSpec is a cluster as requested on the submit command line or in a submit file, before the group's defaults are applied.
+// Blank strings mean the defaults are used
+type jobSpec struct {
+	num         int
+	memory      string
+	disk        string
+	cpu         string
+	gpu         string
+	lifetime    string
+	sites       string
+	runtime     time.Duration
+	outputFiles string
+	log         string
+
+	maxRetries       int
+	retryOnExitCodes string
+	retryBackoff     time.Duration
+	simExitCodes     string
+
+	notify string
+
+	executable string // Only written by --emit.  The simulated pool doesn't run anything
+}
+
+// set sets the field of spec that the submit flag called name sets
+func (spec *jobSpec) set(name, value string) error {
+	switch name {
+	case "num":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number of jobs %q", value)
+		}
+		spec.num = n
+	case "memory":
+		spec.memory = value
+	case "disk":
+		spec.disk = value
+	case "cpu":
+		spec.cpu = value
+	case "gpu":
+		spec.gpu = value
+	case "expected-lifetime":
+		spec.lifetime = value
+	case "site":
+		spec.sites = value
+	case "sim-runtime":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid simulated runtime %q: %w", value, err)
+		}
+		spec.runtime = d
+	case "sim-output-files":
+		spec.outputFiles = value
+	case "log":
+		spec.log = value
+	case "max-retries":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number of retries %q", value)
+		}
+		spec.maxRetries = n
+	case "retry-on-exit-codes":
+		spec.retryOnExitCodes = value
+	case "retry-backoff":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid retry backoff %q: %w", value, err)
+		}
+		spec.retryBackoff = d
+	case "sim-exit-codes":
+		spec.simExitCodes = value
+	case "notify":
+		spec.notify = value
+	case "executable":
+		spec.executable = value
+	default:
+		return fmt.Errorf("%s is not a submit flag", name)
+	}
+	return nil
+}
+
+// newJob checks spec and returns the cluster that it describes for group with role.  A relative log file is made absolute
+// relative to dir, or to the current directory if dir is blank.  The cluster's owner is not set
+func newJob(cfg *config.Config, group *config.GroupConfig, role string, spec jobSpec, dir string) (db.Job, error) {
+	var job db.Job
+	resources, err := parseResources(spec.memory, spec.disk, spec.cpu, spec.gpu, spec.lifetime, group.Resources())
+	if err != nil {
+		return job, err
+	}
+
+	sites, err := parseSites(spec.sites, cfg.SiteNames())
+	if err != nil {
+		return job, err
+	}
+	if spec.runtime <= 0 {
+		return job, errors.New("--sim-runtime must be positive")
+	}
+
+	outputFiles, err := parseOutputFiles(spec.outputFiles)
+	if err != nil {
+		return job, err
+	}
+
+	if spec.maxRetries < 0 {
+		return job, errors.New("--max-retries must not be negative")
+	}
+	if spec.retryBackoff < 0 {
+		return job, errors.New("--retry-backoff must not be negative")
+	}
+	retryExitCodes, err := parseExitCodes(spec.retryOnExitCodes)
+	if err != nil {
+		return job, fmt.Errorf("invalid --retry-on-exit-codes: %w", err)
+	}
+	if slices.Contains(retryExitCodes, 0) {
+		return job, errors.New("invalid --retry-on-exit-codes: jobs that exit 0 succeeded, so are never retried")
+	}
+	simExitCodes, err := parseExitCodes(spec.simExitCodes)
+	if err != nil {
+		return job, fmt.Errorf("invalid --sim-exit-codes: %w", err)
+	}
+
+	notify, err := parseNotify(spec.notify)
+	if err != nil {
+		return job, err
+	}
+
+	// The log is written by the schedd and negotiator, which don't run in this directory
+	logFile := spec.log
+	if logFile != "" {
+		if dir != "" && !filepath.IsAbs(logFile) {
+			logFile = filepath.Join(dir, logFile)
+		}
+		if logFile, err = filepath.Abs(logFile); err != nil {
+			return job, fmt.Errorf("invalid --log: %w", err)
+		}
+	}
+
+	return db.Job{
+		Group:       group.Name,
+		Num:         spec.num,
+		Role:        role,
+		MemoryMB:    resources.MemoryMB,
+		DiskKB:      resources.DiskKB,
+		CPUs:        resources.CPUs,
+		GPUs:        resources.GPUs,
+		Lifetime:    time.Duration(resources.Lifetime),
+		Sites:       sites,
+		Runtime:     spec.runtime,
+		Log:         logFile,
+		OutputFiles: outputFiles,
+
+		MaxRetries:     spec.maxRetries,
+		RetryExitCodes: retryExitCodes,
+		RetryBackoff:   spec.retryBackoff,
+		SimExitCodes:   simExitCodes,
+
+		Notify: notify,
+	}, nil
+}
+
+// getSchedds gets the schedds called names
+func getSchedds(names []string) ([]*condor.Schedd, error) {
+	schedds := make([]*condor.Schedd, 0, len(names))
+	for _, name := range names {
+		schedd, err := condor.GetSchedd(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not get schedd: %w", err)
+		}
+		schedds = append(schedds, schedd)
+	}
+	return schedds, nil
+}
+
+// parseSites parses the comma-separated list of sites given to submit, each of which must be one of valid
+func parseSites(s string, valid []string) ([]string, error) {
+	sites := make([]string, 0)
+	if s == "" {
+		return sites, nil
+	}
+	for _, site := range strings.Split(s, ",") {
+		site = strings.TrimSpace(site)
+		if !slices.Contains(valid, site) {
+			return nil, fmt.Errorf("invalid site %s.  Please choose from valid sites %v", site, valid)
+		}
+		if !slices.Contains(sites, site) {
+			sites = append(sites, site)
+		}
+	}
+	return sites, nil
+}
+
+// parseOutputFiles parses the comma-separated list of output file names given to submit
+func parseOutputFiles(s string) ([]string, error) {
+	files := make([]string, 0)
+	if s == "" {
+		return files, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid output file name %q.  Output files must be plain file names", name)
+		}
+		if name == "out" || name == "err" {
+			return nil, fmt.Errorf("invalid output file name %q.  It is reserved for the job's stdout and stderr", name)
+		}
+		if !slices.Contains(files, name) {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// parseExitCodes parses a comma-separated list of exit codes, each from 0 to 255
+func parseExitCodes(s string) ([]int, error) {
+	codes := make([]int, 0)
+	if s == "" {
+		return codes, nil
+	}
+	for _, str := range strings.Split(s, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(str))
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid exit code %q: must be an integer from 0 to 255", str)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// parseResources parses the resource request flags given to submit.  Blank flags mean that the value in defaults is used
+func parseResources(memory, disk, cpu, gpu, lifetime string, defaults config.Resources) (config.Resources, error) {
+	r := defaults
+	for key, value := range map[string]string{"memory": memory, "disk": disk, "cpus": cpu, "gpus": gpu, "lifetime": lifetime} {
+		if value == "" {
+			continue
+		}
+		v, err := parseResourceValue(key, value)
+		if err != nil {
+			return r, err
+		}
+		setResource(&r, key, v)
+	}
+	if r.CPUs < 1 {
+		return r, errors.New("must request at least 1 CPU")
+	}
+	return r, nil
+}
+
+// parseResourceValue parses value for the resource key into the units stored in the database:  MB for memory, KB for disk,
+// and seconds for lifetime
+func parseResourceValue(key, value string) (int, error) {
+	switch key {
+	case "memory":
+		v, err := units.ParseSize(value, units.MB)
+		return int(v / units.MB), err
+	case "disk":
+		v, err := units.ParseSize(value, units.KB)
+		return int(v / units.KB), err
+	case "cpus", "gpus":
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid number of %s %q: must be a non-negative integer", key, value)
+		}
+		return v, nil
+	case "lifetime":
+		v, err := units.ParseLifetime(value)
+		return int(v.Seconds()), err
+	default:
+		return 0, fmt.Errorf("%s is not a resource", key)
+	}
+}
+
+// setResource sets the resource key in r to v, which is in the units returned by parseResourceValue
+func setResource(r *config.Resources, key string, v int) {
+	switch key {
+	case "memory":
+		r.MemoryMB = v
+	case "disk":
+		r.DiskKB = v
+	case "cpus":
+		r.CPUs = v
+	case "gpus":
+		r.GPUs = v
+	case "lifetime":
+		r.Lifetime = config.Duration(time.Duration(v) * time.Second)
+	}
+}
+
+// jobResources returns the resources requested by each job in the cluster
+func jobResources(job db.Job) config.Resources {
+	return config.Resources{
+		MemoryMB: job.MemoryMB,
+		DiskKB:   job.DiskKB,
+		CPUs:     job.CPUs,
+		GPUs:     job.GPUs,
+		Lifetime: config.Duration(job.Lifetime),
+	}
+}
+
+// scheddsAccepting returns the schedds in names whose limits allow request.  If requested is one of names but its limits
+// do not allow request, an error is returned
+func scheddsAccepting(cfg *config.Config, names []string, requested string, request config.Resources) ([]string, error) {
+	accepting := make([]string, 0, len(names))
+	for _, name := range names {
+		s, err := cfg.Schedd(name)
+		if err != nil {
+			return nil, err
+		}
+		err = s.Limits.Allows(request)
+		if err == nil {
+			accepting = append(accepting, name)
+			continue
+		}
+		if name == requested {
+			return nil, fmt.Errorf("schedd %s cannot accept job: %w", name, err)
+		}
+	}
+	if len(accepting) == 0 {
+		return nil, errors.New("no allowed schedd can accept the requested resources")
+	}
+	return accepting, nil
+}
+
+// chooseSchedd picks the schedd to submit to.  If requested is blank, or is not one of the configured
+// schedds, one of the allowed schedds is picked randomly.  If requested is configured but is not allowed,
+// an error is returned
+func chooseSchedd(requested string, configured, allowed []string) (string, error) {
+	if len(allowed) == 0 {
+		return "", errors.New("group is not allowed to submit to any configured schedd")
+	}
+
+	switch {
+	case requested == "":
+		// Randomly pick a schedd
+		return allowed[rand.Intn(len(allowed))], nil
+	case !slices.Contains(configured, requested):
+		// Randomly pick a schedd
+		slog.Warn("Given schedd is not in the list of configured schedds.  Picking one randomly", "schedd", requested, "configured", configured)
+		return allowed[rand.Intn(len(allowed))], nil
+	case !slices.Contains(allowed, requested):
+		return "", fmt.Errorf("group may not submit to schedd %s.  Allowed schedds are %v", requested, allowed)
+	default:
+		// Use the schedd given
+		return requested, nil
+	}
+}
+
+// listJobsFromSchedds concurrently queries all elements in schedds using list and returns
 // their rows in the order given by schedds.  If there is an error querying one or
 // more of the schedds, a non-nil error is returned indicating which schedds
-// had errors, and what those errors were
-func listJobsFromSchedds(schedds []*condor.Schedd, keys ...string) ([]string, error) {
-	// Where all our rows will get stored by schedd
-	scheddMap := make(map[string][]string, 0)
-	for _, schedd := range schedds {
-		// Initialize the slices that are the values in this map
-		scheddMap[schedd.Name] = make([]string, 0)
+// had errors, and what those errors were.  The rows are read from each schedd
+// as they are iterated, so they are never all in memory
+func listJobsFromSchedds(schedds []*condor.Schedd, list func(*condor.Schedd) (*db.Listing, error)) (iter.Seq2[string, error], error) {
+	scheddMap, err := queryListings(schedds, list)
+	if err != nil {
+		return nil, err
+	}
+
+	// Yield the rows in order
+	return func(yield func(string, error) bool) {
+		for _, schedd := range schedds {
+			if !yield(schedd.Name, nil) { // So we can display by schedd
+				return
+			}
+			for line, err := range scheddMap[schedd.Name].Lines() {
+				if err != nil {
+					yield("", fmt.Errorf("%s: %w", schedd.Name, err))
+					return
+				}
+				if !yield(line, nil) {
+					return
+				}
+			}
+			if !yield("", nil) { // Empty row between schedds
+				return
+			}
+		}
+	}, nil
+}
+
+// printLines prints lines as they are yielded, stopping at the first error
+func printLines(lines iter.Seq2[string, error]) error {
+	for line, err := range lines {
+		if err != nil {
+			return err
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// collectLines returns all of lines, stopping with ctx's error if ctx is done first.  Like db.Listing.Collect, it is for
+// listings that are known to be small
+func collectLines(ctx context.Context, lines iter.Seq2[string, error]) ([]string, error) {
+	s := make([]string, 0)
+	for line, err := range lines {
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		s = append(s, line)
 	}
+	return s, nil
+}
 
-	// Listener for aggregator chan that collects all the rows.  Note that this
+// queryListings concurrently queries all elements in schedds using list and returns
+// their listings by schedd name.  Errors are reported as for listJobsFromSchedds
+func queryListings(schedds []*condor.Schedd, list func(*condor.Schedd) (*db.Listing, error)) (map[string]*db.Listing, error) {
+	// Where all our listings will get stored by schedd
+	scheddMap := make(map[string]*db.Listing, len(schedds))
+
+	// Listener for aggregator chan that collects all the listings.  Note that this
 	// is simply an example to demonstrate channels.  In reality, this would
 	// more clearly/easily be accomplished with a mutex, similar to errorList
 	// below
 	type entryForAgg struct {
 		scheddName string
-		row        string
+		listing    *db.Listing
 	}
 	aggregator := make(chan entryForAgg, len(schedds)) // Second argument is the buffer size of the channel
 	aggDone := make(chan bool)                         // Channel to close when aggregation is done
 	go func() {
 		for entry := range aggregator {
-			scheddMap[entry.scheddName] = append(scheddMap[entry.scheddName], entry.row)
+			scheddMap[entry.scheddName] = entry.listing
 		}
 		close(aggDone)
 	}()
@@ -60,7 +520,7 @@ func listJobsFromSchedds(schedds []*condor.Schedd, keys ...string) ([]string, er
 		wg.Add(1) // Add a "Lock" the waitgroup
 		go func(schedd *condor.Schedd) {
 			defer wg.Done() // "Release" one "lock" from the waitgroup
-			rows, err := schedd.List(0, keys...)
+			listing, err := list(schedd)
 			if err != nil {
 				// Add the error to our errList
 				errList.mux.Lock()
@@ -69,13 +529,10 @@ func listJobsFromSchedds(schedds []*condor.Schedd, keys ...string) ([]string, er
 				errList.mux.Unlock()
 				return
 			}
-			// All is well - send the rows to the aggregator
-			for _, r := range rows {
-				e := entryForAgg{
-					scheddName: schedd.Name,
-					row:        r,
-				}
-				aggregator <- e
+			// All is well - send the listing to the aggregator
+			aggregator <- entryForAgg{
+				scheddName: schedd.Name,
+				listing:    listing,
 			}
 		}(schedd)
 	}
@@ -92,15 +549,5 @@ func listJobsFromSchedds(schedds []*condor.Schedd, keys ...string) ([]string, er
 		return nil, fmt.Errorf("Could not get list of jobs from schedds%s", errCombined)
 	}
 
-	// Compile the rows in order
-	s := make([]string, 0)
-	for _, schedd := range schedds {
-		s = append(s, schedd.Name) // So we can display by schedd
-		for _, row := range scheddMap[schedd.Name] {
-			s = append(s, row)
-		}
-		s = append(s, "") // Empty row between schedds
-	}
-
-	return s, nil
+	return scheddMap, nil
 }
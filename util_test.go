@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fakeJobsub/config"
+)
 
 func TestCheckSubmitForGroup(t *testing.T) {
 	if err := checkSubmitForGroup(""); err == nil {
@@ -10,3 +17,224 @@ func TestCheckSubmitForGroup(t *testing.T) {
 		t.Error("Should have gotten non-nil error for checkSubmitForGroup when no group given")
 	}
 }
+
+func TestChooseSchedd(t *testing.T) {
+	configured := []string{"schedd1", "schedd2", "schedd3"}
+	allowed := []string{"schedd1", "schedd2"}
+
+	t.Run("blank", func(t *testing.T) {
+		s, err := chooseSchedd("", configured, allowed)
+		if err != nil || !slices.Contains(allowed, s) {
+			t.Errorf("Should have gotten one of %v and nil error.  Got %s, %v instead", allowed, s, err)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		s, err := chooseSchedd("schedd42", configured, allowed)
+		if err != nil || !slices.Contains(allowed, s) {
+			t.Errorf("Should have gotten one of %v and nil error.  Got %s, %v instead", allowed, s, err)
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		if _, err := chooseSchedd("schedd3", configured, allowed); err == nil {
+			t.Error("Should have gotten non-nil error for a schedd the group may not use")
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		s, err := chooseSchedd("schedd2", configured, allowed)
+		if err != nil || s != "schedd2" {
+			t.Errorf("Should have gotten schedd2 and nil error.  Got %s, %v instead", s, err)
+		}
+	})
+
+	t.Run("nothing allowed", func(t *testing.T) {
+		if _, err := chooseSchedd("", configured, nil); err == nil {
+			t.Error("Should have gotten non-nil error when no schedds are allowed")
+		}
+	})
+}
+
+func TestParseSites(t *testing.T) {
+	valid := []string{"FermiGrid", "Wisconsin", "Nebraska"}
+	tests := []struct {
+		input      string
+		expected   []string
+		shouldFail bool
+	}{
+		{"", []string{}, false},
+		{"FermiGrid", []string{"FermiGrid"}, false},
+		{"Wisconsin, Nebraska,Wisconsin", []string{"Wisconsin", "Nebraska"}, false},
+		{"FermiGrid,CERN", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			sites, err := parseSites(test.input, valid)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %v instead", sites)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(sites, test.expected) {
+				t.Errorf("Expected %v and nil error.  Got %v, %v instead", test.expected, sites, err)
+			}
+		})
+	}
+}
+
+func TestParseOutputFiles(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		shouldFail bool
+	}{
+		{"", []string{}, false},
+		{"hist.root, ntuple.root,hist.root", []string{"hist.root", "ntuple.root"}, false},
+		{"../escape", nil, true},
+		{"out", nil, true},
+		{"a,,b", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			files, err := parseOutputFiles(test.input)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %v instead", files)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(files, test.expected) {
+				t.Errorf("Expected %v and nil error.  Got %v, %v instead", test.expected, files, err)
+			}
+		})
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []int
+		shouldFail bool
+	}{
+		{"", []int{}, false},
+		{"1, 2,255", []int{1, 2, 255}, false},
+		{"256", nil, true},
+		{"-1", nil, true},
+		{"one", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			codes, err := parseExitCodes(test.input)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %v instead", codes)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(codes, test.expected) {
+				t.Errorf("Expected %v and nil error.  Got %v, %v instead", test.expected, codes, err)
+			}
+		})
+	}
+}
+
+func TestParseNotify(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   string
+		shouldFail bool
+	}{
+		{"", "", false},
+		{"Complete", "complete", false},
+		{"never", "never", false},
+		{"sometimes", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			setting, err := parseNotify(test.input)
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Should have gotten an error.  Got %v instead", setting)
+				}
+				return
+			}
+			if err != nil || setting != test.expected {
+				t.Errorf("Expected %v and nil error.  Got %v, %v instead", test.expected, setting, err)
+			}
+		})
+	}
+}
+
+func TestParseResources(t *testing.T) {
+	defaults := config.DefaultResources
+
+	t.Run("defaults", func(t *testing.T) {
+		r, err := parseResources("", "", "", "", "", defaults)
+		if err != nil || r != defaults {
+			t.Errorf("Should have gotten defaults and nil error.  Got %+v, %v instead", r, err)
+		}
+	})
+
+	t.Run("all given", func(t *testing.T) {
+		expected := config.Resources{MemoryMB: 4096, DiskKB: 20 * 1024 * 1024, CPUs: 4, GPUs: 1, Lifetime: config.Duration(3 * time.Hour)}
+		r, err := parseResources("4GB", "20GB", "4", "1", "short", defaults)
+		if err != nil || r != expected {
+			t.Errorf("Should have gotten %+v and nil error.  Got %+v, %v instead", expected, r, err)
+		}
+	})
+
+	t.Run("bad values", func(t *testing.T) {
+		for _, args := range [][5]string{
+			{"4XB", "", "", "", ""},
+			{"", "lots", "", "", ""},
+			{"", "", "two", "", ""},
+			{"", "", "0", "", ""},
+			{"", "", "", "-1", ""},
+			{"", "", "", "", "forever"},
+		} {
+			if _, err := parseResources(args[0], args[1], args[2], args[3], args[4], defaults); err == nil {
+				t.Errorf("Should have gotten non-nil error for %v", args)
+			}
+		}
+	})
+}
+
+func TestScheddsAccepting(t *testing.T) {
+	cfg := config.Default()
+	all := cfg.ScheddNames()
+	big := config.DefaultResources
+	big.MemoryMB = 20000 // More than schedd2 allows
+
+	t.Run("both", func(t *testing.T) {
+		s, err := scheddsAccepting(cfg, all, "", config.DefaultResources)
+		if err != nil || !slices.Equal(s, all) {
+			t.Errorf("Should have gotten %v and nil error.  Got %v, %v instead", all, s, err)
+		}
+	})
+
+	t.Run("only one fits", func(t *testing.T) {
+		s, err := scheddsAccepting(cfg, all, "", big)
+		if err != nil || !slices.Equal(s, []string{"schedd1"}) {
+			t.Errorf("Should have gotten [schedd1] and nil error.  Got %v, %v instead", s, err)
+		}
+	})
+
+	t.Run("requested doesn't fit", func(t *testing.T) {
+		if _, err := scheddsAccepting(cfg, all, "schedd2", big); err == nil || !strings.Contains(err.Error(), "schedd schedd2 cannot accept job") {
+			t.Errorf("Should have gotten error indicating schedd2 cannot accept job.  Got %v instead", err)
+		}
+	})
+
+	t.Run("none fit", func(t *testing.T) {
+		big.GPUs = 100
+		if _, err := scheddsAccepting(cfg, all, "", big); err == nil {
+			t.Error("Should have gotten non-nil error when no schedd can accept the request")
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ANSI escape sequences used to redraw the watch display
+const (
+	clearScreen    = "\033[H\033[2J"
+	highlightStart = "\033[7m" // Reverse video
+	highlightEnd   = "\033[0m"
+)
+
+// watchIDColumns are the columns that identify a row from one refresh to the next, if they are listed
+var watchIDColumns = []string{"clusterid", "procid", "dagid", "node"}
+
+// watchList redraws the rows returned by fetch, which are in the format returned by listJobsFromSchedds, every interval
+// until ctx is done, like watch(1).  fetch is given ctx, and should stop early when it is done.  Rows whose status changed
+// since the last refresh are highlighted
+func watchList(ctx context.Context, w io.Writer, interval time.Duration, fetch func(context.Context) ([]string, error)) error {
+	var statuses map[string]string
+	for {
+		rows, err := fetch(ctx)
+		if ctx.Err() != nil {
+			return nil // Interrupted, which is how watching ends, even in the middle of a fetch
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, clearScreen)
+		fmt.Fprintf(w, "Every %s: fakeJobsub list\t%s\n\n", interval, time.Now().Format(time.DateTime))
+		statuses = renderWatch(w, rows, statuses)
+		fmt.Fprintln(w, "Press Ctrl-C to exit")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// renderWatch writes rows, which are in the format returned by listJobsFromSchedds, as an aligned table for each schedd,
+// followed by the schedd's totals.  Rows whose status differs from the one in prev are highlighted; if prev is nil (the
+// first refresh), nothing is.  It returns the status of each row, to be passed as prev to the next refresh
+func renderWatch(w io.Writer, rows []string, prev map[string]string) map[string]string {
+	statuses := make(map[string]string)
+	for len(rows) > 0 {
+		// Each schedd's rows are its name, a header, data rows, and a blank row
+		schedd := rows[0]
+		end := slices.Index(rows, "")
+		if end == -1 {
+			end = len(rows)
+		}
+		section := rows[1:end]
+		rows = rows[min(end+1, len(rows)):]
+		if len(section) == 0 {
+			continue
+		}
+
+		header := strings.Split(section[0], "\t")
+		statusIdx := slices.Index(header, "status")
+		idIdx := make([]int, 0)
+		for _, col := range watchIDColumns {
+			if idx := slices.Index(header, col); idx != -1 {
+				idIdx = append(idIdx, idx)
+			}
+		}
+
+		table := make([][]string, 0, len(section))
+		for _, row := range section {
+			table = append(table, strings.Split(row, "\t"))
+		}
+		counts := make(map[string]int)
+		fmt.Fprintln(w, schedd)
+		for idx, line := range formatTable(table) {
+			if idx == 0 || statusIdx == -1 || statusIdx >= len(table[idx]) {
+				fmt.Fprintln(w, line)
+				continue
+			}
+			status := table[idx][statusIdx]
+			counts[status]++
+
+			key := schedd
+			for _, i := range idIdx {
+				if i < len(table[idx]) {
+					key += "\t" + table[idx][i]
+				}
+			}
+			statuses[key] = status
+			if prev != nil && len(idIdx) > 0 && prev[key] != status {
+				line = highlightStart + line + highlightEnd
+			}
+			fmt.Fprintln(w, line)
+		}
+
+		totals := make([]string, 0, len(counts))
+		for _, status := range slices.Sorted(maps.Keys(counts)) {
+			totals = append(totals, fmt.Sprintf("%d %s", counts[status], status))
+		}
+		summary := fmt.Sprintf("%s:  %d row(s)", schedd, len(table)-1)
+		if len(totals) > 0 {
+			summary += ";  " + strings.Join(totals, ", ")
+		}
+		fmt.Fprintf(w, "%s\n\n", summary)
+	}
+	return statuses
+}
+
+// formatTable pads the cells of table so that its columns line up, and returns its lines
+func formatTable(table [][]string) []string {
+	widths := make([]int, 0)
+	for _, row := range table {
+		for idx, cell := range row {
+			if idx >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[idx] = max(widths[idx], len(cell))
+		}
+	}
+	lines := make([]string, 0, len(table))
+	for _, row := range table {
+		cells := make([]string, 0, len(row))
+		for idx, cell := range row {
+			cells = append(cells, fmt.Sprintf("%-*s", widths[idx], cell))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderWatch(t *testing.T) {
+	first := []string{"schedd1", "clusterid\tstatus", "1\tIdle", "2\tIdle", "", "schedd2", "clusterid\tstatus", "1\tRunning", ""}
+	second := []string{"schedd1", "clusterid\tstatus", "1\tRunning", "2\tIdle", "3\tIdle", "", "schedd2", "clusterid\tstatus", "1\tRunning", ""}
+
+	var b strings.Builder
+	prev := renderWatch(&b, first, nil)
+	if strings.Contains(b.String(), highlightStart) {
+		t.Errorf("Nothing should be highlighted on the first refresh.  Got %q", b.String())
+	}
+	if !strings.Contains(b.String(), "schedd1:  2 row(s);  2 Idle\n") || !strings.Contains(b.String(), "schedd2:  1 row(s);  1 Running\n") {
+		t.Errorf("Expected per-schedd totals.  Got %q", b.String())
+	}
+
+	b.Reset()
+	renderWatch(&b, second, prev)
+	out := b.String()
+	for _, line := range []string{"1          Running", "3          Idle"} {
+		if !strings.Contains(out, highlightStart+line+highlightEnd) {
+			t.Errorf("Expected %q to be highlighted.  Got %q", line, out)
+		}
+	}
+	if strings.Count(out, highlightStart) != 2 {
+		t.Errorf("Only the changed and new rows should be highlighted.  Got %q", out)
+	}
+	if !strings.Contains(out, "schedd1:  3 row(s);  2 Idle, 1 Running\n") {
+		t.Errorf("Expected updated totals.  Got %q", out)
+	}
+}
+
+func TestWatchList(t *testing.T) {
+	t.Run("stops when interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		fetches := 0
+		fetch := func(context.Context) ([]string, error) {
+			fetches++
+			if fetches == 2 {
+				cancel()
+			}
+			return []string{"schedd1", "clusterid\tstatus", "1\tIdle", ""}, nil
+		}
+		var b strings.Builder
+		if err := watchList(ctx, &b, time.Millisecond, fetch); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if fetches != 2 || strings.Count(b.String(), clearScreen) != 1 {
+			t.Errorf("Expected 2 fetches and 1 redraw.  Got %d fetches and output %q", fetches, b.String())
+		}
+	})
+
+	t.Run("interrupted during a fetch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		fetch := func(ctx context.Context) ([]string, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		var b strings.Builder
+		if err := watchList(ctx, &b, time.Hour, fetch); err != nil {
+			t.Errorf("Should have gotten nil error.  Got %v instead", err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("Should not have redrawn after being interrupted.  Got %q", b.String())
+		}
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		fetchErr := errors.New("schedd down")
+		err := watchList(context.Background(), &strings.Builder{}, time.Millisecond, func(context.Context) ([]string, error) { return nil, fetchErr })
+		if !errors.Is(err, fetchErr) {
+			t.Errorf("Should have gotten fetch error.  Got %v instead", err)
+		}
+	})
+}